@@ -41,6 +41,22 @@ type BGPAdvertisementSpec struct {
 	// +optional
 	LocalPref uint32 `json:"localPref,omitempty"`
 
+	// ASPathPrepend makes the announcement less preferred by prepending an ASN
+	// to its AS_PATH. Applied only to eBGP peers.
+	// +optional
+	ASPathPrepend *ASPathPrepend `json:"asPathPrepend,omitempty"`
+
+	// The BGP MULTI_EXIT_DISC attribute to be associated with the announcement.
+	// Paths with lower MED are preferred over ones with higher MED.
+	// +optional
+	MED *uint32 `json:"med,omitempty"`
+
+	// NodeOverrides allows to override asPathPrepend and med for the announcements
+	// coming from specific nodes, for example to de-prefer a set of nodes.
+	// When a node is selected by multiple entries, the first one is applied.
+	// +optional
+	NodeOverrides []BGPAdvertisementNodeOverride `json:"nodeOverrides,omitempty"`
+
 	// The BGP communities to be associated with the announcement. Each item can be a standard community of the
 	// form 1234:1234, a large community of the form large:1234:1234:1234 or the name of an alias defined in the
 	// Community CRD.
@@ -64,6 +80,81 @@ type BGPAdvertisementSpec struct {
 	// When empty, the loadbalancer IP is announced to all the BGPPeers configured.
 	// +optional
 	Peers []string `json:"peers,omitempty"`
+
+	// EndpointWeighting makes each node weight the announcement of services with
+	// externalTrafficPolicy set to Local by the number of ready endpoints running on it,
+	// so that routers supporting weighted ECMP can balance the traffic accordingly.
+	// With LinkBandwidth, the number of endpoints is sent as a link bandwidth extended
+	// community (in Mbps). With LocalPref, it is added to the LOCAL_PREF of the announcement.
+	// When empty, all the nodes announce the services with the same attributes.
+	// +kubebuilder:validation:Enum=LinkBandwidth;LocalPref
+	// +optional
+	EndpointWeighting string `json:"endpointWeighting,omitempty"`
+
+	// ServiceOverridesNamespaces lists the namespaces whose services are allowed to override
+	// the BGP attributes of this advertisement (additional communities, localPref and AS path prepend)
+	// via the metallb.universe.tf/bgp-* annotations.
+	// When empty, the annotations are ignored.
+	// +optional
+	ServiceOverridesNamespaces []string `json:"serviceOverridesNamespaces,omitempty"`
+
+	// LeakToVRFs lists the VRFs the announced prefixes are leaked into, from the VRF
+	// of the BGPPeers they are announced to, so that they reach the routing table
+	// of those VRFs. The default VRF is named "default". Supported only in FRR mode.
+	// +optional
+	LeakToVRFs []string `json:"leakToVRFs,omitempty"`
+
+	// EVPN originates the announced prefixes as EVPN type-5 routes, from the given
+	// VRF and L3VNI, to the BGPPeers of the default VRF they are announced to.
+	// Supported only in FRR mode.
+	// +optional
+	EVPN *EVPNAdvertisement `json:"evpn,omitempty"`
+}
+
+// ASPathPrepend describes the ASN to prepend to the AS_PATH of an announcement.
+type ASPathPrepend struct {
+	// The ASN to prepend. When not set, the local ASN is used.
+	// +optional
+	ASN uint32 `json:"asn,omitempty"`
+
+	// The number of times the ASN is prepended.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	Count uint32 `json:"count"`
+}
+
+// EVPNAdvertisement describes how the prefixes are originated as EVPN type-5 routes.
+type EVPNAdvertisement struct {
+	// VRF is the name of the VRF the prefixes are originated from,
+	// which is bound to the L3VNI.
+	// +kubebuilder:validation:MinLength=1
+	VRF string `json:"vrf"`
+
+	// VNI is the L3VNI of the VRF.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777215
+	VNI uint32 `json:"vni"`
+
+	// RouteTargets are the route-target extended communities attached to the
+	// type-5 routes, of the form ASN:NN or IP:NN. When empty, FRR derives them
+	// from the local ASN and the VNI.
+	// +optional
+	RouteTargets []string `json:"routeTargets,omitempty"`
+}
+
+// BGPAdvertisementNodeOverride overrides the attributes of the announcements
+// coming from the selected nodes.
+type BGPAdvertisementNodeOverride struct {
+	// NodeSelectors selects the nodes the override applies to.
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors"`
+
+	// ASPathPrepend to apply instead of the one of the advertisement.
+	// +optional
+	ASPathPrepend *ASPathPrepend `json:"asPathPrepend,omitempty"`
+
+	// MED to apply instead of the one of the advertisement.
+	// +optional
+	MED *uint32 `json:"med,omitempty"`
 }
 
 // BGPAdvertisementStatus defines the observed state of BGPAdvertisement.
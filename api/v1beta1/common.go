@@ -6,6 +6,7 @@ import (
 	"github.com/go-kit/log"
 
 	"go.universe.tf/metallb/api/validate"
+	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -15,4 +16,6 @@ var (
 	WebhookClient    client.Reader
 	Validator        validate.ClusterObjects
 	MetalLBNamespace string
+	// ServiceAnnotationsValidator validates the MetalLB annotations of a service.
+	ServiceAnnotationsValidator func(*v1.Service) error
 )
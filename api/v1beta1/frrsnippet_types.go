@@ -0,0 +1,66 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FRRSnippetSpec defines the desired state of FRRSnippet.
+type FRRSnippetSpec struct {
+	// Config is the raw FRR configuration, appended to the configuration
+	// MetalLB generates.
+	// +kubebuilder:validation:MinLength=1
+	Config string `json:"config"`
+
+	// NodeSelectors limits the nodes the snippet is applied on. When empty,
+	// it is applied on all the nodes.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+}
+
+// FRRSnippetStatus defines the observed state of FRRSnippet.
+type FRRSnippetStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Node Selectors",type=string,JSONPath=`.spec.nodeSelectors`
+
+// FRRSnippet is raw FRR configuration applied on the speakers running in FRR
+// mode, meant to configure the features MetalLB doesn't support. Each snippet is
+// checked with FRR before being applied, and ignored if invalid. Use at your own risk.
+type FRRSnippet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FRRSnippetSpec   `json:"spec,omitempty"`
+	Status FRRSnippetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// FRRSnippetList contains a list of FRRSnippet.
+type FRRSnippetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FRRSnippet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FRRSnippet{}, &FRRSnippetList{})
+}
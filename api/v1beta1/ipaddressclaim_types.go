@@ -0,0 +1,106 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IPAddressClaimFamily is the address family of the addresses claimed.
+// +kubebuilder:validation:Enum=IPv4;IPv6;DualStack
+type IPAddressClaimFamily string
+
+const (
+	IPAddressClaimFamilyIPv4      IPAddressClaimFamily = "IPv4"
+	IPAddressClaimFamilyIPv6      IPAddressClaimFamily = "IPv6"
+	IPAddressClaimFamilyDualStack IPAddressClaimFamily = "DualStack"
+)
+
+// IPAddressClaimSpec defines the desired state of IPAddressClaim.
+type IPAddressClaimSpec struct {
+	// IPFamily is the address family of the addresses claimed. A dual stack
+	// claim gets one address of each family.
+	// +kubebuilder:default:=IPv4
+	// +optional
+	IPFamily IPAddressClaimFamily `json:"ipFamily,omitempty"`
+
+	// IPAddressPool is the pool to allocate the addresses from. When not set,
+	// the pool is chosen as for the services, following the serviceAllocation
+	// of the pools, the service selectors matching the labels of the claim.
+	// +optional
+	IPAddressPool string `json:"ipAddressPool,omitempty"`
+
+	// Addresses are the addresses requested, one for each family.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Announce makes the speakers announce the addresses, as for the
+	// LoadBalancer IPs of the pool. The addresses are not announced when
+	// not set.
+	// +optional
+	Announce *IPAddressClaimAnnouncement `json:"announce,omitempty"`
+}
+
+// IPAddressClaimAnnouncement tells which nodes announce the addresses of a claim.
+type IPAddressClaimAnnouncement struct {
+	// NodeSelectors limits the nodes announcing the addresses, among the
+	// ones selected by the advertisements of the pool. All of them are
+	// eligible when empty.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+}
+
+// IPAddressClaimStatus defines the observed state of IPAddressClaim.
+type IPAddressClaimStatus struct {
+	// Addresses are the addresses allocated to the claim.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// IPAddressPool is the pool the addresses were allocated from.
+	// +optional
+	IPAddressPool string `json:"ipAddressPool,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="IPFamily",type=string,JSONPath=`.spec.ipFamily`
+//+kubebuilder:printcolumn:name="Addresses",type=string,JSONPath=`.status.addresses`
+//+kubebuilder:printcolumn:name="IPAddressPool",type=string,JSONPath=`.status.ipAddressPool`
+
+// IPAddressClaim allows to allocate addresses from the pools to consumers
+// other than the LoadBalancer services, such as virtual machines or
+// appliances outside of the cluster, and optionally to announce them.
+type IPAddressClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IPAddressClaimSpec   `json:"spec,omitempty"`
+	Status IPAddressClaimStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// IPAddressClaimList contains a list of IPAddressClaim.
+type IPAddressClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPAddressClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IPAddressClaim{}, &IPAddressClaimList{})
+}
@@ -49,6 +49,49 @@ type IPAddressPoolSpec struct {
 	// multiple IPAddressPools have the same priority, choice will be random.
 	// +optional
 	AllocateTo *ServiceAllocation `json:"serviceAllocation,omitempty"`
+
+	// AnnounceExternalIPs makes the speakers announce the externalIPs of the
+	// services of any type falling into the pool, as for the LoadBalancer IPs.
+	// The externalIPs are announced only for the services without a LoadBalancer IP.
+	// The pool must not assign IPs automatically, and its service allocation
+	// applies to the externalIPs.
+	// +optional
+	AnnounceExternalIPs bool `json:"announceExternalIPs,omitempty"`
+
+	// ServiceClusterIPRange tells that the addresses of the pool are the service
+	// cluster IP ranges of the cluster. They are never allocated to LoadBalancer
+	// services, and are announced as aggregates by the BGPAdvertisements selecting
+	// the pool.
+	// +optional
+	ServiceClusterIPRange bool `json:"serviceClusterIPRange,omitempty"`
+
+	// IPAM makes the pool lease addresses from an external IPAM system
+	// when no address of the pool is available.
+	// +optional
+	IPAM *IPAMProvider `json:"ipam,omitempty"`
+}
+
+// IPAMProvider defines the external IPAM system the addresses of a pool
+// are leased from.
+type IPAMProvider struct {
+	// Addresses are the ranges the provider leases the addresses from. They
+	// belong to the pool as its other addresses, but are allocated only
+	// through the provider. Each range can be either a CIDR prefix, or an
+	// explicit start-end range of IPs.
+	// +kubebuilder:validation:MinItems=1
+	Addresses []string `json:"addresses"`
+
+	// HTTP is a provider speaking the HTTP/JSON lease protocol.
+	// +optional
+	HTTP *HTTPIPAMProvider `json:"http,omitempty"`
+}
+
+// HTTPIPAMProvider defines a provider speaking the HTTP/JSON lease protocol.
+type HTTPIPAMProvider struct {
+	// URL is the base URL of the provider. The addresses are leased by
+	// posting to its lease path, and given back by posting to its release
+	// path.
+	URL string `json:"url"`
 }
 
 // ServiceAllocation defines ip pool allocation to namespace and/or service.
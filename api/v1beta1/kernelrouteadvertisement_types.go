@@ -0,0 +1,113 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KernelRouteMode tells how the LoadBalancer IPs are programmed into the kernel.
+// +kubebuilder:validation:Enum=Route;Address
+type KernelRouteMode string
+
+const (
+	// KernelRouteModeRoute installs a host route to each LoadBalancer IP.
+	KernelRouteModeRoute KernelRouteMode = "Route"
+	// KernelRouteModeAddress assigns each LoadBalancer IP to an interface.
+	KernelRouteModeAddress KernelRouteMode = "Address"
+)
+
+// KernelRouteAdvertisementSpec defines the desired state of KernelRouteAdvertisement.
+type KernelRouteAdvertisementSpec struct {
+	// The list of IPAddressPools to advertise via this advertisement, selected by name.
+	// +optional
+	IPAddressPools []string `json:"ipAddressPools,omitempty"`
+
+	// A selector for the IPAddressPools which would get advertised via this advertisement.
+	// If no IPAddressPool is selected by this or by the list, the advertisement is applied to all the IPAddressPools.
+	// +optional
+	IPAddressPoolSelectors []metav1.LabelSelector `json:"ipAddressPoolSelectors,omitempty"`
+
+	// NodeSelectors allows to limit the nodes to announce as next hops for the LoadBalancer IP.
+	// When empty, all the nodes are announced as next hops.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+
+	// Mode tells whether a host route to each LoadBalancer IP is installed (Route),
+	// or each LoadBalancer IP is assigned to the interface (Address). Defaults to Route.
+	// +optional
+	Mode KernelRouteMode `json:"mode,omitempty"`
+
+	// Table is the routing table the routes are installed in. Defaults to
+	// the main table. Only valid in Route mode.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Table *uint32 `json:"table,omitempty"`
+
+	// Interface is the interface the routes go through, or the addresses are
+	// assigned to, typically a dummy interface. Required in Address mode.
+	// In Route mode, the routes are blackhole routes when not set.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// Protocol is the routing protocol identifier the routes are tagged with,
+	// for the routing daemons to select them. Defaults to 196. Only valid in
+	// Route mode.
+	// +kubebuilder:validation:Minimum=4
+	// +kubebuilder:validation:Maximum=255
+	// +optional
+	Protocol *uint32 `json:"protocol,omitempty"`
+
+	// Metric is the metric of the routes. Only valid in Route mode.
+	// +optional
+	Metric *uint32 `json:"metric,omitempty"`
+}
+
+// KernelRouteAdvertisementStatus defines the observed state of KernelRouteAdvertisement.
+type KernelRouteAdvertisementStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="IPAddressPools",type=string,JSONPath=`.spec.ipAddressPools`
+//+kubebuilder:printcolumn:name="IPAddressPool Selectors",type=string,JSONPath=`.spec.ipAddressPoolSelectors`
+//+kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+//+kubebuilder:printcolumn:name="Node Selectors",type=string,JSONPath=`.spec.nodeSelectors`,priority=10
+
+// KernelRouteAdvertisement allows to program the LoadBalancer IPs provided
+// by the selected pools into the kernel of the nodes announcing them, as
+// routes or addresses, for an external routing daemon to redistribute them.
+type KernelRouteAdvertisement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KernelRouteAdvertisementSpec   `json:"spec,omitempty"`
+	Status KernelRouteAdvertisementStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KernelRouteAdvertisementList contains a list of KernelRouteAdvertisement.
+type KernelRouteAdvertisementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KernelRouteAdvertisement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KernelRouteAdvertisement{}, &KernelRouteAdvertisementList{})
+}
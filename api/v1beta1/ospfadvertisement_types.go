@@ -0,0 +1,85 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OSPFAdvertisementSpec defines the desired state of OSPFAdvertisement.
+type OSPFAdvertisementSpec struct {
+	// The list of IPAddressPools to advertise via this advertisement, selected by name.
+	// +optional
+	IPAddressPools []string `json:"ipAddressPools,omitempty"`
+
+	// A selector for the IPAddressPools which would get advertised via this advertisement.
+	// If no IPAddressPool is selected by this or by the list, the advertisement is applied to all the IPAddressPools.
+	// +optional
+	IPAddressPoolSelectors []metav1.LabelSelector `json:"ipAddressPoolSelectors,omitempty"`
+
+	// NodeSelectors allows to limit the nodes to announce as next hops for the LoadBalancer IP.
+	// When empty, all the nodes are announced as next hops.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+
+	// The metric of the external routes the LoadBalancer IPs are announced as.
+	// When not set, FRR uses its default metric of 20.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=16777214
+	// +optional
+	Metric *uint32 `json:"metric,omitempty"`
+
+	// The type of the external routes the LoadBalancer IPs are announced as.
+	// Defaults to 2.
+	// +kubebuilder:validation:Enum=1;2
+	// +optional
+	MetricType int32 `json:"metricType,omitempty"`
+}
+
+// OSPFAdvertisementStatus defines the observed state of OSPFAdvertisement.
+type OSPFAdvertisementStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="IPAddressPools",type=string,JSONPath=`.spec.ipAddressPools`
+//+kubebuilder:printcolumn:name="IPAddressPool Selectors",type=string,JSONPath=`.spec.ipAddressPoolSelectors`
+//+kubebuilder:printcolumn:name="Node Selectors",type=string,JSONPath=`.spec.nodeSelectors`,priority=10
+
+// OSPFAdvertisement allows to advertise the LoadBalancer IPs provided
+// by the selected pools via OSPF, as external routes redistributed
+// in the areas of the OSPFAreas. Supported only in FRR mode.
+type OSPFAdvertisement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OSPFAdvertisementSpec   `json:"spec,omitempty"`
+	Status OSPFAdvertisementStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OSPFAdvertisementList contains a list of OSPFAdvertisement.
+type OSPFAdvertisementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OSPFAdvertisement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OSPFAdvertisement{}, &OSPFAdvertisementList{})
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OSPFAreaSpec defines the desired state of OSPFArea.
+type OSPFAreaSpec struct {
+	// Area is the ID of the OSPF area, either as a number or in dotted
+	// decimal notation.
+	// +kubebuilder:validation:MinLength=1
+	Area string `json:"area"`
+
+	// Interfaces are the interfaces OSPF runs on in the area.
+	// +kubebuilder:validation:MinItems=1
+	Interfaces []string `json:"interfaces"`
+
+	// NodeSelectors limits the nodes the area is configured on. When empty,
+	// it is configured on all the nodes.
+	// +optional
+	NodeSelectors []metav1.LabelSelector `json:"nodeSelectors,omitempty"`
+}
+
+// OSPFAreaStatus defines the observed state of OSPFArea.
+type OSPFAreaStatus struct {
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Area",type=string,JSONPath=`.spec.area`
+//+kubebuilder:printcolumn:name="Interfaces",type=string,JSONPath=`.spec.interfaces`
+//+kubebuilder:printcolumn:name="Node Selectors",type=string,JSONPath=`.spec.nodeSelectors`,priority=10
+
+// OSPFArea configures OSPF, for both IPv4 and IPv6, on the given interfaces
+// of the selected nodes. The LoadBalancer IPs selected by OSPFAdvertisements
+// are announced to the OSPF neighbors reached through them. Supported only in FRR mode.
+type OSPFArea struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OSPFAreaSpec   `json:"spec,omitempty"`
+	Status OSPFAreaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OSPFAreaList contains a list of OSPFArea.
+type OSPFAreaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OSPFArea `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OSPFArea{}, &OSPFAreaList{})
+}
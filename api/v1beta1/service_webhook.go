@@ -0,0 +1,106 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ServiceValidator validates the MetalLB annotations of the services.
+type ServiceValidator struct{}
+
+func (s *ServiceValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1.Service{}).
+		WithValidator(s).
+		Complete()
+}
+
+// The service webhook does not fail closed, so that services can still be handled
+// when MetalLB is not running. The speakers ignore invalid annotations anyway.
+// It intercepts all the services, as the annotations can't be selected, and
+// validates only the BGP overrides the speakers apply.
+//+kubebuilder:webhook:verbs=create;update,path=/validate--v1-service,mutating=false,failurePolicy=ignore,groups="",resources=services,versions=v1,name=servicevalidationwebhook.metallb.io,sideEffects=None,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &ServiceValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for Service.
+func (s *ServiceValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validateServiceAnnotations(obj, "create")
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for Service.
+func (s *ServiceValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, validateServiceAnnotations(newObj, "update")
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for Service.
+func (s *ServiceValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// bgpOverridesPrefix is the prefix of the annotations overriding the BGP
+// attributes of a service.
+const bgpOverridesPrefix = "metallb.universe.tf/bgp-"
+
+func validateServiceAnnotations(obj runtime.Object, action string) error {
+	svc, ok := obj.(*v1.Service)
+	if !ok {
+		return fmt.Errorf("expected a Service but got a %T", obj)
+	}
+	if ServiceAnnotationsValidator == nil || !hasBGPOverrides(svc) {
+		return nil
+	}
+	level.Debug(Logger).Log("webhook", "service", "action", action, "name", svc.Name, "namespace", svc.Namespace)
+
+	allowed, err := overridesAllowed(svc.Namespace)
+	if err != nil {
+		level.Error(Logger).Log("webhook", "service", "action", action, "name", svc.Name, "namespace", svc.Namespace, "error", err)
+		return nil
+	}
+	if !allowed {
+		// The speakers ignore the overrides of the services of this namespace.
+		return nil
+	}
+	err = ServiceAnnotationsValidator(svc)
+	if err != nil {
+		level.Error(Logger).Log("webhook", "service", "action", action, "name", svc.Name, "namespace", svc.Namespace, "error", err)
+		return err
+	}
+	return nil
+}
+
+func hasBGPOverrides(svc *v1.Service) bool {
+	for k := range svc.Annotations {
+		if strings.HasPrefix(k, bgpOverridesPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// overridesAllowed tells if a BGPAdvertisement allows the services of the
+// given namespace to override its BGP attributes.
+func overridesAllowed(namespace string) (bool, error) {
+	bgpAdvs, err := getExistingBGPAdvs()
+	if err != nil {
+		return false, err
+	}
+	for _, adv := range bgpAdvs.Items {
+		for _, ns := range adv.Spec.ServiceOverridesNamespaces {
+			if ns == namespace {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package v1beta1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateService(t *testing.T) {
+	Logger = log.NewNopLogger()
+	toRestore := ServiceAnnotationsValidator
+	var validated *v1.Service
+	ServiceAnnotationsValidator = func(svc *v1.Service) error {
+		validated = svc
+		if svc.Annotations["metallb.universe.tf/bgp-local-pref"] == "invalid" {
+			return errors.New("invalid annotation")
+		}
+		return nil
+	}
+	toRestoreBGPAdvs := getExistingBGPAdvs
+	getExistingBGPAdvs = func() (*BGPAdvertisementList, error) {
+		return &BGPAdvertisementList{
+			Items: []BGPAdvertisement{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bgpadv",
+						Namespace: MetalLBTestNameSpace,
+					},
+					Spec: BGPAdvertisementSpec{
+						ServiceOverridesNamespaces: []string{"default"},
+					},
+				},
+			},
+		}, nil
+	}
+	defer func() {
+		ServiceAnnotationsValidator = toRestore
+		getExistingBGPAdvs = toRestoreBGPAdvs
+	}()
+
+	tests := []struct {
+		desc         string
+		svc          *v1.Service
+		isUpdate     bool
+		failValidate bool
+		notValidated bool
+	}{
+		{
+			desc: "Valid service, create",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "default",
+					Annotations: map[string]string{"metallb.universe.tf/bgp-local-pref": "200"},
+				},
+			},
+		},
+		{
+			desc: "Invalid service, create",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "default",
+					Annotations: map[string]string{"metallb.universe.tf/bgp-local-pref": "invalid"},
+				},
+			},
+			failValidate: true,
+		},
+		{
+			desc: "Invalid service, update",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "default",
+					Annotations: map[string]string{"metallb.universe.tf/bgp-local-pref": "invalid"},
+				},
+			},
+			isUpdate:     true,
+			failValidate: true,
+		},
+		{
+			desc: "Service without BGP overrides",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "default",
+					Annotations: map[string]string{"other": "invalid"},
+				},
+			},
+			notValidated: true,
+		},
+		{
+			desc: "Invalid service, namespace not allowed to override",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "svc",
+					Namespace:   "other",
+					Annotations: map[string]string{"metallb.universe.tf/bgp-local-pref": "invalid"},
+				},
+			},
+			notValidated: true,
+		},
+	}
+
+	validator := &ServiceValidator{}
+	for _, test := range tests {
+		validated = nil
+		var err error
+		if test.isUpdate {
+			_, err = validator.ValidateUpdate(context.Background(), &v1.Service{}, test.svc)
+		} else {
+			_, err = validator.ValidateCreate(context.Background(), test.svc)
+		}
+		if test.failValidate && err == nil {
+			t.Fatalf("test %s: expected error, got none", test.desc)
+		}
+		if !test.failValidate && err != nil {
+			t.Fatalf("test %s: unexpected error %s", test.desc, err)
+		}
+		if test.notValidated && validated != nil {
+			t.Fatalf("test %s: validator called with the service", test.desc)
+		}
+		if !test.notValidated && validated != test.svc {
+			t.Fatalf("test %s: validator not called with the service", test.desc)
+		}
+	}
+}
@@ -8,9 +8,25 @@
 package v1beta1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ASPathPrepend) DeepCopyInto(out *ASPathPrepend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ASPathPrepend.
+func (in *ASPathPrepend) DeepCopy() *ASPathPrepend {
+	if in == nil {
+		return nil
+	}
+	out := new(ASPathPrepend)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AddressPool) DeepCopyInto(out *AddressPool) {
 	*out = *in
@@ -300,6 +316,38 @@ func (in *BGPAdvertisementList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPAdvertisementNodeOverride) DeepCopyInto(out *BGPAdvertisementNodeOverride) {
+	*out = *in
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ASPathPrepend != nil {
+		in, out := &in.ASPathPrepend, &out.ASPathPrepend
+		*out = new(ASPathPrepend)
+		**out = **in
+	}
+	if in.MED != nil {
+		in, out := &in.MED, &out.MED
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAdvertisementNodeOverride.
+func (in *BGPAdvertisementNodeOverride) DeepCopy() *BGPAdvertisementNodeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPAdvertisementNodeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPAdvertisementSpec) DeepCopyInto(out *BGPAdvertisementSpec) {
 	*out = *in
@@ -313,6 +361,23 @@ func (in *BGPAdvertisementSpec) DeepCopyInto(out *BGPAdvertisementSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.ASPathPrepend != nil {
+		in, out := &in.ASPathPrepend, &out.ASPathPrepend
+		*out = new(ASPathPrepend)
+		**out = **in
+	}
+	if in.MED != nil {
+		in, out := &in.MED, &out.MED
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.NodeOverrides != nil {
+		in, out := &in.NodeOverrides, &out.NodeOverrides
+		*out = make([]BGPAdvertisementNodeOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Communities != nil {
 		in, out := &in.Communities, &out.Communities
 		*out = make([]string, len(*in))
@@ -328,6 +393,21 @@ func (in *BGPAdvertisementSpec) DeepCopyInto(out *BGPAdvertisementSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ServiceOverridesNamespaces != nil {
+		in, out := &in.ServiceOverridesNamespaces, &out.ServiceOverridesNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LeakToVRFs != nil {
+		in, out := &in.LeakToVRFs, &out.LeakToVRFs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EVPN != nil {
+		in, out := &in.EVPN, &out.EVPN
+		*out = new(EVPNAdvertisement)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAdvertisementSpec.
@@ -454,7 +534,27 @@ func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPAddressPool) DeepCopyInto(out *IPAddressPool) {
+func (in *EVPNAdvertisement) DeepCopyInto(out *EVPNAdvertisement) {
+	*out = *in
+	if in.RouteTargets != nil {
+		in, out := &in.RouteTargets, &out.RouteTargets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EVPNAdvertisement.
+func (in *EVPNAdvertisement) DeepCopy() *EVPNAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(EVPNAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FRRSnippet) DeepCopyInto(out *FRRSnippet) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -462,18 +562,18 @@ func (in *IPAddressPool) DeepCopyInto(out *IPAddressPool) {
 	out.Status = in.Status
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPool.
-func (in *IPAddressPool) DeepCopy() *IPAddressPool {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FRRSnippet.
+func (in *FRRSnippet) DeepCopy() *FRRSnippet {
 	if in == nil {
 		return nil
 	}
-	out := new(IPAddressPool)
+	out := new(FRRSnippet)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *IPAddressPool) DeepCopyObject() runtime.Object {
+func (in *FRRSnippet) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -481,31 +581,31 @@ func (in *IPAddressPool) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPAddressPoolList) DeepCopyInto(out *IPAddressPoolList) {
+func (in *FRRSnippetList) DeepCopyInto(out *FRRSnippetList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]IPAddressPool, len(*in))
+		*out = make([]FRRSnippet, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolList.
-func (in *IPAddressPoolList) DeepCopy() *IPAddressPoolList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FRRSnippetList.
+func (in *FRRSnippetList) DeepCopy() *FRRSnippetList {
 	if in == nil {
 		return nil
 	}
-	out := new(IPAddressPoolList)
+	out := new(FRRSnippetList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *IPAddressPoolList) DeepCopyObject() runtime.Object {
+func (in *FRRSnippetList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -513,66 +613,103 @@ func (in *IPAddressPoolList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPAddressPoolSpec) DeepCopyInto(out *IPAddressPoolSpec) {
+func (in *FRRSnippetSpec) DeepCopyInto(out *FRRSnippetSpec) {
 	*out = *in
-	if in.Addresses != nil {
-		in, out := &in.Addresses, &out.Addresses
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.AutoAssign != nil {
-		in, out := &in.AutoAssign, &out.AutoAssign
-		*out = new(bool)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FRRSnippetSpec.
+func (in *FRRSnippetSpec) DeepCopy() *FRRSnippetSpec {
+	if in == nil {
+		return nil
 	}
+	out := new(FRRSnippetSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolSpec.
-func (in *IPAddressPoolSpec) DeepCopy() *IPAddressPoolSpec {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FRRSnippetStatus) DeepCopyInto(out *FRRSnippetStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FRRSnippetStatus.
+func (in *FRRSnippetStatus) DeepCopy() *FRRSnippetStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(IPAddressPoolSpec)
+	out := new(FRRSnippetStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IPAddressPoolStatus) DeepCopyInto(out *IPAddressPoolStatus) {
+func (in *HTTPIPAMProvider) DeepCopyInto(out *HTTPIPAMProvider) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolStatus.
-func (in *IPAddressPoolStatus) DeepCopy() *IPAddressPoolStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPIPAMProvider.
+func (in *HTTPIPAMProvider) DeepCopy() *HTTPIPAMProvider {
 	if in == nil {
 		return nil
 	}
-	out := new(IPAddressPoolStatus)
+	out := new(HTTPIPAMProvider)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *L2Advertisement) DeepCopyInto(out *L2Advertisement) {
+func (in *IPAMProvider) DeepCopyInto(out *IPAMProvider) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPIPAMProvider)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAMProvider.
+func (in *IPAMProvider) DeepCopy() *IPAMProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAMProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAddressClaim) DeepCopyInto(out *IPAddressClaim) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2Advertisement.
-func (in *L2Advertisement) DeepCopy() *L2Advertisement {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressClaim.
+func (in *IPAddressClaim) DeepCopy() *IPAddressClaim {
 	if in == nil {
 		return nil
 	}
-	out := new(L2Advertisement)
+	out := new(IPAddressClaim)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *L2Advertisement) DeepCopyObject() runtime.Object {
+func (in *IPAddressClaim) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -580,31 +717,53 @@ func (in *L2Advertisement) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *L2AdvertisementList) DeepCopyInto(out *L2AdvertisementList) {
+func (in *IPAddressClaimAnnouncement) DeepCopyInto(out *IPAddressClaimAnnouncement) {
+	*out = *in
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressClaimAnnouncement.
+func (in *IPAddressClaimAnnouncement) DeepCopy() *IPAddressClaimAnnouncement {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAddressClaimAnnouncement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAddressClaimList) DeepCopyInto(out *IPAddressClaimList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]L2Advertisement, len(*in))
+		*out = make([]IPAddressClaim, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementList.
-func (in *L2AdvertisementList) DeepCopy() *L2AdvertisementList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressClaimList.
+func (in *IPAddressClaimList) DeepCopy() *IPAddressClaimList {
 	if in == nil {
 		return nil
 	}
-	out := new(L2AdvertisementList)
+	out := new(IPAddressClaimList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *L2AdvertisementList) DeepCopyObject() runtime.Object {
+func (in *IPAddressClaimList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -612,98 +771,429 @@ func (in *L2AdvertisementList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *L2AdvertisementSpec) DeepCopyInto(out *L2AdvertisementSpec) {
+func (in *IPAddressClaimSpec) DeepCopyInto(out *IPAddressClaimSpec) {
 	*out = *in
-	if in.IPAddressPools != nil {
-		in, out := &in.IPAddressPools, &out.IPAddressPools
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Announce != nil {
+		in, out := &in.Announce, &out.Announce
+		*out = new(IPAddressClaimAnnouncement)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementSpec.
-func (in *L2AdvertisementSpec) DeepCopy() *L2AdvertisementSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressClaimSpec.
+func (in *IPAddressClaimSpec) DeepCopy() *IPAddressClaimSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(L2AdvertisementSpec)
+	out := new(IPAddressClaimSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *L2AdvertisementStatus) DeepCopyInto(out *L2AdvertisementStatus) {
+func (in *IPAddressClaimStatus) DeepCopyInto(out *IPAddressClaimStatus) {
 	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementStatus.
-func (in *L2AdvertisementStatus) DeepCopy() *L2AdvertisementStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressClaimStatus.
+func (in *IPAddressClaimStatus) DeepCopy() *IPAddressClaimStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(L2AdvertisementStatus)
+	out := new(IPAddressClaimStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LegacyBgpAdvertisement) DeepCopyInto(out *LegacyBgpAdvertisement) {
+func (in *IPAddressPool) DeepCopyInto(out *IPAddressPool) {
 	*out = *in
-	if in.AggregationLength != nil {
-		in, out := &in.AggregationLength, &out.AggregationLength
-		*out = new(int32)
-		**out = **in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPool.
+func (in *IPAddressPool) DeepCopy() *IPAddressPool {
+	if in == nil {
+		return nil
 	}
-	if in.AggregationLengthV6 != nil {
-		in, out := &in.AggregationLengthV6, &out.AggregationLengthV6
-		*out = new(int32)
-		**out = **in
+	out := new(IPAddressPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPAddressPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	if in.Communities != nil {
-		in, out := &in.Communities, &out.Communities
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAddressPoolList) DeepCopyInto(out *IPAddressPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IPAddressPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LegacyBgpAdvertisement.
-func (in *LegacyBgpAdvertisement) DeepCopy() *LegacyBgpAdvertisement {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolList.
+func (in *IPAddressPoolList) DeepCopy() *IPAddressPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(LegacyBgpAdvertisement)
+	out := new(IPAddressPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPAddressPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MatchExpression) DeepCopyInto(out *MatchExpression) {
+func (in *IPAddressPoolSpec) DeepCopyInto(out *IPAddressPoolSpec) {
 	*out = *in
-	if in.Values != nil {
-		in, out := &in.Values, &out.Values
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AutoAssign != nil {
+		in, out := &in.AutoAssign, &out.AutoAssign
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IPAM != nil {
+		in, out := &in.IPAM, &out.IPAM
+		*out = new(IPAMProvider)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchExpression.
-func (in *MatchExpression) DeepCopy() *MatchExpression {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolSpec.
+func (in *IPAddressPoolSpec) DeepCopy() *IPAddressPoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MatchExpression)
+	out := new(IPAddressPoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NodeSelector) DeepCopyInto(out *NodeSelector) {
+func (in *IPAddressPoolStatus) DeepCopyInto(out *IPAddressPoolStatus) {
 	*out = *in
-	if in.MatchLabels != nil {
-		in, out := &in.MatchLabels, &out.MatchLabels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressPoolStatus.
+func (in *IPAddressPoolStatus) DeepCopy() *IPAddressPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAddressPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KernelRouteAdvertisement) DeepCopyInto(out *KernelRouteAdvertisement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KernelRouteAdvertisement.
+func (in *KernelRouteAdvertisement) DeepCopy() *KernelRouteAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(KernelRouteAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KernelRouteAdvertisement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KernelRouteAdvertisementList) DeepCopyInto(out *KernelRouteAdvertisementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KernelRouteAdvertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KernelRouteAdvertisementList.
+func (in *KernelRouteAdvertisementList) DeepCopy() *KernelRouteAdvertisementList {
+	if in == nil {
+		return nil
+	}
+	out := new(KernelRouteAdvertisementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KernelRouteAdvertisementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KernelRouteAdvertisementSpec) DeepCopyInto(out *KernelRouteAdvertisementSpec) {
+	*out = *in
+	if in.IPAddressPools != nil {
+		in, out := &in.IPAddressPools, &out.IPAddressPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddressPoolSelectors != nil {
+		in, out := &in.IPAddressPoolSelectors, &out.IPAddressPoolSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Table != nil {
+		in, out := &in.Table, &out.Table
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Protocol != nil {
+		in, out := &in.Protocol, &out.Protocol
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KernelRouteAdvertisementSpec.
+func (in *KernelRouteAdvertisementSpec) DeepCopy() *KernelRouteAdvertisementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KernelRouteAdvertisementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KernelRouteAdvertisementStatus) DeepCopyInto(out *KernelRouteAdvertisementStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KernelRouteAdvertisementStatus.
+func (in *KernelRouteAdvertisementStatus) DeepCopy() *KernelRouteAdvertisementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KernelRouteAdvertisementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2Advertisement) DeepCopyInto(out *L2Advertisement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2Advertisement.
+func (in *L2Advertisement) DeepCopy() *L2Advertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(L2Advertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *L2Advertisement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2AdvertisementList) DeepCopyInto(out *L2AdvertisementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]L2Advertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementList.
+func (in *L2AdvertisementList) DeepCopy() *L2AdvertisementList {
+	if in == nil {
+		return nil
+	}
+	out := new(L2AdvertisementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *L2AdvertisementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2AdvertisementSpec) DeepCopyInto(out *L2AdvertisementSpec) {
+	*out = *in
+	if in.IPAddressPools != nil {
+		in, out := &in.IPAddressPools, &out.IPAddressPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementSpec.
+func (in *L2AdvertisementSpec) DeepCopy() *L2AdvertisementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(L2AdvertisementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2AdvertisementStatus) DeepCopyInto(out *L2AdvertisementStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2AdvertisementStatus.
+func (in *L2AdvertisementStatus) DeepCopy() *L2AdvertisementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(L2AdvertisementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LegacyBgpAdvertisement) DeepCopyInto(out *LegacyBgpAdvertisement) {
+	*out = *in
+	if in.AggregationLength != nil {
+		in, out := &in.AggregationLength, &out.AggregationLength
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AggregationLengthV6 != nil {
+		in, out := &in.AggregationLengthV6, &out.AggregationLengthV6
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LegacyBgpAdvertisement.
+func (in *LegacyBgpAdvertisement) DeepCopy() *LegacyBgpAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(LegacyBgpAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatchExpression) DeepCopyInto(out *MatchExpression) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchExpression.
+func (in *MatchExpression) DeepCopy() *MatchExpression {
+	if in == nil {
+		return nil
+	}
+	out := new(MatchExpression)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeSelector) DeepCopyInto(out *NodeSelector) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
 	if in.MatchExpressions != nil {
@@ -725,6 +1215,220 @@ func (in *NodeSelector) DeepCopy() *NodeSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAdvertisement) DeepCopyInto(out *OSPFAdvertisement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAdvertisement.
+func (in *OSPFAdvertisement) DeepCopy() *OSPFAdvertisement {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAdvertisement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSPFAdvertisement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAdvertisementList) DeepCopyInto(out *OSPFAdvertisementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OSPFAdvertisement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAdvertisementList.
+func (in *OSPFAdvertisementList) DeepCopy() *OSPFAdvertisementList {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAdvertisementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSPFAdvertisementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAdvertisementSpec) DeepCopyInto(out *OSPFAdvertisementSpec) {
+	*out = *in
+	if in.IPAddressPools != nil {
+		in, out := &in.IPAddressPools, &out.IPAddressPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddressPoolSelectors != nil {
+		in, out := &in.IPAddressPoolSelectors, &out.IPAddressPoolSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAdvertisementSpec.
+func (in *OSPFAdvertisementSpec) DeepCopy() *OSPFAdvertisementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAdvertisementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAdvertisementStatus) DeepCopyInto(out *OSPFAdvertisementStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAdvertisementStatus.
+func (in *OSPFAdvertisementStatus) DeepCopy() *OSPFAdvertisementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAdvertisementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFArea) DeepCopyInto(out *OSPFArea) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFArea.
+func (in *OSPFArea) DeepCopy() *OSPFArea {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFArea)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSPFArea) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAreaList) DeepCopyInto(out *OSPFAreaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OSPFArea, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAreaList.
+func (in *OSPFAreaList) DeepCopy() *OSPFAreaList {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAreaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSPFAreaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAreaSpec) DeepCopyInto(out *OSPFAreaSpec) {
+	*out = *in
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelectors != nil {
+		in, out := &in.NodeSelectors, &out.NodeSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAreaSpec.
+func (in *OSPFAreaSpec) DeepCopy() *OSPFAreaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAreaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSPFAreaStatus) DeepCopyInto(out *OSPFAreaStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSPFAreaStatus.
+func (in *OSPFAreaStatus) DeepCopy() *OSPFAreaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OSPFAreaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Community) DeepCopyInto(out *Community) {
 	*out = *in
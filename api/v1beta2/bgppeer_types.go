@@ -24,9 +24,16 @@ import (
 // BGPPeerSpec defines the desired state of Peer.
 type BGPPeerSpec struct {
 	// AS number to use for the local end of the session.
+	// Exactly one of myASN and myASNFrom must be set.
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=4294967295
-	MyASN uint32 `json:"myASN"`
+	// +optional
+	MyASN uint32 `json:"myASN,omitempty"`
+
+	// MyASNFrom makes each node use the AS number stored in one of its
+	// labels or annotations for the local end of the session.
+	// +optional
+	MyASNFrom *NodeValueRef `json:"myASNFrom,omitempty"`
 
 	// AS number to expect from the remote end of the session.
 	// +kubebuilder:validation:Minimum=0
@@ -34,7 +41,25 @@ type BGPPeerSpec struct {
 	ASN uint32 `json:"peerASN"`
 
 	// Address to dial when establishing the session.
-	Address string `json:"peerAddress"`
+	// Exactly one of peerAddress, dynamicAddress and listenRange must be set.
+	// +optional
+	Address string `json:"peerAddress,omitempty"`
+
+	// DynamicAddress makes each node resolve the address of the peer,
+	// instead of using the same peerAddress on all the nodes.
+	// +optional
+	DynamicAddress *DynamicPeerAddress `json:"dynamicAddress,omitempty"`
+
+	// ListenRange makes the nodes accept sessions from any router whose
+	// address belongs to the given CIDR, instead of peering with a single
+	// address. Supported in FRR mode only.
+	// +optional
+	ListenRange string `json:"listenRange,omitempty"`
+
+	// Passive makes the nodes wait for the peer to open the session,
+	// instead of dialing it.
+	// +optional
+	Passive bool `json:"passive,omitempty"`
 
 	// Source address to use when establishing the session.
 	// +optional
@@ -59,6 +84,11 @@ type BGPPeerSpec struct {
 	// +optional
 	RouterID string `json:"routerID,omitempty"`
 
+	// RouterIDFrom makes each node advertise the router ID stored in one
+	// of its labels or annotations. Mutually exclusive with routerID.
+	// +optional
+	RouterIDFrom *NodeValueRef `json:"routerIDFrom,omitempty"`
+
 	// Only connect to this peer on nodes that match one of these
 	// selectors.
 	// +optional
@@ -71,25 +101,135 @@ type BGPPeerSpec struct {
 	// passwordSecret is name of the authentication secret for BGP Peer.
 	// the secret must be of type "kubernetes.io/basic-auth", and created in the
 	// same namespace as the MetalLB deployment. The password is stored in the
-	// secret as the key "password".
+	// secret as the key "password". While the password is rotated, the one the
+	// router switches to is stored as the key "nextPassword". It is ignored in
+	// FRR mode, where the session is established again when the password changes.
 	// +optional
 	PasswordSecret v1.SecretReference `json:"passwordSecret,omitempty"`
 
+	// Authentication selects the TCP option authenticating the session. If not set,
+	// the session is authenticated with TCP MD5 when password or passwordSecret is set.
+	// +optional
+	Authentication *BGPAuthentication `json:"authentication,omitempty"`
+
 	// The name of the BFD Profile to be used for the BFD session associated to the BGP session. If not set, the BFD session won't be set up.
 	// +optional
 	BFDProfile string `json:"bfdProfile,omitempty"`
 
-	// To set if the BGPPeer is multi-hops away. Needed for FRR mode only.
+	// To set if the BGPPeer is multi-hops away.
 	// +optional
 	EBGPMultiHop bool `json:"ebgpMultiHop,omitempty"`
 
+	// EBGPMultiHopTTL is the maximum number of hops to the BGPPeer, used as the TTL
+	// of the packets of the session. Implies ebgpMultiHop.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=255
+	EBGPMultiHopTTL uint32 `json:"ebgpMultiHopTTL,omitempty"`
+
+	// TTLSecurityHops enables the Generalized TTL Security Mechanism (RFC 5082),
+	// accepting only the packets from the BGPPeer that traveled at most the given
+	// number of hops. Mutually exclusive with ebgpMultiHop and ebgpMultiHopTTL.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=254
+	TTLSecurityHops uint32 `json:"ttlSecurityHops,omitempty"`
+
 	// To set if we want to peer with the BGPPeer using an interface belonging to
 	// a host vrf
 	// +optional
 	VRFName string `json:"vrf,omitempty"`
+
+	// Receive selects the routes accepted from the BGPPeer. If not set, all the routes
+	// advertised by the peer are ignored.
+	// +optional
+	Receive *BGPReceive `json:"receive,omitempty"`
+
+	// RawFRRConfig is raw FRR configuration rendered in the router bgp block
+	// the BGPPeer belongs to, meant to configure the session with options
+	// MetalLB doesn't support. It is checked with FRR before being applied, and
+	// ignored if invalid. Supported only in FRR mode, use at your own risk.
+	// +optional
+	RawFRRConfig string `json:"rawFRRConfig,omitempty"`
 	// Add future BGP configuration here
 }
 
+// BGPReceive describes the routes accepted from a BGP peer.
+type BGPReceive struct {
+	// Mode selects the routes accepted from the peer: none of them, all of them, or
+	// only the ones contained in one of the prefixes with filtered.
+	// +kubebuilder:validation:Enum=none;all;filtered
+	// +kubebuilder:default:=none
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Prefixes are the prefixes the routes accepted with filtered mode must be
+	// contained in.
+	// +optional
+	Prefixes []string `json:"prefixes,omitempty"`
+
+	// RoutingTable is the kernel routing table the accepted routes are installed in
+	// on the node. If not set, the routes are not installed. Native mode only: in FRR
+	// mode, the accepted routes are installed by FRR in the routing table of the vrf
+	// of the session.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RoutingTable uint32 `json:"routingTable,omitempty"`
+}
+
+// BGPAuthentication describes how a BGP session is authenticated.
+type BGPAuthentication struct {
+	// Type is the TCP option authenticating the session: MD5 (RFC 2385), with the
+	// password or passwordSecret of the BGPPeer, or AO (RFC 5925), with the key chain
+	// in keyChainSecret. AO is supported in native mode only.
+	// +kubebuilder:validation:Enum=MD5;AO
+	Type string `json:"type"`
+
+	// KeyChainSecret is the name of the secret holding the TCP-AO key chain, for the
+	// AO type. The secret must be created in the same namespace as the MetalLB
+	// deployment. The key chain is stored in the secret as the key "keychain", as a
+	// YAML list of keys with their sendID, recvID, algorithm and key. The first key
+	// is used to send. Changing the keys does not reset established sessions.
+	// +optional
+	KeyChainSecret v1.SecretReference `json:"keyChainSecret,omitempty"`
+}
+
+// DynamicPeerAddress describes how each node resolves the address of a BGPPeer.
+type DynamicPeerAddress struct {
+	// Source is where the address of the peer comes from. With DefaultGateway, the node
+	// peers with its default gateway. With NodeLabel and NodeAnnotation, the address is
+	// the value of the given label or annotation of the node. With Interface, the node
+	// establishes a BGP unnumbered session over the given interface (FRR mode only).
+	// +kubebuilder:validation:Enum=DefaultGateway;NodeLabel;NodeAnnotation;Interface
+	Source string `json:"source"`
+
+	// Key is the name of the label or annotation holding the address of the peer.
+	// Required when source is NodeLabel or NodeAnnotation.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Interface is the name of the interface to establish the unnumbered session over.
+	// Required when source is Interface.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// IPFamily is the family of the default gateway to peer with when
+	// source is DefaultGateway. Defaults to IPv4.
+	// +kubebuilder:validation:Enum=IPv4;IPv6
+	// +optional
+	IPFamily string `json:"ipFamily,omitempty"`
+}
+
+// NodeValueRef references a label or an annotation of the node.
+type NodeValueRef struct {
+	// Source tells if the value is stored in a label or in an annotation of the node.
+	// +kubebuilder:validation:Enum=NodeLabel;NodeAnnotation
+	Source string `json:"source"`
+
+	// Key is the name of the label or annotation.
+	Key string `json:"key"`
+}
+
 // BGPPeerStatus defines the observed state of Peer.
 type BGPPeerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -22,6 +22,9 @@ import (
 
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -57,7 +60,7 @@ func (bgpPeer *BGPPeer) ValidateCreate() (admission.Warnings, error) {
 		level.Error(Logger).Log("webhook", "bgppeer", "action", "create", "name", bgpPeer.Name, "namespace", bgpPeer.Namespace, "error", err)
 		return nil, err
 	}
-	return nil, nil
+	return missingNodeValuesWarnings(bgpPeer), nil
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for AddressPool.
@@ -75,7 +78,7 @@ func (bgpPeer *BGPPeer) ValidateUpdate(old runtime.Object) (admission.Warnings,
 		level.Error(Logger).Log("webhook", "bgppeer", "action", "update", "name", bgpPeer.Name, "namespace", bgpPeer.Namespace, "error", err)
 		return nil, err
 	}
-	return nil, nil
+	return missingNodeValuesWarnings(bgpPeer), nil
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for AddressPool.
@@ -92,6 +95,86 @@ var GetExistingBGPPeers = func() (*BGPPeerList, error) {
 	return existingBGPPeerslList, nil
 }
 
+var getExistingNodes = func() (*v1.NodeList, error) {
+	existingNodeList := &v1.NodeList{}
+	err := WebhookClient.List(context.Background(), existingNodeList)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get existing Node objects")
+	}
+	return existingNodeList, nil
+}
+
+// missingNodeValuesWarnings warns about the nodes selected by the peer
+// that don't have the labels or annotations the peer takes its per node
+// values from. Those nodes won't peer until the values are set, but the
+// peer is still accepted as they may be set later.
+func missingNodeValuesWarnings(bgpPeer *BGPPeer) admission.Warnings {
+	type nodeValue struct {
+		field      string
+		annotation bool
+		key        string
+	}
+	var values []nodeValue
+	if ref := bgpPeer.Spec.MyASNFrom; ref != nil {
+		values = append(values, nodeValue{"myASNFrom", ref.Source == "NodeAnnotation", ref.Key})
+	}
+	if ref := bgpPeer.Spec.RouterIDFrom; ref != nil {
+		values = append(values, nodeValue{"routerIDFrom", ref.Source == "NodeAnnotation", ref.Key})
+	}
+	if d := bgpPeer.Spec.DynamicAddress; d != nil && (d.Source == "NodeLabel" || d.Source == "NodeAnnotation") {
+		values = append(values, nodeValue{"dynamicAddress", d.Source == "NodeAnnotation", d.Key})
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	var selectors []labels.Selector
+	for _, s := range bgpPeer.Spec.NodeSelectors {
+		s := s // so we can use &s
+		selector, err := metav1.LabelSelectorAsSelector(&s)
+		if err != nil {
+			// Reported by the validation of the peer.
+			return nil
+		}
+		selectors = append(selectors, selector)
+	}
+	if len(selectors) == 0 {
+		selectors = []labels.Selector{labels.Everything()}
+	}
+
+	nodes, err := getExistingNodes()
+	if err != nil {
+		level.Error(Logger).Log("webhook", "bgppeer", "name", bgpPeer.Name, "namespace", bgpPeer.Namespace, "error", err)
+		return nil
+	}
+
+	var warnings admission.Warnings
+	for _, node := range nodes.Items {
+		selected := false
+		for _, s := range selectors {
+			if s.Matches(labels.Set(node.Labels)) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+		for _, v := range values {
+			if v.annotation {
+				if _, ok := node.Annotations[v.key]; !ok {
+					warnings = append(warnings, fmt.Sprintf("node %s has no annotation %s, referenced by %s", node.Name, v.key, v.field))
+				}
+				continue
+			}
+			if _, ok := node.Labels[v.key]; !ok {
+				warnings = append(warnings, fmt.Sprintf("node %s has no label %s, referenced by %s", node.Name, v.key, v.field))
+			}
+		}
+	}
+	return warnings
+}
+
 func bgpPeerListWithUpdate(existing *BGPPeerList, toAdd *BGPPeer) *BGPPeerList {
 	res := existing.DeepCopy()
 	for i, item := range res.Items { // We override the element with the fresh copy
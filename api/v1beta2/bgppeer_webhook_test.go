@@ -7,7 +7,9 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 const testNamespace = "namespace"
@@ -142,3 +144,121 @@ func TestValidateBGPPeer(t *testing.T) {
 		}
 	}
 }
+
+func TestBGPPeerMissingNodeValuesWarnings(t *testing.T) {
+	MetalLBNamespace = testNamespace
+	Logger = log.NewNopLogger()
+	Validator = &mockValidator{}
+
+	toRestore := GetExistingBGPPeers
+	GetExistingBGPPeers = func() (*BGPPeerList, error) {
+		return &BGPPeerList{}, nil
+	}
+	toRestoreNodes := getExistingNodes
+	getExistingNodes = func() (*v1.NodeList, error) {
+		return &v1.NodeList{
+			Items: []v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "node1",
+						Labels: map[string]string{
+							"rack":            "a",
+							"example.com/asn": "65001",
+						},
+						Annotations: map[string]string{
+							"example.com/router-id": "10.0.0.1",
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "node2",
+						Labels: map[string]string{
+							"rack": "b",
+						},
+					},
+				},
+			},
+		}, nil
+	}
+	defer func() {
+		GetExistingBGPPeers = toRestore
+		getExistingNodes = toRestoreNodes
+	}()
+
+	tests := []struct {
+		desc     string
+		spec     BGPPeerSpec
+		expected admission.Warnings
+	}{
+		{
+			desc: "no per node values",
+			spec: BGPPeerSpec{
+				MyASN: 65001,
+			},
+		},
+		{
+			desc: "all the nodes selected",
+			spec: BGPPeerSpec{
+				MyASNFrom: &NodeValueRef{
+					Source: "NodeLabel",
+					Key:    "example.com/asn",
+				},
+				RouterIDFrom: &NodeValueRef{
+					Source: "NodeAnnotation",
+					Key:    "example.com/router-id",
+				},
+			},
+			expected: admission.Warnings{
+				"node node2 has no label example.com/asn, referenced by myASNFrom",
+				"node node2 has no annotation example.com/router-id, referenced by routerIDFrom",
+			},
+		},
+		{
+			desc: "only the nodes with all the values selected",
+			spec: BGPPeerSpec{
+				MyASNFrom: &NodeValueRef{
+					Source: "NodeLabel",
+					Key:    "example.com/asn",
+				},
+				NodeSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"rack": "a"}},
+				},
+			},
+		},
+		{
+			desc: "dynamic address",
+			spec: BGPPeerSpec{
+				MyASN: 65001,
+				DynamicAddress: &DynamicPeerAddress{
+					Source: "NodeAnnotation",
+					Key:    "example.com/tor",
+				},
+				NodeSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"rack": "b"}},
+				},
+			},
+			expected: admission.Warnings{
+				"node node2 has no annotation example.com/tor, referenced by dynamicAddress",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bgpPeer := &BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-peer",
+					Namespace: testNamespace,
+				},
+				Spec: test.spec,
+			}
+			warnings, err := bgpPeer.ValidateCreate()
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if !cmp.Equal(test.expected, warnings) {
+				t.Fatalf("unexpected warnings %s", cmp.Diff(test.expected, warnings))
+			}
+		})
+	}
+}
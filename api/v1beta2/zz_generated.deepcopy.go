@@ -12,6 +12,22 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPAuthentication) DeepCopyInto(out *BGPAuthentication) {
+	*out = *in
+	out.KeyChainSecret = in.KeyChainSecret
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPAuthentication.
+func (in *BGPAuthentication) DeepCopy() *BGPAuthentication {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPAuthentication)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPPeer) DeepCopyInto(out *BGPPeer) {
 	*out = *in
@@ -74,8 +90,23 @@ func (in *BGPPeerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 	*out = *in
+	if in.MyASNFrom != nil {
+		in, out := &in.MyASNFrom, &out.MyASNFrom
+		*out = new(NodeValueRef)
+		**out = **in
+	}
+	if in.DynamicAddress != nil {
+		in, out := &in.DynamicAddress, &out.DynamicAddress
+		*out = new(DynamicPeerAddress)
+		**out = **in
+	}
 	out.HoldTime = in.HoldTime
 	out.KeepaliveTime = in.KeepaliveTime
+	if in.RouterIDFrom != nil {
+		in, out := &in.RouterIDFrom, &out.RouterIDFrom
+		*out = new(NodeValueRef)
+		**out = **in
+	}
 	if in.NodeSelectors != nil {
 		in, out := &in.NodeSelectors, &out.NodeSelectors
 		*out = make([]v1.LabelSelector, len(*in))
@@ -84,6 +115,16 @@ func (in *BGPPeerSpec) DeepCopyInto(out *BGPPeerSpec) {
 		}
 	}
 	out.PasswordSecret = in.PasswordSecret
+	if in.Authentication != nil {
+		in, out := &in.Authentication, &out.Authentication
+		*out = new(BGPAuthentication)
+		**out = **in
+	}
+	if in.Receive != nil {
+		in, out := &in.Receive, &out.Receive
+		*out = new(BGPReceive)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPPeerSpec.
@@ -110,3 +151,53 @@ func (in *BGPPeerStatus) DeepCopy() *BGPPeerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPReceive) DeepCopyInto(out *BGPReceive) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPReceive.
+func (in *BGPReceive) DeepCopy() *BGPReceive {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPReceive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynamicPeerAddress) DeepCopyInto(out *DynamicPeerAddress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynamicPeerAddress.
+func (in *DynamicPeerAddress) DeepCopy() *DynamicPeerAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(DynamicPeerAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeValueRef) DeepCopyInto(out *NodeValueRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeValueRef.
+func (in *NodeValueRef) DeepCopy() *NodeValueRef {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeValueRef)
+	in.DeepCopyInto(out)
+	return out
+}
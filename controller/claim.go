@@ -0,0 +1,212 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/ipfamily"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+)
+
+// claims offers methods to mutate an IPAddressClaim object.
+type claims interface {
+	UpdateClaimStatus(claim *v1beta1.IPAddressClaim) error
+	ClaimInfof(claim *v1beta1.IPAddressClaim, desc, msg string, args ...interface{})
+	ClaimErrorf(claim *v1beta1.IPAddressClaim, desc, msg string, args ...interface{})
+}
+
+func (c *controller) SetClaim(l log.Logger, name string, claimRo *v1beta1.IPAddressClaim) controllers.SyncState {
+	level.Debug(l).Log("event", "startUpdate", "msg", "start of claim update")
+	defer level.Debug(l).Log("event", "endUpdate", "msg", "end of claim update")
+
+	if claimRo == nil {
+		if c.isServiceAllocated(name) {
+			c.ips.Unassign(name)
+			level.Info(l).Log("event", "claimDeleted", "msg", "claim deleted")
+			// The addresses may be waited for by services or other
+			// claims.
+			return controllers.SyncStateReprocessAll
+		}
+		return controllers.SyncStateSuccess
+	}
+
+	if c.pools == nil || c.pools.ByName == nil {
+		// Config hasn't been read, nothing we can do just yet.
+		level.Debug(l).Log("event", "noConfig", "msg", "not processing, still waiting for config")
+		return controllers.SyncStateSuccess
+	}
+
+	claim := claimRo.DeepCopy()
+	syncStateRes := controllers.SyncStateSuccess
+
+	prevIPs := c.ips.IPs(name)
+
+	if c.convergeClaim(l, name, claim) != nil {
+		syncStateRes = controllers.SyncStateErrorNoRetry
+	}
+
+	if len(prevIPs) != 0 && !c.isServiceAllocated(name) && c.ips.PoolForIP(prevIPs) != nil {
+		level.Info(l).Log("event", "claimUpdated", "msg", "removed addresses from claim, services will be reprocessed")
+		syncStateRes = controllers.SyncStateReprocessAll
+	}
+
+	if reflect.DeepEqual(claimRo.Status, claim.Status) {
+		level.Debug(l).Log("event", "noChange", "msg", "claim converged, no change")
+		return syncStateRes
+	}
+
+	if err := c.claims.UpdateClaimStatus(claim); err != nil {
+		level.Error(l).Log("op", "updateClaimStatus", "error", err, "msg", "failed to update claim")
+		return controllers.SyncStateError
+	}
+	level.Info(l).Log("event", "claimUpdated", "msg", "updated claim object")
+	return syncStateRes
+}
+
+// convergeClaim allocates the addresses of the claim as a service's,
+// and records them in its status.
+func (c *controller) convergeClaim(l log.Logger, key string, claim *v1beta1.IPAddressClaim) error {
+	family, desiredIPs, err := claimRequest(claim)
+	if err != nil {
+		level.Error(l).Log("event", "clearAssignment", "error", err, "msg", "invalid claim")
+		c.claims.ClaimErrorf(claim, "AllocationFailed", "invalid claim: %s", err)
+		c.clearClaimState(key, claim)
+		return ErrConverge
+	}
+
+	ips := []net.IP{}
+	for _, s := range claim.Status.Addresses {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			ips = []net.IP{}
+			break
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) != 0 {
+		if ipsFamily, err := ipfamily.ForAddressesIPs(ips); err != nil || ipsFamily != family {
+			ips = []net.IP{}
+		}
+	}
+	if len(ips) == 0 {
+		c.clearClaimState(key, claim)
+	}
+
+	// As for the services, the allocation may not be valid anymore, or
+	// the user may have requested different addresses.
+	if len(ips) != 0 {
+		switch err := c.ips.Assign(key, claimService(claim), ips, nil, "", ""); {
+		case err != nil:
+			level.Info(l).Log("event", "clearAssignment", "error", err, "msg", "current addresses not allowed by config, clearing")
+			c.claims.ClaimInfof(claim, "ClearAssignment", "current addresses for %q not allowed by config, will attempt for new assignment: %s", key, err)
+			c.clearClaimState(key, claim)
+			ips = []net.IP{}
+		case claim.Spec.IPAddressPool != "" && c.ips.Pool(key) != claim.Spec.IPAddressPool:
+			level.Info(l).Log("event", "clearAssignment", "reason", "differentPoolRequested", "msg", "user requested a different pool than the one currently assigned")
+			c.clearClaimState(key, claim)
+			ips = []net.IP{}
+		case len(desiredIPs) > 0 && !isEqualIPs(append([]net.IP{}, ips...), desiredIPs):
+			level.Info(l).Log("event", "clearAssignment", "reason", "differentIPRequested", "msg", "user requested different addresses than the ones currently assigned")
+			c.clearClaimState(key, claim)
+			ips = []net.IP{}
+		}
+	}
+
+	if len(ips) == 0 {
+		ips, err = c.allocateClaimIPs(key, claim, family, desiredIPs)
+		if err != nil {
+			level.Error(l).Log("op", "allocateIPs", "error", err, "msg", "IP allocation failed")
+			c.claims.ClaimErrorf(claim, "AllocationFailed", "Failed to allocate IP for %q: %s", key, err)
+			return ErrConverge
+		}
+		level.Info(l).Log("event", "ipAllocated", "ip", ips, "msg", "IP address assigned by controller")
+		c.claims.ClaimInfof(claim, "IPAllocated", "Assigned IP %q", ips)
+	}
+
+	claim.Status.Addresses = []string{}
+	for _, ip := range ips {
+		claim.Status.Addresses = append(claim.Status.Addresses, ip.String())
+	}
+	claim.Status.IPAddressPool = c.ips.Pool(key)
+	return nil
+}
+
+func (c *controller) allocateClaimIPs(key string, claim *v1beta1.IPAddressClaim, family ipfamily.Family, desiredIPs []net.IP) ([]net.IP, error) {
+	svc := claimService(claim)
+	if len(desiredIPs) > 0 {
+		if err := c.ips.Assign(key, svc, desiredIPs, nil, "", ""); err != nil {
+			return nil, err
+		}
+		if claim.Spec.IPAddressPool != "" && c.ips.Pool(key) != claim.Spec.IPAddressPool {
+			c.ips.Unassign(key)
+			return nil, fmt.Errorf("requested addresses %q are not compatible with requested address pool %s", desiredIPs, claim.Spec.IPAddressPool)
+		}
+		return desiredIPs, nil
+	}
+	if claim.Spec.IPAddressPool != "" {
+		return c.ips.AllocateFromPool(key, svc, family, claim.Spec.IPAddressPool, nil, "", "")
+	}
+	return c.ips.Allocate(key, svc, family, nil, "", "")
+}
+
+// clearClaimState clears the addresses allocated to the claim.
+func (c *controller) clearClaimState(key string, claim *v1beta1.IPAddressClaim) {
+	c.ips.Unassign(key)
+	claim.Status = v1beta1.IPAddressClaimStatus{}
+}
+
+// claimRequest returns the family and the addresses requested by the claim.
+func claimRequest(claim *v1beta1.IPAddressClaim) (ipfamily.Family, []net.IP, error) {
+	var family ipfamily.Family
+	switch claim.Spec.IPFamily {
+	case v1beta1.IPAddressClaimFamilyIPv4, "":
+		family = ipfamily.IPv4
+	case v1beta1.IPAddressClaimFamilyIPv6:
+		family = ipfamily.IPv6
+	case v1beta1.IPAddressClaimFamilyDualStack:
+		family = ipfamily.DualStack
+	default:
+		return "", nil, fmt.Errorf("unknown ip family %q", claim.Spec.IPFamily)
+	}
+
+	if len(claim.Spec.Addresses) == 0 {
+		return family, nil, nil
+	}
+	ips := []net.IP{}
+	for _, s := range claim.Spec.Addresses {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", nil, fmt.Errorf("invalid address %q", s)
+		}
+		ips = append(ips, ip)
+	}
+	ipsFamily, err := ipfamily.ForAddressesIPs(ips)
+	if err != nil {
+		return "", nil, err
+	}
+	if ipsFamily != family {
+		return "", nil, fmt.Errorf("requested addresses %q do not match the ip family %s", claim.Spec.Addresses, claim.Spec.IPFamily)
+	}
+	return family, ips, nil
+}
+
+// claimService returns the service the allocator matches the pools
+// against, so the claims follow the same rules as the services.
+func claimService(claim *v1beta1.IPAddressClaim) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: claim.Namespace,
+			Name:      claim.Name,
+			Labels:    claim.Labels,
+		},
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"testing"
 
+	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/controllers"
@@ -66,12 +67,15 @@ func statusAssigned(ips []string) v1.ServiceStatus {
 type testK8S struct {
 	updateService       *v1.Service
 	updateServiceStatus *v1.ServiceStatus
+	updateAnnotations   map[string]string
+	updateClaimStatus   *v1beta1.IPAddressClaimStatus
 	loggedWarning       bool
 	t                   *testing.T
 }
 
 func (s *testK8S) UpdateStatus(svc *v1.Service) error {
 	s.updateServiceStatus = &svc.Status
+	s.updateAnnotations = svc.Annotations
 	return nil
 }
 
@@ -84,9 +88,25 @@ func (s *testK8S) Errorf(_ *v1.Service, evtType string, msg string, args ...inte
 	s.loggedWarning = true
 }
 
+func (s *testK8S) UpdateClaimStatus(claim *v1beta1.IPAddressClaim) error {
+	s.updateClaimStatus = &claim.Status
+	return nil
+}
+
+func (s *testK8S) ClaimInfof(_ *v1beta1.IPAddressClaim, evtType string, msg string, args ...interface{}) {
+	s.t.Logf("k8s Info event %q: %s", evtType, fmt.Sprintf(msg, args...))
+}
+
+func (s *testK8S) ClaimErrorf(_ *v1beta1.IPAddressClaim, evtType string, msg string, args ...interface{}) {
+	s.t.Logf("k8s Warning event %q: %s", evtType, fmt.Sprintf(msg, args...))
+	s.loggedWarning = true
+}
+
 func (s *testK8S) reset() {
 	s.updateService = nil
 	s.updateServiceStatus = nil
+	s.updateAnnotations = nil
+	s.updateClaimStatus = nil
 	s.loggedWarning = false
 }
 
@@ -1132,6 +1152,153 @@ func TestDeleteRecyclesIP(t *testing.T) {
 		t.Fatal("svc2 didn't get an IP")
 	}
 }
+func TestControllerClaims(t *testing.T) {
+	k := &testK8S{t: t}
+	c := &controller{
+		ips:    allocator.New(),
+		client: k,
+		claims: k,
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{
+		ByName: map[string]*config.Pool{
+			"default": {
+				Name:       "default",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("1.2.3.0/31")},
+			},
+			"vms": {
+				Name:       "vms",
+				AutoAssign: true,
+				CIDR:       []*net.IPNet{ipnet("4.5.6.0/32"), ipnet("1000::/128")},
+				ServiceAllocations: &config.ServiceAllocation{
+					Priority:   10,
+					Namespaces: sets.New("vms"),
+				},
+			},
+		},
+		ByNamespace: map[string][]string{"vms": {"vms"}},
+	}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	tests := []struct {
+		desc       string
+		key        string
+		in         *v1beta1.IPAddressClaim
+		want       *v1beta1.IPAddressClaimStatus
+		wantResult controllers.SyncState
+	}{
+		{
+			desc: "claim in the namespace of a pool",
+			key:  "IPAddressClaim/vms/vm1",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "vms", Name: "vm1"},
+			},
+			want: &v1beta1.IPAddressClaimStatus{
+				Addresses:     []string{"4.5.6.0"},
+				IPAddressPool: "vms",
+			},
+		},
+		{
+			desc: "claim already allocated",
+			key:  "IPAddressClaim/vms/vm1",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "vms", Name: "vm1"},
+				Status: v1beta1.IPAddressClaimStatus{
+					Addresses:     []string{"4.5.6.0"},
+					IPAddressPool: "vms",
+				},
+			},
+		},
+		{
+			desc: "pool of a claim full",
+			key:  "IPAddressClaim/vms/vm2",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "vms", Name: "vm2"},
+				Spec: v1beta1.IPAddressClaimSpec{
+					IPAddressPool: "vms",
+				},
+			},
+			wantResult: controllers.SyncStateErrorNoRetry,
+		},
+		{
+			desc: "claim of a specific address",
+			key:  "IPAddressClaim/appliances/fw",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "appliances", Name: "fw"},
+				Spec: v1beta1.IPAddressClaimSpec{
+					Addresses: []string{"1.2.3.1"},
+				},
+			},
+			want: &v1beta1.IPAddressClaimStatus{
+				Addresses:     []string{"1.2.3.1"},
+				IPAddressPool: "default",
+			},
+		},
+		{
+			desc: "claim of an address of the wrong family",
+			key:  "IPAddressClaim/appliances/fw2",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "appliances", Name: "fw2"},
+				Spec: v1beta1.IPAddressClaimSpec{
+					IPFamily:  v1beta1.IPAddressClaimFamilyIPv6,
+					Addresses: []string{"1.2.3.0"},
+				},
+			},
+			wantResult: controllers.SyncStateErrorNoRetry,
+		},
+		{
+			desc:       "claim deleted",
+			key:        "IPAddressClaim/vms/vm1",
+			in:         nil,
+			wantResult: controllers.SyncStateReprocessAll,
+		},
+		{
+			desc: "dual stack claim",
+			key:  "IPAddressClaim/vms/vm2",
+			in: &v1beta1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "vms", Name: "vm2"},
+				Spec: v1beta1.IPAddressClaimSpec{
+					IPFamily: v1beta1.IPAddressClaimFamilyDualStack,
+				},
+			},
+			want: &v1beta1.IPAddressClaimStatus{
+				Addresses:     []string{"4.5.6.0", "1000::"},
+				IPAddressPool: "vms",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		k.reset()
+		if res := c.SetClaim(l, test.key, test.in); res != test.wantResult {
+			t.Errorf("%q: SetClaim returned %v, expected %v", test.desc, res, test.wantResult)
+		}
+		if diff := cmp.Diff(test.want, k.updateClaimStatus); diff != "" {
+			t.Errorf("%q: unexpected claim status (-want +got)\n%s", test.desc, diff)
+		}
+	}
+
+	// The address of the claim is not given to the services.
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"1.2.3.4"},
+		},
+	}
+	k.reset()
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	gotSvc := k.gotService(svc)
+	if gotSvc == nil || len(gotSvc.Status.LoadBalancer.Ingress) == 0 || gotSvc.Status.LoadBalancer.Ingress[0].IP != "1.2.3.0" {
+		t.Fatalf("expected the service to get 1.2.3.0, got %v", gotSvc)
+	}
+}
+
 func TestControllerReassign(t *testing.T) {
 	k := &testK8S{t: t}
 	c := &controller{
@@ -1302,3 +1469,180 @@ func TestControllerDualStackConfig(t *testing.T) {
 		t.Errorf("SetBalancer produced unexpected mutation (-want +got)\n%s", diff)
 	}
 }
+
+// testDNS implements dnsRecords by recording the published records.
+type testDNS struct {
+	records map[string][]string // hostname -> ips
+	calls   int
+	fail    bool
+}
+
+func (d *testDNS) Publish(hostname string, ips []net.IP) error {
+	d.calls++
+	if d.fail {
+		return fmt.Errorf("update refused")
+	}
+	for _, ip := range ips {
+		d.records[hostname] = append(d.records[hostname], ip.String())
+	}
+	return nil
+}
+
+func (d *testDNS) Withdraw(hostname string, ips []net.IP) error {
+	d.calls++
+	for _, ip := range ips {
+		for i, cur := range d.records[hostname] {
+			if cur == ip.String() {
+				d.records[hostname] = append(d.records[hostname][:i], d.records[hostname][i+1:]...)
+				break
+			}
+		}
+	}
+	if len(d.records[hostname]) == 0 {
+		delete(d.records, hostname)
+	}
+	return nil
+}
+
+func TestControllerDNS(t *testing.T) {
+	k := &testK8S{t: t}
+	d := &testDNS{records: map[string][]string{}}
+	c := &controller{
+		ips:           allocator.New(),
+		client:        k,
+		dns:           d,
+		dnsNamespaces: map[string]bool{"default": true},
+	}
+
+	l := log.NewNopLogger()
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"default": {
+			Name:       "default",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.0/31")},
+		},
+	}}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Annotations: map[string]string{
+				AnnotationHostname: "web.example.com",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type:       "LoadBalancer",
+			ClusterIPs: []string{"1.2.3.4"},
+		},
+	}
+	setBalancer := func(desc string, svc *v1.Service, want map[string][]string) *v1.Service {
+		t.Helper()
+		k.reset()
+		if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+			t.Fatalf("%s: SetBalancer failed", desc)
+		}
+		if diff := cmp.Diff(want, d.records); diff != "" {
+			t.Fatalf("%s: unexpected dns records (-want +got)\n%s", desc, diff)
+		}
+		if got := k.gotService(svc); got != nil {
+			// The annotations are written along with the status.
+			got.Annotations = k.updateAnnotations
+			return got
+		}
+		return svc
+	}
+
+	svc = setBalancer("publish", svc, map[string][]string{"web.example.com": {"1.2.3.0"}})
+
+	calls := d.calls
+	svc = setBalancer("no change", svc, map[string][]string{"web.example.com": {"1.2.3.0"}})
+	if d.calls != calls {
+		t.Fatal("dns updated with no change")
+	}
+
+	svc.Annotations[AnnotationHostname] = "api.example.com"
+	svc = setBalancer("hostname changed", svc, map[string][]string{"api.example.com": {"1.2.3.0"}})
+
+	delete(svc.Annotations, AnnotationHostname)
+	svc = setBalancer("hostname removed", svc, map[string][]string{})
+
+	svc.Annotations[AnnotationHostname] = "web.example.com"
+	svc = setBalancer("hostname added", svc, map[string][]string{"web.example.com": {"1.2.3.0"}})
+
+	svc.Annotations[AnnotationLoadBalancerIPs] = "1.2.3.1"
+	svc = setBalancer("ip changed", svc, map[string][]string{"web.example.com": {"1.2.3.1"}})
+
+	svc.Spec.Type = "ClusterIP"
+	svc = setBalancer("not a load balancer", svc, map[string][]string{})
+
+	svc.Spec.Type = "LoadBalancer"
+	svc = setBalancer("load balancer again", svc, map[string][]string{"web.example.com": {"1.2.3.1"}})
+
+	if c.SetBalancer(l, "test", nil, epslices.EpsOrSlices{}) != controllers.SyncStateReprocessAll {
+		t.Fatal("SetBalancer with nil LB didn't tell us to reprocess all balancers")
+	}
+	if len(d.records) != 0 {
+		t.Fatalf("dns records not withdrawn on delete: %v", d.records)
+	}
+
+	// A failed update is reported and retried, and doesn't prevent the
+	// allocation.
+	d.fail = true
+	svc.Status = v1.ServiceStatus{}
+	delete(svc.Annotations, AnnotationDNSPublished)
+	k.reset()
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) != controllers.SyncStateError {
+		t.Fatal("failed dns update not retried")
+	}
+	if !k.loggedWarning {
+		t.Fatal("failed dns update not reported")
+	}
+	got := k.gotService(svc)
+	if got == nil || len(got.Status.LoadBalancer.Ingress) == 0 {
+		t.Fatal("service not allocated when the dns update failed")
+	}
+	got.Annotations = k.updateAnnotations
+	if len(c.published) != 0 {
+		t.Fatalf("failed dns update recorded as published: %v", c.published)
+	}
+	if _, ok := got.Annotations[AnnotationDNSPublished]; ok {
+		t.Fatal("failed dns update recorded as published in the service")
+	}
+	d.fail = false
+	svc = setBalancer("retry", got, map[string][]string{"web.example.com": {"1.2.3.1"}})
+
+	// The published records are recovered from the service after a restart.
+	c = &controller{
+		ips:           allocator.New(),
+		client:        k,
+		dns:           d,
+		dnsNamespaces: map[string]bool{"default": true},
+	}
+	if c.SetPools(l, pools) == controllers.SyncStateError {
+		t.Fatal("SetPools failed")
+	}
+	calls = d.calls
+	svc = setBalancer("restarted", svc, map[string][]string{"web.example.com": {"1.2.3.1"}})
+	if d.calls != calls {
+		t.Fatal("dns updated with no change after a restart")
+	}
+	c.published = nil
+	svc.Annotations[AnnotationHostname] = "api.example.com"
+	svc = setBalancer("hostname changed after a restart", svc, map[string][]string{"api.example.com": {"1.2.3.1"}})
+
+	// Services out of the allowed namespaces don't publish records.
+	svc.Namespace = "other"
+	k.reset()
+	if c.SetBalancer(l, "test", svc, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatal("SetBalancer failed")
+	}
+	if len(d.records) != 0 {
+		t.Fatalf("dns records published for a namespace not allowed: %v", d.records)
+	}
+	if !k.loggedWarning {
+		t.Fatal("namespace not allowed not reported")
+	}
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+)
+
+// dnsRecords publishes the DNS records of the services.
+type dnsRecords interface {
+	Publish(hostname string, ips []net.IP) error
+	Withdraw(hostname string, ips []net.IP) error
+}
+
+// dnsRecord is the hostname published for the IPs of a service.
+type dnsRecord struct {
+	hostname string
+	ips      []net.IP
+}
+
+// publishedRecord is how a dnsRecord is stored in the
+// AnnotationDNSPublished annotation of the service.
+type publishedRecord struct {
+	Hostname string   `json:"hostname"`
+	IPs      []string `json:"ips"`
+}
+
+// publishDNS publishes the records of the hostname the service asks for
+// through its annotation, withdrawing the ones published before if they
+// changed. The records published are recorded in an annotation of the
+// service, so they can be withdrawn after a restart. It fails when the
+// records must be published again.
+func (c *controller) publishDNS(l log.Logger, key string, svc *v1.Service, ips []net.IP) error {
+	if c.dns == nil {
+		return nil
+	}
+	if _, ok := c.published[key]; !ok {
+		c.restorePublished(l, key, svc)
+	}
+	hostname := svc.Annotations[AnnotationHostname]
+	if hostname != "" && !c.dnsAllowed(svc) {
+		level.Error(l).Log("op", "publishDNS", "hostname", hostname, "msg", "the namespace of the service is not allowed to publish dns records")
+		c.client.Errorf(svc, "DNSUpdateFailed", "Namespace %q is not allowed to publish dns records", svc.Namespace)
+		hostname = ""
+	}
+	if prev, ok := c.published[key]; ok && prev.hostname == hostname &&
+		isEqualIPs(append([]net.IP{}, prev.ips...), append([]net.IP{}, ips...)) {
+		return nil
+	}
+
+	if err := c.withdrawDNS(key, svc); err != nil {
+		level.Error(l).Log("op", "withdrawDNS", "error", err, "msg", "failed to withdraw dns records")
+		c.client.Errorf(svc, "DNSUpdateFailed", "Failed to withdraw dns records: %s", err)
+	}
+	if hostname == "" {
+		return nil
+	}
+
+	if err := c.dns.Publish(hostname, ips); err != nil {
+		level.Error(l).Log("op", "publishDNS", "hostname", hostname, "error", err, "msg", "failed to publish dns records")
+		c.client.Errorf(svc, "DNSUpdateFailed", "Failed to publish dns records for %q: %s", hostname, err)
+		return err
+	}
+	record := publishedRecord{Hostname: hostname}
+	for _, ip := range ips {
+		record.IPs = append(record.IPs, ip.String())
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if c.published == nil {
+		c.published = map[string]dnsRecord{}
+	}
+	c.published[key] = dnsRecord{hostname: hostname, ips: ips}
+	svc.Annotations[AnnotationDNSPublished] = string(data)
+	level.Info(l).Log("event", "dnsPublished", "hostname", hostname, "ip", ips, "msg", "dns records published")
+	c.client.Infof(svc, "DNSPublished", "Published %q for %q", hostname, ips)
+	return nil
+}
+
+// restorePublished restores the records published for the service from
+// its annotation, after a restart.
+func (c *controller) restorePublished(l log.Logger, key string, svc *v1.Service) {
+	data, ok := svc.Annotations[AnnotationDNSPublished]
+	if !ok {
+		return
+	}
+	record, err := parsePublished(data)
+	if err != nil {
+		level.Error(l).Log("op", "restorePublished", "error", err, "msg", "ignoring the invalid published dns records")
+		delete(svc.Annotations, AnnotationDNSPublished)
+		return
+	}
+	if c.published == nil {
+		c.published = map[string]dnsRecord{}
+	}
+	c.published[key] = record
+}
+
+func parsePublished(data string) (dnsRecord, error) {
+	record := publishedRecord{}
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return dnsRecord{}, err
+	}
+	if record.Hostname == "" {
+		return dnsRecord{}, fmt.Errorf("no hostname in %q", data)
+	}
+	res := dnsRecord{hostname: record.Hostname}
+	for _, s := range record.IPs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return dnsRecord{}, fmt.Errorf("invalid ip %q", s)
+		}
+		res.ips = append(res.ips, ip)
+	}
+	return res, nil
+}
+
+// dnsAllowed tells if the namespace of the service is allowed to publish
+// dns records.
+func (c *controller) dnsAllowed(svc *v1.Service) bool {
+	return c.dnsNamespaces[svc.Namespace] || c.dnsNamespaces["*"]
+}
+
+// withdrawDNS withdraws the records published for the service, if any.
+// The annotation recording them is removed when svc is not nil.
+func (c *controller) withdrawDNS(key string, svc *v1.Service) error {
+	if svc != nil {
+		delete(svc.Annotations, AnnotationDNSPublished)
+	}
+	prev, ok := c.published[key]
+	if !ok {
+		return nil
+	}
+	// The records are not retried if the withdrawal fails, the hostname
+	// may be owned by another service already.
+	delete(c.published, key)
+	return c.dns.Withdraw(prev.hostname, prev.ips)
+}
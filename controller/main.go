@@ -15,13 +15,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 
 	"go.universe.tf/metallb/internal/allocator"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/dnsupdate"
 	"go.universe.tf/metallb/internal/k8s"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
@@ -42,8 +45,13 @@ type service interface {
 
 type controller struct {
 	client service
+	claims claims
 	pools  *config.Pools
 	ips    *allocator.Allocator
+
+	dns           dnsRecords
+	dnsNamespaces map[string]bool      // namespaces allowed to publish records
+	published     map[string]dnsRecord // svc -> records published
 }
 
 func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _ epslices.EpsOrSlices) controllers.SyncState {
@@ -51,6 +59,9 @@ func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _
 	defer level.Debug(l).Log("event", "endUpdate", "msg", "end of service update")
 
 	if svcRo == nil {
+		if err := c.withdrawDNS(name, nil); err != nil {
+			level.Error(l).Log("op", "withdrawDNS", "error", err, "msg", "failed to withdraw dns records")
+		}
 		if c.isServiceAllocated(name) {
 			c.ips.Unassign(name)
 			level.Info(l).Log("event", "serviceDeleted", "msg", "service deleted")
@@ -77,7 +88,9 @@ func (c *controller) SetBalancer(l log.Logger, name string, svcRo *v1.Service, _
 
 	prevIPs := c.ips.IPs(name)
 
-	if c.convergeBalancer(l, name, svc) != nil {
+	if err := c.convergeBalancer(l, name, svc); errors.Is(err, ErrRetry) {
+		syncStateRes = controllers.SyncStateError
+	} else if err != nil {
 		syncStateRes = controllers.SyncStateErrorNoRetry
 	}
 
@@ -150,6 +163,14 @@ func main() {
 		webhookMode         = flag.String("webhook-mode", "enabled", "webhook mode: can be enabled, disabled or only webhook if we want the controller to act as webhook endpoint only")
 		webhookSecretName   = flag.String("webhook-secret", "webhook-server-cert", "webhook secret: the name of webhook secret, default is webhook-server-cert")
 		webhookHTTP2        = flag.Bool("webhook-http2", false, "enables http2 for the webhook endpoint")
+		dnsServer           = flag.String("dns-server", "", "address of the DNS server the records of the services are published to with dynamic updates. Publishing is disabled when empty")
+		dnsZone             = flag.String("dns-zone", "", "zone the A and AAAA records of the services are published in")
+		dnsPTRZones         = flag.String("dns-ptr-zones", "", "comma separated list of the reverse zones the PTR records of the services are published in")
+		dnsTTL              = flag.Uint("dns-ttl", 300, "TTL of the DNS records published, in seconds")
+		dnsTSIGKeyName      = flag.String("dns-tsig-key-name", "", "name of the TSIG key the dynamic updates are signed with")
+		dnsTSIGAlgorithm    = flag.String("dns-tsig-algorithm", "hmac-sha256", fmt.Sprintf("algorithm of the TSIG key. must be one of: [%s]", strings.Join(dnsupdate.Algorithms, ", ")))
+		dnsTSIGSecretPath   = flag.String("dns-tsig-secret-path", "", "path to where the base64 encoded secret of the TSIG key is mounted")
+		dnsNamespaces       = flag.String("dns-namespaces", "", "comma separated list of the namespaces whose services may publish dns records with the hostname annotation, or * for all of them. Required when dns-server is set")
 	)
 	flag.Parse()
 
@@ -174,6 +195,40 @@ func main() {
 		ips: allocator.New(),
 	}
 
+	if *dnsServer != "" {
+		dnsCfg := dnsupdate.Config{
+			Server:        *dnsServer,
+			Zone:          *dnsZone,
+			TTL:           uint32(*dnsTTL),
+			TSIGKeyName:   *dnsTSIGKeyName,
+			TSIGAlgorithm: *dnsTSIGAlgorithm,
+		}
+		if *dnsNamespaces == "" {
+			level.Error(logger).Log("op", "startup", "msg", "dns-namespaces must be set when dns-server is set")
+			os.Exit(1)
+		}
+		c.dnsNamespaces = map[string]bool{}
+		for _, ns := range strings.Split(*dnsNamespaces, ",") {
+			c.dnsNamespaces[strings.TrimSpace(ns)] = true
+		}
+		if *dnsPTRZones != "" {
+			dnsCfg.PTRZones = strings.Split(*dnsPTRZones, ",")
+		}
+		if *dnsTSIGSecretPath != "" {
+			secret, err := os.ReadFile(*dnsTSIGSecretPath)
+			if err != nil {
+				level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to read the tsig secret")
+				os.Exit(1)
+			}
+			dnsCfg.TSIGSecret = strings.TrimSpace(string(secret))
+		}
+		c.dns, err = dnsupdate.New(dnsCfg)
+		if err != nil {
+			level.Error(logger).Log("op", "startup", "error", err, "msg", "invalid dns configuration")
+			os.Exit(1)
+		}
+	}
+
 	bgpType, present := os.LookupEnv("METALLB_BGP_TYPE")
 	if !present {
 		bgpType = "native"
@@ -192,6 +247,7 @@ func main() {
 		Listener: k8s.Listener{
 			ServiceChanged: c.SetBalancer,
 			PoolChanged:    c.SetPools,
+			ClaimChanged:   c.SetClaim,
 		},
 		ValidateConfig:      validation,
 		EnableWebhook:       true,
@@ -230,6 +286,7 @@ func main() {
 	}
 
 	c.client = client
+	c.claims = client
 	if err := client.Run(nil); err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to run k8s client")
 		os.Exit(1)
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -26,6 +27,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"go.universe.tf/metallb/internal/allocator/k8salloc"
+	"go.universe.tf/metallb/internal/ipam"
 	"go.universe.tf/metallb/internal/ipfamily"
 )
 
@@ -34,10 +36,17 @@ const (
 	AnnotationAddressPool        = AnnotationPrefix + "/" + "address-pool"
 	AnnotationLoadBalancerIPs    = AnnotationPrefix + "/" + "loadBalancerIPs"
 	AnnotationIPAllocateFromPool = AnnotationPrefix + "/" + "ip-allocated-from-pool"
+	AnnotationHostname           = AnnotationPrefix + "/" + "hostname"
+	AnnotationDNSPublished       = AnnotationPrefix + "/" + "dns-published"
 )
 
 var ErrConverge = fmt.Errorf("failed to converge")
 
+// ErrRetry is returned when the service failed to converge because
+// the ipam of its pool or the dns server was unavailable, and must be
+// retried.
+var ErrRetry = fmt.Errorf("failed to converge, retrying")
+
 func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service) error {
 	lbIPs := []net.IP{}
 	var err error
@@ -104,7 +113,14 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 	if len(lbIPs) != 0 {
 		// This assign is idempotent if the config is consistent,
 		// otherwise it'll fail and tell us why.
-		if err = c.ips.Assign(key, svc, lbIPs, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc)); err != nil {
+		err = c.ips.Assign(key, svc, lbIPs, k8salloc.Ports(svc), k8salloc.SharingKey(svc), k8salloc.BackendKey(svc))
+		if errors.Is(err, ipam.ErrUnavailable) {
+			// Keep the IP until the ipam tells whether it is still leased.
+			level.Error(l).Log("op", "assignIPs", "error", err, "msg", "failed to restore the lease of the IP, retrying")
+			c.client.Errorf(svc, "AllocationFailed", "Failed to restore the lease of %q: %s", lbIPs, err)
+			return ErrRetry
+		}
+		if err != nil {
 			level.Info(l).Log("event", "clearAssignment", "error", err, "msg", "current IP not allowed by config, clearing")
 			c.client.Infof(svc, "ClearAssignment", "current IP for %q not allowed by config, will attempt for new IP assignment: %s", key, err)
 			c.clearServiceState(key, svc)
@@ -142,6 +158,9 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 		if err != nil {
 			level.Error(l).Log("op", "allocateIPs", "error", err, "msg", "IP allocation failed")
 			c.client.Errorf(svc, "AllocationFailed", "Failed to allocate IP for %q: %s", key, err)
+			if errors.Is(err, ipam.ErrUnavailable) {
+				return ErrRetry
+			}
 			// The outer controller loop will retry converging this
 			// service when another service gets deleted, so there's
 			// nothing to do here but wait to get called again later.
@@ -178,6 +197,10 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 	}
 	svc.Annotations[AnnotationIPAllocateFromPool] = pool
 
+	if err := c.publishDNS(l, key, svc, lbIPs); err != nil {
+		return ErrRetry
+	}
+
 	return nil
 }
 
@@ -185,6 +208,9 @@ func (c *controller) convergeBalancer(l log.Logger, key string, svc *v1.Service)
 // this controller.
 func (c *controller) clearServiceState(key string, svc *v1.Service) {
 	c.ips.Unassign(key)
+	if err := c.withdrawDNS(key, svc); err != nil {
+		c.client.Errorf(svc, "DNSUpdateFailed", "Failed to withdraw dns records: %s", err)
+	}
 	delete(svc.Annotations, AnnotationIPAllocateFromPool)
 	svc.Status.LoadBalancer = v1.LoadBalancerStatus{}
 }
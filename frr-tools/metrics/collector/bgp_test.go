@@ -31,6 +31,9 @@ var (
 	# HELP metallb_bgp_opens_sent Number of BGP open messages sent
 	# TYPE metallb_bgp_opens_sent counter
 	metallb_bgp_opens_sent{peer="{{ .NeighborIP }}", vrf="{{ .NeighborVRF }}"} {{ .OpensSent }}
+	# HELP metallb_bgp_received_prefixes_total Number of prefixes currently accepted from the peer on the BGP session
+	# TYPE metallb_bgp_received_prefixes_total gauge
+	metallb_bgp_received_prefixes_total{peer="{{ .NeighborIP }}", vrf="{{ .NeighborVRF }}"} {{ .ReceivedPrefixes }}
 	# HELP metallb_bgp_route_refresh_sent Number of BGP route refresh messages sent
 	# TYPE metallb_bgp_route_refresh_sent counter
 	metallb_bgp_route_refresh_sent{peer="{{ .NeighborIP }}", vrf="{{ .NeighborVRF }}"} {{ .RouteRefreshSent }}
@@ -57,6 +60,7 @@ var (
 		neighborIP           string
 		neighborVRF          string
 		announcedPrefixes    int
+		receivedPrefixes     int
 		sessionUp            int
 		updatesTotal         int
 		updatesTotalReceived int
@@ -75,6 +79,7 @@ var (
 			neighborIP:           "172.18.0.4:179",
 			neighborVRF:          "default",
 			announcedPrefixes:    3,
+			receivedPrefixes:     0,
 			sessionUp:            1,
 			updatesTotal:         3,
 			updatesTotalReceived: 3,
@@ -93,6 +98,7 @@ var (
 			neighborIP:           "172.18.0.4:180",
 			neighborVRF:          "default",
 			announcedPrefixes:    6,
+			receivedPrefixes:     13,
 			sessionUp:            1,
 			updatesTotal:         3,
 			updatesTotalReceived: 3,
@@ -448,6 +454,7 @@ func TestCollect(t *testing.T) {
 				"NeighborIP":           tc.neighborIP,
 				"NeighborVRF":          tc.neighborVRF,
 				"AnnouncedPrefixes":    tc.announcedPrefixes,
+				"ReceivedPrefixes":     tc.receivedPrefixes,
 				"SessionUp":            tc.sessionUp,
 				"UpdatesTotal":         tc.updatesTotal,
 				"UpdatesTotalReceived": tc.updatesTotalReceived,
@@ -17,6 +17,7 @@ import (
 
 	"go.universe.tf/metallb/frr-tools/metrics/collector"
 	"go.universe.tf/metallb/frr-tools/metrics/liveness"
+	"go.universe.tf/metallb/frr-tools/metrics/received"
 	"go.universe.tf/metallb/frr-tools/metrics/vtysh"
 	"go.universe.tf/metallb/internal/logging"
 	"go.universe.tf/metallb/internal/version"
@@ -25,6 +26,7 @@ import (
 var (
 	metricsPort = flag.Uint("metrics-port", 7473, "Port to listen on for web interface.")
 	metricsPath = flag.String("metrics-path", "/metrics", "Path under which to expose metrics.")
+	enableDebug = flag.Bool("enable-debug", false, "Serve the debug endpoints, listing the received routes.")
 )
 
 func metricsHandler(logger log.Logger) http.Handler {
@@ -63,6 +65,9 @@ func main() {
 	mux := http.NewServeMux()
 	mux.Handle(*metricsPath, metricsHandler(logger))
 	mux.Handle("/livez", liveness.Handler(vtysh.Run, logger))
+	if *enableDebug {
+		mux.Handle("/debug/bgp/received", received.Handler(vtysh.Run, logger))
+	}
 	level.Info(logger).Log("msg", "Starting exporter", "metricsPath", metricsPath, "port", metricsPort)
 
 	srv := &http.Server{
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package received
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"go.universe.tf/metallb/frr-tools/metrics/vtysh"
+	"go.universe.tf/metallb/internal/bgp"
+	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
+)
+
+// Handler serves as JSON the routes FRR accepted from the BGP
+// peers, in the same format as the speaker does in native mode.
+// Only the peers with accepted routes are listed.
+func Handler(frrCli vtysh.Cli, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		res, err := receivedRoutes(frrCli)
+		if err != nil {
+			level.Error(logger).Log("error", err, "msg", "failed to fetch received routes from FRR")
+			http.Error(w, "failed to fetch received routes", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func receivedRoutes(frrCli vtysh.Cli) ([]bgp.ReceivedRoutes, error) {
+	vrfs, err := vtysh.VRFs(frrCli)
+	if err != nil {
+		return nil, err
+	}
+	res := []bgp.ReceivedRoutes{}
+	for _, vrf := range vrfs {
+		out, err := frrCli(fmt.Sprintf("show bgp vrf %s neighbors json", vrf))
+		if err != nil {
+			return nil, err
+		}
+		neighbors, err := bgpfrr.ParseNeighbours(out)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range neighbors {
+			if n.PrefixReceived == 0 {
+				continue
+			}
+			routes := []bgp.Route{}
+			for _, family := range []string{"ipv4", "ipv6"} {
+				out, err := frrCli(fmt.Sprintf("show bgp vrf %s %s unicast neighbors %s routes json", vrf, family, n.IP))
+				if err != nil {
+					return nil, err
+				}
+				parsed, err := bgpfrr.ParseRoutes(out)
+				if err != nil {
+					return nil, err
+				}
+				for _, r := range parsed {
+					route := bgp.Route{Prefix: r.Destination}
+					if len(r.NextHops) > 0 {
+						route.NextHop = r.NextHops[0]
+					}
+					routes = append(routes, route)
+				}
+			}
+			sort.Slice(routes, func(i, j int) bool {
+				return routes[i].Prefix.String() < routes[j].Prefix.String()
+			})
+			received := bgp.ReceivedRoutes{
+				Peer:   net.JoinHostPort(n.IP.String(), fmt.Sprint(n.Port)),
+				Routes: routes,
+			}
+			if vrf != "default" {
+				received.VRF = vrf
+			}
+			res = append(res, received)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Peer != res[j].Peer {
+			return res[i].Peer < res[j].Peer
+		}
+		return res[i].VRF < res[j].VRF
+	})
+	return res, nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package received
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.universe.tf/metallb/internal/logging"
+)
+
+const (
+	vrfs = `{"default": {}, "red": {}}`
+
+	defaultNeighbors = `{
+  "172.18.0.5": {
+    "remoteAs": 64512,
+    "localAs": 64513,
+    "bgpState": "Established",
+    "portForeign": 179,
+    "addressFamilyInfo": {
+      "ipv4Unicast": {"acceptedPrefixCounter": 2, "sentPrefixCounter": 1},
+      "ipv6Unicast": {"acceptedPrefixCounter": 1, "sentPrefixCounter": 0}
+    }
+  },
+  "172.18.0.6": {
+    "remoteAs": 64512,
+    "localAs": 64513,
+    "bgpState": "Established",
+    "portForeign": 179,
+    "addressFamilyInfo": {
+      "ipv4Unicast": {"acceptedPrefixCounter": 0, "sentPrefixCounter": 1}
+    }
+  }
+}`
+
+	redNeighbors = `{
+  "10.1.1.1": {
+    "remoteAs": 64512,
+    "localAs": 64513,
+    "bgpState": "Established",
+    "portForeign": 180,
+    "addressFamilyInfo": {
+      "ipv4Unicast": {"acceptedPrefixCounter": 1, "sentPrefixCounter": 0}
+    }
+  }
+}`
+
+	defaultV4Routes = `{"routes": {
+  "192.168.2.0/24": [{"valid": true, "nexthops": [{"ip": "172.18.0.5", "afi": "ipv4"}]}],
+  "192.168.1.0/24": [{"valid": true, "nexthops": [{"ip": "172.18.0.5", "afi": "ipv4"}]}]
+}}`
+
+	defaultV6Routes = `{"routes": {
+  "fc00:f853:ccd:e799::/64": [{"valid": true, "nexthops": [{"ip": "fc00:f853:ccd:e793::5", "scope": "global"}, {"ip": "fe80::1", "scope": "link-local"}]}]
+}}`
+
+	redV4Routes = `{"routes": {
+  "10.100.0.0/16": [{"valid": true, "nexthops": [{"ip": "10.1.1.1", "afi": "ipv4"}]}]
+}}`
+)
+
+func TestHandler(t *testing.T) {
+	outputs := map[string]string{
+		"show bgp vrf all json":                                              vrfs,
+		"show bgp vrf default neighbors json":                                defaultNeighbors,
+		"show bgp vrf red neighbors json":                                    redNeighbors,
+		"show bgp vrf default ipv4 unicast neighbors 172.18.0.5 routes json": defaultV4Routes,
+		"show bgp vrf default ipv6 unicast neighbors 172.18.0.5 routes json": defaultV6Routes,
+		"show bgp vrf red ipv4 unicast neighbors 10.1.1.1 routes json":       redV4Routes,
+	}
+	logger, err := logging.Init("error")
+	if err != nil {
+		t.Fatalf("failed to create logger %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/bgp/received", nil)
+
+	t.Run("regular", func(t *testing.T) {
+		vtysh := func(args string) (string, error) {
+			if res, ok := outputs[args]; ok {
+				return res, nil
+			}
+			return "{}", nil
+		}
+		w := httptest.NewRecorder()
+		Handler(vtysh, logger).ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status code %d", w.Code)
+		}
+		want := `[{"peer":"10.1.1.1:180","vrf":"red","routes":[{"prefix":"10.100.0.0/16","nextHop":"10.1.1.1"}]},` +
+			`{"peer":"172.18.0.5:179","routes":[{"prefix":"192.168.1.0/24","nextHop":"172.18.0.5"},{"prefix":"192.168.2.0/24","nextHop":"172.18.0.5"},` +
+			`{"prefix":"fc00:f853:ccd:e799::/64","nextHop":"fc00:f853:ccd:e793::5"}]}]` + "\n"
+		if got := w.Body.String(); got != want {
+			t.Fatalf("unexpected body, want %s got %s", want, got)
+		}
+	})
+
+	t.Run("returns error", func(t *testing.T) {
+		vtysh := func(args string) (string, error) {
+			return "", fmt.Errorf("failed to run")
+		}
+		w := httptest.NewRecorder()
+		Handler(vtysh, logger).ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("unexpected status code %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-kit/log/level"
+
+	"go.universe.tf/metallb/frr-tools/reloader/server"
+	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/version"
+)
+
+var (
+	socket   = flag.String("socket", "/etc/frr_reloader/reloader.sock", "Unix socket to serve the reloader API on.")
+	logLevel = flag.String("log-level", "info", fmt.Sprintf("log level. must be one of: [%s]", logging.Levels.String()))
+)
+
+func main() {
+	flag.Parse()
+
+	logger, err := logging.Init(*logLevel)
+	if err != nil {
+		fmt.Printf("failed to initialize logging: %s\n", err)
+		os.Exit(1)
+	}
+
+	level.Info(logger).Log("version", version.Version(), "commit", version.CommitHash(), "branch", version.Branch(), "goversion", version.GoString(), "msg", "FRR reloader starting "+version.String())
+
+	if err := os.Remove(*socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		level.Error(logger).Log("socket", *socket, "error", err, "msg", "failed to remove stale socket")
+		os.Exit(1)
+	}
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		level.Error(logger).Log("socket", *socket, "error", err, "msg", "failed to listen")
+		os.Exit(1)
+	}
+
+	srv := &http.Server{Handler: server.Handler(server.Run, logger)}
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
+		<-c
+		srv.Close()
+	}()
+
+	level.Info(logger).Log("msg", "serving the reloader API", "socket", *socket)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+}
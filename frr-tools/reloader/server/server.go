@@ -0,0 +1,133 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
+)
+
+const frrReload = "/usr/lib/frr/frr-reload.py"
+
+// Runner runs the given command and returns its combined output.
+type Runner func(name string, args ...string) (string, error)
+
+func Run(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	return string(out), err
+}
+
+var _ Runner = Run
+
+var passwordRegexp = regexp.MustCompile(`password.*`)
+
+// Handler serves the reloader API. Requests are served one at a time,
+// as FRR must not be reconfigured concurrently.
+func Handler(run Runner, logger log.Logger) http.Handler {
+	var mu sync.Mutex
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(bgpfrr.ReloadPath, func(w http.ResponseWriter, r *http.Request) {
+		var req bgpfrr.ReloadRequest
+		if !decode(w, r, &req) {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		file, err := writeTemp("frr-*.conf", req.Config)
+		if err != nil {
+			level.Error(logger).Log("op", "reload", "error", err, "msg", "failed to write the configuration")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(file)
+
+		res := bgpfrr.ReloadResult{}
+		res.Test = step(run, "python3", frrReload, "--test", "--stdout", file)
+		level.Info(logger).Log("op", "reload", "step", "test", "success", res.Test.Success, "seconds", res.Test.DurationSeconds, "output", res.Test.Output)
+		if res.Test.Success && !req.DryRun {
+			reload := step(run, "python3", frrReload, "--reload", "--overwrite", "--stdout", file)
+			res.Reload = &reload
+			level.Info(logger).Log("op", "reload", "step", "reload", "success", reload.Success, "seconds", reload.DurationSeconds, "output", reload.Output)
+		}
+		encode(w, res, logger)
+	})
+
+	mux.HandleFunc(bgpfrr.ChangesPath, func(w http.ResponseWriter, r *http.Request) {
+		var req bgpfrr.ChangesRequest
+		if !decode(w, r, &req) {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		file, err := writeTemp("frr-changes-*.conf", req.Commands)
+		if err != nil {
+			level.Error(logger).Log("op", "changes", "error", err, "msg", "failed to write the changes")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(file)
+
+		res := step(run, "vtysh", "-f", file)
+		level.Info(logger).Log("op", "changes", "success", res.Success, "seconds", res.DurationSeconds, "output", res.Output)
+		encode(w, res, logger)
+	})
+
+	return mux
+}
+
+func step(run Runner, name string, args ...string) bgpfrr.StepResult {
+	start := time.Now()
+	out, err := run(name, args...)
+	return bgpfrr.StepResult{
+		Success:         err == nil,
+		Output:          passwordRegexp.ReplaceAllString(out, "password <retracted>"),
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func encode(w http.ResponseWriter, res interface{}, logger log.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		level.Error(logger).Log("error", err, "msg", "failed to write the answer")
+	}
+}
+
+func writeTemp(pattern, content string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
+	"go.universe.tf/metallb/internal/logging"
+)
+
+type fakeRunner struct {
+	commands []string
+	content  []string
+	fail     map[string]bool
+}
+
+func (f *fakeRunner) run(name string, args ...string) (string, error) {
+	cmd := strings.Join(append([]string{name}, args[:len(args)-1]...), " ")
+	f.commands = append(f.commands, cmd)
+	content, err := os.ReadFile(args[len(args)-1])
+	if err != nil {
+		return "", err
+	}
+	f.content = append(f.content, string(content))
+	if f.fail[cmd] {
+		return "neighbor 1.2.3.4 password secret\nfailed", fmt.Errorf("exit status 1")
+	}
+	return "ok", nil
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		desc             string
+		dryRun           bool
+		fail             map[string]bool
+		expectedCommands []string
+		testSuccess      bool
+		reloadSuccess    *bool
+	}{
+		{
+			desc:   "success",
+			dryRun: false,
+			expectedCommands: []string{
+				"python3 /usr/lib/frr/frr-reload.py --test --stdout",
+				"python3 /usr/lib/frr/frr-reload.py --reload --overwrite --stdout",
+			},
+			testSuccess:   true,
+			reloadSuccess: boolPtr(true),
+		},
+		{
+			desc:   "dry run",
+			dryRun: true,
+			expectedCommands: []string{
+				"python3 /usr/lib/frr/frr-reload.py --test --stdout",
+			},
+			testSuccess: true,
+		},
+		{
+			desc: "test fails",
+			fail: map[string]bool{"python3 /usr/lib/frr/frr-reload.py --test --stdout": true},
+			expectedCommands: []string{
+				"python3 /usr/lib/frr/frr-reload.py --test --stdout",
+			},
+			testSuccess: false,
+		},
+		{
+			desc: "reload fails",
+			fail: map[string]bool{"python3 /usr/lib/frr/frr-reload.py --reload --overwrite --stdout": true},
+			expectedCommands: []string{
+				"python3 /usr/lib/frr/frr-reload.py --test --stdout",
+				"python3 /usr/lib/frr/frr-reload.py --reload --overwrite --stdout",
+			},
+			testSuccess:   true,
+			reloadSuccess: boolPtr(false),
+		},
+	}
+
+	logger, err := logging.Init("error")
+	if err != nil {
+		t.Fatalf("failed to create logger %v", err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			runner := &fakeRunner{fail: tc.fail}
+			body, _ := json.Marshal(bgpfrr.ReloadRequest{Config: "hostname test\n", DryRun: tc.dryRun})
+			req := httptest.NewRequest(http.MethodPost, bgpfrr.ReloadPath, strings.NewReader(string(body)))
+			rr := httptest.NewRecorder()
+			Handler(runner.run, logger).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+			}
+			if diff := cmp.Diff(tc.expectedCommands, runner.commands); diff != "" {
+				t.Fatalf("unexpected commands (-want +got)\n%s", diff)
+			}
+			for _, c := range runner.content {
+				if c != "hostname test\n" {
+					t.Fatalf("unexpected config file content %q", c)
+				}
+			}
+
+			var res bgpfrr.ReloadResult
+			if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+				t.Fatalf("failed to decode the result: %s", err)
+			}
+			if res.Test.Success != tc.testSuccess {
+				t.Fatalf("expected test success %t, got %t", tc.testSuccess, res.Test.Success)
+			}
+			if tc.reloadSuccess == nil {
+				if res.Reload != nil {
+					t.Fatalf("expected no reload, got %+v", res.Reload)
+				}
+				return
+			}
+			if res.Reload == nil || res.Reload.Success != *tc.reloadSuccess {
+				t.Fatalf("expected reload success %t, got %+v", *tc.reloadSuccess, res.Reload)
+			}
+			if !*tc.reloadSuccess && strings.Contains(res.Reload.Output, "secret") {
+				t.Fatalf("expected the password to be retracted, got %s", res.Reload.Output)
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	logger, err := logging.Init("error")
+	if err != nil {
+		t.Fatalf("failed to create logger %v", err)
+	}
+
+	runner := &fakeRunner{}
+	body, _ := json.Marshal(bgpfrr.ChangesRequest{Commands: "router bgp 100\n"})
+	req := httptest.NewRequest(http.MethodPost, bgpfrr.ChangesPath, strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	Handler(runner.run, logger).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	if diff := cmp.Diff([]string{"vtysh -f"}, runner.commands); diff != "" {
+		t.Fatalf("unexpected commands (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"router bgp 100\n"}, runner.content); diff != "" {
+		t.Fatalf("unexpected changes (-want +got)\n%s", diff)
+	}
+	var res bgpfrr.StepResult
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("failed to decode the result: %s", err)
+	}
+	if !res.Success || res.Output != "ok" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	logger, err := logging.Init("error")
+	if err != nil {
+		t.Fatalf("failed to create logger %v", err)
+	}
+
+	runner := &fakeRunner{}
+	req := httptest.NewRequest(http.MethodPost, bgpfrr.ReloadPath, strings.NewReader("{"))
+	rr := httptest.NewRecorder()
+	Handler(runner.run, logger).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", rr.Code)
+	}
+	if len(runner.commands) != 0 {
+		t.Fatalf("expected no commands, got %v", runner.commands)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -11,9 +11,9 @@ import (
 	"strings"
 
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipam"
 	"go.universe.tf/metallb/internal/ipfamily"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/mikioh/ipaddr"
 )
@@ -27,6 +27,8 @@ type Allocator struct {
 	portsInUse      map[string]map[Port]string // ip.String() -> Port -> svc
 	servicesOnIP    map[string]map[string]bool // ip.String() -> svc -> allocated?
 	poolIPsInUse    map[string]map[string]int  // poolName -> ip.String() -> number of users
+	leases          map[string]*lease          // ip.String() -> lease from an ipam
+	providers       map[string]ipam.Provider   // url -> provider
 }
 
 // Port represents one port in use by a service.
@@ -45,6 +47,12 @@ type key struct {
 	backend string
 }
 
+// lease is an address leased from the ipam of a pool.
+type lease struct {
+	pool string
+	url  string
+}
+
 type alloc struct {
 	pool  string
 	ips   []net.IP
@@ -62,6 +70,8 @@ func New() *Allocator {
 		portsInUse:      map[string]map[Port]string{},
 		servicesOnIP:    map[string]map[string]bool{},
 		poolIPsInUse:    map[string]map[string]int{},
+		leases:          map[string]*lease{},
+		providers:       map[string]ipam.Provider{},
 	}
 }
 
@@ -85,7 +95,7 @@ func (a *Allocator) SetPools(pools *config.Pools) {
 			continue
 		}
 		if pool.Name != alloc.pool {
-			a.Unassign(svc)
+			a.unassign(svc)
 			alloc.pool = pool.Name
 			// Use the internal assign, we know for a fact the IP is
 			// still usable.
@@ -93,6 +103,17 @@ func (a *Allocator) SetPools(pools *config.Pools) {
 		}
 	}
 
+	// The leases of the pools deleted, or not leasing from the same ipam
+	// anymore, are released.
+	for ip, l := range a.leases {
+		pool := a.pools.ByName[l.pool]
+		if pool != nil && pool.IPAM != nil && pool.IPAM.URL == l.url && isLeasable(pool, net.ParseIP(ip)) {
+			continue
+		}
+		delete(a.leases, ip)
+		a.releaseLease(net.ParseIP(ip), l)
+	}
+
 	// Refresh or initiate stats
 	for n, p := range a.pools.ByName {
 		stats.poolCapacity.WithLabelValues(n).Set(float64(poolCount(p)))
@@ -103,7 +124,9 @@ func (a *Allocator) SetPools(pools *config.Pools) {
 // assign unconditionally updates internal state to reflect svc's
 // allocation of alloc. Caller must ensure that this call is safe.
 func (a *Allocator) assign(svc string, alloc *alloc) {
-	a.Unassign(svc)
+	prev := a.allocated[svc]
+	a.unassign(svc)
+	defer a.release(prev)
 	a.allocated[svc] = alloc
 	for _, ip := range alloc.ips {
 		a.sharingKeyForIP[ip.String()] = &alloc.key
@@ -133,11 +156,14 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 	if pool == nil {
 		return fmt.Errorf("%q is not allowed in config", ips)
 	}
+	if pool.ServiceClusterIPRange {
+		return fmt.Errorf("%q is in the service cluster IP range of pool %q", ips, pool.Name)
+	}
 	sk := &key{
 		sharing: sharingKey,
 		backend: backendKey,
 	}
-	if !a.isPoolCompatibleWithService(pool, svc) {
+	if !pool.AllowsService(svc) {
 		return fmt.Errorf("pool %s not compatible for ip assignment", pool.Name)
 	}
 	// Check the dual-stack constraints:
@@ -159,6 +185,20 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 		}
 	}
 
+	// The addresses of the ipam of the pool must be leased before being
+	// used, the provider telling if they are still available.
+	leased := []net.IP{}
+	for _, ip := range ips {
+		if !isLeasable(pool, ip) || a.leases[ip.String()] != nil {
+			continue
+		}
+		if _, err := a.lease(pool, svcKey, ipfamily.ForAddress(ip), ip); err != nil {
+			a.releaseIPs(leased)
+			return err
+		}
+		leased = append(leased, ip)
+	}
+
 	// Either the IP is entirely unused, or the requested use is
 	// compatible with existing uses. Assign! But unassign first, in
 	// case we're mutating an existing service (see the "already have
@@ -175,8 +215,17 @@ func (a *Allocator) Assign(svcKey string, svc *v1.Service, ips []net.IP, ports [
 	return nil
 }
 
-// Unassign frees the IP associated with service, if any.
+// Unassign frees the IP associated with service, if any. The addresses
+// leased from an ipam are released once no service uses them.
 func (a *Allocator) Unassign(svc string) {
+	al := a.allocated[svc]
+	a.unassign(svc)
+	a.release(al)
+}
+
+// unassign frees the IP associated with service, keeping the leases of
+// its addresses.
+func (a *Allocator) unassign(svc string) {
 	if a.allocated[svc] == nil {
 		return
 	}
@@ -228,6 +277,9 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 	if pool == nil {
 		return nil, fmt.Errorf("unknown pool %q", poolName)
 	}
+	if pool.ServiceClusterIPRange {
+		return nil, fmt.Errorf("pool %q is a service cluster IP range", poolName)
+	}
 
 	ips := []net.IP{}
 	ipfamilySel := make(map[ipfamily.Family]bool)
@@ -245,6 +297,10 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 			// Not the right ip-family
 			continue
 		}
+		if pool.IPAM.Contains(cidr) {
+			// Only allocated through the provider.
+			continue
+		}
 		ip := a.getIPFromCIDR(cidr, pool.AvoidBuggyIPs, svcKey, ports, sharingKey, backendKey)
 		if ip != nil {
 			ips = append(ips, ip)
@@ -252,17 +308,110 @@ func (a *Allocator) AllocateFromPool(svcKey string, svc *v1.Service, serviceIPFa
 		}
 	}
 
+	// No local address left, ask the ipam of the pool for the missing ones.
+	leased := []net.IP{}
+	if pool.IPAM != nil && len(ipfamilySel) > 0 && pool.AllowsService(svc) {
+		for _, family := range []ipfamily.Family{ipfamily.IPv4, ipfamily.IPv6} {
+			if !ipfamilySel[family] {
+				continue
+			}
+			ip, err := a.lease(pool, svcKey, family, nil)
+			if err != nil {
+				a.releaseIPs(leased)
+				return nil, err
+			}
+			leased = append(leased, ip)
+			ips = append(ips, ip)
+			delete(ipfamilySel, family)
+		}
+	}
+
 	if len(ipfamilySel) > 0 {
 		// Woops, run out of IPs :( Fail.
 		return nil, fmt.Errorf("no available IPs in pool %q for %s IPFamily", poolName, serviceIPFamily)
 	}
 	err := a.Assign(svcKey, svc, ips, ports, sharingKey, backendKey)
 	if err != nil {
+		a.releaseIPs(leased)
 		return nil, err
 	}
 	return ips, nil
 }
 
+// lease leases an address of the family from the ipam of the pool, the
+// given one when ip is not nil.
+func (a *Allocator) lease(pool *config.Pool, svcKey string, family ipfamily.Family, ip net.IP) (net.IP, error) {
+	p := a.providers[pool.IPAM.URL]
+	if p == nil {
+		p = ipam.NewHTTP(pool.IPAM.URL)
+		a.providers[pool.IPAM.URL] = p
+	}
+	leased, err := p.Lease(pool.Name, svcKey, family, ip)
+	if err != nil {
+		return nil, err
+	}
+	l := &lease{pool: pool.Name, url: pool.IPAM.URL}
+	if ip != nil && !ip.Equal(leased) {
+		a.releaseLease(leased, l)
+		return nil, fmt.Errorf("address %s leased for pool %q instead of %s", leased, pool.Name, ip)
+	}
+	if !isLeasable(pool, leased) {
+		a.releaseLease(leased, l)
+		return nil, fmt.Errorf("address %s leased for pool %q is not part of its ipam addresses", leased, pool.Name)
+	}
+	if ip == nil && (a.leases[leased.String()] != nil || len(a.servicesOnIP[leased.String()]) > 0) {
+		// The provider handed out an address we already use, it must
+		// not be released.
+		return nil, fmt.Errorf("address %s leased for pool %q is already in use", leased, pool.Name)
+	}
+	a.leases[leased.String()] = l
+	return leased, nil
+}
+
+// release releases the leased addresses of the allocation that are
+// not in use anymore.
+func (a *Allocator) release(al *alloc) {
+	if al == nil {
+		return
+	}
+	a.releaseIPs(al.ips)
+}
+
+func (a *Allocator) releaseIPs(ips []net.IP) {
+	for _, ip := range ips {
+		l := a.leases[ip.String()]
+		if l == nil || len(a.servicesOnIP[ip.String()]) > 0 {
+			continue
+		}
+		delete(a.leases, ip.String())
+		a.releaseLease(ip, l)
+	}
+}
+
+func (a *Allocator) releaseLease(ip net.IP, l *lease) {
+	p := a.providers[l.url]
+	if p == nil {
+		p = ipam.NewHTTP(l.url)
+		a.providers[l.url] = p
+	}
+	if err := p.Release(l.pool, ip); err != nil {
+		stats.ipamReleaseErrors.WithLabelValues(l.pool).Inc()
+	}
+}
+
+// isLeasable tells if the address is one of the addresses of the ipam of the pool.
+func isLeasable(pool *config.Pool, ip net.IP) bool {
+	if pool.IPAM == nil {
+		return false
+	}
+	for _, cidr := range pool.IPAM.CIDR {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // Allocate assigns any available and assignable IP to service.
 func (a *Allocator) Allocate(svcKey string, svc *v1.Service, serviceIPFamily ipfamily.Family, ports []Port, sharingKey, backendKey string) ([]net.IP, error) {
 	if alloc := a.allocated[svcKey]; alloc != nil {
@@ -299,7 +448,7 @@ func (a *Allocator) pinnedPoolsForService(svc *v1.Service) []*config.Pool {
 	}
 	for _, nsPoolName := range a.pools.ByNamespace[svc.Namespace] {
 		if nsPool, ok := a.pools.ByName[nsPoolName]; ok {
-			if !nsPool.AutoAssign || !a.isPoolCompatibleWithService(nsPool, svc) {
+			if !nsPool.AutoAssign || !nsPool.AllowsService(svc) {
 				continue
 			}
 			pools = append(pools, nsPool)
@@ -307,7 +456,7 @@ func (a *Allocator) pinnedPoolsForService(svc *v1.Service) []*config.Pool {
 	}
 	for _, svcPoolName := range a.pools.ByServiceSelector {
 		if svcPool, ok := a.pools.ByName[svcPoolName]; ok {
-			if !svcPool.AutoAssign || !a.isPoolCompatibleWithService(svcPool, svc) {
+			if !svcPool.AutoAssign || !svcPool.AllowsService(svc) {
 				continue
 			}
 			pools = append(pools, svcPool)
@@ -317,23 +466,6 @@ func (a *Allocator) pinnedPoolsForService(svc *v1.Service) []*config.Pool {
 	return pools
 }
 
-func (a *Allocator) isPoolCompatibleWithService(p *config.Pool, svc *v1.Service) bool {
-	if p.ServiceAllocations != nil && p.ServiceAllocations.Namespaces.Len() > 0 &&
-		!p.ServiceAllocations.Namespaces.Has(svc.Namespace) {
-		return false
-	}
-	if p.ServiceAllocations != nil && len(p.ServiceAllocations.ServiceSelectors) > 0 {
-		svcLabels := labels.Set(svc.Labels)
-		for _, svcSelector := range p.ServiceAllocations.ServiceSelectors {
-			if svcSelector.Matches(svcLabels) {
-				return true
-			}
-		}
-		return false
-	}
-	return true
-}
-
 // Pool returns the pool from which service's IP was allocated. If
 // service has no IP allocated, "" is returned.
 func (a *Allocator) Pool(svc string) string {
@@ -3,14 +3,18 @@
 package allocator
 
 import (
+	"encoding/json"
 	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipam"
 	"go.universe.tf/metallb/internal/ipfamily"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -1885,6 +1889,227 @@ func TestAutoAssign(t *testing.T) {
 	}
 }
 
+func TestServiceClusterIPRange(t *testing.T) {
+	alloc := New()
+	alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{
+		"cluster-ips": {
+			Name:                  "cluster-ips",
+			ServiceClusterIPRange: true,
+			CIDR:                  []*net.IPNet{ipnet("10.96.0.0/12")},
+		},
+	}})
+
+	if _, err := alloc.AllocateFromPool("s1", svc, ipfamily.IPv4, "cluster-ips", nil, "", ""); err == nil {
+		t.Errorf("allocating from a service cluster IP range should have failed")
+	}
+	if err := alloc.Assign("s1", svc, []net.IP{net.ParseIP("10.96.0.10")}, nil, "", ""); err == nil {
+		t.Errorf("assigning an IP of a service cluster IP range should have failed")
+	}
+	if _, err := alloc.Allocate("s1", svc, ipfamily.IPv4, nil, "", ""); err == nil {
+		t.Errorf("allocating with only a service cluster IP range should have failed")
+	}
+}
+
+// ipamStandIn is a local HTTP ipam provider leasing the addresses of a
+// fixed list.
+type ipamStandIn struct {
+	free   []string
+	leased map[string]string // address -> pool
+	calls  int
+}
+
+func (s *ipamStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.calls++
+	switch r.URL.Path {
+	case ipam.LeasePath:
+		req := ipam.LeaseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		addr := req.Address
+		switch {
+		case addr != "" && s.leased[addr] == req.Pool:
+		case addr != "" && s.leased[addr] != "":
+			http.Error(w, "address already leased", http.StatusConflict)
+			return
+		case addr != "":
+			s.leased[addr] = req.Pool
+		case len(s.free) == 0:
+			http.Error(w, "no address left", http.StatusConflict)
+			return
+		default:
+			addr, s.free = s.free[0], s.free[1:]
+			s.leased[addr] = req.Pool
+		}
+		_ = json.NewEncoder(w).Encode(ipam.LeaseResponse{Address: addr})
+	case ipam.ReleasePath:
+		req := ipam.ReleaseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.leased[req.Address] == req.Pool {
+			delete(s.leased, req.Address)
+			s.free = append(s.free, req.Address)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestIPAM(t *testing.T) {
+	standIn := &ipamStandIn{
+		free:   []string{"10.0.0.1", "10.0.0.2"},
+		leased: map[string]string{},
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	pools := &config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("1.2.3.4/32"), ipnet("10.0.0.0/30")},
+			IPAM: &config.IPAM{
+				CIDR: []*net.IPNet{ipnet("10.0.0.0/30")},
+				URL:  server.URL,
+			},
+		},
+	}}
+	alloc := New()
+	alloc.SetPools(pools)
+
+	allocate := func(svcKey, want string) {
+		t.Helper()
+		ips, err := alloc.AllocateFromPool(svcKey, svc, ipfamily.IPv4, "test", nil, "", "")
+		if want == "" {
+			if err == nil {
+				t.Fatalf("allocating %s should have failed, got %s", svcKey, ips)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("allocating %s failed: %s", svcKey, err)
+		}
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP(want)) {
+			t.Fatalf("allocated %s to %s, expected %s", ips, svcKey, want)
+		}
+	}
+
+	// The local addresses are allocated first.
+	allocate("s1", "1.2.3.4")
+	if standIn.calls != 0 {
+		t.Fatalf("the ipam was called with local addresses left")
+	}
+	allocate("s2", "10.0.0.1")
+	allocate("s3", "10.0.0.2")
+	allocate("s4", "")
+	if standIn.leased["10.0.0.1"] != "test" || standIn.leased["10.0.0.2"] != "test" {
+		t.Fatalf("addresses not leased for the pool: %v", standIn.leased)
+	}
+
+	// Reassigning a leased address doesn't lease it again.
+	calls := standIn.calls
+	if err := alloc.Assign("s2", svc, []net.IP{net.ParseIP("10.0.0.1")}, nil, "", ""); err != nil {
+		t.Fatalf("reassigning s2 failed: %s", err)
+	}
+	if standIn.calls != calls {
+		t.Fatalf("the ipam was called reassigning a leased address")
+	}
+
+	// The leased addresses are released once unassigned.
+	alloc.Unassign("s2")
+	if standIn.leased["10.0.0.1"] != "" {
+		t.Fatalf("10.0.0.1 not released on unassign")
+	}
+	alloc.Unassign("s1")
+	if len(standIn.free) != 1 {
+		t.Fatalf("local address released to the ipam: %v", standIn.free)
+	}
+
+	// Assigning an address of the ipam leases it.
+	if err := alloc.Assign("s4", svc, []net.IP{net.ParseIP("10.0.0.1")}, nil, "", ""); err != nil {
+		t.Fatalf("assigning s4 failed: %s", err)
+	}
+	if standIn.leased["10.0.0.1"] != "test" {
+		t.Fatalf("10.0.0.1 not leased on assign")
+	}
+	if err := alloc.Assign("s5", svc, []net.IP{net.ParseIP("10.0.0.3")}, nil, "", ""); err != nil {
+		t.Fatalf("assigning s5 failed: %s", err)
+	}
+
+	// A restarted allocator restores the leases.
+	alloc = New()
+	alloc.SetPools(pools)
+	if err := alloc.Assign("s3", svc, []net.IP{net.ParseIP("10.0.0.2")}, nil, "", ""); err != nil {
+		t.Fatalf("restoring s3 failed: %s", err)
+	}
+
+	// An address the ipam leased for another consumer can't be assigned.
+	standIn.leased["10.0.0.0"] = "other"
+	if err := alloc.Assign("s6", svc, []net.IP{net.ParseIP("10.0.0.0")}, nil, "", ""); err == nil {
+		t.Fatalf("assigning an address leased for another pool should have failed")
+	}
+}
+
+func TestIPAMPoolChanges(t *testing.T) {
+	standIn := &ipamStandIn{
+		free:   []string{"10.0.0.1", "10.0.0.2"},
+		leased: map[string]string{},
+	}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	withIPAM := &config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/30")},
+			IPAM: &config.IPAM{
+				CIDR: []*net.IPNet{ipnet("10.0.0.0/30")},
+				URL:  server.URL,
+			},
+		},
+	}}
+	withoutIPAM := &config.Pools{ByName: map[string]*config.Pool{
+		"test": {
+			Name:       "test",
+			AutoAssign: true,
+			CIDR:       []*net.IPNet{ipnet("10.0.0.0/30")},
+		},
+	}}
+
+	alloc := New()
+	alloc.SetPools(withIPAM)
+	if _, err := alloc.AllocateFromPool("s1", svc, ipfamily.IPv4, "test", nil, "", ""); err != nil {
+		t.Fatalf("allocating s1 failed: %s", err)
+	}
+	if standIn.leased["10.0.0.1"] != "test" {
+		t.Fatalf("10.0.0.1 not leased for the pool: %v", standIn.leased)
+	}
+
+	// The pool doesn't lease from the ipam anymore.
+	alloc.SetPools(withoutIPAM)
+	if len(standIn.leased) != 0 {
+		t.Fatalf("leases not released when the pool stopped using the ipam: %v", standIn.leased)
+	}
+
+	alloc.SetPools(withIPAM)
+	if err := alloc.Assign("s2", svc, []net.IP{net.ParseIP("10.0.0.2")}, nil, "", ""); err != nil {
+		t.Fatalf("assigning s2 failed: %s", err)
+	}
+	if standIn.leased["10.0.0.2"] != "test" {
+		t.Fatalf("10.0.0.2 not leased for the pool: %v", standIn.leased)
+	}
+
+	// The pool is deleted.
+	alloc.SetPools(&config.Pools{ByName: map[string]*config.Pool{}})
+	if len(standIn.leased) != 0 {
+		t.Fatalf("leases not released when the pool was deleted: %v", standIn.leased)
+	}
+}
+
 func TestPoolCount(t *testing.T) {
 	tests := []struct {
 		desc string
@@ -8,6 +8,8 @@ var stats = struct {
 	poolCapacity  *prometheus.GaugeVec
 	poolActive    *prometheus.GaugeVec
 	poolAllocated *prometheus.GaugeVec
+
+	ipamReleaseErrors *prometheus.CounterVec
 }{
 	poolCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "metallb",
@@ -33,10 +35,19 @@ var stats = struct {
 	}, []string{
 		"pool",
 	}),
+	ipamReleaseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "metallb",
+		Subsystem: "allocator",
+		Name:      "ipam_release_errors_total",
+		Help:      "Number of addresses the ipam failed to release, per pool",
+	}, []string{
+		"pool",
+	}),
 }
 
 func init() {
 	prometheus.MustRegister(stats.poolCapacity)
 	prometheus.MustRegister(stats.poolActive)
 	prometheus.MustRegister(stats.poolAllocated)
+	prometheus.MustRegister(stats.ipamReleaseErrors)
 }
@@ -3,6 +3,7 @@
 package bgp // import "go.universe.tf/metallb/internal/bgp"
 
 import (
+	"encoding/json"
 	"io"
 	"net"
 	"reflect"
@@ -25,6 +26,23 @@ type Advertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// The bandwidth, in Mbps, to carry in a link bandwidth extended
+	// community. Zero means no link bandwidth community is attached.
+	LinkBandwidth uint32
+	// The number of times ASPathPrependASN is prepended to the AS_PATH.
+	// Only applied to EBGP peers.
+	ASPathPrependCount uint32
+	// The ASN to prepend to the AS_PATH. Zero means the local ASN.
+	ASPathPrependASN uint32
+	// The value of the MULTI_EXIT_DISC attribute. Nil means
+	// no MED is attached.
+	MED *uint32
+	// The VRFs the prefix is leaked into from the VRF of the peers
+	// it is announced to. The default VRF is named "default".
+	LeakToVRFs []string
+	// How the prefix is originated as an EVPN type-5 route. Nil means
+	// the prefix is not announced via EVPN.
+	EVPN *config.EVPNAdvertisement
 }
 
 // Equal returns true if a and b are equivalent advertisements.
@@ -35,11 +53,34 @@ func (a *Advertisement) Equal(b *Advertisement) bool {
 	if a.LocalPref != b.LocalPref {
 		return false
 	}
+	if a.LinkBandwidth != b.LinkBandwidth {
+		return false
+	}
+	if a.ASPathPrependCount != b.ASPathPrependCount {
+		return false
+	}
+	if a.ASPathPrependASN != b.ASPathPrependASN {
+		return false
+	}
+	if (a.MED == nil) != (b.MED == nil) {
+		return false
+	}
+	if a.MED != nil && *a.MED != *b.MED {
+		return false
+	}
 
 	if !reflect.DeepEqual(a.Peers, b.Peers) {
 		return false
 	}
 
+	if !reflect.DeepEqual(a.LeakToVRFs, b.LeakToVRFs) {
+		return false
+	}
+
+	if !reflect.DeepEqual(a.EVPN, b.EVPN) {
+		return false
+	}
+
 	return reflect.DeepEqual(a.Communities, b.Communities)
 }
 
@@ -58,7 +99,50 @@ func (a *Advertisement) MatchesPeer(peerName string) bool {
 
 type Session interface {
 	io.Closer
+	// Set replaces all the advertisements of the session.
 	Set(advs ...*Advertisement) error
+	// Update changes the advertisements of the session: the given
+	// advertisements replace the ones with the same prefix, and the
+	// withdrawn prefixes are no longer advertised.
+	Update(add []*Advertisement, withdraw []*net.IPNet) error
+}
+
+// AOKeySetter is implemented by the sessions able to change their TCP-AO
+// keys without being established again.
+type AOKeySetter interface {
+	SetAOKeys(keys []config.TCPAOKey) error
+}
+
+// PasswordSetter is implemented by the sessions able to change their TCP
+// MD5 passwords without being established again.
+type PasswordSetter interface {
+	SetPasswords(password, nextPassword string) error
+}
+
+// Route is a route accepted from a BGP peer.
+type Route struct {
+	Prefix  *net.IPNet
+	NextHop net.IP
+}
+
+func (r Route) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Prefix  string `json:"prefix"`
+		NextHop string `json:"nextHop"`
+	}{r.Prefix.String(), r.NextHop.String()})
+}
+
+// ReceivedRoutes are the routes accepted from a BGP peer.
+type ReceivedRoutes struct {
+	Peer   string  `json:"peer"`
+	VRF    string  `json:"vrf,omitempty"`
+	Routes []Route `json:"routes"`
+}
+
+// RouteReceiver is implemented by the session managers keeping the
+// routes accepted from the peers of their sessions.
+type RouteReceiver interface {
+	ReceivedRoutes() []ReceivedRoutes
 }
 
 type SessionParameters struct {
@@ -75,9 +159,51 @@ type SessionParameters struct {
 	EBGPMultiHop  bool
 	VRFName       string
 	SessionName   string
+	// PeerInterface is the interface to establish an unnumbered
+	// session over, in which case PeerAddress is empty.
+	PeerInterface string
+	// ListenRange is the CIDR to accept sessions from, in which case
+	// PeerAddress is empty.
+	ListenRange string
+	// Passive makes the session wait for the peer to open the
+	// connection instead of initiating it.
+	Passive bool
+	// EBGPMultiHopTTL is the TTL of the packets sent to a multi-hops
+	// away peer, zero meaning the default of the implementation.
+	EBGPMultiHopTTL uint32
+	// TTLSecurityHops enables GTSM (RFC 5082) when not zero.
+	TTLSecurityHops uint32
+	// AOKeys is the TCP-AO key chain of the session, used instead
+	// of Password when set. The first key is used to send.
+	AOKeys []config.TCPAOKey
+	// NextPassword is the password the peer is about to switch to,
+	// if the credentials of the session are being rotated.
+	NextPassword string
+	// Receive selects the routes accepted from the peer, none of them
+	// being accepted if nil.
+	Receive *config.ReceivePolicy
+	// RawFRRConfig is raw configuration rendered in the router block
+	// of the session, supported only by the FRR implementation.
+	RawFRRConfig string
 }
 type SessionManager interface {
 	NewSession(logger log.Logger, args SessionParameters) (Session, error)
 	SyncBFDProfiles(profiles map[string]*config.BFDProfile) error
 	SyncExtraInfo(extras string) error
+	// SyncSnippets sets the raw configuration snippets applied on this
+	// node, by name.
+	SyncSnippets(snippets map[string]string) error
+}
+
+// ReloadReporter is implemented by the session managers applying the
+// sessions to a routing daemon by reloading its configuration, checking
+// the raw configuration snippets before.
+type ReloadReporter interface {
+	// ReportReloads makes the session manager call report when the
+	// reloads start failing, with the reason, and with nil when they
+	// succeed again.
+	ReportReloads(report func(error))
+	// ReportSnippets makes the session manager call report each time
+	// a configuration snippet is found to be invalid.
+	ReportSnippets(report func(snippet string, err error))
 }
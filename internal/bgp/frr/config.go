@@ -9,7 +9,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
-	"syscall"
+	"strings"
 	"text/template"
 	"time"
 
@@ -20,8 +20,7 @@ import (
 )
 
 var (
-	configFileName      = "/etc/frr_reloader/frr.conf"
-	reloaderPidFileName = "/etc/frr_reloader/reloader.pid"
+	configFileName = "/etc/frr_reloader/frr.conf"
 	//go:embed templates/* templates/*
 	templates embed.FS
 )
@@ -31,6 +30,9 @@ type frrConfig struct {
 	Hostname    string
 	Routers     []*routerConfig
 	BFDProfiles []BFDProfile
+	VRFs        []vrfConfig
+	OSPF        *ospfConfig
+	Snippets    []snippetConfig
 	ExtraConfig string
 }
 
@@ -49,6 +51,48 @@ type routerConfig struct {
 	VRF          string
 	IPV4Prefixes []string
 	IPV6Prefixes []string
+	// The VRFs the prefixes below are imported from, and the prefixes
+	// leaked into the VRF of the router.
+	ImportVRFs       []string
+	ImportV4Prefixes []string
+	ImportV6Prefixes []string
+	// How the prefixes of the router are advertised as EVPN type-5
+	// routes, if they are.
+	EVPN *evpnConfig
+}
+
+// HasEVPNNeighbors tells if any neighbor of the router is sent
+// EVPN routes.
+func (r *routerConfig) HasEVPNNeighbors() bool {
+	for _, n := range r.Neighbors {
+		if n.EVPN {
+			return true
+		}
+	}
+	return false
+}
+
+type evpnConfig struct {
+	VNI          uint32
+	RouteTargets []string
+	// The families of the prefixes advertised as type-5 routes.
+	HasV4Prefixes bool
+	HasV6Prefixes bool
+}
+
+// vrfConfig binds a VRF to its L3VNI.
+type vrfConfig struct {
+	Name string
+	VNI  uint32
+}
+
+// ImportName returns the name of the VRF of the router, used to name
+// the filters of the imported prefixes.
+func (r *routerConfig) ImportName() string {
+	if r.VRF == "" {
+		return "default"
+	}
+	return r.VRF
 }
 
 type BFDProfile struct {
@@ -67,6 +111,9 @@ type neighborConfig struct {
 	Name                string
 	ASN                 uint32
 	Addr                string
+	Unnumbered          bool
+	ListenRange         string
+	Passive             bool
 	SrcAddr             string
 	Port                uint16
 	HoldTime            uint64
@@ -75,9 +122,16 @@ type neighborConfig struct {
 	Advertisements      []*advertisementConfig
 	BFDProfile          string
 	EBGPMultiHop        bool
+	EBGPMultiHopTTL     uint32
+	TTLSecurityHops     uint32
 	VRFName             string
 	HasV4Advertisements bool
 	HasV6Advertisements bool
+	ReceiveAll          bool
+	ReceiveV4Prefixes   []string
+	ReceiveV6Prefixes   []string
+	EVPN                bool
+	RawConfig           string
 }
 
 func (n *neighborConfig) ID() string {
@@ -93,6 +147,24 @@ type advertisementConfig struct {
 	Communities      []string
 	LargeCommunities []string
 	LocalPref        uint32
+	LinkBandwidth    uint32
+	ASPathPrepend    asPathPrepend
+	MED              *uint32
+}
+
+// asPathPrepend describes the ASNs to prepend to the AS_PATH
+// of an advertisement.
+type asPathPrepend struct {
+	ASN   uint32
+	Count uint32
+}
+
+func (a asPathPrepend) String() string {
+	asns := make([]string, 0, a.Count)
+	for i := uint32(0); i < a.Count; i++ {
+		asns = append(asns, strconv.FormatUint(uint64(a.ASN), 10))
+	}
+	return strings.Join(asns, " ")
 }
 
 // routerName() defines the format of the key of the "Routers" map in the
@@ -110,6 +182,12 @@ func neighborName(peerAddr string, ASN uint32, vrfName string) string {
 // templateConfig uses the template library to template
 // 'globalConfigTemplate' using 'data'.
 func templateConfig(data interface{}) (string, error) {
+	return renderTemplate("frr.tmpl", data)
+}
+
+// renderTemplate renders the named template of the FRR configuration
+// using 'data'.
+func renderTemplate(name string, data interface{}) (string, error) {
 	counterMap := map[string]int{}
 	t, err := template.New("frr.tmpl").Funcs(
 		template.FuncMap{
@@ -128,6 +206,15 @@ func templateConfig(data interface{}) (string, error) {
 			"localPrefPrefixList": func(neighbor *neighborConfig, localPreference uint32) string {
 				return fmt.Sprintf("%s-%d-%s-localpref-prefixes", neighbor.ID(), localPreference, neighbor.IPFamily)
 			},
+			"linkBandwidthPrefixList": func(neighbor *neighborConfig, bandwidth uint32) string {
+				return fmt.Sprintf("%s-%d-%s-linkbandwidth-prefixes", neighbor.ID(), bandwidth, neighbor.IPFamily)
+			},
+			"asPathPrependPrefixList": func(neighbor *neighborConfig, prepend asPathPrepend) string {
+				return fmt.Sprintf("%s-%d-%d-%s-aspathprepend-prefixes", neighbor.ID(), prepend.ASN, prepend.Count, neighbor.IPFamily)
+			},
+			"medPrefixList": func(neighbor *neighborConfig, med uint32) string {
+				return fmt.Sprintf("%s-%d-%s-med-prefixes", neighbor.ID(), med, neighbor.IPFamily)
+			},
 			"communityPrefixList": func(neighbor *neighborConfig, community string) string {
 				return fmt.Sprintf("%s-%s-%s-community-prefixes", neighbor.ID(), community, neighbor.IPFamily)
 			},
@@ -137,6 +224,9 @@ func templateConfig(data interface{}) (string, error) {
 			"allowedPrefixList": func(neighbor *neighborConfig) string {
 				return fmt.Sprintf("%s-pl-%s", neighbor.ID(), neighbor.IPFamily)
 			},
+			"receivedPrefixList": func(neighbor *neighborConfig, ipFamily string) string {
+				return fmt.Sprintf("%s-received-pl-%s", neighbor.ID(), ipFamily)
+			},
 			"mustDisableConnectedCheck": func(ipFamily ipfamily.Family, myASN, asn uint32, eBGPMultiHop bool) bool {
 				// return true only for IPv6 eBGP sessions
 				if ipFamily == "ipv6" && myASN != asn && !eBGPMultiHop {
@@ -164,7 +254,7 @@ func templateConfig(data interface{}) (string, error) {
 	}
 
 	var b bytes.Buffer
-	err = t.Execute(&b, data)
+	err = t.ExecuteTemplate(&b, name, data)
 	return b.String(), err
 }
 
@@ -174,37 +264,31 @@ func writeConfig(config string, filename string) error {
 	return os.WriteFile(filename, []byte(config), 0600)
 }
 
-// reloadConfig requests that FRR reloads the configuration file. This is
-// called after updating the configuration.
-var reloadConfig = func() error {
-	pidFile, found := os.LookupEnv("FRR_RELOADER_PID_FILE")
-	if found {
-		reloaderPidFileName = pidFile
-	}
-
-	pid, err := os.ReadFile(reloaderPidFileName)
-	if err != nil {
-		return err
-	}
-
-	pidInt, err := strconv.Atoi(string(pid))
-	if err != nil {
-		return err
-	}
-
-	// send HUP signal to FRR reloader
-	err = syscall.Kill(pidInt, syscall.SIGHUP)
-	if err != nil {
-		return err
-	}
+// reloadConfig requests that FRR reloads the given configuration, and
+// returns the result of the reload. This is called after updating the
+// configuration.
+var reloadConfig = func(config string) (*ReloadResult, error) {
+	res := &ReloadResult{}
+	err := callReloader(ReloadPath, ReloadRequest{Config: config}, res)
+	return res, err
+}
 
-	return nil
+// reloadChanges requests that FRR applies the given vtysh commands, and
+// returns the result. It is called instead of reloadConfig when the
+// configuration can be updated incrementally.
+var reloadChanges = func(commands string) (*StepResult, error) {
+	res := &StepResult{}
+	err := callReloader(ChangesPath, ChangesRequest{Commands: commands}, res)
+	return res, err
 }
 
 // generateAndReloadConfigFile takes a 'struct frrConfig' and, using a template,
 // generates and writes a valid FRR configuration file. If this completes
 // successfully it will also force FRR to reload that configuration file.
-func generateAndReloadConfigFile(config *frrConfig, l log.Logger) error {
+// When the configuration last applied to FRR differs from the new one only
+// in the advertisements, only the changes are applied, and the whole file is
+// reloaded if that fails.
+func generateAndReloadConfigFile(config, applied *frrConfig, l log.Logger) error {
 	filename, found := os.LookupEnv("FRR_CONFIG_FILE")
 	if found {
 		configFileName = filename
@@ -221,17 +305,59 @@ func generateAndReloadConfigFile(config *frrConfig, l log.Logger) error {
 		return err
 	}
 
-	err = reloadConfig()
+	if applied != nil {
+		err = applyChanges(applied, config, l)
+		if err == nil {
+			return nil
+		}
+		level.Debug(l).Log("op", "reload", "action", "reload config", "reason", err)
+	}
+
+	res, err := reloadConfig(configString)
+	if err != nil {
+		level.Error(l).Log("op", "reload", "error", err, "cause", "reload", "config", config)
+		return err
+	}
+	err = res.Err()
+	stats.reloaded(reloadKindFull, err == nil)
 	if err != nil {
 		level.Error(l).Log("op", "reload", "error", err, "cause", "reload", "config", config)
 		return err
 	}
+	level.Info(l).Log("op", "reload", "action", "reloaded config", "seconds", res.durationSeconds())
+	return nil
+}
+
+// applyChanges applies incrementally the changes from the applied
+// configuration to the given one.
+func applyChanges(applied, config *frrConfig, l log.Logger) error {
+	commands, ok, err := incrementalCommands(applied, config)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the changes can't be applied incrementally")
+	}
+	if commands == "" {
+		// The configuration is reloaded again after a failure.
+		return errors.New("no changes to apply")
+	}
+	res, err := reloadChanges(commands)
+	if err != nil {
+		return err
+	}
+	stats.reloaded(reloadKindChanges, res.Success)
+	if !res.Success {
+		return fmt.Errorf("failed to apply changes: %s", res.Output)
+	}
+	level.Info(l).Log("op", "reload", "action", "applied changes", "seconds", res.DurationSeconds)
 	return nil
 }
 
 // debouncer takes a function that processes an frrConfig, a channel where
 // the update requests are sent, and squashes any requests coming in a given timeframe
-// as a single request.
+// as a single request. The function runs in the background, so the requests keep
+// being accepted while the configuration is reloaded.
 func debouncer(body func(config *frrConfig) error,
 	reload <-chan reloadEvent,
 	reloadInterval time.Duration,
@@ -241,6 +367,10 @@ func debouncer(body func(config *frrConfig) error,
 		var config *frrConfig
 		var timeOut <-chan time.Time
 		timerSet := false
+		// done is set while body runs, and pending tells if the
+		// config changed in the meantime.
+		var done chan error
+		pending := false
 		for {
 			select {
 			case newCfg, ok := <-reload:
@@ -258,18 +388,31 @@ func debouncer(body func(config *frrConfig) error,
 				if !newCfg.useOld {
 					config = newCfg.config
 				}
+				if done != nil {
+					pending = true
+					continue
+				}
 				if !timerSet {
 					timeOut = time.After(reloadInterval)
 					timerSet = true
 				}
 			case <-timeOut:
-				err := body(config)
-				if err != nil {
+				timerSet = false
+				done = make(chan error, 1)
+				go func(config *frrConfig, done chan<- error) {
+					done <- body(config)
+				}(config, done)
+			case err := <-done:
+				done = nil
+				switch {
+				case err != nil:
 					timeOut = time.After(failureRetryInterval)
 					timerSet = true
-					continue
+				case pending:
+					timeOut = time.After(reloadInterval)
+					timerSet = true
 				}
-				timerSet = false
+				pending = false
 			}
 		}
 	}()
@@ -64,7 +64,25 @@ func TestMain(m *testing.M) {
 
 	// override reloadConfig so it doesn't try to reload it.
 	debounceTimeout = time.Millisecond
-	reloadConfig = func() error { return nil }
+	reloadConfig = func(string) (*ReloadResult, error) {
+		return &ReloadResult{Test: StepResult{Success: true}, Reload: &StepResult{Success: true}}, nil
+	}
+	// check the snippets against the FRR running in the container.
+	dryRunConfig = func(config string) (*ReloadResult, error) {
+		fileName := filepath.Join(frrDir, "dryrun.conf")
+		if err := os.WriteFile(fileName, []byte(config), 0644); err != nil {
+			return nil, err
+		}
+		err := testFileIsValid(fileName)
+		var invalid invalidFileErr
+		if errors.As(err, &invalid) {
+			return &ReloadResult{Test: StepResult{Success: false, Output: invalid.Reason}}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &ReloadResult{Test: StepResult{Success: true}}, nil
+	}
 
 	retCode := m.Run()
 	// You can't defer this because os.Exit doesn't care for defer
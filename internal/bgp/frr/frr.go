@@ -8,7 +8,6 @@ import (
 	"os"
 	"sort"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -19,24 +18,30 @@ import (
 	metallbconfig "go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/ospf"
 )
 
 // As the MetalLB controller should handle messages synchronously, there should
 // no need to lock this data structure. TODO: confirm this.
 
 type sessionManager struct {
-	sessions     map[string]*session
-	bfdProfiles  []BFDProfile
-	extraConfig  string
-	reloadConfig chan reloadEvent
-	logLevel     string
+	sessions      map[string]*session
+	bfdProfiles   []BFDProfile
+	extraConfig   string
+	snippets      []snippetConfig
+	ospfAreas     []ospfAreaConfig
+	ospfAds       map[string]*ospf.Advertisement // by prefix
+	reloadConfig  chan reloadEvent
+	logLevel      string
+	reportReload  func(error)
+	reportSnippet func(string, error)
 	sync.Mutex
 }
 
 type session struct {
 	bgp.SessionParameters
 	sessionManager *sessionManager
-	advertised     []*bgp.Advertisement
+	advertised     map[string]*bgp.Advertisement // by prefix
 	logger         log.Logger
 }
 
@@ -47,13 +52,25 @@ var osHostname = os.Hostname
 // sessionName() defines the format of the key of the 'sessions' map in
 // the 'frrState' struct.
 func sessionName(s session) string {
-	baseName := fmt.Sprintf("%d@%s-%d@%s", s.PeerASN, s.PeerAddress, s.MyASN, s.SourceAddress)
+	baseName := fmt.Sprintf("%d@%s-%d@%s", s.PeerASN, peerTarget(s.SessionParameters), s.MyASN, s.SourceAddress)
 	if s.VRFName == "" {
 		return baseName
 	}
 	return baseName + "/" + s.VRFName
 }
 
+// peerTarget returns the address of the peer, its interface
+// for unnumbered sessions or the range sessions are accepted from.
+func peerTarget(p bgp.SessionParameters) string {
+	if p.PeerInterface != "" {
+		return p.PeerInterface
+	}
+	if p.ListenRange != "" {
+		return p.ListenRange
+	}
+	return p.PeerAddress
+}
+
 func validate(adv *bgp.Advertisement) error {
 	if len(adv.Communities) > 63 {
 		return fmt.Errorf("max supported communities is 63, got %d", len(adv.Communities))
@@ -69,13 +86,13 @@ func (s *session) Set(advs ...*bgp.Advertisement) error {
 		return fmt.Errorf("session %s not established before advertisement", sessionName)
 	}
 
-	newAdvs := []*bgp.Advertisement{}
+	newAdvs := map[string]*bgp.Advertisement{}
 	for _, adv := range advs {
 		err := validate(adv)
 		if err != nil {
 			return err
 		}
-		newAdvs = append(newAdvs, adv)
+		newAdvs[adv.Prefix.String()] = adv
 	}
 	oldAdvs := s.advertised
 	s.advertised = newAdvs
@@ -91,6 +108,75 @@ func (s *session) Set(advs ...*bgp.Advertisement) error {
 	return nil
 }
 
+func (s *session) Update(add []*bgp.Advertisement, withdraw []*net.IPNet) error {
+	s.sessionManager.Lock()
+	defer s.sessionManager.Unlock()
+	sessionName := sessionName(*s)
+	if _, found := s.sessionManager.sessions[sessionName]; !found {
+		return fmt.Errorf("session %s not established before advertisement", sessionName)
+	}
+
+	for _, adv := range add {
+		if err := validate(adv); err != nil {
+			return err
+		}
+	}
+
+	// The previous advertisements of the changed prefixes, to restore
+	// them if the new config can't be created.
+	oldAdvs := map[string]*bgp.Advertisement{}
+	for _, pfx := range withdraw {
+		prefix := pfx.String()
+		oldAdvs[prefix] = s.advertised[prefix]
+		delete(s.advertised, prefix)
+	}
+	for _, adv := range add {
+		prefix := adv.Prefix.String()
+		if _, ok := oldAdvs[prefix]; !ok {
+			oldAdvs[prefix] = s.advertised[prefix]
+		}
+		s.advertised[prefix] = adv
+	}
+
+	config, err := s.sessionManager.createConfig()
+	if err != nil {
+		for prefix, adv := range oldAdvs {
+			if adv == nil {
+				delete(s.advertised, prefix)
+				continue
+			}
+			s.advertised[prefix] = adv
+		}
+		return err
+	}
+
+	s.sessionManager.reloadConfig <- reloadEvent{config: config}
+	return nil
+}
+
+// SetPasswords changes the TCP MD5 password of the session. FRR can only
+// authenticate a session with a single password, and establishes the
+// session again when it changes. The next password is ignored, the
+// session is established again once it replaces the current one.
+func (s *session) SetPasswords(password, _ string) error {
+	s.sessionManager.Lock()
+	defer s.sessionManager.Unlock()
+	if password == s.Password {
+		return nil
+	}
+	oldPassword := s.Password
+	s.Password = password
+
+	config, err := s.sessionManager.createConfig()
+	if err != nil {
+		s.Password = oldPassword
+		return err
+	}
+	level.Info(s.logger).Log("event", "passwordChanged", "msg", "applying the new password, the session will be established again")
+	s.sessionManager.reloadConfig <- reloadEvent{config: config}
+	return nil
+}
+
 // Close() shuts down the BGP session.
 func (s *session) Close() error {
 	s.sessionManager.Lock()
@@ -117,8 +203,8 @@ func (sm *sessionManager) NewSession(l log.Logger, args bgp.SessionParameters) (
 	sm.Lock()
 	defer sm.Unlock()
 	s := &session{
-		logger:            log.With(l, "peer", args.PeerAddress, "localASN", args.MyASN, "peerASN", args.PeerASN),
-		advertised:        []*bgp.Advertisement{},
+		logger:            log.With(l, "peer", peerTarget(args), "localASN", args.MyASN, "peerASN", args.PeerASN),
+		advertised:        map[string]*bgp.Advertisement{},
 		sessionManager:    sm,
 		SessionParameters: args,
 	}
@@ -168,6 +254,26 @@ func (sm *sessionManager) SyncExtraInfo(extraInfo string) error {
 	return nil
 }
 
+func (sm *sessionManager) SyncSnippets(snippets map[string]string) error {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.snippets = make([]snippetConfig, 0, len(snippets))
+	for name, config := range snippets {
+		sm.snippets = append(sm.snippets, snippetConfig{Name: name, Config: config})
+	}
+	sort.Slice(sm.snippets, func(i, j int) bool {
+		return sm.snippets[i].Name < sm.snippets[j].Name
+	})
+
+	frrConfig, err := sm.createConfig()
+	if err != nil {
+		return err
+	}
+
+	sm.reloadConfig <- reloadEvent{config: frrConfig}
+	return nil
+}
+
 func (sm *sessionManager) SyncBFDProfiles(profiles map[string]*metallbconfig.BFDProfile) error {
 	sm.Lock()
 	defer sm.Unlock()
@@ -199,6 +305,8 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		Hostname:    hostname,
 		Loglevel:    sm.logLevel,
 		BFDProfiles: sm.bfdProfiles,
+		Snippets:    sm.snippets,
+		OSPF:        ospfConfigFor(sm.ospfAreas, sm.ospfAds),
 		ExtraConfig: sm.extraConfig,
 	}
 
@@ -213,6 +321,34 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 
 	routers := make(map[string]*router)
 
+	// The prefixes leaked into other VRFs, by the name of the VRF.
+	type leak struct {
+		vrf          string
+		myASN        uint32
+		routerID     string
+		vrfs         map[string]string
+		ipV4Prefixes map[string]string
+		ipV6Prefixes map[string]string
+		// How the leaked prefixes are announced to the peers of
+		// the VRF, by prefix.
+		advs map[string]*advertisementConfig
+	}
+
+	leaks := make(map[string]*leak)
+
+	// The prefixes originated as EVPN type-5 routes, by the name of the VRF.
+	type evpn struct {
+		vrf          string
+		vni          uint32
+		routeTargets []string
+		myASN        uint32
+		routerID     string
+		ipV4Prefixes map[string]string
+		ipV6Prefixes map[string]string
+	}
+
+	evpns := make(map[string]*evpn)
+
 	// leave it for backward compatibility
 	frrLogLevel, found := os.LookupEnv("FRR_LOGGING_LEVEL")
 	if found {
@@ -239,36 +375,72 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			routers[routerName] = rout
 		}
 
-		neighborName := neighborName(s.PeerAddress, s.PeerASN, s.VRFName)
+		neighborName := neighborName(peerTarget(s.SessionParameters), s.PeerASN, s.VRFName)
 		if neighbor, exist = rout.neighbors[neighborName]; !exist {
-			host, port, err := net.SplitHostPort(s.PeerAddress)
-			if err != nil {
-				return nil, err
+			// Unnumbered sessions are established over an interface,
+			// using the link local address of the peer on both families.
+			host := s.PeerInterface
+			family := ipfamily.DualStack
+			var portUint uint64
+			// Sessions accepted from a range are configured via a
+			// peer group named after the session.
+			if s.ListenRange != "" {
+				_, cidr, err := net.ParseCIDR(s.ListenRange)
+				if err != nil {
+					return nil, err
+				}
+				host = s.SessionName
+				family = ipfamily.ForCIDR(cidr)
 			}
+			if s.PeerInterface == "" && s.ListenRange == "" {
+				var port string
+				var err error
+				host, port, err = net.SplitHostPort(s.PeerAddress)
+				if err != nil {
+					return nil, err
+				}
 
-			portUint, err := strconv.ParseUint(port, 10, 16)
-			if err != nil {
-				return nil, err
-			}
+				portUint, err = strconv.ParseUint(port, 10, 16)
+				if err != nil {
+					return nil, err
+				}
 
-			family := ipfamily.ForAddress(net.ParseIP(host))
+				family = ipfamily.ForAddress(net.ParseIP(host))
+			}
 
 			neighbor = &neighborConfig{
-				IPFamily:       family,
-				ASN:            s.PeerASN,
-				Addr:           host,
-				Port:           uint16(portUint),
-				HoldTime:       uint64(s.HoldTime / time.Second),
-				KeepaliveTime:  uint64(s.KeepAliveTime / time.Second),
-				Password:       s.Password,
-				Advertisements: make([]*advertisementConfig, 0),
-				BFDProfile:     s.BFDProfile,
-				EBGPMultiHop:   s.EBGPMultiHop,
-				VRFName:        s.VRFName,
+				IPFamily:        family,
+				Name:            s.SessionName,
+				ASN:             s.PeerASN,
+				Addr:            host,
+				Unnumbered:      s.PeerInterface != "",
+				ListenRange:     s.ListenRange,
+				Passive:         s.Passive,
+				Port:            uint16(portUint),
+				HoldTime:        uint64(s.HoldTime / time.Second),
+				KeepaliveTime:   uint64(s.KeepAliveTime / time.Second),
+				Password:        s.Password,
+				Advertisements:  make([]*advertisementConfig, 0),
+				BFDProfile:      s.BFDProfile,
+				EBGPMultiHop:    s.EBGPMultiHop,
+				EBGPMultiHopTTL: s.EBGPMultiHopTTL,
+				TTLSecurityHops: s.TTLSecurityHops,
+				VRFName:         s.VRFName,
+				RawConfig:       s.RawFRRConfig,
 			}
 			if s.SourceAddress != nil {
 				neighbor.SrcAddr = s.SourceAddress.String()
 			}
+			if s.Receive != nil {
+				neighbor.ReceiveAll = s.Receive.Mode == metallbconfig.ReceiveAll
+				for _, p := range s.Receive.Prefixes {
+					if p.IP.To4() != nil {
+						neighbor.ReceiveV4Prefixes = append(neighbor.ReceiveV4Prefixes, p.String())
+						continue
+					}
+					neighbor.ReceiveV6Prefixes = append(neighbor.ReceiveV6Prefixes, p.String())
+				}
+			}
 			rout.neighbors[neighborName] = neighbor
 		}
 
@@ -281,6 +453,36 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 			}
 
 			family := ipfamily.ForAddress(adv.Prefix.IP)
+			prefix := adv.Prefix.String()
+
+			// EVPN prefixes are originated only by the router of their VRF,
+			// and announced only to the peers of the default VRF.
+			if adv.EVPN != nil {
+				if s.VRFName != "" {
+					continue
+				}
+				e, ok := evpns[adv.EVPN.VRF]
+				if !ok {
+					e = &evpn{
+						vrf:          adv.EVPN.VRF,
+						vni:          adv.EVPN.VNI,
+						routeTargets: adv.EVPN.RouteTargets,
+						myASN:        rout.myASN,
+						routerID:     rout.routerID,
+						ipV4Prefixes: make(map[string]string),
+						ipV6Prefixes: make(map[string]string),
+					}
+					evpns[adv.EVPN.VRF] = e
+				}
+				switch family {
+				case ipfamily.IPv4:
+					e.ipV4Prefixes[prefix] = prefix
+				case ipfamily.IPv6:
+					e.ipV6Prefixes[prefix] = prefix
+				}
+				neighbor.EVPN = true
+				continue
+			}
 
 			communities := make([]string, 0)
 			largeCommunities := make([]string, 0)
@@ -294,13 +496,28 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				communities = append(communities, c.String())
 			}
 
-			prefix := adv.Prefix.String()
 			advConfig := advertisementConfig{
 				IPFamily:         family,
 				Prefix:           prefix,
 				Communities:      sort.StringSlice(communities),
 				LargeCommunities: sort.StringSlice(largeCommunities),
 				LocalPref:        adv.LocalPref,
+				LinkBandwidth:    adv.LinkBandwidth,
+				MED:              adv.MED,
+			}
+			// FRR accepts link bandwidth values up to 25600 Mbps.
+			if advConfig.LinkBandwidth > maxLinkBandwidth {
+				advConfig.LinkBandwidth = maxLinkBandwidth
+			}
+			// The AS path is prepended only towards EBGP peers.
+			if adv.ASPathPrependCount > 0 && s.MyASN != s.PeerASN {
+				advConfig.ASPathPrepend = asPathPrepend{
+					ASN:   adv.ASPathPrependASN,
+					Count: adv.ASPathPrependCount,
+				}
+				if advConfig.ASPathPrepend.ASN == 0 {
+					advConfig.ASPathPrepend.ASN = s.MyASN
+				}
 			}
 
 			neighbor.Advertisements = append(neighbor.Advertisements, &advConfig)
@@ -312,10 +529,78 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 				rout.ipV6Prefixes[prefix] = prefix
 				neighbor.HasV6Advertisements = true
 			}
+
+			for _, vrf := range adv.LeakToVRFs {
+				if vrf == defaultVRF {
+					vrf = ""
+				}
+				if vrf == s.VRFName {
+					continue
+				}
+				l, ok := leaks[vrf]
+				if !ok {
+					l = &leak{
+						vrf:          vrf,
+						myASN:        rout.myASN,
+						routerID:     rout.routerID,
+						vrfs:         make(map[string]string),
+						ipV4Prefixes: make(map[string]string),
+						ipV6Prefixes: make(map[string]string),
+						advs:         make(map[string]*advertisementConfig),
+					}
+					leaks[vrf] = l
+				}
+				if _, ok := l.advs[prefix]; !ok {
+					// The AS path is prepended depending on the
+					// type of the peer it is announced to.
+					leaked := advConfig
+					leaked.ASPathPrepend = asPathPrepend{}
+					l.advs[prefix] = &leaked
+				}
+				source := s.VRFName
+				if source == "" {
+					source = defaultVRF
+				}
+				l.vrfs[source] = source
+				switch family {
+				case ipfamily.IPv4:
+					l.ipV4Prefixes[prefix] = prefix
+				case ipfamily.IPv6:
+					l.ipV6Prefixes[prefix] = prefix
+				}
+			}
 		}
 		sortAdvertiesements(neighbor.Advertisements)
 	}
 
+	// The leaked prefixes are announced to the peers of the target VRF.
+	for _, r := range routers {
+		l, ok := leaks[r.vrf]
+		if !ok {
+			continue
+		}
+		for _, n := range r.neighbors {
+			announced := make(map[string]bool, len(n.Advertisements))
+			for _, a := range n.Advertisements {
+				announced[a.Prefix] = true
+			}
+			for _, leaked := range sortMap(l.advs) {
+				if announced[leaked.Prefix] {
+					continue
+				}
+				a := *leaked
+				n.Advertisements = append(n.Advertisements, &a)
+				switch a.IPFamily {
+				case ipfamily.IPv4:
+					n.HasV4Advertisements = true
+				case ipfamily.IPv6:
+					n.HasV6Advertisements = true
+				}
+			}
+			sortAdvertiesements(n.Advertisements)
+		}
+	}
+
 	for _, r := range sortMap(routers) {
 		toAdd := &routerConfig{
 			MyASN:        r.myASN,
@@ -327,9 +612,66 @@ func (sm *sessionManager) createConfig() (*frrConfig, error) {
 		}
 		config.Routers = append(config.Routers, toAdd)
 	}
+
+	// The router of a VRF is created when no session lives in it.
+	// FRR allows only one router per VRF.
+	vrfRouter := func(vrf string, myASN uint32, routerID string) *routerConfig {
+		for _, r := range config.Routers {
+			if r.VRF == vrf {
+				return r
+			}
+		}
+		r := &routerConfig{
+			MyASN:    myASN,
+			RouterID: routerID,
+			VRF:      vrf,
+		}
+		config.Routers = append(config.Routers, r)
+		return r
+	}
+
+	// The leaked prefixes are imported by the router of the target VRF.
+	for _, l := range sortMap(leaks) {
+		target := vrfRouter(l.vrf, l.myASN, l.routerID)
+		target.ImportVRFs = sortMap(l.vrfs)
+		target.ImportV4Prefixes = sortMap(l.ipV4Prefixes)
+		target.ImportV6Prefixes = sortMap(l.ipV6Prefixes)
+	}
+
+	// The EVPN prefixes are originated by the router of their VRF, which
+	// advertises them as type-5 routes.
+	for _, e := range sortMap(evpns) {
+		target := vrfRouter(e.vrf, e.myASN, e.routerID)
+		target.EVPN = &evpnConfig{
+			VNI:           e.vni,
+			RouteTargets:  e.routeTargets,
+			HasV4Prefixes: len(e.ipV4Prefixes) > 0,
+			HasV6Prefixes: len(e.ipV6Prefixes) > 0,
+		}
+		target.IPV4Prefixes = mergePrefixes(target.IPV4Prefixes, e.ipV4Prefixes)
+		target.IPV6Prefixes = mergePrefixes(target.IPV6Prefixes, e.ipV6Prefixes)
+		config.VRFs = append(config.VRFs, vrfConfig{Name: e.vrf, VNI: e.vni})
+	}
 	return config, nil
 }
 
+// mergePrefixes returns the sorted union of the given prefixes.
+func mergePrefixes(prefixes []string, toAdd map[string]string) []string {
+	res := make(map[string]string, len(prefixes)+len(toAdd))
+	for _, p := range prefixes {
+		res[p] = p
+	}
+	for p := range toAdd {
+		res[p] = p
+	}
+	return sortMap(res)
+}
+
+// defaultVRF is the name of the default VRF in the advertisements.
+const defaultVRF = "default"
+
+const maxLinkBandwidth = 25600
+
 var debounceTimeout = 3 * time.Second
 var failureTimeout = time.Second * 5
 
@@ -340,13 +682,7 @@ func NewSessionManager(l log.Logger, logLevel logging.Level) bgp.SessionManager
 		reloadConfig: make(chan reloadEvent),
 		logLevel:     logLevelToFRR(logLevel),
 	}
-	reload := func(config *frrConfig) error {
-		return generateAndReloadConfigFile(config, l)
-	}
-
-	debouncer(reload, res.reloadConfig, debounceTimeout, failureTimeout, l)
-
-	reloadValidator(l, res.reloadConfig)
+	debouncer(res.reloadFunc(l), res.reloadConfig, debounceTimeout, failureTimeout, l)
 
 	return res
 }
@@ -358,61 +694,55 @@ func mockNewSessionManager(l log.Logger, logLevel logging.Level) *sessionManager
 		reloadConfig: make(chan reloadEvent),
 		logLevel:     logLevelToFRR(logLevel),
 	}
-	reload := func(config *frrConfig) error {
-		return generateAndReloadConfigFile(config, l)
-	}
-
-	debouncer(reload, res.reloadConfig, debounceTimeout, failureTimeout, l)
-
-	reloadValidator(l, res.reloadConfig)
+	debouncer(res.reloadFunc(l), res.reloadConfig, debounceTimeout, failureTimeout, l)
 
 	return res
 }
 
-func reloadValidator(l log.Logger, reload chan<- reloadEvent) {
-	var tickerIntervals = 30 * time.Second
-	var prevReloadTimeStamp string
-
-	ticker := time.NewTicker(tickerIntervals)
-	go func() {
-		for range ticker.C {
-			validateReload(l, &prevReloadTimeStamp, reload)
+// reloadFunc returns the function the debouncer reloads the configuration
+// with.
+func (sm *sessionManager) reloadFunc(l log.Logger) func(config *frrConfig) error {
+	// applied is the configuration last applied to FRR, only accessed
+	// by the debouncer, as well as the validator of the snippets.
+	var applied *frrConfig
+	validator := &snippetValidator{}
+	// failing tells if the last reload failed, so only the changes of
+	// the state of the reloads are reported.
+	failing := false
+	return func(config *frrConfig) error {
+		sm.Lock()
+		report, reportSnippet := sm.reportReload, sm.reportSnippet
+		sm.Unlock()
+
+		config, err := validator.validate(config, reportSnippet, l)
+		if err == nil {
+			err = generateAndReloadConfigFile(config, applied, l)
 		}
-	}()
-}
-
-const statusFileName = "/etc/frr_reloader/.status"
-
-func validateReload(l log.Logger, prevReloadTimeStamp *string, reload chan<- reloadEvent) {
-	bytes, err := os.ReadFile(statusFileName)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			level.Error(l).Log("op", "reload-validate", "error", err, "cause", "readFile", "fileName", statusFileName)
+		if report != nil && (err != nil) != failing {
+			report(err)
 		}
-		return
-	}
-
-	lastReloadStatus := strings.Fields(string(bytes))
-	if len(lastReloadStatus) != 2 {
-		level.Error(l).Log("op", "reload-validate", "error", err, "cause", "Fields", "bytes", string(bytes))
-		return
-	}
-
-	timeStamp, status := lastReloadStatus[0], lastReloadStatus[1]
-	if timeStamp == *prevReloadTimeStamp {
-		return
+		failing = err != nil
+		if err != nil {
+			applied = nil
+			return err
+		}
+		applied = config
+		return nil
 	}
+}
 
-	*prevReloadTimeStamp = timeStamp
-
-	if strings.Compare(status, "failure") == 0 {
-		level.Error(l).Log("op", "reload-validate", "error", fmt.Errorf("reload failure"),
-			"cause", "frr reload failed", "status", status)
-		reload <- reloadEvent{useOld: true}
-		return
-	}
+// ReportReloads implements bgp.ReloadReporter.
+func (sm *sessionManager) ReportReloads(report func(error)) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.reportReload = report
+}
 
-	level.Info(l).Log("op", "reload-validate", "success", "reloaded config")
+// ReportSnippets implements bgp.ReloadReporter.
+func (sm *sessionManager) ReportSnippets(report func(string, error)) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.reportSnippet = report
 }
 
 func configBFDProfileToFRR(p *metallbconfig.BFDProfile) *BFDProfile {
@@ -471,6 +801,21 @@ func sortAdvertiesements(toSort []*advertisementConfig) {
 		if toSort[i].LocalPref != toSort[j].LocalPref {
 			return toSort[i].LocalPref < toSort[j].LocalPref
 		}
+		if toSort[i].LinkBandwidth != toSort[j].LinkBandwidth {
+			return toSort[i].LinkBandwidth < toSort[j].LinkBandwidth
+		}
+		if toSort[i].ASPathPrepend != toSort[j].ASPathPrepend {
+			if toSort[i].ASPathPrepend.ASN != toSort[j].ASPathPrepend.ASN {
+				return toSort[i].ASPathPrepend.ASN < toSort[j].ASPathPrepend.ASN
+			}
+			return toSort[i].ASPathPrepend.Count < toSort[j].ASPathPrepend.Count
+		}
+		if (toSort[i].MED == nil) != (toSort[j].MED == nil) {
+			return toSort[i].MED == nil
+		}
+		if toSort[i].MED != nil && *toSort[i].MED != *toSort[j].MED {
+			return *toSort[i].MED < *toSort[j].MED
+		}
 		if len(toSort[i].Communities) != len(toSort[j].Communities) {
 			return len(toSort[i].Communities) < len(toSort[j].Communities)
 		}
@@ -10,6 +10,7 @@ import (
 	"github.com/go-kit/log"
 	"go.universe.tf/metallb/internal/bgp"
 	"go.universe.tf/metallb/internal/bgp/community"
+	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/logging"
 )
 
@@ -654,3 +655,273 @@ func TestTwoAdvertisementsTwoSessionsVRFWithPeerSelector(t *testing.T) {
 
 	testCheckConfigFile(t)
 }
+
+func TestAdvertisementLeakedToDefaultVRF(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			VRFName:       "red"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: classCMask,
+		},
+		LeakToVRFs: []string{"default"},
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		LeakToVRFs: []string{"default"},
+	}
+
+	err = session.Set(adv1, adv2)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementLeakedToVRFs(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	session1, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.3.3.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       300,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer1",
+			VRFName:       "red"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session1.Close()
+
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: classCMask,
+		},
+		LeakToVRFs: []string{"blue", "red"},
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.2.10"),
+			Mask: classCMask,
+		},
+	}
+
+	err = session.Set(adv1, adv2)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementLeakedToPeersOfVRF(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer",
+			VRFName:       "red"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	session1, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.3.3.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       300,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer1"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session1.Close()
+
+	community1, _ := community.New("1111:2222")
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: classCMask,
+		},
+		Communities: []community.BGPCommunity{community1},
+		LeakToVRFs:  []string{"default"},
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		LeakToVRFs: []string{"default"},
+	}
+
+	err = session.Set(adv1, adv2)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementEVPN(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	evpn := &config.EVPNAdvertisement{
+		VRF:          "red",
+		VNI:          100,
+		RouteTargets: []string{"100:100"},
+	}
+	adv1 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: classCMask,
+		},
+		EVPN: evpn,
+	}
+	adv2 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("2001:db8::10"),
+			Mask: net.CIDRMask(128, 128),
+		},
+		EVPN: evpn,
+	}
+	adv3 := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.2.10"),
+			Mask: classCMask,
+		},
+	}
+
+	err = session.Set(adv1, adv2, adv3)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestAdvertisementEVPNSingleFamily(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+	session, err := sessionManager.NewSession(l,
+		bgp.SessionParameters{
+			PeerAddress:   "10.2.2.254:179",
+			SourceAddress: net.ParseIP("10.1.1.254"),
+			MyASN:         100,
+			RouterID:      net.ParseIP("10.1.1.254"),
+			PeerASN:       200,
+			HoldTime:      time.Second,
+			KeepAliveTime: time.Second,
+			CurrentNode:   "hostname",
+			SessionName:   "test-peer"})
+	if err != nil {
+		t.Fatalf("Could not create session: %s", err)
+	}
+	defer session.Close()
+
+	evpn := &config.EVPNAdvertisement{
+		VRF:          "red",
+		VNI:          100,
+		RouteTargets: []string{"100:100"},
+	}
+	adv := &bgp.Advertisement{
+		Prefix: &net.IPNet{
+			IP:   net.ParseIP("172.16.1.10"),
+			Mask: classCMask,
+		},
+		EVPN: evpn,
+	}
+
+	err = session.Set(adv)
+	if err != nil {
+		t.Fatalf("Could not advertise prefix: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
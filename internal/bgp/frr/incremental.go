@@ -0,0 +1,199 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// incrementalCommands returns the vtysh commands turning the running
+// configuration from old into new, and whether the changes between them
+// can be applied without reloading the whole configuration. Only the
+// changes to the advertisements can: announcing or withdrawing a service
+// is by far the most frequent change, and it touches only the prefix
+// lists and route maps of the neighbors and the networks of the routers.
+func incrementalCommands(old, new *frrConfig) (string, bool, error) {
+	if !reflect.DeepEqual(withoutAdvertisements(old), withoutAdvertisements(new)) {
+		return "", false, nil
+	}
+
+	var commands []string
+	for i, r := range new.Routers {
+		for j, n := range r.Neighbors {
+			o := old.Routers[i].Neighbors[j]
+			if reflect.DeepEqual(o, n) {
+				continue
+			}
+			oldFilters, err := renderFilters(old.Routers[i], o)
+			if err != nil {
+				return "", false, err
+			}
+			newFilters, err := renderFilters(r, n)
+			if err != nil {
+				return "", false, err
+			}
+			commands = append(commands, filterCommands(oldFilters, newFilters)...)
+		}
+		commands = append(commands, networkCommands(old.Routers[i], r)...)
+	}
+	if len(commands) == 0 {
+		return "", true, nil
+	}
+	return strings.Join(commands, "\n") + "\n", true, nil
+}
+
+// withoutAdvertisements returns a copy of the given configuration
+// without anything depending on the advertisements. The prefixes leaked
+// into other VRFs are kept, so changing them reloads the whole
+// configuration.
+func withoutAdvertisements(c *frrConfig) *frrConfig {
+	res := *c
+	res.Routers = make([]*routerConfig, len(c.Routers))
+	for i, r := range c.Routers {
+		router := *r
+		router.IPV4Prefixes, router.IPV6Prefixes = nil, nil
+		router.Neighbors = make([]*neighborConfig, len(r.Neighbors))
+		for j, n := range r.Neighbors {
+			neighbor := *n
+			neighbor.Advertisements = nil
+			neighbor.HasV4Advertisements, neighbor.HasV6Advertisements = false, false
+			router.Neighbors[j] = &neighbor
+		}
+		res.Routers[i] = &router
+	}
+	return &res
+}
+
+// filters are the prefix lists and route maps of a neighbor.
+type filters struct {
+	prefixLists []string
+	routeMaps   []routeMapEntry
+}
+
+type routeMapEntry struct {
+	header string
+	lines  []string
+}
+
+func (e routeMapEntry) equal(other routeMapEntry) bool {
+	return e.header == other.header && reflect.DeepEqual(e.lines, other.lines)
+}
+
+// renderFilters renders the filters of the given neighbor, the same way
+// they are rendered in the whole configuration.
+func renderFilters(r *routerConfig, n *neighborConfig) (filters, error) {
+	rendered, err := renderTemplate("neighborfilters", map[string]interface{}{"neighbor": n, "router": r})
+	if err != nil {
+		return filters{}, err
+	}
+
+	res := filters{}
+	var entry *routeMapEntry
+	for _, l := range strings.Split(rendered, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+		case strings.HasPrefix(l, "route-map "):
+			res.routeMaps = append(res.routeMaps, routeMapEntry{header: l})
+			entry = &res.routeMaps[len(res.routeMaps)-1]
+		case strings.HasPrefix(l, "ip prefix-list ") || strings.HasPrefix(l, "ipv6 prefix-list "):
+			res.prefixLists = append(res.prefixLists, l)
+			entry = nil
+		case entry != nil:
+			entry.lines = append(entry.lines, l)
+		default:
+			return filters{}, fmt.Errorf("unexpected filter line %q", l)
+		}
+	}
+	return res, nil
+}
+
+// filterCommands returns the commands turning the old filters into the
+// new ones. The changed entries are removed and added again: FRR
+// processes the changes to the prefix lists and route maps after a delay,
+// so the routes are not withdrawn in the meantime.
+func filterCommands(old, new filters) []string {
+	var res []string
+	for _, e := range old.routeMaps {
+		if n, ok := findRouteMapEntry(new.routeMaps, e.header); !ok || !n.equal(e) {
+			res = append(res, "no "+e.header)
+		}
+	}
+	for _, l := range difference(old.prefixLists, new.prefixLists) {
+		res = append(res, "no "+l)
+	}
+	res = append(res, difference(new.prefixLists, old.prefixLists)...)
+	for _, e := range new.routeMaps {
+		if o, ok := findRouteMapEntry(old.routeMaps, e.header); ok && o.equal(e) {
+			continue
+		}
+		res = append(res, e.header)
+		for _, l := range e.lines {
+			res = append(res, "  "+l)
+		}
+		res = append(res, "exit")
+	}
+	return res
+}
+
+func findRouteMapEntry(entries []routeMapEntry, header string) (routeMapEntry, bool) {
+	for _, e := range entries {
+		if e.header == header {
+			return e, true
+		}
+	}
+	return routeMapEntry{}, false
+}
+
+// networkCommands returns the commands turning the networks of the old
+// router into the ones of the new router.
+func networkCommands(old, new *routerConfig) []string {
+	var res []string
+	for _, f := range []struct {
+		family   string
+		old, new []string
+	}{
+		{"ipv4", old.IPV4Prefixes, new.IPV4Prefixes},
+		{"ipv6", old.IPV6Prefixes, new.IPV6Prefixes},
+	} {
+		removed, added := difference(f.old, f.new), difference(f.new, f.old)
+		if len(removed) == 0 && len(added) == 0 {
+			continue
+		}
+		res = append(res, fmt.Sprintf("  address-family %s unicast", f.family))
+		for _, p := range removed {
+			res = append(res, "    no network "+p)
+		}
+		for _, p := range added {
+			res = append(res, "    network "+p)
+		}
+		res = append(res, "  exit-address-family")
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	router := fmt.Sprintf("router bgp %d", new.MyASN)
+	if new.VRF != "" {
+		router = fmt.Sprintf("%s vrf %s", router, new.VRF)
+	}
+	res = append([]string{router}, res...)
+	return append(res, "exit")
+}
+
+// difference returns the elements of a missing from b, in order.
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var res []string
+	for _, s := range a {
+		if !inB[s] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
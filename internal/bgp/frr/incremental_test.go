@@ -0,0 +1,255 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+func testIncrementalConfig(advs ...*advertisementConfig) *frrConfig {
+	neighbor := &neighborConfig{
+		IPFamily:       ipfamily.IPv4,
+		Name:           "test-peer",
+		ASN:            200,
+		Addr:           "10.2.2.254",
+		Port:           179,
+		HoldTime:       180,
+		KeepaliveTime:  60,
+		Advertisements: advs,
+	}
+	router := &routerConfig{
+		MyASN:     100,
+		RouterID:  "10.1.1.254",
+		Neighbors: []*neighborConfig{neighbor},
+	}
+	for _, a := range advs {
+		if a.IPFamily == ipfamily.IPv6 {
+			neighbor.HasV6Advertisements = true
+			router.IPV6Prefixes = append(router.IPV6Prefixes, a.Prefix)
+			continue
+		}
+		neighbor.HasV4Advertisements = true
+		router.IPV4Prefixes = append(router.IPV4Prefixes, a.Prefix)
+	}
+	return &frrConfig{
+		Loglevel: "informational",
+		Hostname: "dummyhostname",
+		Routers:  []*routerConfig{router},
+	}
+}
+
+func TestIncrementalCommands(t *testing.T) {
+	withCommunity := &advertisementConfig{
+		IPFamily:    ipfamily.IPv4,
+		Prefix:      "172.16.1.10/32",
+		Communities: []string{"1111:2222"},
+	}
+	withLocalPref := &advertisementConfig{
+		IPFamily:  ipfamily.IPv4,
+		Prefix:    "172.16.1.11/32",
+		LocalPref: 200,
+	}
+	v6 := &advertisementConfig{
+		IPFamily: ipfamily.IPv6,
+		Prefix:   "2001:db8::1/128",
+	}
+
+	tests := []struct {
+		desc     string
+		old      *frrConfig
+		new      *frrConfig
+		commands string
+	}{
+		{
+			desc:     "no changes",
+			old:      testIncrementalConfig(withCommunity),
+			new:      testIncrementalConfig(withCommunity),
+			commands: "",
+		},
+		{
+			desc: "first advertisement",
+			old:  testIncrementalConfig(),
+			new:  testIncrementalConfig(withCommunity),
+			commands: `no route-map 10.2.2.254-out permit 1
+no route-map 10.2.2.254-out permit 2
+no ip prefix-list 10.2.2.254-pl-ipv4 seq 1 deny any
+ip prefix-list 10.2.2.254-1111:2222-ipv4-community-prefixes seq 1 permit 172.16.1.10/32
+ip prefix-list 10.2.2.254-pl-ipv4 seq 1 permit 172.16.1.10/32
+route-map 10.2.2.254-out permit 1
+  match ip address prefix-list 10.2.2.254-1111:2222-ipv4-community-prefixes
+  set community 1111:2222 additive
+  on-match next
+exit
+route-map 10.2.2.254-out permit 2
+  match ip address prefix-list 10.2.2.254-pl-ipv4
+exit
+route-map 10.2.2.254-out permit 3
+  match ipv6 address prefix-list 10.2.2.254-pl-ipv4
+exit
+router bgp 100
+  address-family ipv4 unicast
+    network 172.16.1.10/32
+  exit-address-family
+exit
+`,
+		},
+		{
+			desc: "replace an advertisement",
+			old:  testIncrementalConfig(withCommunity, v6),
+			new:  testIncrementalConfig(withLocalPref, v6),
+			commands: `no route-map 10.2.2.254-out permit 1
+no ip prefix-list 10.2.2.254-1111:2222-ipv4-community-prefixes seq 1 permit 172.16.1.10/32
+no ip prefix-list 10.2.2.254-pl-ipv4 seq 1 permit 172.16.1.10/32
+ip prefix-list 10.2.2.254-200-ipv4-localpref-prefixes seq 1 permit 172.16.1.11/32
+ip prefix-list 10.2.2.254-pl-ipv4 seq 1 permit 172.16.1.11/32
+route-map 10.2.2.254-out permit 1
+  match ip address prefix-list 10.2.2.254-200-ipv4-localpref-prefixes
+  set local-preference 200
+  on-match next
+exit
+router bgp 100
+  address-family ipv4 unicast
+    no network 172.16.1.10/32
+    network 172.16.1.11/32
+  exit-address-family
+exit
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			commands, ok, err := incrementalCommands(tc.old, tc.new)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !ok {
+				t.Fatal("expected the changes to be applied incrementally")
+			}
+			if diff := cmp.Diff(tc.commands, commands); diff != "" {
+				t.Fatalf("unexpected commands (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIncrementalCommandsUnsupported(t *testing.T) {
+	adv := &advertisementConfig{
+		IPFamily: ipfamily.IPv4,
+		Prefix:   "172.16.1.10/32",
+	}
+	old := testIncrementalConfig(adv)
+	new := testIncrementalConfig(adv)
+	new.Routers[0].Neighbors[0].HoldTime = 90
+
+	_, ok, err := incrementalCommands(old, new)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Fatal("expected the changes not to be applied incrementally")
+	}
+}
+
+func TestGenerateAndReloadConfigFileChanges(t *testing.T) {
+	oldConfigFileName := configFileName
+	t.Setenv("FRR_CONFIG_FILE", filepath.Join(t.TempDir(), "frr.conf"))
+	oldReloadConfig, oldReloadChanges := reloadConfig, reloadChanges
+	defer func() {
+		configFileName = oldConfigFileName
+		reloadConfig, reloadChanges = oldReloadConfig, oldReloadChanges
+	}()
+
+	var reloads int
+	var changes []string
+	var changesFail bool
+	reloadConfig = func(string) (*ReloadResult, error) {
+		reloads++
+		return &ReloadResult{Test: StepResult{Success: true}, Reload: &StepResult{Success: true}}, nil
+	}
+	reloadChanges = func(commands string) (*StepResult, error) {
+		changes = append(changes, commands)
+		return &StepResult{Success: !changesFail, Output: "output"}, nil
+	}
+
+	adv := &advertisementConfig{
+		IPFamily: ipfamily.IPv4,
+		Prefix:   "172.16.1.10/32",
+	}
+	withoutAdv := testIncrementalConfig()
+	withAdv := testIncrementalConfig(adv)
+	otherHoldTime := testIncrementalConfig(adv)
+	otherHoldTime.Routers[0].Neighbors[0].HoldTime = 90
+
+	tests := []struct {
+		desc        string
+		config      *frrConfig
+		applied     *frrConfig
+		changesFail bool
+		wantReload  bool
+		wantChanges bool
+	}{
+		{
+			desc:       "nothing applied",
+			config:     withAdv,
+			wantReload: true,
+		},
+		{
+			desc:        "advertisement added",
+			config:      withAdv,
+			applied:     withoutAdv,
+			wantChanges: true,
+		},
+		{
+			desc:        "changes fail",
+			config:      withAdv,
+			applied:     withoutAdv,
+			changesFail: true,
+			wantReload:  true,
+			wantChanges: true,
+		},
+		{
+			desc:       "unsupported change",
+			config:     otherHoldTime,
+			applied:    withAdv,
+			wantReload: true,
+		},
+		{
+			desc:       "no change",
+			config:     withAdv,
+			applied:    withAdv,
+			wantReload: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			reloads, changes, changesFail = 0, nil, tc.changesFail
+			err := generateAndReloadConfigFile(tc.config, tc.applied, log.NewNopLogger())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := reloads == 1; got != tc.wantReload {
+				t.Fatalf("expected reload %v, got %d reloads", tc.wantReload, reloads)
+			}
+			if got := len(changes) == 1; got != tc.wantChanges {
+				t.Fatalf("expected changes %v, got %v", tc.wantChanges, changes)
+			}
+			written, err := os.ReadFile(configFileName)
+			if err != nil {
+				t.Fatalf("failed to read the configuration file: %s", err)
+			}
+			expected, err := templateConfig(tc.config)
+			if err != nil {
+				t.Fatalf("failed to template the configuration: %s", err)
+			}
+			if string(written) != expected {
+				t.Fatal("the configuration file was not written")
+			}
+		})
+	}
+}
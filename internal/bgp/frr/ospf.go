@@ -0,0 +1,133 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"fmt"
+	"sort"
+
+	"go.universe.tf/metallb/internal/ipfamily"
+	"go.universe.tf/metallb/internal/ospf"
+)
+
+// ospfConfig is the configuration of OSPF, for both IPv4 and IPv6. The
+// prefixes are announced as static routes to Null0, redistributed as
+// external routes.
+type ospfConfig struct {
+	Areas          []ospfAreaConfig
+	V4Routes       []string
+	V6Routes       []string
+	V4Redistribute []ospfRedistributeConfig
+	V6Redistribute []ospfRedistributeConfig
+}
+
+type ospfAreaConfig struct {
+	ID         string
+	Interfaces []string
+}
+
+// ospfRedistributeConfig describes the prefixes redistributed with the
+// same metric.
+type ospfRedistributeConfig struct {
+	PrefixList string
+	Prefixes   []string
+	Metric     *uint32
+	MetricType uint32
+}
+
+var _ ospf.Announcer = &sessionManager{}
+
+func (sm *sessionManager) SyncOSPFAreas(areas []ospf.Area) error {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.ospfAreas = make([]ospfAreaConfig, 0, len(areas))
+	for _, a := range areas {
+		interfaces := make([]string, len(a.Interfaces))
+		copy(interfaces, a.Interfaces)
+		sort.Strings(interfaces)
+		sm.ospfAreas = append(sm.ospfAreas, ospfAreaConfig{ID: a.ID, Interfaces: interfaces})
+	}
+	sort.Slice(sm.ospfAreas, func(i, j int) bool {
+		return sm.ospfAreas[i].ID < sm.ospfAreas[j].ID
+	})
+
+	frrConfig, err := sm.createConfig()
+	if err != nil {
+		return err
+	}
+
+	sm.reloadConfig <- reloadEvent{config: frrConfig}
+	return nil
+}
+
+func (sm *sessionManager) SetOSPFAdvertisements(advs []*ospf.Advertisement) error {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.ospfAds = make(map[string]*ospf.Advertisement, len(advs))
+	for _, adv := range advs {
+		sm.ospfAds[adv.Prefix.String()] = adv
+	}
+
+	frrConfig, err := sm.createConfig()
+	if err != nil {
+		return err
+	}
+
+	sm.reloadConfig <- reloadEvent{config: frrConfig}
+	return nil
+}
+
+// ospfConfigFor returns the configuration of OSPF, or nil when
+// no area is configured.
+func ospfConfigFor(areas []ospfAreaConfig, advertised map[string]*ospf.Advertisement) *ospfConfig {
+	if len(areas) == 0 {
+		return nil
+	}
+	res := &ospfConfig{Areas: areas}
+
+	v4 := map[string]*ospfRedistributeConfig{}
+	v6 := map[string]*ospfRedistributeConfig{}
+	for _, prefix := range sortedKeys(advertised) {
+		adv := advertised[prefix]
+		family := ipfamily.ForAddress(adv.Prefix.IP)
+		metric := "default"
+		if adv.Metric != nil {
+			metric = fmt.Sprint(*adv.Metric)
+		}
+		prefixList := fmt.Sprintf("metallb-ospf-%s-type%d-%s", metric, adv.MetricType, family)
+
+		redistribute := v4
+		if family == ipfamily.IPv6 {
+			redistribute = v6
+			res.V6Routes = append(res.V6Routes, prefix)
+		} else {
+			res.V4Routes = append(res.V4Routes, prefix)
+		}
+		r, ok := redistribute[prefixList]
+		if !ok {
+			r = &ospfRedistributeConfig{
+				PrefixList: prefixList,
+				Metric:     adv.Metric,
+				MetricType: adv.MetricType,
+			}
+			redistribute[prefixList] = r
+		}
+		r.Prefixes = append(r.Prefixes, prefix)
+	}
+	for _, r := range sortMap(v4) {
+		res.V4Redistribute = append(res.V4Redistribute, *r)
+	}
+	for _, r := range sortMap(v6) {
+		res.V6Redistribute = append(res.V6Redistribute, *r)
+	}
+	return res
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
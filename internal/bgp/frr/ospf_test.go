@@ -0,0 +1,74 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/ospf"
+)
+
+func TestOSPFAreas(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+
+	err := sessionManager.SyncOSPFAreas([]ospf.Area{
+		{ID: "0.0.0.1", Interfaces: []string{"eth1", "eth0"}},
+		{ID: "0.0.0.0", Interfaces: []string{"eth2"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not sync the ospf areas: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestOSPFAdvertisements(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+
+	err := sessionManager.SyncOSPFAreas([]ospf.Area{
+		{ID: "0.0.0.0", Interfaces: []string{"eth0"}},
+	})
+	if err != nil {
+		t.Fatalf("Could not sync the ospf areas: %s", err)
+	}
+
+	metric := uint32(100)
+	err = sessionManager.SetOSPFAdvertisements([]*ospf.Advertisement{
+		{Prefix: ipnet("172.16.1.10/32"), MetricType: 2},
+		{Prefix: ipnet("172.16.1.11/32"), MetricType: 2},
+		{Prefix: ipnet("172.16.2.10/32"), Metric: &metric, MetricType: 1},
+		{Prefix: ipnet("2001:db8::10/128"), MetricType: 2},
+	})
+	if err != nil {
+		t.Fatalf("Could not advertise prefixes: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestOSPFAdvertisementsWithoutAreas(t *testing.T) {
+	testSetup(t)
+
+	l := log.NewNopLogger()
+	sessionManager := mockNewSessionManager(l, logging.LevelInfo)
+	defer close(sessionManager.reloadConfig)
+
+	err := sessionManager.SetOSPFAdvertisements([]*ospf.Advertisement{
+		{Prefix: ipnet("172.16.1.10/32"), MetricType: 2},
+	})
+	if err != nil {
+		t.Fatalf("Could not advertise prefixes: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
@@ -76,7 +76,7 @@ func TestNeighbour(t *testing.T) {
         "ipv4Unicast":{
           "routerAlwaysNextHop":true,
           "commAttriSentToNbr":"extendedAndStandard",
-          "acceptedPrefixCounter":0,
+          "acceptedPrefixCounter":3,
           "sentPrefixCounter":%d
         },
         "ipv6Unicast":{
@@ -169,6 +169,9 @@ func TestNeighbour(t *testing.T) {
 			if tt.ipv4PrefixSent+tt.ipv6PrefixSent != n.PrefixSent {
 				t.Fatal("Expected prefix sent", tt.ipv4PrefixSent+tt.ipv6PrefixSent, "got", n.PrefixSent)
 			}
+			if n.PrefixReceived != 3 {
+				t.Fatal("Expected prefix received", 3, "got", n.PrefixReceived)
+			}
 			if tt.port != n.Port {
 				t.Fatal("Expected port", tt.port, "got", n.Port)
 			}
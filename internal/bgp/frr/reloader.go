@@ -0,0 +1,112 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The FRR reloader runs next to FRR, and serves the requests to apply a
+// configuration on a unix socket shared with the speaker.
+const (
+	// ReloadPath is the path of the ReloadRequests, answered with a
+	// ReloadResult.
+	ReloadPath = "/reload"
+	// ChangesPath is the path of the ChangesRequests, answered with a
+	// StepResult.
+	ChangesPath = "/changes"
+)
+
+// ReloadRequest asks the FRR reloader to apply a whole configuration,
+// with frr-reload.py.
+type ReloadRequest struct {
+	Config string `json:"config"`
+	// DryRun only tests the configuration, without applying it.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ChangesRequest asks the FRR reloader to apply the given vtysh commands.
+type ChangesRequest struct {
+	Commands string `json:"commands"`
+}
+
+// StepResult is the result of a command run by the FRR reloader.
+type StepResult struct {
+	Success         bool    `json:"success"`
+	Output          string  `json:"output"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// ReloadResult is the result of a ReloadRequest.
+type ReloadResult struct {
+	// Test is the result of testing the configuration with frr-reload.py
+	// --test.
+	Test StepResult `json:"test"`
+	// Reload is the result of applying the configuration. It is not set
+	// when the test failed, or on dry runs.
+	Reload *StepResult `json:"reload,omitempty"`
+}
+
+// Err returns the failure of the reload, if any.
+func (r *ReloadResult) Err() error {
+	if !r.Test.Success {
+		return fmt.Errorf("invalid configuration: %s", r.Test.Output)
+	}
+	if r.Reload != nil && !r.Reload.Success {
+		return fmt.Errorf("failed to apply configuration: %s", r.Reload.Output)
+	}
+	return nil
+}
+
+func (r *ReloadResult) durationSeconds() float64 {
+	if r.Reload == nil {
+		return r.Test.DurationSeconds
+	}
+	return r.Test.DurationSeconds + r.Reload.DurationSeconds
+}
+
+var (
+	reloaderSocket  = "/etc/frr_reloader/reloader.sock"
+	reloaderTimeout = 2 * time.Minute
+)
+
+// callReloader sends the given request to the FRR reloader, and decodes
+// its answer in res.
+func callReloader(path string, req, res interface{}) error {
+	socket, found := os.LookupEnv("FRR_RELOADER_SOCKET")
+	if found {
+		reloaderSocket = socket
+	}
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", reloaderSocket)
+			},
+		},
+		Timeout: reloaderTimeout,
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post("http://reloader"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("reloader answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
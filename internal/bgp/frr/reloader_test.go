@@ -0,0 +1,113 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCallReloader(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "reloader.sock")
+	oldSocket := reloaderSocket
+	reloaderSocket = socket
+	defer func() { reloaderSocket = oldSocket }()
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %s", socket, err)
+	}
+	var received ReloadRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc(ReloadPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(ReloadResult{
+			Test:   StepResult{Success: true, Output: "test output", DurationSeconds: 1},
+			Reload: &StepResult{Success: false, Output: "reload output", DurationSeconds: 2},
+		})
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	res := &ReloadResult{}
+	err = callReloader(ReloadPath, ReloadRequest{Config: "hostname test\n"}, res)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if received.Config != "hostname test\n" || received.DryRun {
+		t.Fatalf("unexpected request %+v", received)
+	}
+	expected := &ReloadResult{
+		Test:   StepResult{Success: true, Output: "test output", DurationSeconds: 1},
+		Reload: &StepResult{Success: false, Output: "reload output", DurationSeconds: 2},
+	}
+	if diff := cmp.Diff(expected, res); diff != "" {
+		t.Fatalf("unexpected result (-want +got)\n%s", diff)
+	}
+	if err := res.Err(); err == nil || !strings.Contains(err.Error(), "reload output") {
+		t.Fatalf("expected the reload output in the error, got %v", err)
+	}
+
+	err = callReloader(ChangesPath, ChangesRequest{Commands: "router bgp 100\n"}, &StepResult{})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected the status of the answer in the error, got %v", err)
+	}
+}
+
+func TestReloadFailure(t *testing.T) {
+	oldConfigFileName := configFileName
+	t.Setenv("FRR_CONFIG_FILE", filepath.Join(t.TempDir(), "frr.conf"))
+	oldReloadConfig := reloadConfig
+	defer func() {
+		configFileName = oldConfigFileName
+		reloadConfig = oldReloadConfig
+	}()
+
+	reloadConfig = func(string) (*ReloadResult, error) {
+		return &ReloadResult{Test: StepResult{Success: false, Output: "line 3: % Unknown command"}}, nil
+	}
+	failures := testutil.ToFloat64(stats.reloads.WithLabelValues(reloadKindFull, "failure"))
+
+	sm := &sessionManager{}
+	var reported []error
+	sm.ReportReloads(func(err error) {
+		reported = append(reported, err)
+	})
+	err := sm.reloadFunc(log.NewNopLogger())(testIncrementalConfig())
+	if err == nil || !strings.Contains(err.Error(), "Unknown command") {
+		t.Fatalf("expected the test output in the error, got %v", err)
+	}
+	if len(reported) != 1 || reported[0] != err {
+		t.Fatalf("expected the failure to be reported, got %v", reported)
+	}
+	if got := testutil.ToFloat64(stats.reloads.WithLabelValues(reloadKindFull, "failure")); got != failures+1 {
+		t.Fatalf("expected the failure to be counted, got %v", got-failures)
+	}
+	if got := testutil.ToFloat64(stats.lastReloadSuccessful); got != 0 {
+		t.Fatalf("expected the last reload to be reported failed, got %v", got)
+	}
+
+	// Only the changes of the state of the reloads are reported.
+	reload := sm.reloadFunc(log.NewNopLogger())
+	for _, succeed := range []bool{false, false, true, true, false} {
+		reloadConfig = func(string) (*ReloadResult, error) {
+			return &ReloadResult{Test: StepResult{Success: succeed}, Reload: &StepResult{Success: succeed}}, nil
+		}
+		_ = reload(testIncrementalConfig())
+	}
+	if len(reported) != 4 || reported[1] == nil || reported[2] != nil || reported[3] == nil {
+		t.Fatalf("expected a failure, a success and a failure to be reported, got %v", reported)
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// snippetConfig is raw configuration appended to the generated one.
+type snippetConfig struct {
+	Name   string
+	Config string
+}
+
+// dryRunConfig checks the given configuration with FRR, without applying it.
+var dryRunConfig = func(config string) (*ReloadResult, error) {
+	res := &ReloadResult{}
+	err := callReloader(ReloadPath, ReloadRequest{Config: config, DryRun: true}, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// snippetValidator checks the raw configuration snippets with FRR before
+// they are applied, remembering the outcome for each of them so that the
+// same snippet is not checked again on every reload.
+type snippetValidator struct {
+	// results holds the outcome of the check of each snippet, by key.
+	results map[string]error
+}
+
+// snippetCandidate is a snippet of the configuration being validated.
+type snippetCandidate struct {
+	name   string
+	key    string
+	apply  func()
+	revert func()
+}
+
+// validate returns a copy of the given configuration holding only the
+// snippets FRR accepts. Each snippet is checked on top of the generated
+// configuration and of the snippets previously accepted. report is called
+// for each snippet found to be invalid.
+func (v *snippetValidator) validate(config *frrConfig, report func(string, error), l log.Logger) (*frrConfig, error) {
+	res := withoutSnippets(config)
+
+	var candidates []snippetCandidate
+	for i, r := range config.Routers {
+		for j, n := range r.Neighbors {
+			if n.RawConfig == "" {
+				continue
+			}
+			neighbor := res.Routers[i].Neighbors[j]
+			raw := n.RawConfig
+			candidates = append(candidates, snippetCandidate{
+				name:   fmt.Sprintf("bgppeer %s", n.Name),
+				key:    fmt.Sprintf("neighbor/%s/%s\x00%s", n.Name, n.ID(), raw),
+				apply:  func() { neighbor.RawConfig = raw },
+				revert: func() { neighbor.RawConfig = "" },
+			})
+		}
+	}
+	for _, s := range config.Snippets {
+		s := s
+		candidates = append(candidates, snippetCandidate{
+			name:   fmt.Sprintf("frrsnippet %s", s.Name),
+			key:    fmt.Sprintf("snippet/%s\x00%s", s.Name, s.Config),
+			apply:  func() { res.Snippets = append(res.Snippets, s) },
+			revert: func() { res.Snippets = res.Snippets[:len(res.Snippets)-1] },
+		})
+	}
+
+	results := map[string]error{}
+	for _, c := range candidates {
+		err, checked := v.results[c.key]
+		if !checked {
+			c.apply()
+			err = v.check(res)
+			c.revert()
+			if err != nil {
+				var invalid invalidSnippetError
+				if !errors.As(err, &invalid) {
+					return nil, err
+				}
+				level.Error(l).Log("op", "validateSnippet", "snippet", c.name, "error", err, "msg", "ignoring invalid configuration snippet")
+				if report != nil {
+					report(c.name, err)
+				}
+			}
+		}
+		results[c.key] = err
+		if err == nil {
+			c.apply()
+		}
+	}
+	v.results = results
+	return res, nil
+}
+
+// invalidSnippetError is returned when FRR rejects a snippet.
+type invalidSnippetError struct {
+	err error
+}
+
+func (e invalidSnippetError) Error() string {
+	return e.err.Error()
+}
+
+// check asks FRR to validate the given configuration. It returns an
+// invalidSnippetError if the configuration is rejected, or the reason FRR
+// could not be asked.
+func (v *snippetValidator) check(config *frrConfig) error {
+	rendered, err := templateConfig(config)
+	if err != nil {
+		return err
+	}
+	res, err := dryRunConfig(rendered)
+	if err != nil {
+		return err
+	}
+	if err := res.Err(); err != nil {
+		return invalidSnippetError{err}
+	}
+	return nil
+}
+
+// withoutSnippets returns a copy of the given configuration without
+// any raw configuration snippet.
+func withoutSnippets(c *frrConfig) *frrConfig {
+	res := *c
+	res.Snippets = nil
+	res.Routers = make([]*routerConfig, len(c.Routers))
+	for i, r := range c.Routers {
+		router := *r
+		router.Neighbors = make([]*neighborConfig, len(r.Neighbors))
+		for j, n := range r.Neighbors {
+			neighbor := *n
+			neighbor.RawConfig = ""
+			router.Neighbors[j] = &neighbor
+		}
+		res.Routers[i] = &router
+	}
+	return &res
+}
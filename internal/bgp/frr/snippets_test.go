@@ -0,0 +1,102 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnippetValidation(t *testing.T) {
+	oldDryRunConfig := dryRunConfig
+	defer func() { dryRunConfig = oldDryRunConfig }()
+
+	var checked []string
+	unreachable := false
+	dryRunConfig = func(config string) (*ReloadResult, error) {
+		if unreachable {
+			return nil, errors.New("connection refused")
+		}
+		checked = append(checked, config)
+		if strings.Contains(config, "invalid") {
+			return &ReloadResult{Test: StepResult{Success: false, Output: "% Unknown command: invalid"}}, nil
+		}
+		return &ReloadResult{Test: StepResult{Success: true}}, nil
+	}
+
+	config := testIncrementalConfig()
+	config.Routers[0].Neighbors[0].RawConfig = "  neighbor 10.2.2.254 description test-peer"
+	config.Snippets = []snippetConfig{
+		{Name: "bad", Config: "invalid"},
+		{Name: "good", Config: "ip prefix-list extra seq 5 permit 192.168.1.0/24"},
+	}
+
+	reported := map[string]error{}
+	report := func(name string, err error) {
+		reported[name] = err
+	}
+
+	v := &snippetValidator{}
+	validated, err := v.validate(config, report, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(checked) != 3 {
+		t.Fatalf("expected each snippet to be checked, got %d checks", len(checked))
+	}
+	for _, c := range checked[1:] {
+		if !strings.Contains(c, "description test-peer") {
+			t.Fatalf("expected the accepted snippets to be checked along the next ones, got\n%s", c)
+		}
+	}
+	if got := validated.Routers[0].Neighbors[0].RawConfig; got != config.Routers[0].Neighbors[0].RawConfig {
+		t.Fatalf("expected the neighbor snippet to be kept, got %q", got)
+	}
+	if diff := cmp.Diff([]snippetConfig{config.Snippets[1]}, validated.Snippets); diff != "" {
+		t.Fatalf("unexpected snippets (-want +got)\n%s", diff)
+	}
+	if len(reported) != 1 || reported["frrsnippet bad"] == nil {
+		t.Fatalf("expected the invalid snippet to be reported, got %v", reported)
+	}
+	if len(config.Snippets) != 2 || config.Routers[0].Neighbors[0].RawConfig == "" {
+		t.Fatal("expected the original configuration to be left untouched")
+	}
+
+	checked, reported = nil, map[string]error{}
+	validated, err = v.validate(config, report, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(checked) != 0 || len(reported) != 0 {
+		t.Fatalf("expected the snippets not to be checked again, got %d checks and %v", len(checked), reported)
+	}
+	if diff := cmp.Diff([]snippetConfig{config.Snippets[1]}, validated.Snippets); diff != "" {
+		t.Fatalf("unexpected snippets (-want +got)\n%s", diff)
+	}
+
+	config.Snippets[0].Config = "bgp community-list standard extra seq 5 permit 65000:1"
+	validated, err = v.validate(config, report, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(checked) != 1 || len(reported) != 0 {
+		t.Fatalf("expected only the changed snippet to be checked, got %d checks and %v", len(checked), reported)
+	}
+	if diff := cmp.Diff(config.Snippets, validated.Snippets); diff != "" {
+		t.Fatalf("unexpected snippets (-want +got)\n%s", diff)
+	}
+
+	unreachable = true
+	config.Snippets[1].Config = "ip prefix-list extra seq 10 permit 192.168.2.0/24"
+	_, err = v.validate(config, report, log.NewNopLogger())
+	if err == nil {
+		t.Fatal("expected an error when the reloader can't be reached")
+	}
+	if len(reported) != 0 {
+		t.Fatalf("expected no snippet to be reported when the reloader can't be reached, got %v", reported)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	bgpmetrics "go.universe.tf/metallb/internal/bgp/metrics"
+)
+
+const (
+	reloadKindFull    = "full"
+	reloadKindChanges = "changes"
+)
+
+var stats = metrics{
+	reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: bgpmetrics.Namespace,
+		Subsystem: "frr",
+		Name:      "config_reloads_total",
+		Help:      "Number of FRR configuration reloads, by kind (full or changes) and result (success or failure)",
+	}, []string{"kind", "result"}),
+
+	lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: bgpmetrics.Namespace,
+		Subsystem: "frr",
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last FRR configuration reload succeeded (1) or failed (0)",
+	}),
+}
+
+type metrics struct {
+	reloads              *prometheus.CounterVec
+	lastReloadSuccessful prometheus.Gauge
+}
+
+func init() {
+	prometheus.MustRegister(stats.reloads)
+	prometheus.MustRegister(stats.lastReloadSuccessful)
+}
+
+func (m *metrics) reloaded(kind string, success bool) {
+	result, value := "success", 1.0
+	if !success {
+		result, value = "failure", 0
+	}
+	m.reloads.WithLabelValues(kind, result).Inc()
+	m.lastReloadSuccessful.Set(value)
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+)
+
+// listenAddress is where the connections opened by passive peers are
+// accepted, overridden in tests.
+var listenAddress = "[::]:179"
+
+// register tracks the given session, listening for connections from the
+// peers as long as there is at least one passive session in its VRF.
+func (sm *sessionManager) register(s *session) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.sessions[s] = struct{}{}
+	l, ok := sm.listeners[s.VRFName]
+	if !ok {
+		if !s.Passive {
+			return
+		}
+		// On failure, the session retries listening until it succeeds,
+		// see listening.
+		_ = sm.listen(s.VRFName)
+		return
+	}
+	if err := setListenerAuth(l, s); err != nil {
+		level.Error(sm.logger).Log("op", "listen", "peer", s.PeerAddress, "error", err, "msg", "failed to set the authentication of the peer")
+	}
+}
+
+// unregister stops tracking the given session, and stops listening in
+// its VRF if there are no passive sessions left there.
+func (sm *sessionManager) unregister(s *session) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, ok := sm.sessions[s]; !ok {
+		return
+	}
+	delete(sm.sessions, s)
+	l, ok := sm.listeners[s.VRFName]
+	if !ok {
+		return
+	}
+
+	passive := false
+	samePeer := false
+	for other := range sm.sessions {
+		if other.VRFName != s.VRFName {
+			continue
+		}
+		passive = passive || other.Passive
+		samePeer = samePeer || other.peerIP.Equal(s.peerIP)
+	}
+	if !passive {
+		l.Close()
+		delete(sm.listeners, s.VRFName)
+		return
+	}
+	if samePeer {
+		return
+	}
+	if err := removeListenerAuth(l, s); err != nil {
+		level.Error(sm.logger).Log("op", "listen", "peer", s.PeerAddress, "error", err, "msg", "failed to remove the authentication of the peer")
+	}
+}
+
+// updateListenerAOKeys replaces the TCP-AO keys of the given session on
+// the listener of its VRF, if any.
+func (sm *sessionManager) updateListenerAOKeys(s *session, old, new []config.TCPAOKey) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	l, ok := sm.listeners[s.VRFName]
+	if !ok {
+		return
+	}
+	peer, err := newAOPeer(s.peerIP, s.VRFName)
+	if err == nil {
+		err = control(l, func(fd int) error {
+			return updateAOKeys(fd, peer, old, new, true)
+		})
+	}
+	if err != nil {
+		level.Error(sm.logger).Log("op", "listen", "peer", s.PeerAddress, "error", err, "msg", "failed to change the TCP-AO keys of the peer")
+	}
+}
+
+// listening makes sure the connections from the peers of the VRF of the
+// given session are accepted, listening again if the previous attempts
+// failed.
+func (sm *sessionManager) listening(s *session) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, ok := sm.sessions[s]; !ok {
+		return nil
+	}
+	if _, ok := sm.listeners[s.VRFName]; ok {
+		return nil
+	}
+	return sm.listen(s.VRFName)
+}
+
+// listen starts accepting the connections from the peers of the given
+// VRF. It must be called with sm.mu held.
+func (sm *sessionManager) listen(vrf string) error {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			cerr := c.Control(func(fd uintptr) {
+				err = bindToVRF(int(fd), vrf)
+			})
+			if cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+	ln, err := lc.Listen(context.Background(), "tcp", listenAddress)
+	if err != nil {
+		return err
+	}
+	l := ln.(*net.TCPListener)
+	for s := range sm.sessions {
+		if s.VRFName != vrf {
+			continue
+		}
+		if err := setListenerAuth(l, s); err != nil {
+			l.Close()
+			return fmt.Errorf("setting the authentication of %q: %w", s.PeerAddress, err)
+		}
+	}
+	sm.listeners[vrf] = l
+	go sm.accept(l, vrf)
+	return nil
+}
+
+// accept dispatches the connections accepted on the given listener to
+// the session with the same peer in the VRF, until the listener is closed.
+func (sm *sessionManager) accept(l *net.TCPListener, vrf string) {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			level.Error(sm.logger).Log("op", "accept", "error", err, "msg", "failed to accept BGP connection")
+			continue
+		}
+
+		remote := conn.RemoteAddr().(*net.TCPAddr).IP
+		s := sm.sessionFor(vrf, remote)
+		if s == nil {
+			level.Info(sm.logger).Log("op", "accept", "peer", remote, "msg", "rejecting BGP connection from unknown peer")
+			conn.Close()
+			continue
+		}
+		s.handleIncoming(conn)
+	}
+}
+
+// sessionFor returns the session with the given peer in the given VRF,
+// preferring the passive ones.
+func (sm *sessionManager) sessionFor(vrf string, ip net.IP) *session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	var res *session
+	for s := range sm.sessions {
+		if s.VRFName != vrf || !s.peerIP.Equal(ip) {
+			continue
+		}
+		if s.Passive {
+			return s
+		}
+		res = s
+	}
+	return res
+}
+
+// setListenerAuth sets the TCP MD5 signature or the TCP-AO keys required
+// for the connections from the peer of the given session, if any.
+func setListenerAuth(l *net.TCPListener, s *session) error {
+	if len(s.AOKeys) > 0 {
+		peer, err := newAOPeer(s.peerIP, s.VRFName)
+		if err != nil {
+			return err
+		}
+		return control(l, func(fd int) error {
+			return addAOKeys(fd, peer, s.AOKeys, true)
+		})
+	}
+	if s.password != "" {
+		return setListenerMD5(l, s.peerIP, s.password)
+	}
+	return nil
+}
+
+// removeListenerAuth removes the TCP MD5 signature or the TCP-AO keys
+// set for the peer of the given session, if any.
+func removeListenerAuth(l *net.TCPListener, s *session) error {
+	if len(s.AOKeys) > 0 {
+		peer, err := newAOPeer(s.peerIP, s.VRFName)
+		if err != nil {
+			return err
+		}
+		return control(l, func(fd int) error {
+			for _, k := range s.AOKeys {
+				if err := delAOKey(fd, peer, k, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if s.password != "" {
+		return setListenerMD5(l, s.peerIP, "")
+	}
+	return nil
+}
+
+// setListenerMD5 sets the TCP MD5 signature required for the
+// connections from the given address, or removes it if the password is
+// empty.
+func setListenerMD5(l *net.TCPListener, addr net.IP, password string) error {
+	return control(l, func(fd int) error {
+		return setMD5(fd, addr, password)
+	})
+}
+
+// setPassword changes the password the given session authenticates its
+// connections with, including the ones accepted on the listener of its
+// VRF. It must be called with the mutex of the session held.
+func (sm *sessionManager) setPassword(s *session, password string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s.password = password
+	l, ok := sm.listeners[s.VRFName]
+	if !ok {
+		return
+	}
+	if err := setListenerMD5(l, s.peerIP, password); err != nil {
+		level.Error(sm.logger).Log("op", "listen", "peer", s.PeerAddress, "error", err, "msg", "failed to change the password of the peer")
+	}
+}
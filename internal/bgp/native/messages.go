@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"time"
 
@@ -52,6 +53,14 @@ func sendOpen(w io.Writer, asn uint32, routerID net.IP, holdTime time.Duration)
 		CapType uint8
 		CapLen  uint8
 		ASN32   uint32
+
+		// Capability: extended messages
+		ExtType uint8
+		ExtLen  uint8
+
+		// Capability: route refresh
+		RRType uint8
+		RRLen  uint8
 	}{
 		Marker1: 0xffffffffffffffff,
 		Marker2: 0xffffffffffffffff,
@@ -63,9 +72,9 @@ func sendOpen(w io.Writer, asn uint32, routerID net.IP, holdTime time.Duration)
 		HoldTime: uint16(holdTime.Seconds()),
 		// RouterID filled below
 
-		OptsLen: 20,
+		OptsLen: 24,
 		OptType: 2, // Capabilities
-		OptLen:  18,
+		OptLen:  22,
 
 		MP4Type: 1, // BGP Multi-protocol Extensions
 		MP4Len:  4,
@@ -80,6 +89,12 @@ func sendOpen(w io.Writer, asn uint32, routerID net.IP, holdTime time.Duration)
 		CapType: 65, // 4-byte ASN
 		CapLen:  4,
 		ASN32:   asn,
+
+		ExtType: 6, // Extended messages
+		ExtLen:  0,
+
+		RRType: 2, // Route refresh
+		RRLen:  0,
 	}
 	msg.Len = uint16(binary.Size(msg))
 	if asn > 65535 {
@@ -93,12 +108,26 @@ func sendOpen(w io.Writer, asn uint32, routerID net.IP, holdTime time.Duration)
 type openResult struct {
 	asn      uint32
 	holdTime time.Duration
+	routerID net.IP
 	mp4      bool
 	mp6      bool
 	// Four-byte ASN supported
 	fbasn bool
+	// Extended messages (RFC 8654) supported
+	extendedMessage bool
 }
 
+// The maximum length of a BGP message, unless both speakers support
+// extended messages.
+const (
+	maxMessageLen         = 4096
+	maxExtendedMessageLen = 65535
+)
+
+// Cease subcode sent on the connection closed to resolve a collision,
+// as described in RFC 4271 section 6.8.
+const notificationCollision = 0x0607
+
 var notificationCodes = map[uint16]string{
 	0x0100: "Message header error (unspecific)",
 	0x0101: "Connection not synchronized",
@@ -142,6 +171,24 @@ var notificationCodes = map[uint16]string{
 	0x0608: "Out of Resources",
 }
 
+// sendNotification sends a NOTIFICATION with the given error code
+// and subcode, as listed in notificationCodes.
+func sendNotification(w io.Writer, code uint16) error {
+	msg := struct {
+		Marker1, Marker2 uint64
+		Len              uint16
+		Type             uint8
+		Code             uint16
+	}{
+		Marker1: 0xffffffffffffffff,
+		Marker2: 0xffffffffffffffff,
+		Len:     21,
+		Type:    3,
+		Code:    code,
+	}
+	return binary.Write(w, binary.BigEndian, msg)
+}
+
 // readNotification reads the body of a notification message (header
 // has already been consumed). It must always return an error, because
 // receiving a notification is an error.
@@ -205,7 +252,9 @@ func readOpen(r io.Reader) (*openResult, error) {
 	ret := &openResult{
 		asn:      uint32(open.ASN16),
 		holdTime: time.Duration(open.HoldTime) * time.Second,
+		routerID: make(net.IP, net.IPv4len),
 	}
+	binary.BigEndian.PutUint32(ret.routerID, open.RouterID)
 
 	if err := readOptions(lr, ret); err != nil {
 		return nil, err
@@ -265,6 +314,8 @@ func readCapabilities(r io.Reader, ret *openResult) error {
 				return err
 			}
 			ret.fbasn = true
+		case 6:
+			ret.extendedMessage = true
 		case 1:
 			af := struct{ AFI, SAFI uint16 }{}
 			if err := binary.Read(&lr, binary.BigEndian, &af); err != nil {
@@ -289,43 +340,59 @@ func readCapabilities(r io.Reader, ret *openResult) error {
 	}
 }
 
-func sendUpdate(w io.Writer, asn uint32, ibgp, fbasn bool, nextHop net.IP, adv *bgp.Advertisement) error {
+// sendUpdate advertises the given prefixes with the given encoded path
+// attributes, packing as many prefixes as fit in maxLen bytes in each
+// UPDATE message. It returns the number of messages sent.
+func sendUpdate(w io.Writer, maxLen int, attrs []byte, prefixes []*net.IPNet) (int, error) {
 	var b bytes.Buffer
-
-	hdr := struct {
-		M1, M2  uint64
-		Len     uint16
-		Type    uint8
-		WdrLen  uint16
-		AttrLen uint16
-	}{
-		M1:   uint64(0xffffffffffffffff),
-		M2:   uint64(0xffffffffffffffff),
-		Type: 2,
-	}
-	if err := binary.Write(&b, binary.BigEndian, hdr); err != nil {
-		return err
-	}
-	l := b.Len()
-	if err := encodePathAttrs(&b, asn, ibgp, fbasn, nextHop, adv); err != nil {
-		return err
+	msgs := 0
+	for len(prefixes) > 0 {
+		start := b.Len()
+		hdr := struct {
+			M1, M2  uint64
+			Len     uint16
+			Type    uint8
+			WdrLen  uint16
+			AttrLen uint16
+		}{
+			M1:      uint64(0xffffffffffffffff),
+			M2:      uint64(0xffffffffffffffff),
+			Type:    2,
+			AttrLen: uint16(len(attrs)),
+		}
+		if err := binary.Write(&b, binary.BigEndian, hdr); err != nil {
+			return 0, err
+		}
+		b.Write(attrs)
+		left := encodePrefixes(&b, prefixes, maxLen-(b.Len()-start))
+		if len(left) == len(prefixes) {
+			return 0, fmt.Errorf("path attributes too long for a %d bytes message (%d bytes)", maxLen, len(attrs))
+		}
+		prefixes = left
+		binary.BigEndian.PutUint16(b.Bytes()[start+16:start+18], uint16(b.Len()-start))
+		msgs++
 	}
-	binary.BigEndian.PutUint16(b.Bytes()[21:23], uint16(b.Len()-l))
-	encodePrefixes(&b, []*net.IPNet{adv.Prefix})
-	binary.BigEndian.PutUint16(b.Bytes()[16:18], uint16(b.Len()))
 
 	if _, err := io.Copy(w, &b); err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return msgs, nil
 }
 
-func encodePrefixes(b *bytes.Buffer, pfxs []*net.IPNet) {
-	for _, pfx := range pfxs {
+// encodePrefixes encodes as many of the given prefixes as fit in room
+// bytes, and returns the ones left.
+func encodePrefixes(b *bytes.Buffer, pfxs []*net.IPNet, room int) []*net.IPNet {
+	for i, pfx := range pfxs {
 		o, _ := pfx.Mask.Size()
+		n := bytesForBits(o)
+		if 1+n > room {
+			return pfxs[i:]
+		}
 		b.WriteByte(byte(o))
-		b.Write(pfx.IP.To4()[:bytesForBits(o)])
+		b.Write(pfx.IP.To4()[:n])
+		room -= 1 + n
 	}
+	return nil
 }
 
 func bytesForBits(n int) int {
@@ -346,23 +413,39 @@ func encodePathAttrs(b *bytes.Buffer, asn uint32, ibgp, fbasn bool, nextHop net.
 	if ibgp {
 		b.WriteByte(0) // empty AS path
 	} else {
+		// Our own ASN, followed by the prepended ones.
+		asns := []uint32{asn}
+		prependASN := adv.ASPathPrependASN
+		if prependASN == 0 {
+			prependASN = asn
+		}
+		for i := uint32(0); i < adv.ASPathPrependCount; i++ {
+			asns = append(asns, prependASN)
+		}
 		if fbasn {
 			b.Write([]byte{
-				6, // len (1x 4-byte ASN)
-				2, // AS_SEQUENCE
-				1, // len (in number of ASes)
+				byte(2 + len(asns)*4), // len (4-byte ASNs)
+				2,                     // AS_SEQUENCE
+				byte(len(asns)),       // len (in number of ASes)
 			})
-			if err := binary.Write(b, binary.BigEndian, asn); err != nil {
-				return err
+			for _, a := range asns {
+				if err := binary.Write(b, binary.BigEndian, a); err != nil {
+					return err
+				}
 			}
 		} else {
 			b.Write([]byte{
-				4, // len (1x 2-byte ASN)
-				2, // AS_SEQUENCE
-				1, // len (in number of ASes)
+				byte(2 + len(asns)*2), // len (2-byte ASNs)
+				2,                     // AS_SEQUENCE
+				byte(len(asns)),       // len (in number of ASes)
 			})
-			if err := binary.Write(b, binary.BigEndian, uint16(asn)); err != nil {
-				return err
+			for _, a := range asns {
+				if a > 65535 {
+					a = 23456
+				}
+				if err := binary.Write(b, binary.BigEndian, uint16(a)); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -373,6 +456,16 @@ func encodePathAttrs(b *bytes.Buffer, asn uint32, ibgp, fbasn bool, nextHop net.
 
 	b.Write(nextHop)
 
+	if adv.MED != nil {
+		b.Write([]byte{
+			0x80, 4, // optional non-transitive, multi-exit-disc
+			4, // len
+		})
+		if err := binary.Write(b, binary.BigEndian, *adv.MED); err != nil {
+			return err
+		}
+	}
+
 	if ibgp {
 		b.Write([]byte{
 			0x40, 5, // well-known, localpref
@@ -408,37 +501,180 @@ func encodePathAttrs(b *bytes.Buffer, asn uint32, ibgp, fbasn bool, nextHop net.
 		}
 	}
 
+	if adv.LinkBandwidth > 0 {
+		b.Write([]byte{
+			0xc0, 16, // optional transitive, extended communities
+			8,          // len (1x extended community)
+			0x40, 0x04, // non-transitive two-octet AS specific, link bandwidth
+		})
+		asn16 := uint16(asn)
+		if asn > 65535 {
+			asn16 = 23456
+		}
+		if err := binary.Write(b, binary.BigEndian, asn16); err != nil {
+			return err
+		}
+		// The bandwidth is carried in bytes per second, as an IEEE
+		// floating point number.
+		bw := float32(adv.LinkBandwidth) * 1000000 / 8
+		if err := binary.Write(b, binary.BigEndian, math.Float32bits(bw)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func sendWithdraw(w io.Writer, prefixes []*net.IPNet) error {
+// sendWithdraw withdraws the given prefixes, packing as many prefixes
+// as fit in maxLen bytes in each UPDATE message. It returns the number
+// of messages sent.
+func sendWithdraw(w io.Writer, maxLen int, prefixes []*net.IPNet) (int, error) {
 	var b bytes.Buffer
+	msgs := 0
+	for len(prefixes) > 0 {
+		start := b.Len()
+		hdr := struct {
+			M1, M2 uint64
+			Len    uint16
+			Type   uint8
+			WdrLen uint16
+		}{
+			M1:   uint64(0xffffffffffffffff),
+			M2:   uint64(0xffffffffffffffff),
+			Type: 2,
+		}
+		if err := binary.Write(&b, binary.BigEndian, hdr); err != nil {
+			return 0, err
+		}
+		// Room is left for the empty path attributes.
+		prefixes = encodePrefixes(&b, prefixes, maxLen-(b.Len()-start)-2)
+		binary.BigEndian.PutUint16(b.Bytes()[start+19:start+21], uint16(b.Len()-start-21))
+		if err := binary.Write(&b, binary.BigEndian, uint16(0)); err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint16(b.Bytes()[start+16:start+18], uint16(b.Len()-start))
+		msgs++
+	}
 
-	hdr := struct {
-		M1, M2 uint64
-		Len    uint16
-		Type   uint8
-		WdrLen uint16
-	}{
-		M1:   uint64(0xffffffffffffffff),
-		M2:   uint64(0xffffffffffffffff),
-		Type: 2,
+	if _, err := io.Copy(w, &b); err != nil {
+		return 0, err
 	}
-	if err := binary.Write(&b, binary.BigEndian, hdr); err != nil {
-		return err
+	return msgs, nil
+}
+
+// receivedUpdate is the content of an UPDATE message received from
+// the peer, limited to the IPv4 unicast routes.
+type receivedUpdate struct {
+	withdrawn []*net.IPNet
+	nextHop   net.IP
+	nlri      []*net.IPNet
+}
+
+// decodeUpdate decodes the body of an UPDATE message (header has
+// already been consumed).
+func decodeUpdate(b []byte) (*receivedUpdate, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("UPDATE too short")
 	}
-	l := b.Len()
-	encodePrefixes(&b, prefixes)
-	binary.BigEndian.PutUint16(b.Bytes()[19:21], uint16(b.Len()-l))
-	if err := binary.Write(&b, binary.BigEndian, uint16(0)); err != nil {
-		return err
+	wdrLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < wdrLen+2 {
+		return nil, fmt.Errorf("withdrawn routes length %d exceeds the message", wdrLen)
+	}
+	withdrawn, err := decodePrefixes(b[:wdrLen])
+	if err != nil {
+		return nil, err
+	}
+	b = b[wdrLen:]
+	attrLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < attrLen {
+		return nil, fmt.Errorf("path attributes length %d exceeds the message", attrLen)
+	}
+	nextHop, err := decodeNextHop(b[:attrLen])
+	if err != nil {
+		return nil, err
 	}
-	binary.BigEndian.PutUint16(b.Bytes()[16:18], uint16(b.Len()))
+	nlri, err := decodePrefixes(b[attrLen:])
+	if err != nil {
+		return nil, err
+	}
+	if len(nlri) > 0 && nextHop == nil {
+		return nil, fmt.Errorf("missing NEXT_HOP attribute")
+	}
+	return &receivedUpdate{
+		withdrawn: withdrawn,
+		nextHop:   nextHop,
+		nlri:      nlri,
+	}, nil
+}
 
-	if _, err := io.Copy(w, &b); err != nil {
-		return err
+// decodePrefixes decodes a list of IPv4 prefixes, as carried in the
+// withdrawn routes and the NLRI of UPDATE messages.
+func decodePrefixes(b []byte) ([]*net.IPNet, error) {
+	res := []*net.IPNet{}
+	for len(b) > 0 {
+		o := int(b[0])
+		if o > 32 {
+			return nil, fmt.Errorf("invalid prefix length %d", o)
+		}
+		n := bytesForBits(o)
+		if len(b) < 1+n {
+			return nil, fmt.Errorf("truncated prefix")
+		}
+		ip := make(net.IP, net.IPv4len)
+		copy(ip, b[1:1+n])
+		pfx := &net.IPNet{IP: ip, Mask: net.CIDRMask(o, 32)}
+		pfx.IP = pfx.IP.Mask(pfx.Mask)
+		res = append(res, pfx)
+		b = b[1+n:]
 	}
-	return nil
+	return res, nil
+}
+
+// decodeNextHop returns the NEXT_HOP attribute among the given path
+// attributes, if any.
+func decodeNextHop(b []byte) (net.IP, error) {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("truncated path attribute")
+		}
+		flags, typ := b[0], b[1]
+		l, hdrLen := int(b[2]), 3
+		if flags&0x10 != 0 {
+			// Extended length.
+			if len(b) < 4 {
+				return nil, fmt.Errorf("truncated path attribute")
+			}
+			l, hdrLen = int(binary.BigEndian.Uint16(b[2:])), 4
+		}
+		if len(b) < hdrLen+l {
+			return nil, fmt.Errorf("path attribute %d length %d exceeds the message", typ, l)
+		}
+		if typ == 3 {
+			if l != 4 {
+				return nil, fmt.Errorf("invalid NEXT_HOP length %d", l)
+			}
+			return net.IP(append([]byte{}, b[hdrLen:hdrLen+4]...)), nil
+		}
+		b = b[hdrLen+l:]
+	}
+	return nil, nil
+}
+
+// readRouteRefresh reads the body of a ROUTE-REFRESH message (header
+// has already been consumed), and returns whether it asks for the IPv4
+// unicast routes.
+func readRouteRefresh(r io.Reader) (bool, error) {
+	msg := struct {
+		AFI      uint16
+		Reserved uint8
+		SAFI     uint8
+	}{}
+	if err := binary.Read(r, binary.BigEndian, &msg); err != nil {
+		return false, err
+	}
+	return msg.AFI == 1 && msg.SAFI == 1, nil
 }
 
 func sendKeepalive(w io.Writer) error {
@@ -4,6 +4,8 @@ package native
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -11,10 +13,19 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"go.universe.tf/metallb/internal/bgp"
 	"go.universe.tf/metallb/internal/bgp/community"
 )
 
+func ipnet(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
 // Just test that sendOpen and readOpen can at least talk to each other.
 func TestOpen(t *testing.T) {
 	var b bytes.Buffer
@@ -33,6 +44,9 @@ func TestOpen(t *testing.T) {
 	if op.asn != wantASN {
 		t.Errorf("Wrong ASN, want %d, got %d", wantASN, op.asn)
 	}
+	if !op.extendedMessage {
+		t.Errorf("Extended messages not supported")
+	}
 }
 
 func TestPcapInterop(t *testing.T) {
@@ -152,8 +166,11 @@ func TestSendUpdate(t *testing.T) {
 		},
 	}
 	for d, tc := range tcs {
-		var b bytes.Buffer
-		err := sendUpdate(&b, tc.asn, tc.ibgp, tc.fbasn, tc.nextHop, tc.adv)
+		var attrs, b bytes.Buffer
+		err := encodePathAttrs(&attrs, tc.asn, tc.ibgp, tc.fbasn, tc.nextHop, tc.adv)
+		if err == nil {
+			_, err = sendUpdate(&b, maxMessageLen, attrs.Bytes(), []*net.IPNet{tc.adv.Prefix})
+		}
 		if tc.errorString == "" && err != nil {
 			t.Fatalf("%s(%s): send update, err: %q", t.Name(), d, err)
 		}
@@ -163,6 +180,318 @@ func TestSendUpdate(t *testing.T) {
 	}
 }
 
+// update is a decoded UPDATE message.
+type update struct {
+	withdrawn []string
+	attrs     []byte
+	nlri      []string
+}
+
+// decodeUpdates decodes the UPDATE messages in b, checking that none of
+// them is longer than maxLen.
+func decodeUpdates(t testing.TB, b []byte, maxLen int) []update {
+	t.Helper()
+	decodePrefixes := func(b []byte) []string {
+		res := []string{}
+		for len(b) > 0 {
+			o := int(b[0])
+			ip := make(net.IP, net.IPv4len)
+			copy(ip, b[1:1+bytesForBits(o)])
+			res = append(res, (&net.IPNet{IP: ip, Mask: net.CIDRMask(o, 32)}).String())
+			b = b[1+bytesForBits(o):]
+		}
+		return res
+	}
+
+	res := []update{}
+	for len(b) > 0 {
+		l := int(binary.BigEndian.Uint16(b[16:18]))
+		if l > maxLen {
+			t.Fatalf("message of %d bytes, longer than %d", l, maxLen)
+		}
+		if b[18] != 2 {
+			t.Fatalf("expected update, got message type %d", b[18])
+		}
+		msg := b[19:l]
+		wdrLen := int(binary.BigEndian.Uint16(msg))
+		u := update{withdrawn: decodePrefixes(msg[2 : 2+wdrLen])}
+		msg = msg[2+wdrLen:]
+		attrLen := int(binary.BigEndian.Uint16(msg))
+		u.attrs = msg[2 : 2+attrLen]
+		u.nlri = decodePrefixes(msg[2+attrLen:])
+		res = append(res, u)
+		b = b[l:]
+	}
+	return res
+}
+
+func TestPackPrefixes(t *testing.T) {
+	prefixes := []*net.IPNet{}
+	want := []string{}
+	for i := 0; i < 2000; i++ {
+		pfx := ipnet(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+		prefixes = append(prefixes, pfx)
+		want = append(want, pfx.String())
+	}
+	var attrs bytes.Buffer
+	adv := &bgp.Advertisement{LocalPref: 100}
+	if err := encodePathAttrs(&attrs, 65000, true, true, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+
+	tcs := map[string]struct {
+		maxLen   int
+		wantMsgs int
+		wantWdrs int
+	}{
+		"standard messages": {
+			maxLen:   maxMessageLen,
+			wantMsgs: 2,
+			wantWdrs: 2,
+		},
+		"extended messages": {
+			maxLen:   maxExtendedMessageLen,
+			wantMsgs: 1,
+			wantWdrs: 1,
+		},
+		"one prefix per message": {
+			maxLen:   23 + attrs.Len() + 4,
+			wantMsgs: 2000,
+			// Withdrawals don't carry path attributes.
+			wantWdrs: 334,
+		},
+	}
+	for d, tc := range tcs {
+		var b bytes.Buffer
+		n, err := sendUpdate(&b, tc.maxLen, attrs.Bytes(), prefixes)
+		if err != nil {
+			t.Fatalf("%s: send update: %s", d, err)
+		}
+		if n != tc.wantMsgs {
+			t.Errorf("%s: expected %d updates, got %d", d, tc.wantMsgs, n)
+		}
+		got := []string{}
+		for _, u := range decodeUpdates(t, b.Bytes(), tc.maxLen) {
+			if !bytes.Equal(u.attrs, attrs.Bytes()) {
+				t.Fatalf("%s: unexpected path attributes %x", d, u.attrs)
+			}
+			got = append(got, u.nlri...)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("%s: unexpected advertised prefixes (-want +got)\n%s", d, diff)
+		}
+
+		b.Reset()
+		n, err = sendWithdraw(&b, tc.maxLen, prefixes)
+		if err != nil {
+			t.Fatalf("%s: send withdraw: %s", d, err)
+		}
+		if n != tc.wantWdrs {
+			t.Errorf("%s: expected %d withdrawals, got %d", d, tc.wantWdrs, n)
+		}
+		got = []string{}
+		for _, u := range decodeUpdates(t, b.Bytes(), tc.maxLen) {
+			if len(u.attrs) != 0 || len(u.nlri) != 0 {
+				t.Fatalf("%s: unexpected path attributes or prefixes in withdrawal", d)
+			}
+			got = append(got, u.withdrawn...)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("%s: unexpected withdrawn prefixes (-want +got)\n%s", d, diff)
+		}
+	}
+
+	var b bytes.Buffer
+	if _, err := sendUpdate(&b, 23+attrs.Len(), attrs.Bytes(), prefixes); err == nil {
+		t.Fatalf("expected path attributes longer than the message to fail")
+	}
+}
+
+func TestDecodeUpdate(t *testing.T) {
+	var attrs bytes.Buffer
+	adv := &bgp.Advertisement{LocalPref: 100}
+	if err := encodePathAttrs(&attrs, 65000, false, true, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+	var b bytes.Buffer
+	if _, err := sendUpdate(&b, maxMessageLen, attrs.Bytes(), []*net.IPNet{ipnet("10.0.0.0/8"), ipnet("172.16.1.0/24")}); err != nil {
+		t.Fatalf("send update: %s", err)
+	}
+	u, err := decodeUpdate(b.Bytes()[19:])
+	if err != nil {
+		t.Fatalf("decode update: %s", err)
+	}
+	if !u.nextHop.Equal(net.ParseIP("192.168.123.10")) {
+		t.Errorf("expected next hop 192.168.123.10, got %s", u.nextHop)
+	}
+	if diff := cmp.Diff([]*net.IPNet{ipnet("10.0.0.0/8"), ipnet("172.16.1.0/24")}, u.nlri); diff != "" {
+		t.Errorf("unexpected prefixes (-want +got)\n%s", diff)
+	}
+
+	b.Reset()
+	if _, err := sendWithdraw(&b, maxMessageLen, []*net.IPNet{ipnet("10.1.2.3/32")}); err != nil {
+		t.Fatalf("send withdraw: %s", err)
+	}
+	u, err = decodeUpdate(b.Bytes()[19:])
+	if err != nil {
+		t.Fatalf("decode withdraw: %s", err)
+	}
+	if diff := cmp.Diff([]*net.IPNet{ipnet("10.1.2.3/32")}, u.withdrawn); diff != "" {
+		t.Errorf("unexpected withdrawn prefixes (-want +got)\n%s", diff)
+	}
+
+	for d, body := range map[string][]byte{
+		"too short":                 {0},
+		"withdrawn routes too long": {0, 10, 8, 10},
+		"invalid prefix length":     {0, 2, 33, 10, 0, 0},
+		"truncated prefix":          {0, 2, 24, 10, 0, 0},
+		"attributes too long":       {0, 0, 0, 10, 0x40, 1},
+		"invalid next hop":          {0, 0, 0, 5, 0x40, 3, 2, 10, 0},
+		"missing next hop":          {0, 0, 0, 4, 0x40, 1, 1, 0, 8, 10},
+	} {
+		if _, err := decodeUpdate(body); err == nil {
+			t.Errorf("%s: expected decoding to fail", d)
+		}
+	}
+}
+
+func TestEncodeLinkBandwidth(t *testing.T) {
+	tcs := map[string]struct {
+		asn  uint32
+		bw   uint32
+		want []byte
+	}{
+		"two bytes asn": {
+			asn: 65000,
+			bw:  3,
+			// 3 Mbps = 375000 bytes per second
+			want: []byte{0xc0, 16, 8, 0x40, 0x04, 0xfd, 0xe8, 0x48, 0xb7, 0x1b, 0x00},
+		},
+		"four bytes asn": {
+			asn:  4200000000,
+			bw:   1,
+			want: []byte{0xc0, 16, 8, 0x40, 0x04, 0x5b, 0xa0, 0x47, 0xf4, 0x24, 0x00},
+		},
+	}
+	for d, tc := range tcs {
+		var b bytes.Buffer
+		adv := &bgp.Advertisement{
+			Prefix:        ipnet("172.16.0.1/32"),
+			LinkBandwidth: tc.bw,
+		}
+		if err := encodePathAttrs(&b, tc.asn, false, true, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+			t.Fatalf("%s: encode path attributes: %s", d, err)
+		}
+		if !bytes.HasSuffix(b.Bytes(), tc.want) {
+			t.Fatalf("%s: expected path attributes to end with %x, got %x", d, tc.want, b.Bytes())
+		}
+	}
+
+	var b bytes.Buffer
+	adv := &bgp.Advertisement{Prefix: ipnet("172.16.0.1/32")}
+	if err := encodePathAttrs(&b, 65000, false, true, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+	if bytes.Contains(b.Bytes(), []byte{0xc0, 16}) {
+		t.Fatalf("unexpected extended communities attribute in %x", b.Bytes())
+	}
+}
+
+func TestEncodeASPathPrepend(t *testing.T) {
+	tcs := map[string]struct {
+		ibgp  bool
+		fbasn bool
+		want  []byte
+	}{
+		"four bytes asn": {
+			fbasn: true,
+			want:  []byte{0x40, 2, 14, 2, 3, 0, 0, 0xfd, 0xe8, 0, 0, 0xfd, 0xe8, 0, 0, 0xfd, 0xe8},
+		},
+		"two bytes asn": {
+			want: []byte{0x40, 2, 8, 2, 3, 0xfd, 0xe8, 0xfd, 0xe8, 0xfd, 0xe8},
+		},
+		"ibgp": {
+			ibgp: true,
+			want: []byte{0x40, 2, 0},
+		},
+	}
+	for d, tc := range tcs {
+		var b bytes.Buffer
+		adv := &bgp.Advertisement{
+			Prefix:             ipnet("172.16.0.1/32"),
+			ASPathPrependCount: 2,
+		}
+		if err := encodePathAttrs(&b, 65000, tc.ibgp, tc.fbasn, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+			t.Fatalf("%s: encode path attributes: %s", d, err)
+		}
+		// Skip the origin attribute.
+		if !bytes.HasPrefix(b.Bytes()[4:], tc.want) {
+			t.Fatalf("%s: expected as path attribute %x, got %x", d, tc.want, b.Bytes()[4:])
+		}
+	}
+}
+
+func TestEncodeASPathPrependASN(t *testing.T) {
+	tcs := map[string]struct {
+		asn   uint32
+		fbasn bool
+		want  []byte
+	}{
+		"four bytes asn": {
+			asn:   65000,
+			fbasn: true,
+			want:  []byte{0x40, 2, 10, 2, 2, 0, 0, 0xfd, 0xe8, 0xfa, 0x56, 0xea, 0x00},
+		},
+		"two bytes asn, as_trans": {
+			asn:  65000,
+			want: []byte{0x40, 2, 6, 2, 2, 0xfd, 0xe8, 0x5b, 0xa0},
+		},
+	}
+	for d, tc := range tcs {
+		var b bytes.Buffer
+		adv := &bgp.Advertisement{
+			Prefix:             ipnet("172.16.0.1/32"),
+			ASPathPrependCount: 1,
+			ASPathPrependASN:   4200000000,
+		}
+		if err := encodePathAttrs(&b, tc.asn, false, tc.fbasn, net.ParseIP("192.168.123.10").To4(), adv); err != nil {
+			t.Fatalf("%s: encode path attributes: %s", d, err)
+		}
+		// Skip the origin attribute.
+		if !bytes.HasPrefix(b.Bytes()[4:], tc.want) {
+			t.Fatalf("%s: expected as path attribute %x, got %x", d, tc.want, b.Bytes()[4:])
+		}
+	}
+}
+
+func TestEncodeMED(t *testing.T) {
+	med := uint32(300)
+	nextHop := net.ParseIP("192.168.123.10").To4()
+	adv := &bgp.Advertisement{
+		Prefix: ipnet("172.16.0.1/32"),
+		MED:    &med,
+	}
+
+	var b bytes.Buffer
+	if err := encodePathAttrs(&b, 65000, false, true, nextHop, adv); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+	want := append([]byte{0x40, 3, 4}, nextHop...)
+	want = append(want, 0x80, 4, 4, 0, 0, 0x01, 0x2c)
+	if !bytes.Contains(b.Bytes(), want) {
+		t.Fatalf("expected path attributes to contain %x, got %x", want, b.Bytes())
+	}
+
+	b.Reset()
+	adv.MED = nil
+	if err := encodePathAttrs(&b, 65000, false, true, nextHop, adv); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+	if bytes.Contains(b.Bytes(), []byte{0x80, 4, 4}) {
+		t.Fatalf("unexpected med attribute in %x", b.Bytes())
+	}
+}
+
 func FuzzReadOpen(f *testing.F) {
 	ms, err := filepath.Glob("testdata/open-*")
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -25,32 +26,77 @@ import (
 
 var errClosed = errors.New("session closed")
 
+// passwordSwitchInterval is how long passive sessions wait for the peer
+// to connect before switching password, while the passwords are rotated.
+var passwordSwitchInterval = 30 * time.Second
+
 // session represents one BGP session to an external router.
 type session struct {
 	bgp.SessionParameters
-	peerFBASNSupport bool
+	peerFBASNSupport       bool
+	peerExtendedMsgSupport bool
 
-	logger log.Logger
+	logger  log.Logger
+	manager *sessionManager
+	peerIP  net.IP
 
 	newHoldTime chan bool
 	backoff     backoff
 
+	// incoming holds the connection opened by the peer, if any,
+	// until the session goroutine picks it up.
+	incoming    chan net.Conn
+	done        chan struct{}
+	established atomic.Bool
+	// lastReceived is when the last message from the peer was received,
+	// in nanoseconds since the epoch.
+	lastReceived atomic.Int64
+
+	// password is the one of Password and NextPassword the connections
+	// are authenticated with. It is changed with both mu and the mutex
+	// of the manager held, see usePassword.
+	password         string
+	passwordSwitched time.Time
+
 	mu             sync.Mutex
 	cond           *sync.Cond
 	closed         bool
 	conn           net.Conn
 	actualHoldTime time.Duration
 	nextHop        net.IP
-	advertised     map[string]*bgp.Advertisement
-	new            map[string]*bgp.Advertisement
+	// desired holds the advertisements the peer should receive and
+	// advertised the ones it received, by prefix. dirty holds the
+	// prefixes changed since they were last sent.
+	desired    map[string]*bgp.Advertisement
+	advertised map[string]*bgp.Advertisement
+	dirty      map[string]bool
+	// received holds the routes accepted from the peer, by prefix.
+	received map[string]bgp.Route
 }
 
-// The 'Native' implementation does not require a session manager .
+// The 'Native' session manager keeps track of the sessions in order to
+// dispatch the connections opened by the peers.
 type sessionManager struct {
+	logger log.Logger
+
+	mu        sync.Mutex
+	sessions  map[*session]struct{}
+	listeners map[string]*net.TCPListener // by VRF
+
+	// routesMu protects routes, the routes accepted from the peers of
+	// all the sessions, by routing table and prefix. It is taken with
+	// the mutexes of the sessions held.
+	routesMu sync.Mutex
+	routes   map[routeKey]*prefixRoutes
 }
 
 func NewSessionManager(l log.Logger) bgp.SessionManager {
-	return &sessionManager{}
+	return &sessionManager{
+		logger:    l,
+		sessions:  map[*session]struct{}{},
+		listeners: map[string]*net.TCPListener{},
+		routes:    map[routeKey]*prefixRoutes{},
+	}
 }
 
 // NewSession() creates a BGP session using the given session parameters.
@@ -58,18 +104,38 @@ func NewSessionManager(l log.Logger) bgp.SessionManager {
 // The session will immediately try to connect and synchronize its
 // local state with the peer.
 func (sm *sessionManager) NewSession(l log.Logger, args bgp.SessionParameters) (bgp.Session, error) {
+	host, _, err := net.SplitHostPort(args.PeerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %q: %w", args.PeerAddress, err)
+	}
+	if err := checkRoutingTable(args.Receive); err != nil {
+		return nil, err
+	}
 	ret := &session{
 		SessionParameters: args,
 		logger:            log.With(l, "peer", args.PeerAddress, "localASN", args.MyASN, "peerASN", args.PeerASN),
+		manager:           sm,
+		peerIP:            net.ParseIP(host),
 		newHoldTime:       make(chan bool, 1),
+		incoming:          make(chan net.Conn, 1),
+		done:              make(chan struct{}),
+		desired:           map[string]*bgp.Advertisement{},
 		advertised:        map[string]*bgp.Advertisement{},
+		dirty:             map[string]bool{},
+		received:          map[string]bgp.Route{},
+		password:          args.Password,
 	}
 	ret.cond = sync.NewCond(&ret.mu)
+	sm.register(ret)
 	go ret.sendKeepalives()
 	go ret.run()
 
 	stats.sessionUp.WithLabelValues(ret.PeerAddress).Set(0)
 	stats.prefixes.WithLabelValues(ret.PeerAddress).Set(0)
+	if ret.Receive != nil {
+		stats.ReceivedPrefixes(ret.PeerAddress, 0)
+	}
+	stats.NextPasswordActive(ret.PeerAddress, false)
 
 	return ret, nil
 }
@@ -88,17 +154,37 @@ func (sm *sessionManager) SyncExtraInfo(extras string) error {
 	return nil
 }
 
+func (sm *sessionManager) SyncSnippets(snippets map[string]string) error {
+	if len(snippets) > 0 {
+		return errors.New("frr snippets not supported in native mode")
+	}
+	return nil
+}
+
 // run tries to stay connected to the peer, and pumps route updates to it.
 func (s *session) run() {
 	defer stats.DeleteSession(s.PeerAddress)
-	for {
-		if err := s.connect(); err != nil {
+	var (
+		inbound net.Conn
+		ok      = true
+	)
+	if s.Passive {
+		inbound, ok = s.acceptPeer()
+	}
+	for ok {
+		if err := s.connect(inbound); err != nil {
 			if err == errClosed {
 				return
 			}
 			level.Error(s.logger).Log("op", "connect", "error", err, "msg", "failed to connect to peer")
-			backoff := s.backoff.Duration()
-			time.Sleep(backoff)
+			s.mu.Lock()
+			s.switchPassword()
+			s.mu.Unlock()
+			if s.Passive {
+				inbound, ok = s.acceptPeer()
+				continue
+			}
+			inbound, ok = s.waitForPeer(s.backoff.Duration())
 			continue
 		}
 		stats.SessionUp(s.PeerAddress)
@@ -111,9 +197,98 @@ func (s *session) run() {
 		}
 		stats.SessionDown(s.PeerAddress)
 		level.Warn(s.logger).Log("event", "sessionDown", "msg", "BGP session down")
+
+		inbound = nil
+		if s.Passive {
+			inbound, ok = s.acceptPeer()
+		}
+	}
+}
+
+// acceptPeer waits for the peer of a passive session to open a
+// connection. While the passwords are rotated, the password the
+// connections are accepted with alternates until the peer connects.
+// If the connections can't be accepted, listening is retried with
+// backoff.
+func (s *session) acceptPeer() (net.Conn, bool) {
+	for {
+		if err := s.manager.listening(s); err != nil {
+			level.Error(s.logger).Log("op", "listen", "vrf", s.VRFName, "error", err, "msg", "failed to listen for BGP connections, retrying")
+			d := s.backoff.Duration()
+			if d == 0 {
+				// Waiting for zero is waiting forever.
+				d = s.backoff.Duration()
+			}
+			if _, ok := s.waitForPeer(d); !ok {
+				return nil, false
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		rotating := s.NextPassword != ""
+		s.mu.Unlock()
+		if !rotating {
+			return s.waitForPeer(0)
+		}
+
+		conn, ok := s.waitForPeer(passwordSwitchInterval)
+		if conn != nil || !ok {
+			return conn, ok
+		}
+		s.mu.Lock()
+		s.switchPassword()
+		s.mu.Unlock()
+	}
+}
+
+// waitForPeer waits for the peer to open a connection, for at most the
+// given duration or forever if zero. It returns the connection, nil if
+// the time expired, and false if the session was closed meanwhile.
+func (s *session) waitForPeer(d time.Duration) (net.Conn, bool) {
+	var timeout <-chan time.Time
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case conn := <-s.incoming:
+		return conn, true
+	case <-timeout:
+		return nil, true
+	case <-s.done:
+		return nil, false
 	}
 }
 
+// handleIncoming hands a connection opened by the peer over to the
+// session. Connections colliding with an established session are
+// rejected, as the existing one is preserved.
+func (s *session) handleIncoming(conn net.Conn) {
+	if s.established.Load() {
+		level.Info(s.logger).Log("event", "connectionCollision", "msg", "session already established, rejecting connection from peer")
+		rejectConn(conn)
+		return
+	}
+	select {
+	case <-s.done:
+		conn.Close()
+	case s.incoming <- conn:
+	default:
+		// A connection from the peer is already pending.
+		conn.Close()
+	}
+}
+
+// rejectConn notifies the peer that the connection is closed to resolve
+// a collision, and closes it.
+func rejectConn(conn net.Conn) {
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = sendNotification(conn, notificationCollision)
+	conn.Close()
+}
+
 // sendUpdates waits for changes to desired advertisements, and pushes
 // them out to the peer.
 func (s *session) sendUpdates() bool {
@@ -129,23 +304,26 @@ func (s *session) sendUpdates() bool {
 
 	ibgp := s.MyASN == s.PeerASN
 	fbasn := s.peerFBASNSupport
+	maxLen := maxMessageLen
+	if s.peerExtendedMsgSupport {
+		maxLen = maxExtendedMessageLen
+	}
 
-	if s.new != nil {
-		s.advertised, s.new = s.new, nil
+	// The peer received nothing over this connection yet.
+	s.advertised = map[string]*bgp.Advertisement{}
+	s.dirty = map[string]bool{}
+	for c := range s.desired {
+		s.dirty[c] = true
 	}
 
-	for c, adv := range s.advertised {
-		if err := sendUpdate(s.conn, s.MyASN, ibgp, fbasn, s.nextHop, adv); err != nil {
+	for {
+		if err := s.sendDirty(ibgp, fbasn, maxLen); err != nil {
 			s.abort()
-			level.Error(s.logger).Log("op", "sendUpdate", "ip", c, "error", err, "msg", "failed to send BGP update")
+			level.Error(s.logger).Log("op", "sendUpdate", "error", err, "msg", "failed to send BGP update")
 			return true
 		}
-		stats.UpdateSent(s.PeerAddress)
-	}
-	stats.AdvertisedPrefixes(s.PeerAddress, len(s.advertised))
 
-	for {
-		for s.new == nil && s.conn != nil {
+		for len(s.dirty) == 0 && s.conn != nil {
 			s.cond.Wait()
 		}
 
@@ -155,105 +333,139 @@ func (s *session) sendUpdates() bool {
 		if s.conn == nil {
 			return true
 		}
-		if s.new == nil {
-			// nil is "no pending updates", contrast to a non-nil
-			// empty map which means "withdraw all".
-			continue
-		}
+	}
+}
 
-		for c, adv := range s.new {
-			if adv2, ok := s.advertised[c]; ok && adv.Equal(adv2) {
-				// Peer already has correct state for this
-				// advertisement, nothing to do.
-				continue
+// sendDirty sends the advertisements of the dirty prefixes that the peer
+// doesn't have yet, and withdraws the ones that are no longer desired.
+// The advertisements sharing the same path attributes are packed in the
+// same UPDATE messages, up to maxLen bytes each.
+// It must be called with s.mu held.
+func (s *session) sendDirty(ibgp, fbasn bool, maxLen int) error {
+	type group struct {
+		attrs    []byte
+		prefixes []string
+		advs     []*bgp.Advertisement
+	}
+	groups := []*group{}
+	byAttrs := map[string]*group{}
+	wdr := []*net.IPNet{}
+	for c := range s.dirty {
+		adv, sent := s.desired[c], s.advertised[c]
+		if adv == nil {
+			if sent != nil {
+				wdr = append(wdr, sent.Prefix)
 			}
+			continue
+		}
+		if sent != nil && adv.Equal(sent) {
+			// Peer already has correct state for this
+			// advertisement, nothing to do.
+			continue
+		}
+		var b bytes.Buffer
+		if err := encodePathAttrs(&b, s.MyASN, ibgp, fbasn, s.nextHop, adv); err != nil {
+			return fmt.Errorf("advertising %s: %w", c, err)
+		}
+		g, ok := byAttrs[b.String()]
+		if !ok {
+			g = &group{attrs: b.Bytes()}
+			byAttrs[b.String()] = g
+			groups = append(groups, g)
+		}
+		g.prefixes = append(g.prefixes, c)
+		g.advs = append(g.advs, adv)
+	}
 
-			if err := sendUpdate(s.conn, s.MyASN, ibgp, fbasn, s.nextHop, adv); err != nil {
-				s.abort()
-				level.Error(s.logger).Log("op", "sendUpdate", "prefix", c, "error", err, "msg", "failed to send BGP update")
-				return true
-			}
-			stats.UpdateSent(s.PeerAddress)
+	for _, g := range groups {
+		prefixes := make([]*net.IPNet, 0, len(g.advs))
+		for _, adv := range g.advs {
+			prefixes = append(prefixes, adv.Prefix)
+		}
+		n, err := sendUpdate(s.conn, maxLen, g.attrs, prefixes)
+		if err != nil {
+			return fmt.Errorf("advertising %v: %w", prefixes, err)
 		}
+		stats.UpdatesSent(s.PeerAddress, n)
+		for i, c := range g.prefixes {
+			s.advertised[c] = g.advs[i]
+		}
+	}
 
-		wdr := []*net.IPNet{}
-		for c, adv := range s.advertised {
-			if s.new[c] == nil {
-				wdr = append(wdr, adv.Prefix)
-			}
+	if len(wdr) > 0 {
+		n, err := sendWithdraw(s.conn, maxLen, wdr)
+		if err != nil {
+			return fmt.Errorf("withdrawing %v: %w", wdr, err)
 		}
-		if len(wdr) > 0 {
-			if err := sendWithdraw(s.conn, wdr); err != nil {
-				s.abort()
-				for _, pfx := range wdr {
-					level.Error(s.logger).Log("op", "sendWithdraw", "prefix", pfx, "error", err, "msg", "failed to send BGP withdraw")
-				}
-				return true
-			}
-			stats.UpdateSent(s.PeerAddress)
+		stats.UpdatesSent(s.PeerAddress, n)
+		for _, pfx := range wdr {
+			delete(s.advertised, pfx.String())
 		}
-		s.advertised, s.new = s.new, nil
-		stats.AdvertisedPrefixes(s.PeerAddress, len(s.advertised))
 	}
+	s.dirty = map[string]bool{}
+	stats.AdvertisedPrefixes(s.PeerAddress, len(s.advertised))
+	return nil
 }
 
-// connect establishes the BGP session with the peer.
+// connect establishes the BGP session with the peer, over the given
+// connection opened by the peer if not nil, or by dialing it otherwise.
 // Sets TCP_MD5 sockopt if password is !="".
-func (s *session) connect() error {
+func (s *session) connect(inbound net.Conn) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.closed {
+		if inbound != nil {
+			inbound.Close()
+		}
 		return errClosed
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	deadline, _ := ctx.Deadline()
-	conn, err := dialMD5(ctx, s.PeerAddress, s.SourceAddress, s.Password)
-	if err != nil {
-		return fmt.Errorf("dial %q: %s", s.PeerAddress, err)
-	}
-
-	if err = conn.SetDeadline(deadline); err != nil {
-		conn.Close()
-		return fmt.Errorf("setting deadline on conn to %q: %s", s.PeerAddress, err)
-	}
 
-	addr, ok := conn.LocalAddr().(*net.TCPAddr)
-	if !ok {
-		conn.Close()
-		return fmt.Errorf("getting local addr for default nexthop to %q: %s", s.PeerAddress, err)
-	}
-	s.nextHop = addr.IP
-
-	routerID := s.RouterID
-	if routerID == nil {
-		routerID, err = getRouterID(s.nextHop, s.CurrentNode)
+	var (
+		hs  *handshake
+		err error
+	)
+	if inbound != nil {
+		ttl, minTTL := ttlFor(s.SessionParameters)
+		if err := setConnTTL(inbound, ttl, minTTL); err != nil {
+			inbound.Close()
+			return fmt.Errorf("setting TTL on conn from %q: %s", s.PeerAddress, err)
+		}
+		// The connection got the keys of the listener, but the
+		// current one is picked by the peer.
+		if len(s.AOKeys) > 0 {
+			err := control(inbound, func(fd int) error {
+				return setAOCurrentKey(fd, s.AOKeys[0])
+			})
+			if err != nil {
+				inbound.Close()
+				return fmt.Errorf("setting TCP-AO key on conn from %q: %s", s.PeerAddress, err)
+			}
+		}
+		hs, err = s.handshake(inbound, deadline)
 		if err != nil {
 			return err
 		}
+	} else {
+		ttl, minTTL := ttlFor(s.SessionParameters)
+		conn, err := dialMD5(ctx, s.PeerAddress, s.SourceAddress, s.password, s.VRFName, ttl, minTTL, s.AOKeys)
+		if err != nil {
+			return fmt.Errorf("dial %q: %s", s.PeerAddress, err)
+		}
+		hs, err = s.handshake(conn, deadline)
+		if err != nil {
+			return err
+		}
+		hs = s.resolveCollision(hs, deadline)
 	}
-
-	if err = sendOpen(conn, s.MyASN, routerID, s.HoldTime); err != nil {
-		conn.Close()
-		return fmt.Errorf("send OPEN to %q: %s", s.PeerAddress, err)
-	}
-
-	op, err := readOpen(conn)
-	if err != nil {
-		conn.Close()
-		return fmt.Errorf("read OPEN from %q: %s", s.PeerAddress, err)
-	}
-	if op.asn != s.PeerASN {
-		conn.Close()
-		return fmt.Errorf("unexpected peer ASN %d, want %d", op.asn, s.PeerASN)
-	}
+	conn, op := hs.conn, hs.open
+	s.nextHop = hs.nextHop
 	s.peerFBASNSupport = op.fbasn
-	if s.MyASN > 65536 && !s.peerFBASNSupport {
-		conn.Close()
-		return fmt.Errorf("peer does not support 4-byte ASNs")
-	}
+	s.peerExtendedMsgSupport = op.extendedMessage
 
 	// BGP session is established, clear the connect timeout deadline.
 	if err := conn.SetDeadline(time.Time{}); err != nil {
@@ -281,9 +493,111 @@ func (s *session) connect() error {
 	}
 
 	s.conn = conn
+	s.established.Store(true)
+	s.lastReceived.Store(time.Now().UnixNano())
+
+	// The peer may have tried to connect in the meantime.
+	select {
+	case c := <-s.incoming:
+		rejectConn(c)
+	default:
+	}
 	return nil
 }
 
+// handshake is a connection to the peer on which the OPEN messages
+// have been exchanged.
+type handshake struct {
+	conn     net.Conn
+	nextHop  net.IP
+	routerID net.IP
+	open     *openResult
+}
+
+// handshake exchanges the OPEN messages with the peer over the given
+// connection, which is closed on failure.
+func (s *session) handshake(conn net.Conn, deadline time.Time) (*handshake, error) {
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("setting deadline on conn to %q: %s", s.PeerAddress, err)
+	}
+
+	addr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("getting local addr for default nexthop to %q", s.PeerAddress)
+	}
+	nextHop := addr.IP
+	if ip := nextHop.To4(); ip != nil {
+		// Connections accepted on the dual stack listener have
+		// IPv4-mapped addresses.
+		nextHop = ip
+	}
+
+	routerID := s.RouterID
+	if routerID == nil {
+		var err error
+		routerID, err = getRouterID(nextHop, s.CurrentNode, s.VRFName)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := sendOpen(conn, s.MyASN, routerID, s.HoldTime); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("send OPEN to %q: %s", s.PeerAddress, err)
+	}
+
+	op, err := readOpen(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read OPEN from %q: %s", s.PeerAddress, err)
+	}
+	if op.asn != s.PeerASN {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected peer ASN %d, want %d", op.asn, s.PeerASN)
+	}
+	if s.MyASN > 65536 && !op.fbasn {
+		conn.Close()
+		return nil, fmt.Errorf("peer does not support 4-byte ASNs")
+	}
+
+	return &handshake{
+		conn:     conn,
+		nextHop:  nextHop,
+		routerID: routerID,
+		open:     op,
+	}, nil
+}
+
+// resolveCollision checks whether the peer opened a connection while
+// we were establishing ours, and picks the one to keep as described in
+// RFC 4271 section 6.8: the connection initiated by the speaker with
+// the higher BGP identifier is preserved.
+func (s *session) resolveCollision(outbound *handshake, deadline time.Time) *handshake {
+	var conn net.Conn
+	select {
+	case conn = <-s.incoming:
+	default:
+		return outbound
+	}
+
+	inbound, err := s.handshake(conn, deadline)
+	if err != nil {
+		level.Info(s.logger).Log("event", "connectionCollision", "error", err, "msg", "failed to exchange OPEN over the connection from peer, keeping ours")
+		return outbound
+	}
+	if bytes.Compare(outbound.routerID.To4(), outbound.open.routerID.To4()) < 0 {
+		level.Info(s.logger).Log("event", "connectionCollision", "msg", "peer has higher BGP identifier, keeping the connection from peer")
+		rejectConn(outbound.conn)
+		return inbound
+	}
+	level.Info(s.logger).Log("event", "connectionCollision", "msg", "local BGP identifier is higher, keeping our connection")
+	rejectConn(inbound.conn)
+	return outbound
+}
+
 func hashRouterID(hostname string) (net.IP, error) {
 	buf := new(bytes.Buffer)
 	err := binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE([]byte(hostname)))
@@ -294,13 +608,14 @@ func hashRouterID(hostname string) (net.IP, error) {
 }
 
 // Ipv4; Use the address as-is.
-// Ipv6; Pick the first ipv4 address on the same interface as the address.
-func getRouterID(addr net.IP, myNode string) (net.IP, error) {
+// Ipv6; Pick the first ipv4 address on the same interface as the address,
+// looking only at the interfaces of the given vrf if not empty.
+func getRouterID(addr net.IP, myNode string, vrf string) (net.IP, error) {
 	if addr.To4() != nil {
 		return addr, nil
 	}
 
-	ifaces, err := net.Interfaces()
+	ifaces, err := localInterfaces(vrf)
 	if err != nil {
 		return hashRouterID(myNode)
 	}
@@ -341,11 +656,12 @@ func getRouterID(addr net.IP, myNode string) (net.IP, error) {
 }
 
 // sendKeepalives sends BGP KEEPALIVE packets at the negotiated rate
-// whenever the session is connected.
+// whenever the session is connected. While the passwords are rotated,
+// it also checks that the peer is still sending messages.
 func (s *session) sendKeepalives() {
 	var (
-		t  *time.Ticker
-		ch <-chan time.Time
+		t, w    *time.Ticker
+		ch, wch <-chan time.Time
 	)
 
 	for {
@@ -356,12 +672,15 @@ func (s *session) sendKeepalives() {
 			s.mu.Unlock()
 			if t != nil {
 				t.Stop()
-				t = nil
-				ch = nil
+				w.Stop()
+				t, w = nil, nil
+				ch, wch = nil, nil
 			}
 			if ht != 0 {
 				t = time.NewTicker(ht / 3)
 				ch = t.C
+				w = time.NewTicker(ht / 6)
+				wch = w.C
 			}
 
 		case <-ch:
@@ -370,10 +689,35 @@ func (s *session) sendKeepalives() {
 				// done here.
 				return
 			}
+
+		case <-wch:
+			s.checkPeerSilence()
 		}
 	}
 }
 
+// checkPeerSilence switches to the other password while the passwords
+// are rotated, if nothing was received from the peer for half of the
+// hold time since the last switch. The segments of a peer that started
+// using the other password are dropped by the kernel, and TCP
+// retransmits them once the passwords match again.
+func (s *session) checkPeerSilence() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil || s.NextPassword == "" {
+		return
+	}
+	last := time.Unix(0, s.lastReceived.Load())
+	if s.passwordSwitched.After(last) {
+		last = s.passwordSwitched
+	}
+	if time.Since(last) < s.actualHoldTime/2 {
+		return
+	}
+	level.Warn(s.logger).Log("event", "peerSilent", "since", last, "msg", "nothing received from peer, it may have switched password")
+	s.switchPassword()
+}
+
 // sendKeepalive sends a single BGP KEEPALIVE packet.
 func (s *session) sendKeepalive() error {
 	s.mu.Lock()
@@ -421,19 +765,66 @@ func (s *session) consumeBGP(conn io.ReadCloser) {
 			// TODO: propagate
 			return
 		}
+		s.lastReceived.Store(time.Now().UnixNano())
 		if hdr.Type == 3 {
 			// TODO: propagate better than just logging directly.
 			err := readNotification(conn)
 			level.Error(s.logger).Log("event", "peerNotification", "error", err, "msg", "peer sent notification, closing session")
 			return
 		}
-		if _, err := io.Copy(io.Discard, io.LimitReader(conn, int64(hdr.Len)-19)); err != nil {
+		body := io.LimitReader(conn, int64(hdr.Len)-19)
+		if hdr.Type == 2 && s.Receive != nil {
+			b, err := io.ReadAll(body)
+			if err != nil {
+				// TODO: propagate
+				return
+			}
+			u, err := decodeUpdate(b)
+			if err != nil {
+				level.Error(s.logger).Log("event", "peerUpdate", "error", err, "msg", "failed to decode UPDATE from peer, closing session")
+				return
+			}
+			s.receive(conn, u)
+		}
+		if hdr.Type == 5 {
+			ipv4, err := readRouteRefresh(body)
+			if err != nil {
+				// TODO: propagate
+				return
+			}
+			if ipv4 {
+				s.refresh(conn)
+			}
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
 			// TODO: propagate
 			return
 		}
 	}
 }
 
+// refresh sends again the advertisements the peer received over the
+// given connection, as asked by a ROUTE-REFRESH message.
+func (s *session) refresh(conn io.ReadCloser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != conn {
+		return
+	}
+	level.Info(s.logger).Log("event", "routeRefresh", "msg", "peer asked for a route refresh, sending advertisements again")
+	for c := range s.advertised {
+		// The prefixes no longer desired are already dirty, and
+		// must stay advertised until they are withdrawn.
+		if s.desired[c] == nil {
+			continue
+		}
+		delete(s.advertised, c)
+		s.dirty[c] = true
+	}
+	s.cond.Broadcast()
+}
+
 func validate(adv *bgp.Advertisement) error {
 	if adv.Prefix.IP.To4() == nil {
 		return fmt.Errorf("cannot advertise non-v4 prefix %q", adv.Prefix)
@@ -465,56 +856,193 @@ func (s *session) Set(advs ...*bgp.Advertisement) error {
 		newAdvs[adv.Prefix.String()] = adv
 	}
 
-	s.new = newAdvs
-	stats.PendingPrefixes(s.PeerAddress, len(s.new))
+	for c := range s.desired {
+		s.dirty[c] = true
+	}
+	for c := range newAdvs {
+		s.dirty[c] = true
+	}
+	s.desired = newAdvs
+	stats.PendingPrefixes(s.PeerAddress, len(s.desired))
+	s.cond.Broadcast()
+
+	return nil
+}
+
+// Update changes the advertisements of the session: the given
+// advertisements replace the ones with the same prefix, and the withdrawn
+// prefixes are no longer advertised.
+//
+// Like Set, changes are propagated to the peer asynchronously.
+func (s *session) Update(add []*bgp.Advertisement, withdraw []*net.IPNet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, adv := range add {
+		if err := validate(adv); err != nil {
+			return err
+		}
+	}
+
+	for _, pfx := range withdraw {
+		c := pfx.String()
+		delete(s.desired, c)
+		s.dirty[c] = true
+	}
+	for _, adv := range add {
+		if !adv.MatchesPeer(s.SessionName) {
+			continue
+		}
+		c := adv.Prefix.String()
+		s.desired[c] = adv
+		s.dirty[c] = true
+	}
+	stats.PendingPrefixes(s.PeerAddress, len(s.desired))
 	s.cond.Broadcast()
 
 	return nil
 }
 
+// SetAOKeys changes the TCP-AO keys of the session, without establishing
+// it again.
+func (s *session) SetAOKeys(keys []config.TCPAOKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.AOKeys) == 0 || len(keys) == 0 {
+		return errors.New("changing the authentication type requires establishing the session again")
+	}
+	peer, err := newAOPeer(s.peerIP, s.VRFName)
+	if err != nil {
+		return err
+	}
+	if s.conn != nil {
+		err := control(s.conn, func(fd int) error {
+			return updateAOKeys(fd, peer, s.AOKeys, keys, false)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.manager.updateListenerAOKeys(s, s.AOKeys, keys)
+	s.AOKeys = keys
+	return nil
+}
+
+// SetPasswords changes the TCP MD5 passwords of the session, without
+// establishing it again. The password in use is kept as long as it is
+// one of the new ones, otherwise the current one replaces it on the
+// established connection.
+func (s *session) SetPasswords(password, nextPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Password == "" || password == "" {
+		return errors.New("changing the authentication type requires establishing the session again")
+	}
+	s.Password, s.NextPassword = password, nextPassword
+	if s.password == password || s.password == nextPassword {
+		stats.NextPasswordActive(s.PeerAddress, s.password != password)
+		return nil
+	}
+	return s.usePassword(password)
+}
+
+// switchPassword alternates between the current and the next password
+// while the passwords are rotated, as a TCP MD5 socket only accepts one
+// password per peer. It must be called with s.mu held.
+func (s *session) switchPassword() {
+	if s.NextPassword == "" {
+		return
+	}
+	password := s.NextPassword
+	if s.password == s.NextPassword {
+		password = s.Password
+	}
+	if err := s.usePassword(password); err != nil {
+		level.Error(s.logger).Log("op", "switchPassword", "error", err, "msg", "failed to switch password")
+		return
+	}
+	level.Info(s.logger).Log("event", "passwordSwitched", "nextPassword", password == s.NextPassword, "msg", "switched password")
+}
+
+// usePassword authenticates the connections of the session with the
+// given password, changing it in place on the established connection.
+// It must be called with s.mu held.
+func (s *session) usePassword(password string) error {
+	if s.conn != nil {
+		err := control(s.conn, func(fd int) error {
+			return setMD5(fd, s.peerIP, password)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.manager.setPassword(s, password)
+	s.passwordSwitched = time.Now()
+	stats.NextPasswordActive(s.PeerAddress, password != s.Password)
+	return nil
+}
+
 // abort closes any existing connection, updates stats, and cleans up
 // state ready for another connection attempt.
 func (s *session) abort() {
 	if s.conn != nil {
 		s.conn.Close()
 		s.conn = nil
+		s.established.Store(false)
 		stats.SessionDown(s.PeerAddress)
+		s.dropReceived()
 	}
 	// Next time we retry the connection, we can just skip straight to
 	// the desired end state.
-	if s.new != nil {
-		s.advertised, s.new = s.new, nil
-		stats.PendingPrefixes(s.PeerAddress, len(s.advertised))
-	}
+	stats.PendingPrefixes(s.PeerAddress, len(s.desired))
 	s.cond.Broadcast()
 }
 
 // Close shuts down the BGP session.
 func (s *session) Close() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if !s.closed {
+		close(s.done)
+	}
 	s.closed = true
 	s.abort()
+	s.mu.Unlock()
+
+	s.manager.unregister(s)
+	select {
+	case conn := <-s.incoming:
+		conn.Close()
+	default:
+	}
 	return nil
 }
 
 // DialTCP does the part of creating a connection manually,  including setting the
 // proper TCP MD5 options when the password is not empty. Works by manipulating
 // the low level FD's, skipping the net.Conn API as it has not hooks to set
-// the necessary sockopts for TCP MD5.
-func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string) (net.Conn, error) {
-	// If srcAddr exists on any of the local network interfaces, use it as the
-	// source address of the TCP socket. Otherwise, use the IPv6 unspecified
-	// address ("::") to let the kernel figure out the source address.
+// the necessary sockopts for TCP MD5. When vrf is not empty, the socket is
+// bound to the VRF device so the connection goes through its routing table.
+// The ttl and minTTL are set on the socket when not zero, see ttlFor. The
+// aoKeys are used instead of the password when set.
+func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string, vrf string, ttl, minTTL int, aoKeys []config.TCPAOKey) (net.Conn, error) {
+	// If srcAddr exists on any of the local network interfaces (of the vrf,
+	// if set), use it as the source address of the TCP socket. Otherwise, use
+	// the IPv6 unspecified address ("::") to let the kernel figure out the
+	// source address.
 	// NOTE: On Linux, "::" also includes "0.0.0.0" (all IPv4 addresses).
 	a := "[::]"
 	if srcAddr != nil {
-		ifs, err := net.Interfaces()
+		ifs, err := localInterfaces(vrf)
 		if err != nil {
-			return nil, fmt.Errorf("querying local interfaces: %w", err)
+			return nil, err
 		}
 
 		if !localAddressExists(ifs, srcAddr) {
+			if vrf != "" {
+				return nil, fmt.Errorf("address %q doesn't exist in vrf %q", srcAddr, vrf)
+			}
 			return nil, fmt.Errorf("address %q doesn't exist on this host", srcAddr)
 		}
 
@@ -580,6 +1108,14 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 		}
 	}()
 
+	if err = bindToVRF(fd, vrf); err != nil {
+		return nil, err
+	}
+
+	if err = setTTL(fd, family, ttl, minTTL); err != nil {
+		return nil, err
+	}
+
 	if password != "" {
 		sig := buildTCPMD5Sig(raddr.IP, password)
 		// Better way may be available in  Go 1.11, see go-review.googlesource.com/c/go/+/72810
@@ -588,6 +1124,16 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 		}
 	}
 
+	if len(aoKeys) > 0 {
+		peer, err := newAOPeer(raddr.IP, vrf)
+		if err != nil {
+			return nil, err
+		}
+		if err = addAOKeys(fd, peer, aoKeys, false); err != nil {
+			return nil, fmt.Errorf("setting TCP-AO keys: %w", err)
+		}
+	}
+
 	if err = unix.Bind(fd, la); err != nil {
 		return nil, os.NewSyscallError("bind", err)
 	}
@@ -654,6 +1200,22 @@ func dialMD5(ctx context.Context, addr string, srcAddr net.IP, password string)
 	}
 }
 
+// setMD5 sets the TCP MD5 signature of the connections with the given
+// address on the socket, or removes it if the password is empty. IPv4
+// addresses are set in their IPv4-mapped form on IPv6 sockets.
+func setMD5(fd int, addr net.IP, password string) error {
+	family, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_DOMAIN)
+	if err != nil {
+		return os.NewSyscallError("getsockopt", err)
+	}
+	sig := buildTCPMD5Sig(addr, password)
+	if family == unix.AF_INET6 && addr.To4() != nil {
+		sig.Addr = unix.SockaddrStorage{Family: unix.AF_INET6}
+		copy(sig.Addr.Data[6:], addr.To16())
+	}
+	return os.NewSyscallError("setsockopt", unix.SetsockoptTCPMD5Sig(fd, unix.IPPROTO_TCP, unix.TCP_MD5SIG, sig))
+}
+
 func buildTCPMD5Sig(addr net.IP, key string) *unix.TCPMD5Sig {
 	t := unix.TCPMD5Sig{}
 	if addr.To4() != nil {
@@ -670,6 +1232,27 @@ func buildTCPMD5Sig(addr net.IP, key string) *unix.TCPMD5Sig {
 	return &t
 }
 
+// control runs f on the file descriptor of the given connection or
+// listener, if it has one.
+func control(c interface{}, f func(fd int) error) error {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	err = rc.Control(func(fd uintptr) {
+		ferr = f(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return ferr
+}
+
 // localAddressExists returns true if the address addr exists on any of the
 // network interfaces in the ifs slice.
 func localAddressExists(ifs []net.Interface, addr net.IP) bool {
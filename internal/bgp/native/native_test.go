@@ -0,0 +1,855 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/bgp/community"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/netroute"
+	"golang.org/x/sys/unix"
+)
+
+// tcpPair returns the two ends of a loopback TCP connection.
+func tcpPair(t testing.TB) (net.Conn, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// readMessageType reads a whole BGP message and returns its type.
+func readMessageType(t *testing.T, conn net.Conn) uint8 {
+	t.Helper()
+	return readMessage(t, conn)[18]
+}
+
+// readMessage reads a whole BGP message, header included.
+func readMessage(t *testing.T, conn net.Conn) []byte {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %s", err)
+	}
+	msg := make([]byte, 19)
+	if _, err := readFull(conn, msg); err != nil {
+		t.Fatalf("read header: %s", err)
+	}
+	msg = append(msg, make([]byte, int(binary.BigEndian.Uint16(msg[16:18]))-19)...)
+	if _, err := readFull(conn, msg[19:]); err != nil {
+		t.Fatalf("read body: %s", err)
+	}
+	return msg
+}
+
+func readFull(conn net.Conn, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := conn.Read(b[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func TestPassiveSession(t *testing.T) {
+	oldAddress := listenAddress
+	listenAddress = "127.0.0.1:0"
+	defer func() { listenAddress = oldAddress }()
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	s, err := sm.NewSession(log.NewNopLogger(), bgp.SessionParameters{
+		PeerAddress:   "127.0.0.1:179",
+		MyASN:         64500,
+		PeerASN:       64501,
+		RouterID:      net.ParseIP("10.0.0.1"),
+		HoldTime:      90 * time.Second,
+		KeepAliveTime: 30 * time.Second,
+		Passive:       true,
+		SessionName:   "peer",
+	})
+	if err != nil {
+		t.Fatalf("create session: %s", err)
+	}
+	defer s.Close()
+
+	l, ok := sm.listeners[""]
+	if !ok {
+		t.Fatalf("expected the session manager to listen for passive sessions")
+	}
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+
+	if err := sendOpen(conn, 64501, net.ParseIP("10.0.0.2"), 90*time.Second); err != nil {
+		t.Fatalf("send open: %s", err)
+	}
+	op, err := readOpen(conn)
+	if err != nil {
+		t.Fatalf("read open: %s", err)
+	}
+	if op.asn != 64500 {
+		t.Fatalf("expected ASN 64500, got %d", op.asn)
+	}
+	if !op.routerID.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("expected router ID 10.0.0.1, got %s", op.routerID)
+	}
+	if got := readMessageType(t, conn); got != 4 {
+		t.Fatalf("expected keepalive, got message type %d", got)
+	}
+
+	err = s.Set(&bgp.Advertisement{
+		Prefix: ipnet("1.2.3.4/32"),
+		Peers:  []string{"peer"},
+	})
+	if err != nil {
+		t.Fatalf("set advertisements: %s", err)
+	}
+	if got := readMessageType(t, conn); got != 2 {
+		t.Fatalf("expected update, got message type %d", got)
+	}
+
+	// One update advertises the new prefix, another withdraws the old one.
+	err = s.Update([]*bgp.Advertisement{{
+		Prefix: ipnet("1.2.3.5/32"),
+		Peers:  []string{"peer"},
+	}}, []*net.IPNet{ipnet("1.2.3.4/32")})
+	if err != nil {
+		t.Fatalf("update advertisements: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if got := readMessageType(t, conn); got != 2 {
+			t.Fatalf("expected update, got message type %d", got)
+		}
+	}
+	sess := s.(*session)
+	sess.mu.Lock()
+	if _, ok := sess.advertised["1.2.3.4/32"]; ok || len(sess.advertised) != 1 {
+		t.Errorf("expected only 1.2.3.5/32 to be advertised, got %v", sess.advertised)
+	}
+	sess.mu.Unlock()
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("close session: %s", err)
+	}
+	if len(sm.listeners) != 0 {
+		t.Fatalf("expected the session manager to stop listening")
+	}
+}
+
+func TestPassiveSessionListenRetry(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	oldAddress := listenAddress
+	listenAddress = busy.Addr().String()
+	defer func() { listenAddress = oldAddress }()
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	s, err := sm.NewSession(log.NewNopLogger(), bgp.SessionParameters{
+		PeerAddress:   "127.0.0.1:179",
+		MyASN:         64500,
+		PeerASN:       64501,
+		RouterID:      net.ParseIP("10.0.0.1"),
+		HoldTime:      90 * time.Second,
+		KeepAliveTime: 30 * time.Second,
+		Passive:       true,
+		SessionName:   "peer",
+	})
+	if err != nil {
+		t.Fatalf("create session: %s", err)
+	}
+	defer s.Close()
+
+	listening := func() bool {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		_, ok := sm.listeners[""]
+		return ok
+	}
+	if listening() {
+		t.Fatalf("expected listening to fail while the address is in use")
+	}
+
+	busy.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !listening() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected listening to be retried")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestRouteRefresh(t *testing.T) {
+	oldAddress := listenAddress
+	listenAddress = "127.0.0.1:0"
+	defer func() { listenAddress = oldAddress }()
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	s, err := sm.NewSession(log.NewNopLogger(), bgp.SessionParameters{
+		PeerAddress:   "127.0.0.1:179",
+		MyASN:         64500,
+		PeerASN:       64501,
+		RouterID:      net.ParseIP("10.0.0.1"),
+		HoldTime:      90 * time.Second,
+		KeepAliveTime: 30 * time.Second,
+		Passive:       true,
+		SessionName:   "peer",
+	})
+	if err != nil {
+		t.Fatalf("create session: %s", err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", sm.listeners[""].Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+	if err := sendOpen(conn, 64501, net.ParseIP("10.0.0.2"), 90*time.Second); err != nil {
+		t.Fatalf("send open: %s", err)
+	}
+	if _, err := readOpen(conn); err != nil {
+		t.Fatalf("read open: %s", err)
+	}
+	if got := readMessageType(t, conn); got != 4 {
+		t.Fatalf("expected keepalive, got message type %d", got)
+	}
+
+	err = s.Set(&bgp.Advertisement{
+		Prefix: ipnet("1.2.3.4/32"),
+		Peers:  []string{"peer"},
+	}, &bgp.Advertisement{
+		Prefix: ipnet("1.2.3.5/32"),
+		Peers:  []string{"peer"},
+	})
+	if err != nil {
+		t.Fatalf("set advertisements: %s", err)
+	}
+	readPrefixes := func() []string {
+		t.Helper()
+		res := []string{}
+		for _, u := range decodeUpdates(t, readMessage(t, conn), maxExtendedMessageLen) {
+			res = append(res, u.nlri...)
+		}
+		sort.Strings(res)
+		return res
+	}
+	want := []string{"1.2.3.4/32", "1.2.3.5/32"}
+	if diff := cmp.Diff(want, readPrefixes()); diff != "" {
+		t.Fatalf("unexpected advertised prefixes (-want +got)\n%s", diff)
+	}
+
+	routeRefresh := func(afi uint16) {
+		t.Helper()
+		msg := []byte{
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0x00, 23, // len
+			5,          // ROUTE-REFRESH
+			0x00, 0x00, // AFI, filled below
+			0, // reserved
+			1, // unicast
+		}
+		binary.BigEndian.PutUint16(msg[19:21], afi)
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatalf("send route refresh: %s", err)
+		}
+	}
+
+	// The IPv6 routes are not advertised, there is nothing to send again.
+	routeRefresh(2)
+	routeRefresh(1)
+	if diff := cmp.Diff(want, readPrefixes()); diff != "" {
+		t.Fatalf("unexpected refreshed prefixes (-want +got)\n%s", diff)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+		t.Fatalf("set deadline: %s", err)
+	}
+	if n, err := conn.Read(make([]byte, 19)); err == nil {
+		t.Fatalf("expected no more messages, got %d bytes", n)
+	}
+}
+
+func TestReceiveRoutes(t *testing.T) {
+	oldAddress := listenAddress
+	listenAddress = "127.0.0.1:0"
+	defer func() { listenAddress = oldAddress }()
+
+	policy := &config.ReceivePolicy{
+		Mode:         config.ReceiveFiltered,
+		Prefixes:     []*net.IPNet{ipnet("10.0.0.0/8")},
+		RoutingTable: 4243,
+	}
+	oldHasNetAdmin := hasNetAdmin
+	hasNetAdmin = func() (bool, error) { return true, nil }
+	defer func() { hasNetAdmin = oldHasNetAdmin }()
+	// The routes are installed only when allowed to change the
+	// routing tables.
+	probe := netroute.Route{Dst: ipnet("198.51.100.0/24"), Gateway: net.ParseIP("127.0.0.2"), Table: policy.RoutingTable}
+	canInstall := netroute.Add(probe) == nil
+	if canInstall {
+		if err := netroute.Delete(probe); err != nil {
+			t.Fatalf("delete route: %s", err)
+		}
+	}
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	s, err := sm.NewSession(log.NewNopLogger(), bgp.SessionParameters{
+		PeerAddress:   "127.0.0.1:179",
+		MyASN:         64500,
+		PeerASN:       64501,
+		RouterID:      net.ParseIP("10.0.0.1"),
+		HoldTime:      90 * time.Second,
+		KeepAliveTime: 30 * time.Second,
+		Passive:       true,
+		SessionName:   "peer",
+		Receive:       policy,
+	})
+	if err != nil {
+		t.Fatalf("create session: %s", err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", sm.listeners[""].Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+	if err := sendOpen(conn, 64501, net.ParseIP("10.0.0.2"), 90*time.Second); err != nil {
+		t.Fatalf("send open: %s", err)
+	}
+	if _, err := readOpen(conn); err != nil {
+		t.Fatalf("read open: %s", err)
+	}
+	if got := readMessageType(t, conn); got != 4 {
+		t.Fatalf("expected keepalive, got message type %d", got)
+	}
+
+	var attrs bytes.Buffer
+	if err := encodePathAttrs(&attrs, 64501, false, true, net.ParseIP("127.0.0.2").To4(), &bgp.Advertisement{}); err != nil {
+		t.Fatalf("encode path attributes: %s", err)
+	}
+	if _, err := sendUpdate(conn, maxMessageLen, attrs.Bytes(), []*net.IPNet{ipnet("10.1.0.0/16"), ipnet("192.168.0.0/24")}); err != nil {
+		t.Fatalf("send update: %s", err)
+	}
+
+	waitFor := func(want []bgp.ReceivedRoutes) {
+		t.Helper()
+		var got []bgp.ReceivedRoutes
+		for i := 0; i < 50; i++ {
+			got = sm.ReceivedRoutes()
+			if cmp.Equal(want, got) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("unexpected received routes (-want +got)\n%s", cmp.Diff(want, got))
+	}
+	waitFor([]bgp.ReceivedRoutes{{
+		Peer:   "127.0.0.1:179",
+		Routes: []bgp.Route{{Prefix: ipnet("10.1.0.0/16"), NextHop: net.ParseIP("127.0.0.2").To4()}},
+	}})
+	installed := netroute.Route{Dst: ipnet("10.1.0.0/16"), Gateway: net.ParseIP("127.0.0.2"), Table: policy.RoutingTable, Protocol: unix.RTPROT_BGP}
+	if canInstall {
+		if err := netroute.Add(installed); !errors.Is(err, unix.EEXIST) {
+			t.Fatalf("expected the route to be installed, adding it again returned %v", err)
+		}
+	}
+	if got := testutil.ToFloat64(stats.receivedPrefixes.WithLabelValues("127.0.0.1:179")); got != 1 {
+		t.Fatalf("expected 1 received prefix, got %v", got)
+	}
+
+	if _, err := sendWithdraw(conn, maxMessageLen, []*net.IPNet{ipnet("10.1.0.0/16")}); err != nil {
+		t.Fatalf("send withdraw: %s", err)
+	}
+	waitFor([]bgp.ReceivedRoutes{{
+		Peer:   "127.0.0.1:179",
+		Routes: []bgp.Route{},
+	}})
+	if canInstall {
+		if err := netroute.Delete(installed); !errors.Is(err, unix.ESRCH) {
+			t.Fatalf("expected the route to be removed, removing it again returned %v", err)
+		}
+	}
+
+	// The routes are dropped with the connection.
+	if _, err := sendUpdate(conn, maxMessageLen, attrs.Bytes(), []*net.IPNet{ipnet("10.2.0.0/16")}); err != nil {
+		t.Fatalf("send update: %s", err)
+	}
+	waitFor([]bgp.ReceivedRoutes{{
+		Peer:   "127.0.0.1:179",
+		Routes: []bgp.Route{{Prefix: ipnet("10.2.0.0/16"), NextHop: net.ParseIP("127.0.0.2").To4()}},
+	}})
+	conn.Close()
+	waitFor([]bgp.ReceivedRoutes{{
+		Peer:   "127.0.0.1:179",
+		Routes: []bgp.Route{},
+	}})
+}
+
+func TestConnectionCollision(t *testing.T) {
+	tests := []struct {
+		desc         string
+		peerRouterID string
+		keepInbound  bool
+	}{
+		{
+			desc:         "local identifier is higher",
+			peerRouterID: "10.0.0.0",
+			keepInbound:  false,
+		},
+		{
+			desc:         "peer identifier is higher",
+			peerRouterID: "10.0.0.2",
+			keepInbound:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			s := &session{
+				SessionParameters: bgp.SessionParameters{
+					PeerAddress: "127.0.0.1:179",
+					MyASN:       64500,
+					PeerASN:     64501,
+					RouterID:    net.ParseIP("10.0.0.1"),
+					HoldTime:    90 * time.Second,
+				},
+				logger:   log.NewNopLogger(),
+				incoming: make(chan net.Conn, 1),
+			}
+			peerRouterID := net.ParseIP(test.peerRouterID)
+
+			outPeer, outLocal := tcpPair(t)
+			outbound := &handshake{
+				conn:     outLocal,
+				routerID: s.RouterID,
+				open:     &openResult{asn: 64501, routerID: peerRouterID},
+			}
+
+			inPeer, inLocal := tcpPair(t)
+			s.incoming <- inLocal
+			if err := sendOpen(inPeer, 64501, peerRouterID, 90*time.Second); err != nil {
+				t.Fatalf("send open: %s", err)
+			}
+
+			got := s.resolveCollision(outbound, time.Now().Add(5*time.Second))
+
+			rejected := outPeer
+			want := outLocal
+			if test.keepInbound {
+				want = inLocal
+			} else {
+				// Skip the OPEN sent over the inbound connection.
+				if _, err := readOpen(inPeer); err != nil {
+					t.Fatalf("read open: %s", err)
+				}
+				rejected = inPeer
+			}
+			if got.conn != want {
+				t.Fatalf("kept the wrong connection")
+			}
+			if msgType := readMessageType(t, rejected); msgType != 3 {
+				t.Fatalf("expected notification on the rejected connection, got message type %d", msgType)
+			}
+		})
+	}
+}
+
+func TestPasswordRotation(t *testing.T) {
+	loopback := net.ParseIP("127.0.0.1")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+	if err := setListenerMD5(l.(*net.TCPListener), loopback, "current"); err != nil {
+		t.Fatalf("set listener password: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := dialMD5(ctx, l.Addr().String(), nil, "current", "", 0, 0, nil)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer client.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+	defer server.Close()
+
+	s := &session{
+		SessionParameters: bgp.SessionParameters{
+			PeerAddress:  l.Addr().String(),
+			Password:     "current",
+			NextPassword: "next",
+		},
+		logger:         log.NewNopLogger(),
+		manager:        NewSessionManager(log.NewNopLogger()).(*sessionManager),
+		peerIP:         loopback,
+		conn:           client,
+		password:       "current",
+		actualHoldTime: 90 * time.Second,
+	}
+	s.lastReceived.Store(time.Now().UnixNano())
+
+	exchange := func() {
+		t.Helper()
+		if _, err := client.Write([]byte("ping")); err != nil {
+			t.Fatalf("write: %s", err)
+		}
+		if err := server.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("set deadline: %s", err)
+		}
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(server, buf); err != nil {
+			t.Fatalf("read: %s", err)
+		}
+	}
+	setPeerPassword := func(password string) {
+		t.Helper()
+		err := control(server, func(fd int) error {
+			return setMD5(fd, loopback, password)
+		})
+		if err != nil {
+			t.Fatalf("set peer password: %s", err)
+		}
+	}
+	checkPassword := func(password string, nextActive float64) {
+		t.Helper()
+		if s.password != password {
+			t.Fatalf("expected password %q, got %q", password, s.password)
+		}
+		if got := testutil.ToFloat64(stats.nextPasswordActive.WithLabelValues(s.PeerAddress)); got != nextActive {
+			t.Fatalf("expected next_password_active to be %v, got %v", nextActive, got)
+		}
+	}
+	exchange()
+
+	// The peer goes silent after switching to the next password.
+	setPeerPassword("next")
+	s.checkPeerSilence()
+	if s.password != "current" {
+		t.Fatalf("switched password while the peer is not silent")
+	}
+	s.lastReceived.Store(time.Now().Add(-time.Minute).UnixNano())
+	s.checkPeerSilence()
+	checkPassword("next", 1)
+	exchange()
+	s.checkPeerSilence()
+	checkPassword("next", 1)
+
+	// The next password becomes the current one.
+	if err := s.SetPasswords("next", ""); err != nil {
+		t.Fatalf("set passwords: %s", err)
+	}
+	checkPassword("next", 0)
+	exchange()
+
+	// The password in use is rotated out.
+	if err := s.SetPasswords("newer", ""); err != nil {
+		t.Fatalf("set passwords: %s", err)
+	}
+	checkPassword("newer", 0)
+	setPeerPassword("newer")
+	exchange()
+
+	if err := s.SetPasswords("", ""); err == nil {
+		t.Fatalf("expected removing the password to require a new session")
+	}
+}
+
+func TestPasswordSwitchedByPeer(t *testing.T) {
+	oldAddress := listenAddress
+	listenAddress = "127.0.0.1:0"
+	defer func() { listenAddress = oldAddress }()
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	s, err := sm.NewSession(log.NewNopLogger(), bgp.SessionParameters{
+		PeerAddress:   "127.0.0.1:179",
+		MyASN:         64500,
+		PeerASN:       64501,
+		RouterID:      net.ParseIP("10.0.0.1"),
+		HoldTime:      3 * time.Second,
+		KeepAliveTime: time.Second,
+		Passive:       true,
+		SessionName:   "peer",
+		Password:      "current",
+		NextPassword:  "next",
+	})
+	if err != nil {
+		t.Fatalf("create session: %s", err)
+	}
+	defer s.Close()
+	sess := s.(*session)
+
+	sm.mu.Lock()
+	l := sm.listeners[""]
+	sm.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialMD5(ctx, l.Addr().String(), nil, "current", "", 0, 0, nil)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+
+	if err := sendOpen(conn, 64501, net.ParseIP("10.0.0.2"), 3*time.Second); err != nil {
+		t.Fatalf("send open: %s", err)
+	}
+	if _, err := readOpen(conn); err != nil {
+		t.Fatalf("read open: %s", err)
+	}
+	if got := readMessageType(t, conn); got != 4 {
+		t.Fatalf("expected keepalive, got message type %d", got)
+	}
+	sess.mu.Lock()
+	established := sess.conn
+	sess.mu.Unlock()
+
+	// The peer switches to the next password in the middle of the
+	// session, and keeps sending keepalives the session drops until it
+	// switches as well.
+	err = control(conn, func(fd int) error {
+		return setMD5(fd, net.ParseIP("127.0.0.1"), "next")
+	})
+	if err != nil {
+		t.Fatalf("set peer password: %s", err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := sendKeepalive(conn); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	usesNext := func() bool {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		return sess.password == "next"
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !usesNext() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the session to switch to the next password")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(stats.nextPasswordActive.WithLabelValues(sess.PeerAddress)); got != 1 {
+		t.Fatalf("expected next_password_active to be 1, got %v", got)
+	}
+
+	// The messages signed with the next password flow again, on the same
+	// connection.
+	before := sess.lastReceived.Load()
+	deadline = time.Now().Add(10 * time.Second)
+	for sess.lastReceived.Load() == before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the keepalives of the peer to be received again")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := readMessageType(t, conn); got != 4 {
+		t.Fatalf("expected keepalive, got message type %d", got)
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.conn != established {
+		t.Fatalf("expected the session not to be established again")
+	}
+}
+
+// sendingSession returns a session established with the given connection.
+func sendingSession(conn net.Conn) *session {
+	return &session{
+		SessionParameters: bgp.SessionParameters{
+			PeerAddress: "127.0.0.1:179",
+			MyASN:       64500,
+			PeerASN:     64500,
+			SessionName: "peer",
+		},
+		conn:       conn,
+		nextHop:    net.ParseIP("10.0.0.1").To4(),
+		desired:    map[string]*bgp.Advertisement{},
+		advertised: map[string]*bgp.Advertisement{},
+		dirty:      map[string]bool{},
+	}
+}
+
+func TestSendDirtyGroupsAttributes(t *testing.T) {
+	client, server := tcpPair(t)
+	s := sendingSession(client)
+
+	c, err := community.New("0:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	advs := []*bgp.Advertisement{
+		{Prefix: ipnet("1.2.3.4/32"), LocalPref: 100},
+		{Prefix: ipnet("1.2.3.5/32"), LocalPref: 100},
+		{Prefix: ipnet("1.2.3.6/32"), LocalPref: 200},
+		{Prefix: ipnet("1.2.3.7/32"), LocalPref: 100, Communities: []community.BGPCommunity{c}},
+		{Prefix: ipnet("1.2.3.8/32"), LocalPref: 200},
+	}
+	for _, adv := range advs {
+		s.desired[adv.Prefix.String()] = adv
+		s.dirty[adv.Prefix.String()] = true
+	}
+
+	got := map[string][]string{}
+	gotMsgs := func(b []byte) {
+		for _, u := range decodeUpdates(t, b, maxMessageLen) {
+			sort.Strings(u.nlri)
+			sort.Strings(u.withdrawn)
+			got[string(u.attrs)] = append(got[string(u.attrs)], strings.Join(u.nlri, ","))
+			if len(u.withdrawn) > 0 {
+				got["withdrawn"] = append(got["withdrawn"], strings.Join(u.withdrawn, ","))
+			}
+		}
+	}
+	read := func() []byte {
+		t.Helper()
+		if err := server.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+			t.Fatalf("set deadline: %s", err)
+		}
+		b, _ := io.ReadAll(server)
+		return b
+	}
+	attrs := func(adv *bgp.Advertisement) string {
+		var b bytes.Buffer
+		if err := encodePathAttrs(&b, 64500, true, false, s.nextHop, adv); err != nil {
+			t.Fatalf("encode path attributes: %s", err)
+		}
+		return b.String()
+	}
+
+	if err := s.sendDirty(true, false, maxMessageLen); err != nil {
+		t.Fatalf("send updates: %s", err)
+	}
+	gotMsgs(read())
+	want := map[string][]string{
+		attrs(advs[0]): {"1.2.3.4/32,1.2.3.5/32"},
+		attrs(advs[2]): {"1.2.3.6/32,1.2.3.8/32"},
+		attrs(advs[3]): {"1.2.3.7/32"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected updates (-want +got)\n%s", diff)
+	}
+
+	// The withdrawals are sent together, the unchanged prefixes are not
+	// sent again.
+	got = map[string][]string{}
+	for _, adv := range advs[:3] {
+		delete(s.desired, adv.Prefix.String())
+		s.dirty[adv.Prefix.String()] = true
+	}
+	s.dirty[advs[4].Prefix.String()] = true
+	if err := s.sendDirty(true, false, maxMessageLen); err != nil {
+		t.Fatalf("send updates: %s", err)
+	}
+	gotMsgs(read())
+	want = map[string][]string{
+		"":          {""},
+		"withdrawn": {"1.2.3.4/32,1.2.3.5/32,1.2.3.6/32"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected updates (-want +got)\n%s", diff)
+	}
+	if len(s.advertised) != 2 {
+		t.Fatalf("expected 2 advertised prefixes, got %d", len(s.advertised))
+	}
+}
+
+// BenchmarkSendDirty measures advertising 5000 prefixes sharing the same
+// path attributes to a local peer, packed in as few UPDATE messages as
+// possible or one per message.
+func BenchmarkSendDirty(b *testing.B) {
+	var attrs bytes.Buffer
+	adv := &bgp.Advertisement{Prefix: ipnet("10.0.0.1/32"), LocalPref: 100}
+	if err := encodePathAttrs(&attrs, 64500, true, false, net.ParseIP("10.0.0.1").To4(), adv); err != nil {
+		b.Fatalf("encode path attributes: %s", err)
+	}
+
+	for _, bc := range []struct {
+		name   string
+		maxLen int
+	}{
+		{"packed", maxMessageLen},
+		{"extended", maxExtendedMessageLen},
+		{"one per message", 23 + attrs.Len() + 5},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			client, server := tcpPair(b)
+			go func() {
+				_, _ = io.Copy(io.Discard, server)
+			}()
+			s := sendingSession(client)
+			for i := 0; i < 5000; i++ {
+				pfx := ipnet(fmt.Sprintf("10.%d.%d.%d/32", i/65536, i/256%256, i%256))
+				s.desired[pfx.String()] = &bgp.Advertisement{Prefix: pfx, LocalPref: 100}
+			}
+
+			sent := stats.updatesSent.WithLabelValues(s.PeerAddress)
+			before := testutil.ToFloat64(sent)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Like when the session is established.
+				s.advertised = map[string]*bgp.Advertisement{}
+				for c := range s.desired {
+					s.dirty[c] = true
+				}
+				if err := s.sendDirty(true, false, bc.maxLen); err != nil {
+					b.Fatalf("send updates: %s", err)
+				}
+			}
+			b.ReportMetric((testutil.ToFloat64(sent)-before)/float64(b.N), "updates/op")
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/netroute"
+	"golang.org/x/sys/unix"
+)
+
+// These are overridden in tests.
+var (
+	addKernelRoute    = netroute.Add
+	deleteKernelRoute = netroute.Delete
+	hasNetAdmin       = netroute.HasNetAdmin
+)
+
+// routeKey identifies the route to a prefix in a routing table.
+type routeKey struct {
+	table  uint32
+	prefix string
+}
+
+// candidateRoute is a route to a prefix accepted from the peer of a session.
+type candidateRoute struct {
+	session *session
+	route   bgp.Route
+}
+
+// prefixRoutes are the routes to a prefix accepted from the peers of the
+// sessions installing them in the same routing table. The first one is
+// the one installed, if installed is set, the others replace it in turn
+// when it is withdrawn.
+type prefixRoutes struct {
+	candidates []candidateRoute
+	installed  bool
+}
+
+// checkRoutingTable fails if the routes accepted with the given policy
+// are installed in a routing table the speaker is not allowed to change.
+func checkRoutingTable(p *config.ReceivePolicy) error {
+	if p == nil || p.RoutingTable == 0 {
+		return nil
+	}
+	ok, err := hasNetAdmin()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("installing the received routes in routing table %d requires the NET_ADMIN capability", p.RoutingTable)
+	}
+	return nil
+}
+
+// receive applies the given UPDATE received over the given connection
+// to the routes accepted from the peer.
+func (s *session) receive(conn io.ReadCloser, u *receivedUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != conn {
+		return
+	}
+	for _, pfx := range u.withdrawn {
+		s.dropRoute(pfx.String())
+	}
+	for _, pfx := range u.nlri {
+		c := pfx.String()
+		if !s.Receive.Accepts(pfx) {
+			// The policy never changes during the session, so
+			// the prefix can't have been accepted before.
+			continue
+		}
+		if r, ok := s.received[c]; ok && r.NextHop.Equal(u.nextHop) {
+			continue
+		}
+		s.dropRoute(c)
+		r := bgp.Route{Prefix: pfx, NextHop: u.nextHop}
+		s.received[c] = r
+		s.installRoute(r)
+	}
+	stats.ReceivedPrefixes(s.PeerAddress, len(s.received))
+}
+
+// dropReceived forgets all the routes accepted from the peer, as they
+// are no longer valid once the connection is closed. It must be called
+// with s.mu held.
+func (s *session) dropReceived() {
+	for c := range s.received {
+		s.dropRoute(c)
+	}
+	stats.ReceivedPrefixes(s.PeerAddress, 0)
+}
+
+// dropRoute forgets the route to the given prefix accepted from the peer,
+// if any. It must be called with s.mu held.
+func (s *session) dropRoute(prefix string) {
+	r, ok := s.received[prefix]
+	if !ok {
+		return
+	}
+	delete(s.received, prefix)
+	if s.Receive.RoutingTable == 0 {
+		return
+	}
+	s.manager.dropRoute(s, r)
+}
+
+// installRoute installs the given route in the routing table of the
+// session, if any, unless a route to the same prefix accepted from
+// another peer is already there. It must be called with s.mu held.
+func (s *session) installRoute(r bgp.Route) {
+	if s.Receive.RoutingTable == 0 {
+		return
+	}
+	s.manager.installRoute(s, r)
+}
+
+// installRoute adds the given route of the given session to the
+// candidates to its prefix, installing it if it is the first one.
+func (sm *sessionManager) installRoute(s *session, r bgp.Route) {
+	sm.routesMu.Lock()
+	defer sm.routesMu.Unlock()
+
+	k := routeKey{table: s.Receive.RoutingTable, prefix: r.Prefix.String()}
+	pr := sm.routes[k]
+	if pr == nil {
+		pr = &prefixRoutes{}
+		sm.routes[k] = pr
+	}
+	pr.candidates = append(pr.candidates, candidateRoute{session: s, route: r})
+	if len(pr.candidates) == 1 {
+		pr.installed = pr.candidates[0].install()
+		return
+	}
+	level.Info(s.logger).Log("op", "receive", "prefix", k.prefix, "table", k.table, "msg", "not installing route, the routing table already has a route to the prefix from another peer")
+}
+
+// dropRoute removes the given route of the given session from the
+// candidates to its prefix. If it was installed, the next candidate
+// replaces it.
+func (sm *sessionManager) dropRoute(s *session, r bgp.Route) {
+	sm.routesMu.Lock()
+	defer sm.routesMu.Unlock()
+
+	k := routeKey{table: s.Receive.RoutingTable, prefix: r.Prefix.String()}
+	pr := sm.routes[k]
+	if pr == nil {
+		return
+	}
+	i := 0
+	for i < len(pr.candidates) && pr.candidates[i].session != s {
+		i++
+	}
+	if i == len(pr.candidates) {
+		return
+	}
+	dropped := pr.candidates[i]
+	pr.candidates = append(pr.candidates[:i], pr.candidates[i+1:]...)
+	if len(pr.candidates) == 0 {
+		delete(sm.routes, k)
+	}
+	if i != 0 {
+		return
+	}
+	if pr.installed {
+		dropped.remove()
+		pr.installed = false
+	}
+	if len(pr.candidates) > 0 {
+		pr.installed = pr.candidates[0].install()
+	}
+}
+
+// install adds the route to the routing table of its session, and returns
+// whether it did.
+func (c candidateRoute) install() bool {
+	s := c.session
+	err := addKernelRoute(s.kernelRoute(c.route))
+	if errors.Is(err, unix.EEXIST) {
+		level.Info(s.logger).Log("op", "receive", "prefix", c.route.Prefix, "table", s.Receive.RoutingTable, "msg", "not installing route, the routing table already has a route to the prefix")
+		return false
+	}
+	if err != nil {
+		level.Error(s.logger).Log("op", "receive", "prefix", c.route.Prefix, "table", s.Receive.RoutingTable, "error", err, "msg", "failed to install route in the routing table")
+		return false
+	}
+	return true
+}
+
+// remove deletes the route from the routing table of its session.
+func (c candidateRoute) remove() {
+	s := c.session
+	err := deleteKernelRoute(s.kernelRoute(c.route))
+	if err != nil && !errors.Is(err, unix.ESRCH) {
+		level.Error(s.logger).Log("op", "receive", "prefix", c.route.Prefix, "error", err, "msg", "failed to remove route from the routing table")
+	}
+}
+
+func (s *session) kernelRoute(r bgp.Route) netroute.Route {
+	return netroute.Route{
+		Dst:      r.Prefix,
+		Gateway:  r.NextHop,
+		Table:    s.Receive.RoutingTable,
+		Protocol: unix.RTPROT_BGP,
+	}
+}
+
+// ReceivedRoutes returns the routes accepted from the peers of the
+// sessions receiving routes.
+func (sm *sessionManager) ReceivedRoutes() []bgp.ReceivedRoutes {
+	sm.mu.Lock()
+	sessions := make([]*session, 0, len(sm.sessions))
+	for s := range sm.sessions {
+		sessions = append(sessions, s)
+	}
+	sm.mu.Unlock()
+
+	res := []bgp.ReceivedRoutes{}
+	for _, s := range sessions {
+		if s.Receive == nil {
+			continue
+		}
+		s.mu.Lock()
+		routes := make([]bgp.Route, 0, len(s.received))
+		for _, r := range s.received {
+			routes = append(routes, r)
+		}
+		s.mu.Unlock()
+		sort.Slice(routes, func(i, j int) bool {
+			return routes[i].Prefix.String() < routes[j].Prefix.String()
+		})
+		res = append(res, bgp.ReceivedRoutes{
+			Peer:   s.PeerAddress,
+			VRF:    s.VRFName,
+			Routes: routes,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Peer != res[j].Peer {
+			return res[i].Peer < res[j].Peer
+		}
+		return res[i].VRF < res[j].VRF
+	})
+	return res
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/netroute"
+)
+
+func TestReceivedRoutesFailover(t *testing.T) {
+	// The routing table, by prefix.
+	table := map[string]string{}
+	oldAdd, oldDelete := addKernelRoute, deleteKernelRoute
+	addKernelRoute = func(r netroute.Route) error {
+		table[r.Dst.String()] = r.Gateway.String()
+		return nil
+	}
+	deleteKernelRoute = func(r netroute.Route) error {
+		if table[r.Dst.String()] != r.Gateway.String() {
+			t.Fatalf("deleting route %s via %s, the table has %v", r.Dst, r.Gateway, table)
+		}
+		delete(table, r.Dst.String())
+		return nil
+	}
+	defer func() { addKernelRoute, deleteKernelRoute = oldAdd, oldDelete }()
+
+	sm := NewSessionManager(log.NewNopLogger()).(*sessionManager)
+	newSession := func() *session {
+		return &session{
+			SessionParameters: bgp.SessionParameters{
+				Receive: &config.ReceivePolicy{Mode: config.ReceiveAll, RoutingTable: 100},
+			},
+			logger:   log.NewNopLogger(),
+			manager:  sm,
+			received: map[string]bgp.Route{},
+		}
+	}
+	s1, s2, s3 := newSession(), newSession(), newSession()
+	receive := func(s *session, nextHop string) {
+		s.dropRoute("10.1.0.0/16")
+		r := bgp.Route{Prefix: ipnet("10.1.0.0/16"), NextHop: net.ParseIP(nextHop)}
+		s.received["10.1.0.0/16"] = r
+		s.installRoute(r)
+	}
+
+	tests := []struct {
+		desc   string
+		update func()
+		want   map[string]string
+	}{
+		{
+			desc:   "first route installed",
+			update: func() { receive(s1, "192.168.1.1") },
+			want:   map[string]string{"10.1.0.0/16": "192.168.1.1"},
+		},
+		{
+			desc: "other routes to the same prefix are candidates",
+			update: func() {
+				receive(s2, "192.168.1.2")
+				receive(s3, "192.168.1.3")
+			},
+			want: map[string]string{"10.1.0.0/16": "192.168.1.1"},
+		},
+		{
+			desc:   "candidate withdrawn",
+			update: func() { s3.dropRoute("10.1.0.0/16") },
+			want:   map[string]string{"10.1.0.0/16": "192.168.1.1"},
+		},
+		{
+			desc:   "installed route withdrawn, the remaining candidate replaces it",
+			update: func() { s1.dropRoute("10.1.0.0/16") },
+			want:   map[string]string{"10.1.0.0/16": "192.168.1.2"},
+		},
+		{
+			desc:   "session down",
+			update: func() { s2.dropReceived() },
+			want:   map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		test.update()
+		if diff := cmp.Diff(test.want, table); diff != "" {
+			t.Fatalf("%q: unexpected routing table (-want +got)\n%s", test.desc, diff)
+		}
+	}
+	if len(sm.routes) != 0 {
+		t.Fatalf("expected no candidate routes left, got %v", sm.routes)
+	}
+}
+
+func TestReceiveRoutingTableRequiresNetAdmin(t *testing.T) {
+	oldHasNetAdmin := hasNetAdmin
+	hasNetAdmin = func() (bool, error) { return false, nil }
+	defer func() { hasNetAdmin = oldHasNetAdmin }()
+
+	sm := NewSessionManager(log.NewNopLogger())
+	params := bgp.SessionParameters{
+		PeerAddress: "127.0.0.1:179",
+		MyASN:       64500,
+		PeerASN:     64501,
+		Receive:     &config.ReceivePolicy{Mode: config.ReceiveAll, RoutingTable: 100},
+	}
+	if _, err := sm.NewSession(log.NewNopLogger(), params); err == nil {
+		t.Fatalf("expected installing the received routes without NET_ADMIN to fail")
+	}
+
+	params.Receive.RoutingTable = 0
+	s, err := sm.NewSession(log.NewNopLogger(), params)
+	if err != nil {
+		t.Fatalf("expected receiving without installing the routes to succeed, got %s", err)
+	}
+	s.Close()
+}
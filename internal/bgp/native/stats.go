@@ -37,13 +37,30 @@ var stats = metrics{
 		Name:      "pending_prefixes_total",
 		Help:      "Number of prefixes that should be advertised on the BGP session",
 	}, labels),
+
+	receivedPrefixes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: bgpmetrics.Namespace,
+		Subsystem: bgpmetrics.Subsystem,
+		Name:      bgpmetrics.ReceivedPrefixes.Name,
+		Help:      bgpmetrics.ReceivedPrefixes.Help,
+	}, labels),
+
+	nextPasswordActive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: bgpmetrics.Namespace,
+		Subsystem: bgpmetrics.Subsystem,
+		Name:      "next_password_active",
+		Help:      "Whether the BGP session is authenticated with the next password of its secret (1) or with the current one (0)",
+	}, labels),
 }
 
 type metrics struct {
-	sessionUp       *prometheus.GaugeVec
-	updatesSent     *prometheus.CounterVec
-	prefixes        *prometheus.GaugeVec
-	pendingPrefixes *prometheus.GaugeVec
+	sessionUp        *prometheus.GaugeVec
+	updatesSent      *prometheus.CounterVec
+	prefixes         *prometheus.GaugeVec
+	pendingPrefixes  *prometheus.GaugeVec
+	receivedPrefixes *prometheus.GaugeVec
+
+	nextPasswordActive *prometheus.GaugeVec
 }
 
 func init() {
@@ -51,6 +68,8 @@ func init() {
 	prometheus.MustRegister(stats.updatesSent)
 	prometheus.MustRegister(stats.prefixes)
 	prometheus.MustRegister(stats.pendingPrefixes)
+	prometheus.MustRegister(stats.receivedPrefixes)
+	prometheus.MustRegister(stats.nextPasswordActive)
 }
 
 func (m *metrics) NewSession(addr string) {
@@ -64,7 +83,9 @@ func (m *metrics) DeleteSession(addr string) {
 	m.sessionUp.DeleteLabelValues(addr)
 	m.prefixes.DeleteLabelValues(addr)
 	m.pendingPrefixes.DeleteLabelValues(addr)
+	m.receivedPrefixes.DeleteLabelValues(addr)
 	m.updatesSent.DeleteLabelValues(addr)
+	m.nextPasswordActive.DeleteLabelValues(addr)
 }
 
 func (m *metrics) SessionUp(addr string) {
@@ -77,8 +98,8 @@ func (m *metrics) SessionDown(addr string) {
 	m.prefixes.WithLabelValues(addr).Set(0)
 }
 
-func (m *metrics) UpdateSent(addr string) {
-	m.updatesSent.WithLabelValues(addr).Inc()
+func (m *metrics) UpdatesSent(addr string, n int) {
+	m.updatesSent.WithLabelValues(addr).Add(float64(n))
 }
 
 func (m *metrics) PendingPrefixes(addr string, n int) {
@@ -89,3 +110,15 @@ func (m *metrics) AdvertisedPrefixes(addr string, n int) {
 	m.prefixes.WithLabelValues(addr).Set(float64(n))
 	m.pendingPrefixes.WithLabelValues(addr).Set(float64(n))
 }
+
+func (m *metrics) ReceivedPrefixes(addr string, n int) {
+	m.receivedPrefixes.WithLabelValues(addr).Set(float64(n))
+}
+
+func (m *metrics) NextPasswordActive(addr string, active bool) {
+	v := 0.0
+	if active {
+		v = 1
+	}
+	m.nextPasswordActive.WithLabelValues(addr).Set(v)
+}
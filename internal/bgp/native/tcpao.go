@@ -0,0 +1,247 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"unsafe"
+
+	"go.universe.tf/metallb/internal/byteorder"
+	"go.universe.tf/metallb/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// TCP-AO (RFC 5925) socket options and flags, from include/uapi/linux/tcp.h.
+// They are not available in golang.org/x/sys yet.
+const (
+	tcpAOAddKey = 38 // TCP_AO_ADD_KEY
+	tcpAODelKey = 39 // TCP_AO_DEL_KEY
+	tcpAOInfo   = 40 // TCP_AO_INFO
+
+	tcpAOKeyfIfindex = 1 << 0 // TCP_AO_KEYF_IFINDEX
+
+	// Bitfields of the flags of tcpAOAdd, tcpAODel and tcpAOInfoOpt.
+	tcpAOSetCurrent = 0
+	tcpAOSetRNext   = 1
+	tcpAODelAsync   = 2
+)
+
+// aoFlags returns the flags of a TCP-AO option with the given bitfields
+// set. The bitfields are allocated from the least significant bit on
+// little endian hosts, and from the most significant one on big endian
+// hosts.
+func aoFlags(bitfields ...uint) uint32 {
+	littleEndian := byteorder.Host == binary.LittleEndian
+
+	res := uint32(0)
+	for _, b := range bitfields {
+		if littleEndian {
+			res |= 1 << b
+			continue
+		}
+		res |= 1 << (31 - b)
+	}
+	return res
+}
+
+// tcpAOAdd is struct tcp_ao_add.
+type tcpAOAdd struct {
+	Addr      [128]byte
+	AlgName   [64]byte
+	Ifindex   int32
+	Flags     uint32
+	Reserved2 uint16
+	Prefix    uint8
+	SndID     uint8
+	RcvID     uint8
+	MacLen    uint8
+	KeyFlags  uint8
+	KeyLen    uint8
+	Key       [80]byte
+}
+
+// tcpAODel is struct tcp_ao_del.
+type tcpAODel struct {
+	Addr       [128]byte
+	Ifindex    int32
+	Flags      uint32
+	Reserved2  uint16
+	Prefix     uint8
+	SndID      uint8
+	RcvID      uint8
+	CurrentKey uint8
+	RNext      uint8
+	KeyFlags   uint8
+}
+
+// tcpAOInfoOpt is struct tcp_ao_info_opt.
+type tcpAOInfoOpt struct {
+	Flags          uint32
+	Reserved2      uint16
+	CurrentKey     uint8
+	RNext          uint8
+	PktGood        uint64
+	PktBad         uint64
+	PktKeyNotFound uint64
+	PktAORequired  uint64
+	PktDroppedICMP uint64
+}
+
+// aoPeer is the peer the TCP-AO keys of a socket apply to.
+type aoPeer struct {
+	addr net.IP
+	// ifindex is the index of the VRF device the socket is bound to, if any.
+	ifindex int32
+}
+
+func newAOPeer(addr net.IP, vrf string) (aoPeer, error) {
+	res := aoPeer{addr: addr}
+	if vrf == "" {
+		return res, nil
+	}
+	i, err := net.InterfaceByName(vrf)
+	if err != nil {
+		return aoPeer{}, fmt.Errorf("vrf %q doesn't exist on this host: %w", vrf, err)
+	}
+	res.ifindex = int32(i.Index)
+	return res, nil
+}
+
+// addAOKeys adds the given keys to the socket, making the first one the
+// current key unless the socket is listening.
+func addAOKeys(fd int, peer aoPeer, keys []config.TCPAOKey, listening bool) error {
+	for i, k := range keys {
+		if err := addAOKey(fd, peer, k, i == 0 && !listening); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateAOKeys replaces the old keys of the socket with the new ones, and
+// makes the first new key the current one unless the socket is listening.
+// The keys are replaced one at a time, so the connection is never left
+// without a key matching the ones of the peer.
+func updateAOKeys(fd int, peer aoPeer, old, new []config.TCPAOKey, listening bool) error {
+	if len(new) == 0 {
+		return fmt.Errorf("no TCP-AO keys")
+	}
+	isOld := map[config.TCPAOKey]bool{}
+	for _, k := range old {
+		isOld[k] = true
+	}
+	isNew := map[config.TCPAOKey]bool{}
+	for _, k := range new {
+		isNew[k] = true
+	}
+
+	for _, k := range new {
+		if isOld[k] {
+			continue
+		}
+		if err := addAOKey(fd, peer, k, false); err != nil {
+			return err
+		}
+	}
+	if !listening {
+		if err := setAOCurrentKey(fd, new[0]); err != nil {
+			return err
+		}
+	}
+	for _, k := range old {
+		if isNew[k] {
+			continue
+		}
+		if err := delAOKey(fd, peer, k, listening); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addAOKey(fd int, peer aoPeer, k config.TCPAOKey, current bool) error {
+	opt := tcpAOAdd{
+		SndID:  k.SendID,
+		RcvID:  k.RecvID,
+		KeyLen: uint8(len(k.Key)),
+	}
+	if err := peer.fill(fd, &opt.Addr, &opt.Prefix, &opt.Ifindex, &opt.KeyFlags); err != nil {
+		return err
+	}
+	copy(opt.AlgName[:], k.Algorithm)
+	copy(opt.Key[:], k.Key)
+	if current {
+		opt.Flags = aoFlags(tcpAOSetCurrent, tcpAOSetRNext)
+	}
+	return setsockoptStruct(fd, tcpAOAddKey, unsafe.Pointer(&opt), unsafe.Sizeof(opt))
+}
+
+func delAOKey(fd int, peer aoPeer, k config.TCPAOKey, listening bool) error {
+	opt := tcpAODel{
+		SndID: k.SendID,
+		RcvID: k.RecvID,
+	}
+	if err := peer.fill(fd, &opt.Addr, &opt.Prefix, &opt.Ifindex, &opt.KeyFlags); err != nil {
+		return err
+	}
+	if listening {
+		// The keys of listening sockets may be in use by the
+		// connections being established.
+		opt.Flags = aoFlags(tcpAODelAsync)
+	}
+	return setsockoptStruct(fd, tcpAODelKey, unsafe.Pointer(&opt), unsafe.Sizeof(opt))
+}
+
+// setAOCurrentKey makes the given key the one used to send, and asks the
+// peer to do the same.
+func setAOCurrentKey(fd int, k config.TCPAOKey) error {
+	opt := tcpAOInfoOpt{
+		Flags:      aoFlags(tcpAOSetCurrent, tcpAOSetRNext),
+		CurrentKey: k.SendID,
+		RNext:      k.RecvID,
+	}
+	return setsockoptStruct(fd, tcpAOInfo, unsafe.Pointer(&opt), unsafe.Sizeof(opt))
+}
+
+// fill sets the address, prefix and VRF of the peer in a TCP-AO option,
+// according to the family of the socket.
+func (p aoPeer) fill(fd int, addr *[128]byte, prefix *uint8, ifindex *int32, keyFlags *uint8) error {
+	family, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_DOMAIN)
+	if err != nil {
+		return os.NewSyscallError("getsockopt", err)
+	}
+	// struct sockaddr_in and sockaddr_in6 start with the family in
+	// host byte order and the port, followed by the address.
+	*(*uint16)(unsafe.Pointer(&addr[0])) = uint16(family)
+	switch family {
+	case unix.AF_INET:
+		ip := p.addr.To4()
+		if ip == nil {
+			return fmt.Errorf("can't set the TCP-AO key of IPv6 peer %s on an IPv4 socket", p.addr)
+		}
+		copy(addr[4:], ip)
+		*prefix = 32
+	case unix.AF_INET6:
+		// IPv4 peers are in their IPv4-mapped form on IPv6 sockets.
+		copy(addr[8:], p.addr.To16())
+		*prefix = 128
+	default:
+		return fmt.Errorf("unexpected socket family %d", family)
+	}
+	if p.ifindex != 0 {
+		*ifindex = p.ifindex
+		*keyFlags |= tcpAOKeyfIfindex
+	}
+	return nil
+}
+
+func setsockoptStruct(fd int, opt int, p unsafe.Pointer, size uintptr) error {
+	_, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), unix.IPPROTO_TCP, uintptr(opt), uintptr(p), size, 0)
+	if errno != 0 {
+		return os.NewSyscallError("setsockopt", errno)
+	}
+	return nil
+}
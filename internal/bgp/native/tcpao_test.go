@@ -0,0 +1,103 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.universe.tf/metallb/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+func TestTCPAOKeyRotation(t *testing.T) {
+	oldKeys := []config.TCPAOKey{
+		{SendID: 1, RecvID: 1, Algorithm: "hmac(sha1)", Key: "old-key"},
+	}
+	newKeys := []config.TCPAOKey{
+		{SendID: 2, RecvID: 2, Algorithm: "hmac(sha256)", Key: "new-key"},
+		{SendID: 1, RecvID: 1, Algorithm: "hmac(sha1)", Key: "old-key"},
+	}
+	loopback := net.ParseIP("127.0.0.1")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+	err = control(l, func(fd int) error {
+		return addAOKeys(fd, aoPeer{addr: loopback}, oldKeys, true)
+	})
+	if errors.Is(err, unix.ENOPROTOOPT) {
+		t.Skip("TCP-AO is not supported by the kernel")
+	}
+	if err != nil {
+		t.Fatalf("set listener keys: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := dialMD5(ctx, l.Addr().String(), nil, "", "", 0, 0, oldKeys)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer client.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+	defer server.Close()
+
+	exchange := func() {
+		t.Helper()
+		if _, err := client.Write([]byte("ping")); err != nil {
+			t.Fatalf("write: %s", err)
+		}
+		if err := server.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("set deadline: %s", err)
+		}
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(server, buf); err != nil {
+			t.Fatalf("read: %s", err)
+		}
+	}
+	exchange()
+
+	// Both sides first add the new key, then drop the old one.
+	for _, c := range []net.Conn{server, client} {
+		err := control(c, func(fd int) error {
+			return updateAOKeys(fd, aoPeer{addr: loopback}, oldKeys, newKeys, false)
+		})
+		if err != nil {
+			t.Fatalf("add new key: %s", err)
+		}
+	}
+	exchange()
+	for _, c := range []net.Conn{server, client} {
+		err := control(c, func(fd int) error {
+			return updateAOKeys(fd, aoPeer{addr: loopback}, newKeys, newKeys[:1], false)
+		})
+		if err != nil {
+			t.Fatalf("remove old key: %s", err)
+		}
+	}
+	exchange()
+}
+
+func TestTCPAOOptionSizes(t *testing.T) {
+	// The sizes of the structs in include/uapi/linux/tcp.h.
+	if size := unsafe.Sizeof(tcpAOAdd{}); size != 288 {
+		t.Fatalf("expected tcp_ao_add to be 288 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(tcpAODel{}); size != 144 {
+		t.Fatalf("expected tcp_ao_del to be 144 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(tcpAOInfoOpt{}); size != 48 {
+		t.Fatalf("expected tcp_ao_info_opt to be 48 bytes, got %d", size)
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"net"
+	"os"
+
+	"go.universe.tf/metallb/internal/bgp"
+	"golang.org/x/sys/unix"
+)
+
+// maxTTL is the TTL of the packets sent to multi-hops away peers when
+// not set explicitly, and to the peers with GTSM enabled.
+const maxTTL = 255
+
+// ttlFor returns the TTL of the packets sent to the peer of the session,
+// and the minimum TTL of the packets accepted from it. Zero means
+// leaving the default of the system.
+func ttlFor(p bgp.SessionParameters) (int, int) {
+	switch {
+	case p.TTLSecurityHops != 0:
+		// RFC 5082: the packets are sent with the maximum TTL, so the
+		// ones from the peer can't have traveled more hops than allowed.
+		return maxTTL, maxTTL + 1 - int(p.TTLSecurityHops)
+	case p.EBGPMultiHopTTL != 0:
+		return int(p.EBGPMultiHopTTL), 0
+	case p.EBGPMultiHop:
+		return maxTTL, 0
+	}
+	return 0, 0
+}
+
+// setTTL sets the TTL and the minimum TTL on the given socket, of the
+// given address family, when not zero.
+func setTTL(fd int, family int, ttl, minTTL int) error {
+	level, ttlOpt, minTTLOpt := unix.IPPROTO_IP, unix.IP_TTL, unix.IP_MINTTL
+	if family == unix.AF_INET6 {
+		level, ttlOpt, minTTLOpt = unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, unix.IPV6_MINHOPCOUNT
+	}
+	if ttl != 0 {
+		if err := unix.SetsockoptInt(fd, level, ttlOpt, ttl); err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+	}
+	if minTTL != 0 {
+		if err := unix.SetsockoptInt(fd, level, minTTLOpt, minTTL); err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+	}
+	return nil
+}
+
+// setConnTTL sets the TTL and the minimum TTL on the socket of the given
+// connection, when not zero. The options are chosen from the address of
+// the peer rather than from the family of the socket, as the IPv4 peers
+// connect to IPv6 sockets listening on all the addresses.
+func setConnTTL(conn net.Conn, ttl, minTTL int) error {
+	if ttl == 0 && minTTL == 0 {
+		return nil
+	}
+	family := unix.AF_INET6
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok && a.IP.To4() != nil {
+		family = unix.AF_INET
+	}
+	return control(conn, func(fd int) error {
+		return setTTL(fd, family, ttl, minTTL)
+	})
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.universe.tf/metallb/internal/bgp"
+	"golang.org/x/sys/unix"
+)
+
+func TestTTLFor(t *testing.T) {
+	tests := []struct {
+		desc       string
+		params     bgp.SessionParameters
+		wantTTL    int
+		wantMinTTL int
+	}{
+		{
+			desc: "single hop",
+		},
+		{
+			desc:    "multi hop",
+			params:  bgp.SessionParameters{EBGPMultiHop: true},
+			wantTTL: 255,
+		},
+		{
+			desc:    "multi hop with ttl",
+			params:  bgp.SessionParameters{EBGPMultiHop: true, EBGPMultiHopTTL: 3},
+			wantTTL: 3,
+		},
+		{
+			desc:       "ttl security",
+			params:     bgp.SessionParameters{TTLSecurityHops: 2},
+			wantTTL:    255,
+			wantMinTTL: 254,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ttl, minTTL := ttlFor(test.params)
+			if ttl != test.wantTTL || minTTL != test.wantMinTTL {
+				t.Fatalf("expected ttl %d and min ttl %d, got %d and %d", test.wantTTL, test.wantMinTTL, ttl, minTTL)
+			}
+		})
+	}
+}
+
+func TestDialSetsTTL(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The listener replies with the default TTL of 64, which must
+	// be accepted for the connection to succeed.
+	conn, err := dialMD5(ctx, l.Addr().String(), nil, "", "", 255, 64, nil)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer conn.Close()
+
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %s", err)
+	}
+	var ttl, minTTL int
+	var ttlErr, minTTLErr error
+	err = rc.Control(func(fd uintptr) {
+		ttl, ttlErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL)
+		minTTL, minTTLErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MINTTL)
+	})
+	if err != nil || ttlErr != nil || minTTLErr != nil {
+		t.Fatalf("getsockopt: %v %v %v", err, ttlErr, minTTLErr)
+	}
+	if ttl != 255 || minTTL != 64 {
+		t.Fatalf("expected ttl 255 and min ttl 64, got %d and %d", ttl, minTTL)
+	}
+}
+
+func TestAcceptedConnSetsTTL(t *testing.T) {
+	// IPv4 peers connect to the v4-mapped addresses of the listener.
+	l, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		t.Skipf("listen on all the IPv6 addresses: %s", err)
+	}
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	client, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer client.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+	defer conn.Close()
+
+	if err := setConnTTL(conn, 255, 64); err != nil {
+		t.Fatalf("set ttl: %s", err)
+	}
+
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %s", err)
+	}
+	var ttl, minTTL int
+	var ttlErr, minTTLErr error
+	err = rc.Control(func(fd uintptr) {
+		ttl, ttlErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL)
+		minTTL, minTTLErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MINTTL)
+	})
+	if err != nil || ttlErr != nil || minTTLErr != nil {
+		t.Fatalf("getsockopt: %v %v %v", err, ttlErr, minTTLErr)
+	}
+	if ttl != 255 || minTTL != 64 {
+		t.Fatalf("expected ttl 255 and min ttl 64, got %d and %d", ttl, minTTL)
+	}
+}
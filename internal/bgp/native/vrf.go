@@ -0,0 +1,64 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysClassNet is where the kernel exposes the network interfaces,
+// overridden in tests.
+var sysClassNet = "/sys/class/net"
+
+// localInterfaces returns the interfaces of the given VRF, including the
+// VRF device itself, or all the interfaces of the host if vrf is empty.
+func localInterfaces(vrf string) ([]net.Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("querying local interfaces: %w", err)
+	}
+	if vrf == "" {
+		return ifs, nil
+	}
+	return interfacesInVRF(ifs, vrf)
+}
+
+// interfacesInVRF filters the given interfaces, keeping the VRF device
+// and the interfaces enslaved to it.
+func interfacesInVRF(ifs []net.Interface, vrf string) ([]net.Interface, error) {
+	found := false
+	res := []net.Interface{}
+	for _, i := range ifs {
+		if i.Name == vrf {
+			found = true
+			res = append(res, i)
+			continue
+		}
+		master, err := os.Readlink(filepath.Join(sysClassNet, i.Name, "master"))
+		if err != nil {
+			// Not enslaved to any device.
+			continue
+		}
+		if filepath.Base(master) == vrf {
+			res = append(res, i)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("vrf %q doesn't exist on this host", vrf)
+	}
+	return res, nil
+}
+
+// bindToVRF binds the given socket to the VRF device, so the routing
+// table of the VRF is used for its traffic.
+func bindToVRF(fd int, vrf string) error {
+	if vrf == "" {
+		return nil
+	}
+	return os.NewSyscallError("setsockopt", unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, vrf))
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package native
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+func TestInterfacesInVRF(t *testing.T) {
+	dir := t.TempDir()
+	oldSysClassNet := sysClassNet
+	sysClassNet = dir
+	defer func() { sysClassNet = oldSysClassNet }()
+
+	for _, i := range []string{"lo", "eth0", "eth1", "eth2", "red", "blue"} {
+		if err := os.Mkdir(filepath.Join(dir, i), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for iface, master := range map[string]string{"eth0": "red", "eth1": "blue"} {
+		if err := os.Symlink(filepath.Join("..", master), filepath.Join(dir, iface, "master")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ifs := []net.Interface{{Name: "lo"}, {Name: "eth0"}, {Name: "eth1"}, {Name: "eth2"}, {Name: "red"}, {Name: "blue"}}
+
+	tests := []struct {
+		desc     string
+		vrf      string
+		want     []string
+		mustFail bool
+	}{
+		{
+			desc: "red",
+			vrf:  "red",
+			want: []string{"eth0", "red"},
+		},
+		{
+			desc: "blue",
+			vrf:  "blue",
+			want: []string{"eth1", "blue"},
+		},
+		{
+			desc:     "missing vrf",
+			vrf:      "green",
+			mustFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			res, err := interfacesInVRF(ifs, test.vrf)
+			if test.mustFail {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			got := []string{}
+			for _, i := range res {
+				got = append(got, i.Name)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("unexpected interfaces (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDialBoundToDevice(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The loopback device stands in for a VRF device, as the routing
+	// table lookup goes through the device the socket is bound to.
+	conn, err := dialMD5(ctx, l.Addr().String(), nil, "", "lo", 0, 0, nil)
+	if err != nil {
+		t.Fatalf("dial bound to lo: %s", err)
+	}
+	conn.Close()
+
+	if _, err := dialMD5(ctx, l.Addr().String(), nil, "", "missing-vrf", 0, 0, nil); err == nil {
+		t.Fatalf("expected dialing through a missing device to fail")
+	}
+}
+
+// withVRFNetns runs the test in a new network namespace, where the red
+// VRF contains dummy0 with 192.168.10.1/24.
+func withVRFNetns(t *testing.T, test func(t *testing.T)) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating a network namespace requires root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("the ip command is not available")
+	}
+
+	// The namespace is bound to the thread, so are the sockets created and
+	// the commands run from it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	hostNs, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		t.Fatalf("opening the host namespace: %s", err)
+	}
+	defer hostNs.Close()
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		t.Skipf("creating a network namespace: %s", err)
+	}
+	defer func() {
+		if err := unix.Setns(int(hostNs.Fd()), unix.CLONE_NEWNET); err != nil {
+			t.Fatalf("restoring the host namespace: %s", err)
+		}
+	}()
+
+	for _, cmd := range [][]string{
+		{"link", "set", "lo", "up"},
+		{"link", "add", "red", "type", "vrf", "table", "10"},
+		{"link", "set", "red", "up"},
+		{"link", "add", "dummy0", "type", "dummy"},
+		{"link", "set", "dummy0", "master", "red", "up"},
+		{"addr", "add", "192.168.10.1/24", "dev", "dummy0"},
+	} {
+		if out, err := exec.Command("ip", cmd...).CombinedOutput(); err != nil {
+			t.Skipf("ip %v: %s %s", cmd, err, out)
+		}
+	}
+
+	// sysfs shows the interfaces of the namespace it was mounted from.
+	dir := t.TempDir()
+	if err := unix.Mount("sysfs", dir, "sysfs", 0, ""); err != nil {
+		t.Skipf("mounting sysfs: %s", err)
+	}
+	defer unix.Unmount(dir, unix.MNT_DETACH)
+	oldSysClassNet := sysClassNet
+	sysClassNet = filepath.Join(dir, "class", "net")
+	defer func() { sysClassNet = oldSysClassNet }()
+
+	test(t)
+}
+
+func TestDialInVRF(t *testing.T) {
+	withVRFNetns(t, func(t *testing.T) {
+		ifs, err := localInterfaces("red")
+		if err != nil {
+			t.Fatalf("listing the interfaces of red: %s", err)
+		}
+		got := []string{}
+		for _, i := range ifs {
+			got = append(got, i.Name)
+		}
+		if diff := cmp.Diff([]string{"red", "dummy0"}, got); diff != "" {
+			t.Fatalf("unexpected interfaces (-want +got)\n%s", diff)
+		}
+
+		lc := net.ListenConfig{
+			Control: func(_, _ string, c syscall.RawConn) error {
+				var err error
+				if cerr := c.Control(func(fd uintptr) {
+					err = bindToVRF(int(fd), "red")
+				}); cerr != nil {
+					return cerr
+				}
+				return err
+			},
+		}
+		l, err := lc.Listen(context.Background(), "tcp4", "192.168.10.1:0")
+		if err != nil {
+			t.Fatalf("listen in red: %s", err)
+		}
+		defer l.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn, err := dialMD5(ctx, l.Addr().String(), net.ParseIP("192.168.10.1"), "", "red", 0, 0, nil)
+		if err != nil {
+			t.Fatalf("dial in red: %s", err)
+		}
+		conn.Close()
+
+		// The address is routed in the table of the VRF only.
+		if _, err := dialMD5(ctx, l.Addr().String(), nil, "", "", 0, 0, nil); err == nil {
+			t.Fatalf("expected dialing outside of the vrf to fail")
+		}
+		if _, err := dialMD5(ctx, l.Addr().String(), net.ParseIP("127.0.0.1"), "", "red", 0, 0, nil); err == nil {
+			t.Fatalf("expected dialing from an address outside of the vrf to fail")
+		}
+	})
+}
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package byteorder // import "go.universe.tf/metallb/internal/byteorder"
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// Host is the byte order of the host, in which the kernel exposes and
+// expects the values of its interfaces.
+var Host = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -17,9 +17,12 @@ package config // import "go.universe.tf/metallb/internal/config"
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"net"
+	"net/url"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,25 +31,32 @@ import (
 	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/bgp/community"
+	"go.universe.tf/metallb/internal/ipfamily"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/yaml"
 )
 
 type ClusterResources struct {
-	Pools              []metallbv1beta1.IPAddressPool    `json:"ipaddresspools"`
-	Peers              []metallbv1beta2.BGPPeer          `json:"bgppeers"`
-	BFDProfiles        []metallbv1beta1.BFDProfile       `json:"bfdprofiles"`
-	BGPAdvs            []metallbv1beta1.BGPAdvertisement `json:"bgpadvertisements"`
-	L2Advs             []metallbv1beta1.L2Advertisement  `json:"l2advertisements"`
-	LegacyAddressPools []metallbv1beta1.AddressPool      `json:"legacyaddresspools"`
-	Communities        []metallbv1beta1.Community        `json:"communities"`
-	PasswordSecrets    map[string]corev1.Secret          `json:"passwordsecrets"`
-	Nodes              []corev1.Node                     `json:"nodes"`
-	Namespaces         []corev1.Namespace                `json:"namespaces"`
-	BGPExtras          corev1.ConfigMap                  `json:"bgpextras"`
+	Pools              []metallbv1beta1.IPAddressPool            `json:"ipaddresspools"`
+	Peers              []metallbv1beta2.BGPPeer                  `json:"bgppeers"`
+	BFDProfiles        []metallbv1beta1.BFDProfile               `json:"bfdprofiles"`
+	BGPAdvs            []metallbv1beta1.BGPAdvertisement         `json:"bgpadvertisements"`
+	L2Advs             []metallbv1beta1.L2Advertisement          `json:"l2advertisements"`
+	LegacyAddressPools []metallbv1beta1.AddressPool              `json:"legacyaddresspools"`
+	Communities        []metallbv1beta1.Community                `json:"communities"`
+	PasswordSecrets    map[string]corev1.Secret                  `json:"passwordsecrets"`
+	Nodes              []corev1.Node                             `json:"nodes"`
+	Namespaces         []corev1.Namespace                        `json:"namespaces"`
+	BGPExtras          corev1.ConfigMap                          `json:"bgpextras"`
+	FRRSnippets        []metallbv1beta1.FRRSnippet               `json:"frrsnippets"`
+	OSPFAdvs           []metallbv1beta1.OSPFAdvertisement        `json:"ospfadvertisements"`
+	OSPFAreas          []metallbv1beta1.OSPFArea                 `json:"ospfareas"`
+	KernelRouteAdvs    []metallbv1beta1.KernelRouteAdvertisement `json:"kernelrouteadvertisements"`
 }
 
 // Config is a parsed MetalLB configuration.
@@ -59,6 +69,20 @@ type Config struct {
 	BFDProfiles map[string]*BFDProfile
 	// Protocol dependent extra config. Currently used only by FRR
 	BGPExtras string
+	// Raw FRR configuration snippets, by name.
+	FRRSnippets map[string]*FRRSnippet
+	// OSPF areas, by name.
+	OSPFAreas map[string]*OSPFArea
+}
+
+// FRRSnippet is raw FRR configuration applied on a set of nodes.
+type FRRSnippet struct {
+	// Snippet name.
+	Name string
+	// The configuration to append to the one generated by MetalLB.
+	Config string
+	// Only apply the snippet on nodes that match one of these selectors.
+	NodeSelectors []labels.Selector
 }
 
 // Pools contains address pools and its namespace/service specific allocations.
@@ -78,12 +102,14 @@ type Proto string
 const (
 	BGP    Proto = "bgp"
 	Layer2 Proto = "layer2"
+	OSPF   Proto = "ospf"
+	Kernel Proto = "kernel"
 )
 
 const bgpExtrasField = "extras"
 
 var Protocols = []Proto{
-	BGP, Layer2,
+	BGP, Layer2, OSPF, Kernel,
 }
 
 // Peer is the configuration of a BGP peering session.
@@ -92,10 +118,20 @@ type Peer struct {
 	Name string
 	// AS number to use for the local end of the session.
 	MyASN uint32
+	// Optional per node AS number to use for the local end of the session,
+	// instead of MyASN.
+	MyASNFrom *NodeValueRef
 	// AS number to expect from the remote end of the session.
 	ASN uint32
-	// Address to dial when establishing the session.
+	// Address to dial when establishing the session. Nil if the address
+	// is resolved on each node via DynamicAddr.
 	Addr net.IP
+	// Optional per node resolution of the address of the peer.
+	DynamicAddr *DynamicPeerAddress
+	// Optional range of addresses to accept sessions from, instead of a single peer.
+	ListenRange *net.IPNet
+	// Wait for the peer to open the session instead of dialing it.
+	Passive bool
 	// Source address to use when establishing the session.
 	SrcAddr net.IP
 	// Port to dial when establishing the session.
@@ -106,20 +142,140 @@ type Peer struct {
 	KeepaliveTime time.Duration
 	// BGP router ID to advertise to the peer
 	RouterID net.IP
+	// Optional per node router ID to advertise to the peer, instead of RouterID.
+	RouterIDFrom *NodeValueRef
 	// Only connect to this peer on nodes that match one of these
 	// selectors.
 	NodeSelectors []labels.Selector
 	// Authentication password for routers enforcing TCP MD5 authenticated sessions
 	Password string
+	// Optional password the router is about to switch to, accepted
+	// alongside Password while the credentials are rotated.
+	NextPassword string
+	// TCP-AO key chain for routers enforcing TCP-AO authenticated sessions,
+	// the first key being used to send.
+	AOKeys []TCPAOKey
 	// The optional BFD profile to be used for this BGP session
 	BFDProfile string
 	// Optional ebgp peer is multi-hops away.
 	EBGPMultiHop bool
+	// Optional TTL of the packets sent to a multi-hops away ebgp peer.
+	EBGPMultiHopTTL uint32
+	// Optional number of hops the packets from the peer may travel (RFC 5082).
+	TTLSecurityHops uint32
 	// Optional name of the vrf to establish the session from
 	VRF string
+	// Optional routes accepted from the peer, all of them being ignored
+	// if nil.
+	Receive *ReceivePolicy
+	// Optional raw FRR configuration rendered in the router block of the peer.
+	RawFRRConfig string
 	// TODO: more BGP session settings
 }
 
+// ReceiveMode selects the routes accepted from a BGP peer.
+type ReceiveMode string
+
+const (
+	ReceiveNone     ReceiveMode = "none"
+	ReceiveAll      ReceiveMode = "all"
+	ReceiveFiltered ReceiveMode = "filtered"
+)
+
+// ReceivePolicy describes the routes accepted from a BGP peer.
+type ReceivePolicy struct {
+	// ReceiveAll or ReceiveFiltered.
+	Mode ReceiveMode
+	// Prefixes the accepted routes must be contained in, with ReceiveFiltered.
+	Prefixes []*net.IPNet
+	// Optional kernel routing table the accepted routes are installed in.
+	RoutingTable uint32
+}
+
+// Accepts tells if the route to the given prefix is accepted from the peer.
+func (r *ReceivePolicy) Accepts(prefix *net.IPNet) bool {
+	if r == nil {
+		return false
+	}
+	if r.Mode == ReceiveAll {
+		return true
+	}
+	ones, bits := prefix.Mask.Size()
+	for _, p := range r.Prefixes {
+		pOnes, pBits := p.Mask.Size()
+		if pBits == bits && pOnes <= ones && p.Contains(prefix.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// DynamicAddressSource is where a node finds the address of a dynamic peer.
+type DynamicAddressSource string
+
+const (
+	// DynamicAddressDefaultGateway peers with the default gateway of the node.
+	DynamicAddressDefaultGateway DynamicAddressSource = "DefaultGateway"
+	// DynamicAddressNodeLabel peers with the address in a label of the node.
+	DynamicAddressNodeLabel DynamicAddressSource = "NodeLabel"
+	// DynamicAddressNodeAnnotation peers with the address in an annotation of the node.
+	DynamicAddressNodeAnnotation DynamicAddressSource = "NodeAnnotation"
+	// DynamicAddressInterface establishes an unnumbered session over an interface of the node.
+	DynamicAddressInterface DynamicAddressSource = "Interface"
+)
+
+// DynamicPeerAddress describes how each node resolves the address of a peer.
+type DynamicPeerAddress struct {
+	Source DynamicAddressSource
+	// Name of the label or annotation, for the NodeLabel and NodeAnnotation sources.
+	Key string
+	// Name of the interface, for the Interface source.
+	Interface string
+	// Family of the default gateway, for the DefaultGateway source.
+	IPFamily ipfamily.Family
+}
+
+// NodeValueSource tells if a per node value is stored in a label or
+// in an annotation of the node.
+type NodeValueSource string
+
+const (
+	NodeValueLabel      NodeValueSource = "NodeLabel"
+	NodeValueAnnotation NodeValueSource = "NodeAnnotation"
+)
+
+// NodeValueRef references a label or an annotation of the node.
+type NodeValueRef struct {
+	Source NodeValueSource
+	Key    string
+}
+
+// AuthType is the TCP option authenticating a BGP session.
+type AuthType string
+
+const (
+	AuthMD5 AuthType = "MD5"
+	AuthAO  AuthType = "AO"
+)
+
+// TCPAOKey is a key of a TCP-AO key chain, as stored in a secret.
+type TCPAOKey struct {
+	// SendID identifies the key in the segments sent to the peer.
+	SendID uint8 `json:"sendID"`
+	// RecvID identifies the key in the segments received from the peer.
+	RecvID uint8 `json:"recvID"`
+	// Algorithm is the MAC algorithm, one of TCPAOAlgorithms.
+	Algorithm string `json:"algorithm"`
+	Key       string `json:"key"`
+}
+
+// TCPAOAlgorithms are the MAC algorithms supported for TCP-AO keys,
+// named after the kernel crypto API.
+var TCPAOAlgorithms = []string{"hmac(sha1)", "cmac(aes128)", "hmac(sha256)"}
+
+// tcpAOMaxKeyLen is the maximum length of a TCP-AO key accepted by the kernel.
+const tcpAOMaxKeyLen = 80
+
 // Pool is the configuration of an IP address pool.
 type Pool struct {
 	// Pool Name
@@ -137,6 +293,15 @@ type Pool struct {
 	// If false, prevents IP addresses to be automatically assigned
 	// from this pool.
 	AutoAssign bool
+	// If true, the externalIPs of the services in the pool are
+	// announced.
+	AnnounceExternalIPs bool
+	// If true, the pool holds the service cluster IP ranges of the
+	// cluster, which are announced as a whole and never allocated.
+	ServiceClusterIPRange bool
+	// The external IPAM system addresses are leased from, when the
+	// other addresses of the pool are exhausted.
+	IPAM *IPAM
 
 	// The list of BGPAdvertisements associated with this address pool.
 	BGPAdvertisements []*BGPAdvertisement
@@ -144,11 +309,39 @@ type Pool struct {
 	// The list of L2Advertisements associated with this address pool.
 	L2Advertisements []*L2Advertisement
 
+	// The list of OSPFAdvertisements associated with this address pool.
+	OSPFAdvertisements []*OSPFAdvertisement
+
+	// The list of KernelRouteAdvertisements associated with this address pool.
+	KernelRouteAdvertisements []*KernelRouteAdvertisement
+
 	cidrsPerAddresses map[string][]*net.IPNet
 
 	ServiceAllocations *ServiceAllocation
 }
 
+// IPAM is the external IPAM system leasing addresses to a pool.
+type IPAM struct {
+	// The addresses leased by the provider, expressed as CIDR prefixes.
+	// They are part of the CIDR of the pool too.
+	CIDR []*net.IPNet
+	// The base URL of the HTTP provider.
+	URL string
+}
+
+// Contains tells if the prefix is one of the addresses leased by the provider.
+func (i *IPAM) Contains(cidr *net.IPNet) bool {
+	if i == nil {
+		return false
+	}
+	for _, c := range i.CIDR {
+		if c.String() == cidr.String() {
+			return true
+		}
+	}
+	return false
+}
+
 // ServiceAllocation makes ip pool allocation to specific namespace and/or service.
 type ServiceAllocation struct {
 	// The priority of ip pool for a given service allocation.
@@ -160,6 +353,25 @@ type ServiceAllocation struct {
 	ServiceSelectors []labels.Selector
 }
 
+// AllowsService tells if the IPs of the pool can be given to the service,
+// according to the service allocation of the pool.
+func (p *Pool) AllowsService(svc *corev1.Service) bool {
+	if p.ServiceAllocations != nil && p.ServiceAllocations.Namespaces.Len() > 0 &&
+		!p.ServiceAllocations.Namespaces.Has(svc.Namespace) {
+		return false
+	}
+	if p.ServiceAllocations != nil && len(p.ServiceAllocations.ServiceSelectors) > 0 {
+		svcLabels := labels.Set(svc.Labels)
+		for _, svcSelector := range p.ServiceAllocations.ServiceSelectors {
+			if svcSelector.Matches(svcLabels) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
 // BGPAdvertisement describes one translation from an IP address to a BGP advertisement.
 type BGPAdvertisement struct {
 	// The name of the advertisement
@@ -181,8 +393,83 @@ type BGPAdvertisement struct {
 	// Used to declare the intent of announcing IPs
 	// only to the BGPPeers in this list.
 	Peers []string
+	// How to weight the announcement of services with
+	// externalTrafficPolicy=Local by their local endpoints.
+	EndpointWeighting EndpointWeighting
+	// The namespaces whose services are allowed to override
+	// the BGP attributes of this advertisement.
+	ServiceOverridesNamespaces map[string]bool
+	// The AS path prepend to apply to the announcement. Optional.
+	ASPathPrepend *ASPathPrepend
+	// Value of the MULTI_EXIT_DISC BGP path attribute. Optional.
+	MED *uint32
+	// The overrides of ASPathPrepend and MED, by node name.
+	NodeOverrides map[string]BGPNodeOverride
+	// The VRFs the announced prefixes are leaked into, the default
+	// VRF being named "default". Optional.
+	LeakToVRFs []string
+	// How the prefixes are originated as EVPN type-5 routes. Optional.
+	EVPN *EVPNAdvertisement
+}
+
+// EVPNAdvertisement describes how the prefixes of an advertisement
+// are originated as EVPN type-5 routes.
+type EVPNAdvertisement struct {
+	// The VRF the prefixes are originated from.
+	VRF string
+	// The L3VNI of the VRF.
+	VNI uint32
+	// The route targets attached to the routes. Optional.
+	RouteTargets []string
+}
+
+// ASPathPrepend describes the ASN to prepend to the AS_PATH
+// of an announcement.
+type ASPathPrepend struct {
+	// The ASN to prepend. Zero means the local ASN.
+	ASN uint32
+	// The number of times the ASN is prepended.
+	Count uint32
+}
+
+// BGPNodeOverride overrides the attributes of the announcements
+// of an advertisement coming from a given node. Nil fields
+// are not overridden.
+type BGPNodeOverride struct {
+	ASPathPrepend *ASPathPrepend
+	MED           *uint32
 }
 
+// AttributesFor returns the AS path prepend and the MED of
+// the announcements coming from the given node.
+func (a *BGPAdvertisement) AttributesFor(node string) (*ASPathPrepend, *uint32) {
+	asPathPrepend, med := a.ASPathPrepend, a.MED
+	override, ok := a.NodeOverrides[node]
+	if !ok {
+		return asPathPrepend, med
+	}
+	if override.ASPathPrepend != nil {
+		asPathPrepend = override.ASPathPrepend
+	}
+	if override.MED != nil {
+		med = override.MED
+	}
+	return asPathPrepend, med
+}
+
+// EndpointWeighting describes how a node weights its announcements by the
+// number of ready endpoints of the service running on it.
+type EndpointWeighting string
+
+const (
+	// NoEndpointWeighting means all the nodes announce the service with the same attributes.
+	NoEndpointWeighting EndpointWeighting = ""
+	// LinkBandwidthWeighting attaches a link bandwidth extended community to the announcement.
+	LinkBandwidthWeighting EndpointWeighting = "LinkBandwidth"
+	// LocalPrefWeighting adds the number of endpoints to the LOCAL_PREF of the announcement.
+	LocalPrefWeighting EndpointWeighting = "LocalPref"
+)
+
 type L2Advertisement struct {
 	// The map of nodes allowed for this advertisement
 	Nodes map[string]bool
@@ -192,6 +479,52 @@ type L2Advertisement struct {
 	AllInterfaces bool
 }
 
+// OSPFAdvertisement describes how to announce a pool via OSPF.
+type OSPFAdvertisement struct {
+	// The name of the advertisement
+	Name string
+	// The map of nodes allowed for this advertisement
+	Nodes map[string]bool
+	// The metric of the external routes. Optional.
+	Metric *uint32
+	// The type of the external routes, 1 or 2.
+	MetricType uint32
+}
+
+// OSPFArea is the configuration of OSPF in an area, on a set of nodes.
+type OSPFArea struct {
+	// Area name.
+	Name string
+	// The ID of the area, in dotted decimal notation.
+	Area string
+	// The interfaces OSPF runs on.
+	Interfaces []string
+	// Only configure the area on the nodes matching one of these
+	// selectors.
+	NodeSelectors []labels.Selector
+}
+
+// KernelRouteAdvertisement describes how to program a pool into the
+// kernel of the nodes, for an external routing daemon to announce it.
+type KernelRouteAdvertisement struct {
+	// The name of the advertisement
+	Name string
+	// The map of nodes allowed for this advertisement
+	Nodes map[string]bool
+	// Address tells if the IPs are assigned to Interface, instead of
+	// being routed.
+	Address bool
+	// The interface the routes go through or the addresses are assigned
+	// to. Blackhole routes are installed when empty.
+	Interface string
+	// The routing table of the routes.
+	Table uint32
+	// The protocol the routes, or the addresses, are tagged with.
+	Protocol uint8
+	// The metric of the routes.
+	Metric uint32
+}
+
 // BFDProfile describes a BFD profile to be applied to a set of peers.
 type BFDProfile struct {
 	Name             string
@@ -237,6 +570,16 @@ func For(resources ClusterResources, validate Validate) (*Config, error) {
 		return nil, err
 	}
 
+	cfg.FRRSnippets, err = frrSnippetsFor(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.OSPFAreas, err = ospfAreasFor(resources)
+	if err != nil {
+		return nil, err
+	}
+
 	err = validateConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -331,6 +674,16 @@ func poolsFor(resources ClusterResources) (*Pools, error) {
 		return nil, err
 	}
 
+	err = setOSPFAdvertisementsToPools(resources.Pools, resources.OSPFAdvs, resources.Nodes, pools)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setKernelRouteAdvertisementsToPools(resources.Pools, resources.KernelRouteAdvs, resources.Nodes, pools)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, p := range resources.LegacyAddressPools {
 		allNodes, err := selectedNodes(resources.Nodes, nil)
 		if err != nil {
@@ -369,6 +722,51 @@ func bgpExtrasFor(resources ClusterResources) string {
 	return resources.BGPExtras.Data[bgpExtrasField]
 }
 
+func frrSnippetsFor(resources ClusterResources) (map[string]*FRRSnippet, error) {
+	if len(resources.FRRSnippets) == 0 {
+		return nil, nil
+	}
+	res := make(map[string]*FRRSnippet)
+	for _, s := range resources.FRRSnippets {
+		parsed, err := frrSnippetFromCR(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing frr snippet %s: %s", s.Name, err)
+		}
+		res[parsed.Name] = parsed
+	}
+	return res, nil
+}
+
+func frrSnippetFromCR(s metallbv1beta1.FRRSnippet) (*FRRSnippet, error) {
+	if strings.TrimSpace(s.Spec.Config) == "" {
+		return nil, errors.New("missing config")
+	}
+
+	err := validateLabelSelectorDuplicate(s.Spec.NodeSelectors, "nodeSelectors")
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeSels []labels.Selector
+	for _, sel := range s.Spec.NodeSelectors {
+		sel := sel // so we can use &sel
+		labelSelector, err := metav1.LabelSelectorAsSelector(&sel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to convert frr snippet %s node selector", s.Name)
+		}
+		nodeSels = append(nodeSels, labelSelector)
+	}
+	if len(nodeSels) == 0 {
+		nodeSels = []labels.Selector{labels.Everything()}
+	}
+
+	return &FRRSnippet{
+		Name:          s.Name,
+		Config:        s.Spec.Config,
+		NodeSelectors: nodeSels,
+	}, nil
+}
+
 func communitiesFromCrs(cs []metallbv1beta1.Community) (map[string]community.BGPCommunity, error) {
 	communities := map[string]community.BGPCommunity{}
 	for _, c := range cs {
@@ -387,24 +785,68 @@ func communitiesFromCrs(cs []metallbv1beta1.Community) (map[string]community.BGP
 }
 
 func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (*Peer, error) {
-	if p.Spec.MyASN == 0 {
+	if p.Spec.MyASN == 0 && p.Spec.MyASNFrom == nil {
 		return nil, errors.New("missing local ASN")
 	}
+	if p.Spec.MyASN != 0 && p.Spec.MyASNFrom != nil {
+		return nil, errors.New("myASN and myASNFrom are mutually exclusive")
+	}
 	if p.Spec.ASN == 0 {
 		return nil, errors.New("missing peer ASN")
 	}
-	if p.Spec.ASN == p.Spec.MyASN && p.Spec.EBGPMultiHop {
+	ebgpMultiHop := p.Spec.EBGPMultiHop || p.Spec.EBGPMultiHopTTL != 0
+	// With a per node ASN, this is checked by the speaker.
+	if p.Spec.ASN == p.Spec.MyASN && ebgpMultiHop {
 		return nil, errors.New("invalid ebgp-multihop parameter set for an ibgp peer")
 	}
-	ip := net.ParseIP(p.Spec.Address)
-	if ip == nil {
-		return nil, fmt.Errorf("invalid BGPPeer address %q", p.Spec.Address)
+	if p.Spec.EBGPMultiHopTTL > 255 {
+		return nil, fmt.Errorf("invalid ebgpMultiHopTTL %d, must be between 1 and 255", p.Spec.EBGPMultiHopTTL)
+	}
+	if p.Spec.TTLSecurityHops > 254 {
+		return nil, fmt.Errorf("invalid ttlSecurityHops %d, must be between 1 and 254", p.Spec.TTLSecurityHops)
+	}
+	if p.Spec.TTLSecurityHops != 0 && ebgpMultiHop {
+		return nil, errors.New("ttlSecurityHops is mutually exclusive with ebgpMultiHop and ebgpMultiHopTTL")
+	}
+	myASNFrom, err := nodeValueRefFromCR(p.Spec.MyASNFrom)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid myASNFrom")
+	}
+	var ip net.IP
+	var dynamicAddr *DynamicPeerAddress
+	var listenRange *net.IPNet
+	addresses := 0
+	for _, set := range []bool{p.Spec.Address != "", p.Spec.DynamicAddress != nil, p.Spec.ListenRange != ""} {
+		if set {
+			addresses++
+		}
+	}
+	switch {
+	case addresses > 1:
+		return nil, errors.New("peerAddress, dynamicAddress and listenRange are mutually exclusive")
+	case p.Spec.DynamicAddress != nil:
+		var err error
+		dynamicAddr, err = dynamicAddressFromCR(p.Spec)
+		if err != nil {
+			return nil, err
+		}
+	case p.Spec.ListenRange != "":
+		var err error
+		_, listenRange, err = net.ParseCIDR(p.Spec.ListenRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listenRange %q", p.Spec.ListenRange)
+		}
+	default:
+		ip = net.ParseIP(p.Spec.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid BGPPeer address %q", p.Spec.Address)
+		}
 	}
 	holdTime := p.Spec.HoldTime.Duration
 	if holdTime == 0 {
 		holdTime = 90 * time.Second
 	}
-	err := validateHoldTime(holdTime)
+	err = validateHoldTime(holdTime)
 	if err != nil {
 		return nil, err
 	}
@@ -428,6 +870,13 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 			return nil, fmt.Errorf("invalid router ID %q", p.Spec.RouterID)
 		}
 	}
+	if p.Spec.RouterID != "" && p.Spec.RouterIDFrom != nil {
+		return nil, errors.New("routerID and routerIDFrom are mutually exclusive")
+	}
+	routerIDFrom, err := nodeValueRefFromCR(p.Spec.RouterIDFrom)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid routerIDFrom")
+	}
 	src := net.ParseIP(p.Spec.SrcAddress)
 	if p.Spec.SrcAddress != "" && src == nil {
 		return nil, fmt.Errorf("invalid source IP %q", p.Spec.SrcAddress)
@@ -451,53 +900,225 @@ func peerFromCR(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secr
 		nodeSels = []labels.Selector{labels.Everything()}
 	}
 
-	password, err := passwordForPeer(p, passwordSecrets)
+	password, nextPassword, err := passwordForPeer(p, passwordSecrets)
+	if err != nil {
+		return nil, err
+	}
+	aoKeys, err := aoKeysForPeer(p, passwordSecrets)
+	if err != nil {
+		return nil, err
+	}
+	receive, err := receiveFromCR(p.Spec.Receive)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Peer{
-		Name:          p.Name,
-		MyASN:         p.Spec.MyASN,
-		ASN:           p.Spec.ASN,
-		Addr:          ip,
-		SrcAddr:       src,
-		Port:          p.Spec.Port,
-		HoldTime:      holdTime,
-		KeepaliveTime: keepaliveTime,
-		RouterID:      routerID,
-		NodeSelectors: nodeSels,
-		Password:      password,
-		BFDProfile:    p.Spec.BFDProfile,
-		EBGPMultiHop:  p.Spec.EBGPMultiHop,
-		VRF:           p.Spec.VRFName,
+		Name:            p.Name,
+		MyASN:           p.Spec.MyASN,
+		MyASNFrom:       myASNFrom,
+		ASN:             p.Spec.ASN,
+		Addr:            ip,
+		DynamicAddr:     dynamicAddr,
+		ListenRange:     listenRange,
+		Passive:         p.Spec.Passive,
+		SrcAddr:         src,
+		Port:            p.Spec.Port,
+		HoldTime:        holdTime,
+		KeepaliveTime:   keepaliveTime,
+		RouterID:        routerID,
+		RouterIDFrom:    routerIDFrom,
+		NodeSelectors:   nodeSels,
+		Password:        password,
+		NextPassword:    nextPassword,
+		AOKeys:          aoKeys,
+		BFDProfile:      p.Spec.BFDProfile,
+		EBGPMultiHop:    ebgpMultiHop,
+		EBGPMultiHopTTL: p.Spec.EBGPMultiHopTTL,
+		TTLSecurityHops: p.Spec.TTLSecurityHops,
+		VRF:             p.Spec.VRFName,
+		Receive:         receive,
+		RawFRRConfig:    p.Spec.RawFRRConfig,
 	}, nil
 }
 
-func passwordForPeer(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (string, error) {
+// receiveFromCR returns the routes accepted from the peer, or nil if all
+// of them are ignored.
+func receiveFromCR(r *metallbv1beta2.BGPReceive) (*ReceivePolicy, error) {
+	if r == nil {
+		return nil, nil
+	}
+	mode := ReceiveMode(r.Mode)
+	switch mode {
+	case "", ReceiveNone:
+		if len(r.Prefixes) > 0 || r.RoutingTable != 0 {
+			return nil, errors.New("receive prefixes and routingTable set while no route is received")
+		}
+		return nil, nil
+	case ReceiveAll:
+		if len(r.Prefixes) > 0 {
+			return nil, fmt.Errorf("receive prefixes set with %s mode", ReceiveAll)
+		}
+	case ReceiveFiltered:
+		if len(r.Prefixes) == 0 {
+			return nil, fmt.Errorf("missing receive prefixes with %s mode", ReceiveFiltered)
+		}
+	default:
+		return nil, fmt.Errorf("invalid receive mode %q", r.Mode)
+	}
+
+	res := &ReceivePolicy{
+		Mode:         mode,
+		RoutingTable: r.RoutingTable,
+	}
+	for _, p := range r.Prefixes {
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid receive prefix %q", p)
+		}
+		res.Prefixes = append(res.Prefixes, n)
+	}
+	return res, nil
+}
+
+func nodeValueRefFromCR(ref *metallbv1beta2.NodeValueRef) (*NodeValueRef, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	source := NodeValueSource(ref.Source)
+	if source != NodeValueLabel && source != NodeValueAnnotation {
+		return nil, fmt.Errorf("invalid source %q", ref.Source)
+	}
+	if errs := validation.IsQualifiedName(ref.Key); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid key %q: %s", ref.Key, strings.Join(errs, ", "))
+	}
+	return &NodeValueRef{Source: source, Key: ref.Key}, nil
+}
+
+func dynamicAddressFromCR(spec metallbv1beta2.BGPPeerSpec) (*DynamicPeerAddress, error) {
+	d := spec.DynamicAddress
+	res := &DynamicPeerAddress{
+		Source: DynamicAddressSource(d.Source),
+	}
+	switch res.Source {
+	case DynamicAddressDefaultGateway:
+		if spec.VRFName != "" {
+			return nil, errors.New("dynamicAddress with source DefaultGateway can't be used with a vrf")
+		}
+		switch d.IPFamily {
+		case "", "IPv4":
+			res.IPFamily = ipfamily.IPv4
+		case "IPv6":
+			res.IPFamily = ipfamily.IPv6
+		default:
+			return nil, fmt.Errorf("invalid dynamicAddress ipFamily %q", d.IPFamily)
+		}
+	case DynamicAddressNodeLabel, DynamicAddressNodeAnnotation:
+		if d.Key == "" {
+			return nil, fmt.Errorf("dynamicAddress with source %s requires a key", d.Source)
+		}
+		if errs := validation.IsQualifiedName(d.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid dynamicAddress key %q: %s", d.Key, strings.Join(errs, ", "))
+		}
+		res.Key = d.Key
+	case DynamicAddressInterface:
+		if d.Interface == "" {
+			return nil, errors.New("dynamicAddress with source Interface requires an interface")
+		}
+		res.Interface = d.Interface
+	default:
+		return nil, fmt.Errorf("invalid dynamicAddress source %q", d.Source)
+	}
+	return res, nil
+}
+
+func passwordForPeer(p metallbv1beta2.BGPPeer, passwordSecrets map[string]corev1.Secret) (string, string, error) {
 	if p.Spec.Password != "" && p.Spec.PasswordSecret.Name != "" {
-		return "", fmt.Errorf("can not have both password and secret ref set in peer config %q/%q", p.Namespace,
+		return "", "", fmt.Errorf("can not have both password and secret ref set in peer config %q/%q", p.Namespace,
 			p.Name)
 	}
-	var password string
 	if p.Spec.Password != "" {
-		password = p.Spec.Password
-	} else if p.Spec.PasswordSecret.Name != "" {
-		secret, ok := passwordSecrets[p.Spec.PasswordSecret.Name]
-		if !ok {
-			return "", TransientError{Message: fmt.Sprintf("secret ref not found for peer config %q/%q", p.Namespace, p.Name)}
+		return p.Spec.Password, "", nil
+	}
+	if p.Spec.PasswordSecret.Name == "" {
+		return "", "", nil
+	}
+	secret, ok := passwordSecrets[p.Spec.PasswordSecret.Name]
+	if !ok {
+		return "", "", TransientError{Message: fmt.Sprintf("secret ref not found for peer config %q/%q", p.Namespace, p.Name)}
+	}
+	if secret.Type != corev1.SecretTypeBasicAuth {
+		return "", "", fmt.Errorf("secret type mismatch on %q/%q, type %q is expected ", secret.Namespace,
+			secret.Name, corev1.SecretTypeBasicAuth)
+	}
+	srcPass, ok := secret.Data["password"]
+	if !ok {
+		return "", "", fmt.Errorf("password not specified in the secret %q/%q", secret.Namespace, secret.Name)
+	}
+	nextPass, ok := secret.Data["nextPassword"]
+	if ok && len(nextPass) == 0 {
+		return "", "", fmt.Errorf("empty nextPassword in the secret %q/%q", secret.Namespace, secret.Name)
+	}
+	if string(nextPass) == string(srcPass) {
+		// Nothing to rotate.
+		nextPass = nil
+	}
+	return string(srcPass), string(nextPass), nil
+}
+
+// aoKeysForPeer returns the TCP-AO key chain of the peer, or nil if the
+// session is not authenticated with TCP-AO.
+func aoKeysForPeer(p metallbv1beta2.BGPPeer, secrets map[string]corev1.Secret) ([]TCPAOKey, error) {
+	auth := p.Spec.Authentication
+	if auth == nil || AuthType(auth.Type) == AuthMD5 {
+		if auth != nil && auth.KeyChainSecret.Name != "" {
+			return nil, fmt.Errorf("keyChainSecret set on peer %q/%q with %s authentication", p.Namespace, p.Name, AuthMD5)
+		}
+		return nil, nil
+	}
+	if AuthType(auth.Type) != AuthAO {
+		return nil, fmt.Errorf("invalid authentication type %q on peer %q/%q", auth.Type, p.Namespace, p.Name)
+	}
+	if p.Spec.Password != "" || p.Spec.PasswordSecret.Name != "" {
+		return nil, fmt.Errorf("can not have password set with %s authentication in peer config %q/%q", AuthAO, p.Namespace, p.Name)
+	}
+	if auth.KeyChainSecret.Name == "" {
+		return nil, fmt.Errorf("missing keyChainSecret with %s authentication in peer config %q/%q", AuthAO, p.Namespace, p.Name)
+	}
+	secret, ok := secrets[auth.KeyChainSecret.Name]
+	if !ok {
+		return nil, TransientError{Message: fmt.Sprintf("key chain secret ref not found for peer config %q/%q", p.Namespace, p.Name)}
+	}
+	data, ok := secret.Data["keychain"]
+	if !ok {
+		return nil, fmt.Errorf("keychain not specified in the secret %q/%q", secret.Namespace, secret.Name)
+	}
+	var keys []TCPAOKey
+	if err := yaml.UnmarshalStrict(data, &keys); err != nil {
+		return nil, errors.Wrapf(err, "invalid keychain in the secret %q/%q", secret.Namespace, secret.Name)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty keychain in the secret %q/%q", secret.Namespace, secret.Name)
+	}
+	sendIDs := map[uint8]bool{}
+	recvIDs := map[uint8]bool{}
+	for _, k := range keys {
+		if !slices.Contains(TCPAOAlgorithms, k.Algorithm) {
+			return nil, fmt.Errorf("invalid algorithm %q in the secret %q/%q, must be one of %s", k.Algorithm, secret.Namespace, secret.Name, strings.Join(TCPAOAlgorithms, ", "))
 		}
-		if secret.Type != corev1.SecretTypeBasicAuth {
-			return "", fmt.Errorf("secret type mismatch on %q/%q, type %q is expected ", secret.Namespace,
-				secret.Name, corev1.SecretTypeBasicAuth)
+		if len(k.Key) == 0 || len(k.Key) > tcpAOMaxKeyLen {
+			return nil, fmt.Errorf("invalid key length %d in the secret %q/%q, must be between 1 and %d", len(k.Key), secret.Namespace, secret.Name, tcpAOMaxKeyLen)
 		}
-		srcPass, ok := secret.Data["password"]
-		if !ok {
-			return "", fmt.Errorf("password not specified in the secret %q/%q", secret.Namespace, secret.Name)
+		if sendIDs[k.SendID] {
+			return nil, fmt.Errorf("duplicate sendID %d in the secret %q/%q", k.SendID, secret.Namespace, secret.Name)
 		}
-		password = string(srcPass)
+		if recvIDs[k.RecvID] {
+			return nil, fmt.Errorf("duplicate recvID %d in the secret %q/%q", k.RecvID, secret.Namespace, secret.Name)
+		}
+		sendIDs[k.SendID] = true
+		recvIDs[k.RecvID] = true
 	}
-	return password, nil
+	return keys, nil
 }
 
 func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Namespace) (*Pool, error) {
@@ -506,19 +1127,38 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 	}
 
 	ret := &Pool{
-		Name:          p.Name,
-		AvoidBuggyIPs: p.Spec.AvoidBuggyIPs,
-		AutoAssign:    true,
+		Name:                  p.Name,
+		AvoidBuggyIPs:         p.Spec.AvoidBuggyIPs,
+		AutoAssign:            true,
+		AnnounceExternalIPs:   p.Spec.AnnounceExternalIPs,
+		ServiceClusterIPRange: p.Spec.ServiceClusterIPRange,
 	}
 
 	if p.Spec.AutoAssign != nil {
 		ret.AutoAssign = *p.Spec.AutoAssign
 	}
 
-	if len(p.Spec.Addresses) == 0 {
+	if len(p.Spec.Addresses) == 0 && p.Spec.IPAM == nil {
 		return nil, errors.New("pool has no prefixes defined")
 	}
 
+	if p.Spec.ServiceClusterIPRange {
+		if p.Spec.AnnounceExternalIPs {
+			return nil, fmt.Errorf("pool %q can't both be a service cluster IP range and announce external IPs", p.Name)
+		}
+		if p.Spec.AllocateTo != nil {
+			return nil, fmt.Errorf("pool %q is a service cluster IP range, it can't have a service allocation", p.Name)
+		}
+		// The cluster IPs are allocated by Kubernetes.
+		ret.AutoAssign = false
+	}
+
+	// The external IPs are set by the users, the LoadBalancer services
+	// getting the same IPs would be announced along with them.
+	if p.Spec.AnnounceExternalIPs && ret.AutoAssign {
+		return nil, fmt.Errorf("pool %q announces external IPs, it must not assign IPs automatically", p.Name)
+	}
+
 	ret.cidrsPerAddresses = map[string][]*net.IPNet{}
 	for _, cidr := range p.Spec.Addresses {
 		nets, err := ParseCIDR(cidr)
@@ -529,6 +1169,23 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 		ret.cidrsPerAddresses[cidr] = nets
 	}
 
+	if p.Spec.IPAM != nil {
+		ipam, err := ipamFromCR(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, cidr := range p.Spec.IPAM.Addresses {
+			nets, err := ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q in the ipam of pool %q: %s", cidr, p.Name, err)
+			}
+			ipam.CIDR = append(ipam.CIDR, nets...)
+			ret.CIDR = append(ret.CIDR, nets...)
+			ret.cidrsPerAddresses[cidr] = nets
+		}
+		ret.IPAM = ipam
+	}
+
 	serviceAllocations, err := addressPoolServiceAllocationsFromCR(p, namespaces)
 	if err != nil {
 		return nil, err
@@ -538,6 +1195,26 @@ func addressPoolFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Names
 	return ret, nil
 }
 
+func ipamFromCR(p metallbv1beta1.IPAddressPool) (*IPAM, error) {
+	if p.Spec.ServiceClusterIPRange {
+		return nil, fmt.Errorf("pool %q is a service cluster IP range, it can't lease addresses from an ipam", p.Name)
+	}
+	if len(p.Spec.IPAM.Addresses) == 0 {
+		return nil, fmt.Errorf("the ipam of pool %q has no prefixes defined", p.Name)
+	}
+	if p.Spec.IPAM.HTTP == nil {
+		return nil, fmt.Errorf("the ipam of pool %q has no provider", p.Name)
+	}
+	u, err := url.Parse(p.Spec.IPAM.HTTP.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q in the ipam of pool %q: %s", p.Spec.IPAM.HTTP.URL, p.Name, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q in the ipam of pool %q: must be an absolute http or https url", p.Spec.IPAM.HTTP.URL, p.Name)
+	}
+	return &IPAM{URL: p.Spec.IPAM.HTTP.URL}, nil
+}
+
 func addressPoolServiceAllocationsFromCR(p metallbv1beta1.IPAddressPool, namespaces []corev1.Namespace) (*ServiceAllocation, error) {
 	if p.Spec.AllocateTo == nil {
 		return nil, nil
@@ -762,6 +1439,244 @@ func setBGPAdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, bgpAdvs
 	return nil
 }
 
+func setOSPFAdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, ospfAdvs []metallbv1beta1.OSPFAdvertisement,
+	nodes []corev1.Node, ipPoolMap map[string]*Pool) error {
+	for _, ospfAdv := range ospfAdvs {
+		adv, err := ospfAdvertisementFromCR(ospfAdv, nodes)
+		if err != nil {
+			return err
+		}
+		ipPoolsSelected, err := selectedPools(ipPools, ospfAdv.Spec.IPAddressPoolSelectors)
+		if err != nil {
+			return err
+		}
+		// No pool selector means select all pools
+		if len(ospfAdv.Spec.IPAddressPools) == 0 && len(ospfAdv.Spec.IPAddressPoolSelectors) == 0 {
+			for _, pool := range ipPoolMap {
+				pool.OSPFAdvertisements = append(pool.OSPFAdvertisements, adv)
+			}
+			continue
+		}
+		for _, poolName := range append(ospfAdv.Spec.IPAddressPools, ipPoolsSelected...) {
+			if pool, ok := ipPoolMap[poolName]; ok {
+				pool.OSPFAdvertisements = append(pool.OSPFAdvertisements, adv)
+			}
+		}
+	}
+	return nil
+}
+
+func ospfAdvertisementFromCR(crdAd metallbv1beta1.OSPFAdvertisement, nodes []corev1.Node) (*OSPFAdvertisement, error) {
+	err := validateDuplicate(crdAd.Spec.IPAddressPools, "ipAddressPools")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(crdAd.Spec.IPAddressPoolSelectors, "ipAddressPoolSelectors")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(crdAd.Spec.NodeSelectors, "nodeSelectors")
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectedNodes(nodes, crdAd.Spec.NodeSelectors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse node selector for %s", crdAd.Name)
+	}
+
+	ad := &OSPFAdvertisement{
+		Name:       crdAd.Name,
+		Nodes:      selected,
+		MetricType: 2,
+	}
+	if crdAd.Spec.Metric != nil {
+		if *crdAd.Spec.Metric > maxOSPFMetric {
+			return nil, fmt.Errorf("invalid ospf metric %d, must be at most %d", *crdAd.Spec.Metric, maxOSPFMetric)
+		}
+		metric := *crdAd.Spec.Metric
+		ad.Metric = &metric
+	}
+	switch crdAd.Spec.MetricType {
+	case 0:
+	case 1, 2:
+		ad.MetricType = uint32(crdAd.Spec.MetricType)
+	default:
+		return nil, fmt.Errorf("invalid ospf metric type %d, must be 1 or 2", crdAd.Spec.MetricType)
+	}
+	return ad, nil
+}
+
+const maxOSPFMetric = 16777214
+
+func ospfAreasFor(resources ClusterResources) (map[string]*OSPFArea, error) {
+	if len(resources.OSPFAreas) == 0 {
+		return nil, nil
+	}
+	res := make(map[string]*OSPFArea)
+	for _, a := range resources.OSPFAreas {
+		area, err := ospfAreaFromCR(a)
+		if err != nil {
+			return nil, fmt.Errorf("parsing ospf area %s: %s", a.Name, err)
+		}
+		res[area.Name] = area
+	}
+	return res, nil
+}
+
+func ospfAreaFromCR(a metallbv1beta1.OSPFArea) (*OSPFArea, error) {
+	area, err := parseOSPFArea(a.Spec.Area)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Spec.Interfaces) == 0 {
+		return nil, errors.New("missing interfaces")
+	}
+	err = validateDuplicate(a.Spec.Interfaces, "interfaces")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(a.Spec.NodeSelectors, "nodeSelectors")
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeSels []labels.Selector
+	for _, sel := range a.Spec.NodeSelectors {
+		sel := sel // so we can use &sel
+		labelSelector, err := metav1.LabelSelectorAsSelector(&sel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to convert ospf area %s node selector", a.Name)
+		}
+		nodeSels = append(nodeSels, labelSelector)
+	}
+	if len(nodeSels) == 0 {
+		nodeSels = []labels.Selector{labels.Everything()}
+	}
+
+	res := &OSPFArea{
+		Name:          a.Name,
+		Area:          area,
+		NodeSelectors: nodeSels,
+	}
+	res.Interfaces = make([]string, 0, len(a.Spec.Interfaces))
+	res.Interfaces = append(res.Interfaces, a.Spec.Interfaces...)
+	return res, nil
+}
+
+// parseOSPFArea parses an OSPF area ID, either a number or in dotted
+// decimal notation, and returns it in dotted decimal notation.
+func parseOSPFArea(area string) (string, error) {
+	if ip := net.ParseIP(area); ip != nil && ip.To4() != nil {
+		return ip.To4().String(), nil
+	}
+	id, err := strconv.ParseUint(area, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid area %q, must be a number or in dotted decimal notation", area)
+	}
+	return net.IPv4(byte(id>>24), byte(id>>16), byte(id>>8), byte(id)).String(), nil
+}
+
+func setKernelRouteAdvertisementsToPools(ipPools []metallbv1beta1.IPAddressPool, kernelAdvs []metallbv1beta1.KernelRouteAdvertisement,
+	nodes []corev1.Node, ipPoolMap map[string]*Pool) error {
+	for _, kernelAdv := range kernelAdvs {
+		adv, err := kernelRouteAdvertisementFromCR(kernelAdv, nodes)
+		if err != nil {
+			return err
+		}
+		ipPoolsSelected, err := selectedPools(ipPools, kernelAdv.Spec.IPAddressPoolSelectors)
+		if err != nil {
+			return err
+		}
+		// No pool selector means select all pools
+		if len(kernelAdv.Spec.IPAddressPools) == 0 && len(kernelAdv.Spec.IPAddressPoolSelectors) == 0 {
+			for _, pool := range ipPoolMap {
+				pool.KernelRouteAdvertisements = append(pool.KernelRouteAdvertisements, adv)
+			}
+			continue
+		}
+		for _, poolName := range append(kernelAdv.Spec.IPAddressPools, ipPoolsSelected...) {
+			if pool, ok := ipPoolMap[poolName]; ok {
+				pool.KernelRouteAdvertisements = append(pool.KernelRouteAdvertisements, adv)
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	// The main routing table, RT_TABLE_MAIN.
+	mainRoutingTable = 254
+	// The local routing table, RT_TABLE_LOCAL, where the kernel keeps
+	// the routes to the addresses of the node.
+	localRoutingTable = 255
+	// The default protocol the routes are tagged with, not used by the
+	// well known routing daemons.
+	defaultKernelRouteProtocol = 196
+	// The protocols below RTPROT_STATIC are reserved for the kernel.
+	minKernelRouteProtocol = 4
+)
+
+func kernelRouteAdvertisementFromCR(crdAd metallbv1beta1.KernelRouteAdvertisement, nodes []corev1.Node) (*KernelRouteAdvertisement, error) {
+	err := validateDuplicate(crdAd.Spec.IPAddressPools, "ipAddressPools")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(crdAd.Spec.IPAddressPoolSelectors, "ipAddressPoolSelectors")
+	if err != nil {
+		return nil, err
+	}
+	err = validateLabelSelectorDuplicate(crdAd.Spec.NodeSelectors, "nodeSelectors")
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectedNodes(nodes, crdAd.Spec.NodeSelectors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse node selector for %s", crdAd.Name)
+	}
+
+	ad := &KernelRouteAdvertisement{
+		Name:      crdAd.Name,
+		Nodes:     selected,
+		Interface: crdAd.Spec.Interface,
+		Table:     mainRoutingTable,
+		Protocol:  defaultKernelRouteProtocol,
+	}
+	switch crdAd.Spec.Mode {
+	case "", metallbv1beta1.KernelRouteModeRoute:
+	case metallbv1beta1.KernelRouteModeAddress:
+		if crdAd.Spec.Interface == "" {
+			return nil, fmt.Errorf("kernelrouteadvertisement %s: mode Address requires an interface", crdAd.Name)
+		}
+		if crdAd.Spec.Table != nil || crdAd.Spec.Protocol != nil || crdAd.Spec.Metric != nil {
+			return nil, fmt.Errorf("kernelrouteadvertisement %s: table, protocol and metric can't be set in mode Address", crdAd.Name)
+		}
+		ad.Address = true
+		ad.Table = 0
+		return ad, nil
+	default:
+		return nil, fmt.Errorf("kernelrouteadvertisement %s: invalid mode %q", crdAd.Name, crdAd.Spec.Mode)
+	}
+
+	if crdAd.Spec.Table != nil {
+		table := *crdAd.Spec.Table
+		if table == 0 || table == localRoutingTable {
+			return nil, fmt.Errorf("kernelrouteadvertisement %s: invalid table %d", crdAd.Name, table)
+		}
+		ad.Table = table
+	}
+	if crdAd.Spec.Protocol != nil {
+		protocol := *crdAd.Spec.Protocol
+		if protocol < minKernelRouteProtocol || protocol > math.MaxUint8 {
+			return nil, fmt.Errorf("kernelrouteadvertisement %s: invalid protocol %d, must be between %d and %d", crdAd.Name, protocol, minKernelRouteProtocol, math.MaxUint8)
+		}
+		ad.Protocol = uint8(protocol)
+	}
+	if crdAd.Spec.Metric != nil {
+		ad.Metric = *crdAd.Spec.Metric
+	}
+	return ad, nil
+}
+
 func l2AdvertisementFromCR(crdAd metallbv1beta1.L2Advertisement, nodes []corev1.Node) (*L2Advertisement, error) {
 	err := validateDuplicate(crdAd.Spec.IPAddressPools, "ipAddressPools")
 	if err != nil {
@@ -789,6 +1704,61 @@ func l2AdvertisementFromCR(crdAd metallbv1beta1.L2Advertisement, nodes []corev1.
 	return l2, nil
 }
 
+func evpnFromCR(evpn *metallbv1beta1.EVPNAdvertisement) (*EVPNAdvertisement, error) {
+	if evpn.VRF == "" || strings.ContainsAny(evpn.VRF, " \t/") || evpn.VRF == "default" {
+		return nil, fmt.Errorf("invalid evpn vrf %q", evpn.VRF)
+	}
+	if evpn.VNI == 0 || evpn.VNI > maxVNI {
+		return nil, fmt.Errorf("invalid evpn vni %d, must be between 1 and %d", evpn.VNI, maxVNI)
+	}
+	err := validateDuplicate(evpn.RouteTargets, "routeTargets")
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range evpn.RouteTargets {
+		if err := validateRouteTarget(rt); err != nil {
+			return nil, errors.Wrapf(err, "invalid evpn route target %q", rt)
+		}
+	}
+	res := &EVPNAdvertisement{
+		VRF: evpn.VRF,
+		VNI: evpn.VNI,
+	}
+	if len(evpn.RouteTargets) > 0 {
+		res.RouteTargets = make([]string, 0, len(evpn.RouteTargets))
+		res.RouteTargets = append(res.RouteTargets, evpn.RouteTargets...)
+	}
+	return res, nil
+}
+
+const maxVNI = 1<<24 - 1
+
+// validateRouteTarget checks that the given route target is of the
+// form ASN:NN or IP:NN, with the sizes allowed by the extended communities.
+func validateRouteTarget(rt string) error {
+	i := strings.LastIndex(rt, ":")
+	if i < 0 {
+		return errors.New("expected ASN:NN or IP:NN")
+	}
+	global, local := rt[:i], rt[i+1:]
+	localBits := 16
+	if ip := net.ParseIP(global); ip == nil {
+		asn, err := strconv.ParseUint(global, 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid global administrator %q", global)
+		}
+		if asn <= math.MaxUint16 {
+			localBits = 32
+		}
+	} else if ip.To4() == nil {
+		return fmt.Errorf("invalid global administrator %q, must be an IPv4 address", global)
+	}
+	if _, err := strconv.ParseUint(local, 10, localBits); err != nil {
+		return errors.Wrapf(err, "invalid local administrator %q", local)
+	}
+	return nil
+}
+
 func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities map[string]community.BGPCommunity, nodes []corev1.Node) (*BGPAdvertisement, error) {
 	err := validateDuplicate(crdAd.Spec.IPAddressPools, "ipAddressPools")
 	if err != nil {
@@ -802,6 +1772,10 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 	if err != nil {
 		return nil, err
 	}
+	err = validateDuplicate(crdAd.Spec.ServiceOverridesNamespaces, "serviceOverridesNamespaces")
+	if err != nil {
+		return nil, err
+	}
 	err = validateLabelSelectorDuplicate(crdAd.Spec.IPAddressPoolSelectors, "ipAddressPoolSelectors")
 	if err != nil {
 		return nil, err
@@ -834,11 +1808,48 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 
 	ad.LocalPref = crdAd.Spec.LocalPref
 
+	switch EndpointWeighting(crdAd.Spec.EndpointWeighting) {
+	case NoEndpointWeighting, LinkBandwidthWeighting, LocalPrefWeighting:
+		ad.EndpointWeighting = EndpointWeighting(crdAd.Spec.EndpointWeighting)
+	default:
+		return nil, fmt.Errorf("invalid endpoint weighting %q", crdAd.Spec.EndpointWeighting)
+	}
+
 	if len(crdAd.Spec.Peers) > 0 {
 		ad.Peers = make([]string, 0, len(crdAd.Spec.Peers))
 		ad.Peers = append(ad.Peers, crdAd.Spec.Peers...)
 	}
 
+	if len(crdAd.Spec.LeakToVRFs) > 0 {
+		for _, vrf := range crdAd.Spec.LeakToVRFs {
+			if vrf == "" || strings.ContainsAny(vrf, " \t/") {
+				return nil, fmt.Errorf("invalid vrf %q in leakToVRFs", vrf)
+			}
+		}
+		if err := validateDuplicate(crdAd.Spec.LeakToVRFs, "leakToVRFs"); err != nil {
+			return nil, err
+		}
+		ad.LeakToVRFs = make([]string, 0, len(crdAd.Spec.LeakToVRFs))
+		ad.LeakToVRFs = append(ad.LeakToVRFs, crdAd.Spec.LeakToVRFs...)
+	}
+
+	if crdAd.Spec.EVPN != nil {
+		ad.EVPN, err = evpnFromCR(crdAd.Spec.EVPN)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(crdAd.Spec.ServiceOverridesNamespaces) > 0 {
+		ad.ServiceOverridesNamespaces = map[string]bool{}
+		for _, ns := range crdAd.Spec.ServiceOverridesNamespaces {
+			if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid namespace %q in serviceOverridesNamespaces: %s", ns, strings.Join(errs, ", "))
+			}
+			ad.ServiceOverridesNamespaces[ns] = true
+		}
+	}
+
 	for _, c := range crdAd.Spec.Communities {
 		v, err := getCommunityValue(c, communities)
 		if err != nil {
@@ -852,9 +1863,63 @@ func bgpAdvertisementFromCR(crdAd metallbv1beta1.BGPAdvertisement, communities m
 		return nil, errors.Wrapf(err, "Failed to parse node selector for ls %s", crdAd.Name)
 	}
 	ad.Nodes = selected
+
+	ad.ASPathPrepend, err = asPathPrependFromCR(crdAd.Spec.ASPathPrepend)
+	if err != nil {
+		return nil, err
+	}
+	if crdAd.Spec.MED != nil {
+		med := *crdAd.Spec.MED
+		ad.MED = &med
+	}
+
+	for i, o := range crdAd.Spec.NodeOverrides {
+		if len(o.NodeSelectors) == 0 {
+			return nil, fmt.Errorf("node override %d of %s has no node selectors", i, crdAd.Name)
+		}
+		if o.ASPathPrepend == nil && o.MED == nil {
+			return nil, fmt.Errorf("node override %d of %s does not override any attribute", i, crdAd.Name)
+		}
+		override := BGPNodeOverride{}
+		override.ASPathPrepend, err = asPathPrependFromCR(o.ASPathPrepend)
+		if err != nil {
+			return nil, err
+		}
+		if o.MED != nil {
+			med := *o.MED
+			override.MED = &med
+		}
+		selected, err := selectedNodes(nodes, o.NodeSelectors)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse node selector for node override %d of %s", i, crdAd.Name)
+		}
+		for node := range selected {
+			if ad.NodeOverrides == nil {
+				ad.NodeOverrides = map[string]BGPNodeOverride{}
+			}
+			// The first override matching a node wins.
+			if _, ok := ad.NodeOverrides[node]; ok {
+				continue
+			}
+			ad.NodeOverrides[node] = override
+		}
+	}
 	return ad, nil
 }
 
+func asPathPrependFromCR(crdPrepend *metallbv1beta1.ASPathPrepend) (*ASPathPrepend, error) {
+	if crdPrepend == nil {
+		return nil, nil
+	}
+	if crdPrepend.Count < 1 || crdPrepend.Count > MaxASPathPrepend {
+		return nil, fmt.Errorf("invalid as path prepend count %d, must be between 1 and %d", crdPrepend.Count, MaxASPathPrepend)
+	}
+	return &ASPathPrepend{
+		ASN:   crdPrepend.ASN,
+		Count: crdPrepend.Count,
+	}, nil
+}
+
 func bgpAdvertisementsFromLegacyCR(ads []metallbv1beta1.LegacyBgpAdvertisement, cidrsPerAddresses map[string][]*net.IPNet, communities map[string]community.BGPCommunity, allNodes map[string]bool) ([]*BGPAdvertisement, error) {
 	if len(ads) == 0 {
 		return []*BGPAdvertisement{
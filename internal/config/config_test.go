@@ -11,6 +11,7 @@ import (
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/bgp/community"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/pointer"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -680,63 +681,180 @@ func TestParse(t *testing.T) {
 		},
 
 		{
-			desc: "invalid my-asn",
+			desc: "dynamic peers",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							ASN:     42,
-							Address: "1.2.3.4",
+							MyASN: 42,
+							ASN:   142,
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source:   "DefaultGateway",
+								IPFamily: "IPv6",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer2",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN: 42,
+							ASN:   142,
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "NodeAnnotation",
+								Key:    "example.com/tor-address",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer3",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN: 42,
+							ASN:   142,
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source:    "Interface",
+								Interface: "eth1",
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:  "peer1",
+						MyASN: 42,
+						ASN:   142,
+						DynamicAddr: &DynamicPeerAddress{
+							Source:   DynamicAddressDefaultGateway,
+							IPFamily: ipfamily.IPv6,
+						},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Name:  "peer2",
+						MyASN: 42,
+						ASN:   142,
+						DynamicAddr: &DynamicPeerAddress{
+							Source: DynamicAddressNodeAnnotation,
+							Key:    "example.com/tor-address",
+						},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer3": {
+						Name:  "peer3",
+						MyASN: 42,
+						ASN:   142,
+						DynamicAddr: &DynamicPeerAddress{
+							Source:    DynamicAddressInterface,
+							Interface: "eth1",
 						},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
 					},
 				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid peer-asn",
+			desc: "peer with per node asn and router id",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn",
+							},
+							ASN:     42,
 							Address: "1.2.3.4",
+							RouterIDFrom: &v1beta2.NodeValueRef{
+								Source: "NodeAnnotation",
+								Key:    "example.com/router-id",
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name: "peer1",
+						MyASNFrom: &NodeValueRef{
+							Source: NodeValueLabel,
+							Key:    "example.com/asn",
+						},
+						ASN:  42,
+						Addr: net.ParseIP("1.2.3.4"),
+						RouterIDFrom: &NodeValueRef{
+							Source: NodeValueAnnotation,
+							Key:    "example.com/router-id",
 						},
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
 					},
 				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid ebgp-multihop",
+			desc: "both my-asn and per node asn",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:        42,
-							ASN:          42,
-							Address:      "1.2.3.4",
-							EBGPMultiHop: true,
+							MyASN: 42,
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn",
+							},
+							ASN:     42,
+							Address: "1.2.3.4",
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "invalid hold time (too short)",
+			desc: "per node asn with invalid key",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:    42,
-							ASN:      42,
-							Address:  "1.2.3.4",
-							HoldTime: metav1.Duration{Duration: time.Second},
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn/foo",
+							},
+							ASN:     42,
+							Address: "1.2.3.4",
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "invalid RouterID",
+			desc: "both router id and per node router id",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -744,14 +862,19 @@ func TestParse(t *testing.T) {
 							MyASN:    42,
 							ASN:      42,
 							Address:  "1.2.3.4",
-							RouterID: "oh god how do I BGP",
+							RouterID: "10.0.0.1",
+							RouterIDFrom: &v1beta2.NodeValueRef{
+								Source: "NodeAnnotation",
+								Key:    "example.com/router-id",
+							},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "empty node selector (select everything)",
+			desc: "passive peers",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -760,8 +883,19 @@ func TestParse(t *testing.T) {
 						},
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
-							ASN:     42,
+							ASN:     142,
 							Address: "1.2.3.4",
+							Passive: true,
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer2",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:       42,
+							ASN:         142,
+							ListenRange: "10.0.0.0/24",
 						},
 					},
 				},
@@ -771,8 +905,18 @@ func TestParse(t *testing.T) {
 					"peer1": {
 						Name:          "peer1",
 						MyASN:         42,
-						ASN:           42,
+						ASN:           142,
 						Addr:          net.ParseIP("1.2.3.4"),
+						Passive:       true,
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Name:          "peer2",
+						MyASN:         42,
+						ASN:           142,
+						ListenRange:   ipnet("10.0.0.0/24"),
 						HoldTime:      90 * time.Second,
 						KeepaliveTime: 30 * time.Second,
 						NodeSelectors: []labels.Selector{labels.Everything()},
@@ -782,62 +926,97 @@ func TestParse(t *testing.T) {
 				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+
 		{
-			desc: "invalid expression node selector (missing key)",
+			desc: "peers with ttl settings",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []metav1.LabelSelectorRequirement{
-										{
-											Operator: "In",
-											Values:   []string{"quux"},
-										},
-									},
-								},
-							},
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.4",
+							EBGPMultiHopTTL: 3,
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer2",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.5",
+							TTLSecurityHops: 2,
 						},
 					},
 				},
 			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:            "peer1",
+						MyASN:           42,
+						ASN:             142,
+						Addr:            net.ParseIP("1.2.3.4"),
+						EBGPMultiHop:    true,
+						EBGPMultiHopTTL: 3,
+						HoldTime:        90 * time.Second,
+						KeepaliveTime:   30 * time.Second,
+						NodeSelectors:   []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Name:            "peer2",
+						MyASN:           42,
+						ASN:             142,
+						Addr:            net.ParseIP("1.2.3.5"),
+						TTLSecurityHops: 2,
+						HoldTime:        90 * time.Second,
+						KeepaliveTime:   30 * time.Second,
+						NodeSelectors:   []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
 		},
+
 		{
-			desc: "invalid expression node selector (missing operator)",
+			desc: "both peer-address and listen range",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []metav1.LabelSelectorRequirement{
-										{
-											Key:    "bar",
-											Values: []string{"quux"},
-										},
-									},
-								},
-							},
+							MyASN:       42,
+							ASN:         42,
+							Address:     "1.2.3.4",
+							ListenRange: "10.0.0.0/24",
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "invalid expression node selector (invalid operator)",
+			desc: "invalid listen range",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:       42,
+							ASN:         42,
+							ListenRange: "10.0.0.0",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			desc: "both peer-address and dynamic address",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -845,396 +1024,458 @@ func TestParse(t *testing.T) {
 							MyASN:   42,
 							ASN:     42,
 							Address: "1.2.3.4",
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []metav1.LabelSelectorRequirement{
-										{
-											Key:      "bar",
-											Operator: "surrounds",
-											Values:   []string{"quux"},
-										},
-									},
-								},
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "DefaultGateway",
 							},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "duplicate values node selector match expression",
+			desc: "dynamic address from label without key",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "peer",
-						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:        100,
-							ASN:          200,
-							Address:      "2.3.4.5",
-							EBGPMultiHop: false,
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{
-										"foo": "bar",
-									},
-									MatchExpressions: []metav1.LabelSelectorRequirement{
-										{
-											Key:      "bar",
-											Operator: metav1.LabelSelectorOpIn,
-											Values:   []string{"quux", "quux"},
-										},
-									},
-								},
+							MyASN: 42,
+							ASN:   42,
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "NodeLabel",
 							},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "duplicate peers",
+			desc: "dynamic address from interface without interface",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
+							MyASN: 42,
+							ASN:   42,
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "Interface",
+							},
 						},
 					},
+				},
+			},
+		},
+
+		{
+			desc: "dynamic address from default gateway with vrf",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
 							ASN:     42,
-							Address: "1.2.3.4",
+							VRFName: "red",
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "DefaultGateway",
+							},
 						},
 					},
 				},
 			},
 		},
+
 		{
-			desc: "no pool name",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
-					{},
-				},
-			},
-		},
-		{
-			desc: "address pool with no address",
+			desc: "invalid my-asn",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
+						Spec: v1beta2.BGPPeerSpec{
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
 					},
 				},
 			},
 		},
 		{
-			desc: "address pool with no protocol",
+			desc: "invalid peer-asn",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							Address: "1.2.3.4",
+						},
 					},
 				},
 			},
 		},
 		{
-			desc: "invalid pool CIDR",
+			desc: "invalid ebgp-multihop",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"100.200.300.400/24",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        42,
+							ASN:          42,
+							Address:      "1.2.3.4",
+							EBGPMultiHop: true,
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "invalid pool CIDR prefix length",
+			desc: "invalid ebgp-multihop ttl on ibgp peer",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/33",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             42,
+							Address:         "1.2.3.4",
+							EBGPMultiHopTTL: 3,
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "invalid pool CIDR, first address of the range is after the second",
+			desc: "both ebgp-multihop and ttl security",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.10-1.2.3.1",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.4",
+							EBGPMultiHop:    true,
+							TTLSecurityHops: 2,
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "simple advertisement",
+			desc: "both ebgp-multihop ttl and ttl security",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.4",
+							EBGPMultiHopTTL: 3,
+							TTLSecurityHops: 2,
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+			},
+		},
+		{
+			desc: "ebgp-multihop ttl too high",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "adv3",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:           42,
+							ASN:             142,
+							Address:         "1.2.3.4",
+							EBGPMultiHopTTL: 256,
 						},
 					},
 				},
 			},
-			want: &Config{
-				Pools: &Pools{ByName: map[string]*Pool{
-					"pool1": {
-						Name:       "pool1",
-						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
-						BGPAdvertisements: []*BGPAdvertisement{
-							{
-								Name:                "adv3",
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{},
-							},
+		},
+		{
+			desc: "invalid hold time (too short)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							HoldTime: metav1.Duration{Duration: time.Second},
 						},
 					},
-				}},
-				BFDProfiles: map[string]*BFDProfile{},
-				Peers:       map[string]*Peer{},
+				},
 			},
 		},
 		{
-			desc: "advertisement with default BGP settings",
+			desc: "invalid RouterID",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							RouterID: "oh god how do I BGP",
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+			},
+		},
+		{
+			desc: "empty node selector (select everything)",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "adv3",
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
 						},
 					},
 				},
 			},
 			want: &Config{
-				Pools: &Pools{ByName: map[string]*Pool{
-					"pool1": {
-						Name:       "pool1",
-						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
-						BGPAdvertisements: []*BGPAdvertisement{
-							{
-								Name:                "adv3",
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{},
-							},
-						},
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
 					},
-				}},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
 				BFDProfiles: map[string]*BFDProfile{},
-				Peers:       map[string]*Peer{},
 			},
 		},
 		{
-			desc: "bad aggregation length (too long)",
+			desc: "invalid expression node selector (missing key)",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.10-1.2.3.1",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []metav1.LabelSelectorRequirement{
+										{
+											Operator: "In",
+											Values:   []string{"quux"},
+										},
+									},
+								},
 							},
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
-					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(34),
-						},
-					},
-				},
 			},
 		},
 		{
-			desc: "bad aggregation length (incompatible with CIDR)",
+			desc: "invalid expression node selector (missing operator)",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.20.30.40/24",
-								"1.2.3.0/28",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []metav1.LabelSelectorRequirement{
+										{
+											Key:    "bar",
+											Values: []string{"quux"},
+										},
+									},
+								},
 							},
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
-					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(26),
-						},
-					},
-				},
 			},
 		},
 		{
-			desc: "different local pref - same peers and nodes",
+			desc: "invalid expression node selector (invalid operator)",
 			crs: ClusterResources{
-				Nodes: []corev1.Node{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node1",
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node2",
-						},
-					},
-				},
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.20.30.40/24",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []metav1.LabelSelectorRequirement{
+										{
+											Key:      "bar",
+											Operator: "surrounds",
+											Values:   []string{"quux"},
+										},
+									},
+								},
 							},
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
-					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 100,
-						},
-					},
-					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 200,
-						},
-					},
-				},
 			},
 		},
 		{
-			desc: "different local pref - different ipv4 aggregation length",
+			desc: "duplicate values node selector match expression",
 			crs: ClusterResources{
-				Nodes: []corev1.Node{
+				Peers: []v1beta2.BGPPeer{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "node1",
+							Name: "peer",
 						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node2",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:        100,
+							ASN:          200,
+							Address:      "2.3.4.5",
+							EBGPMultiHop: false,
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{
+										"foo": "bar",
+									},
+									MatchExpressions: []metav1.LabelSelectorRequirement{
+										{
+											Key:      "bar",
+											Operator: metav1.LabelSelectorOpIn,
+											Values:   []string{"quux", "quux"},
+										},
+									},
+								},
+							},
 						},
 					},
 				},
-				Pools: []v1beta1.IPAddressPool{
+			},
+		},
+		{
+			desc: "duplicate peers",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.20.30.40/24",
-							},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
 						},
 					},
-				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref:         100,
-							AggregationLength: pointer.Int32Ptr(24),
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
 						},
 					},
+				},
+			},
+		},
+		{
+			desc: "no pool name",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{},
+				},
+			},
+		},
+		{
+			desc: "address pool with no address",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 200,
-						},
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
 					},
 				},
 			},
 		},
 		{
-			desc: "different local pref - different aggregation lengths",
+			desc: "address pool with no protocol",
 			crs: ClusterResources{
-				Nodes: []corev1.Node{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node1",
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+					},
+				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"100.200.300.400/24",
+							},
 						},
 					},
+				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR prefix length",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node2",
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/33",
+							},
 						},
 					},
 				},
+			},
+		},
+		{
+			desc: "invalid pool CIDR, first address of the range is after the second",
+			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
 							Addresses: []string{
-								"10.20.30.40/24",
+								"1.2.3.10-1.2.3.1",
 							},
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+			},
+		},
+		{
+			desc: "simple advertisement",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref:           100,
-							AggregationLength:   pointer.Int32Ptr(24),
-							AggregationLengthV6: pointer.Int32Ptr(120),
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
 						},
 					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 200,
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv3",
 						},
 					},
 				},
@@ -1244,23 +1485,14 @@ func TestParse(t *testing.T) {
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR: []*net.IPNet{
-							ipnet("10.20.30.40/24"),
-						},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
-								AggregationLength:   24,
-								AggregationLengthV6: 120,
-								LocalPref:           100,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node1": true, "node2": true},
-							},
-							{
+								Name:                "adv3",
 								AggregationLength:   32,
 								AggregationLengthV6: 128,
-								LocalPref:           200,
 								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node1": true, "node2": true},
+								Nodes:               map[string]bool{},
 							},
 						},
 					},
@@ -1270,51 +1502,22 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "different local pref - same peers & different nodes",
+			desc: "advertisement with default BGP settings",
 			crs: ClusterResources{
-				Nodes: []corev1.Node{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:   "node1",
-							Labels: map[string]string{"node": "node1"},
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:   "node2",
-							Labels: map[string]string{"node": "node2"},
-						},
-					},
-				},
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
 							Addresses: []string{
-								"10.20.30.40/24",
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 100,
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{"node": "node1"},
-								},
-							},
-						},
-					},
-					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 200,
-							NodeSelectors: []metav1.LabelSelector{
-								{
-									MatchLabels: map[string]string{"node": "node2"},
-								},
-							},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv3",
 						},
 					},
 				},
@@ -1324,23 +1527,14 @@ func TestParse(t *testing.T) {
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR: []*net.IPNet{
-							ipnet("10.20.30.40/24"),
-						},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
+								Name:                "adv3",
 								AggregationLength:   32,
 								AggregationLengthV6: 128,
-								LocalPref:           100,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node1": true},
-							},
-							{
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								LocalPref:           200,
 								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node2": true},
+								Nodes:               map[string]bool{},
 							},
 						},
 					},
@@ -1350,41 +1544,25 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "different local pref - different peers & same nodes",
+			desc: "advertisement with endpoint weighting",
 			crs: ClusterResources{
-				Nodes: []corev1.Node{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node1",
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "node2",
-						},
-					},
-				},
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
 							Addresses: []string{
-								"10.20.30.40/24",
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 100,
-							Peers:     []string{"peer1"},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv1",
 						},
-					},
-					{
 						Spec: v1beta1.BGPAdvertisementSpec{
-							LocalPref: 200,
-							Peers:     []string{"peer2"},
+							EndpointWeighting: "LinkBandwidth",
 						},
 					},
 				},
@@ -1394,25 +1572,15 @@ func TestParse(t *testing.T) {
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR: []*net.IPNet{
-							ipnet("10.20.30.40/24"),
-						},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
+								Name:                "adv1",
 								AggregationLength:   32,
 								AggregationLengthV6: 128,
-								LocalPref:           100,
-								Peers:               []string{"peer1"},
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node1": true, "node2": true},
-							},
-							{
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								LocalPref:           200,
-								Peers:               []string{"peer2"},
 								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{"node1": true, "node2": true},
+								Nodes:               map[string]bool{},
+								EndpointWeighting:   LinkBandwidthWeighting,
 							},
 						},
 					},
@@ -1422,22 +1590,25 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "aggregation length by range",
+			desc: "advertisement with service overrides namespaces",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
 							Addresses: []string{
-								"3.3.3.2-3.3.3.254",
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv1",
+						},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(26),
+							ServiceOverridesNamespaces: []string{"team-a", "team-b"},
 						},
 					},
 				},
@@ -1447,27 +1618,15 @@ func TestParse(t *testing.T) {
 					"pool1": {
 						Name:       "pool1",
 						AutoAssign: true,
-						CIDR: []*net.IPNet{
-							ipnet("3.3.3.2/31"),
-							ipnet("3.3.3.4/30"),
-							ipnet("3.3.3.8/29"),
-							ipnet("3.3.3.16/28"),
-							ipnet("3.3.3.32/27"),
-							ipnet("3.3.3.64/26"),
-							ipnet("3.3.3.128/26"),
-							ipnet("3.3.3.192/27"),
-							ipnet("3.3.3.224/28"),
-							ipnet("3.3.3.240/29"),
-							ipnet("3.3.3.248/30"),
-							ipnet("3.3.3.252/31"),
-							ipnet("3.3.3.254/32"),
-						},
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
 						BGPAdvertisements: []*BGPAdvertisement{
 							{
-								AggregationLength:   26,
-								AggregationLengthV6: 128,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{},
+								Name:                       "adv1",
+								AggregationLength:          32,
+								AggregationLengthV6:        128,
+								Communities:                map[community.BGPCommunity]bool{},
+								Nodes:                      map[string]bool{},
+								ServiceOverridesNamespaces: map[string]bool{"team-a": true, "team-b": true},
 							},
 						},
 					},
@@ -1477,14 +1636,14 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			desc: "aggregation length by range, too wide",
+			desc: "bad service overrides namespace",
 			crs: ClusterResources{
 				Pools: []v1beta1.IPAddressPool{
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
 						Spec: v1beta1.IPAddressPoolSpec{
 							Addresses: []string{
-								"3.3.3.2-3.3.3.254",
+								"1.2.3.0/24",
 							},
 						},
 					},
@@ -1492,326 +1651,2156 @@ func TestParse(t *testing.T) {
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
 						Spec: v1beta1.BGPAdvertisementSpec{
-							AggregationLength: pointer.Int32Ptr(24),
+							ServiceOverridesNamespaces: []string{"Not_A_Namespace"},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate ip address pools - in L2 adv",
+			desc: "advertisement leaked to vrfs",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				L2Advs: []v1beta1.L2Advertisement{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
-						Spec: v1beta1.L2AdvertisementSpec{
-							IPAddressPools: []string{testPoolName, testPoolName},
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "duplicate ip address pools - in BGP adv",
-			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv1",
+						},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							IPAddressPools: []string{testPoolName, testPoolName},
+							LeakToVRFs: []string{"default", "red"},
 						},
 					},
 				},
 			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{},
+								LeakToVRFs:          []string{"default", "red"},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
 		},
 		{
-			desc: "duplicate peers - in BGP adv",
+			desc: "duplicate leak vrf",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
-				Peers: []v1beta2.BGPPeer{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "peer1",
-						},
-						Spec: v1beta2.BGPPeerSpec{
-							MyASN:   42,
-							ASN:     42,
-							Address: "1.2.3.4",
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
 						},
 					},
 				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							IPAddressPools: []string{testPoolName},
-							Peers:          []string{"peer1", "peer1"},
+							LeakToVRFs: []string{"red", "red"},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (wrong format) - in BGP adv",
+			desc: "empty leak vrf",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1234"},
-							IPAddressPools: []string{testPoolName},
+							LeakToVRFs: []string{""},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (asn part doesn't fit) - in BGP adv",
+			desc: "advertisement with evpn",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv1",
+						},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"99999999:1"},
-							IPAddressPools: []string{testPoolName},
+							EVPN: &v1beta1.EVPNAdvertisement{
+								VRF:          "red",
+								VNI:          100,
+								RouteTargets: []string{"64512:100", "4200000000:100", "10.0.0.1:100"},
+							},
 						},
 					},
 				},
 			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{},
+								EVPN: &EVPNAdvertisement{
+									VRF:          "red",
+									VNI:          100,
+									RouteTargets: []string{"64512:100", "4200000000:100", "10.0.0.1:100"},
+								},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
 		},
 		{
-			desc: "bad community literal (community# part doesn't fit) - in BGP adv",
+			desc: "evpn with an invalid vni",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1:99999999"},
-							IPAddressPools: []string{testPoolName},
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 1 << 24},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community ref (unknown ref) - in BGP adv",
+			desc: "evpn in the default vrf",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"community"},
-							IPAddressPools: []string{testPoolName},
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "default", VNI: 100},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "duplicate community literal - in BGP adv",
+			desc: "evpn with an invalid route target",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{testPool},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
 				BGPAdvs: []v1beta1.BGPAdvertisement{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
 						Spec: v1beta1.BGPAdvertisementSpec{
-							Communities:    []string{"1234:5678", "1234:5678"},
-							IPAddressPools: []string{testPoolName},
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100, RouteTargets: []string{"4200000000:70000"}},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "bad community literal (wrong format) - in the community CR",
+			desc: "evpn with an ipv6 route target",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "community",
-						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234",
-								},
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100, RouteTargets: []string{"2001:db8::1:100"}},
+						},
+					},
+				},
 			},
 		},
 		{
-			desc: "bad community literal (asn part doesn't fit) - in the community CR",
+			desc: "ospf advertisement and areas",
 			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				Pools: []v1beta1.IPAddressPool{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "community",
-						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "99999999:1",
-								},
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "bad community literal (community# part doesn't fit) - in the community CR",
-			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				OSPFAdvs: []v1beta1.OSPFAdvertisement{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "community",
+							Name: "adv1",
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1:99999999",
-								},
-							},
+						Spec: v1beta1.OSPFAdvertisementSpec{
+							Metric:     pointer.Uint32Ptr(100),
+							MetricType: 1,
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv2",
 						},
 					},
 				},
-			},
-		},
-		{
-			desc: "duplicate communities definition (in 2 different crs)",
-			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+				OSPFAreas: []v1beta1.OSPFArea{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "community1",
+							Name: "backbone",
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234:5678",
-								},
-							},
+						Spec: v1beta1.OSPFAreaSpec{
+							Area:       "0.0.0.0",
+							Interfaces: []string{"eth0"},
 						},
 					},
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "community2",
+							Name: "area1",
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
+						Spec: v1beta1.OSPFAreaSpec{
+							Area:       "1",
+							Interfaces: []string{"eth1", "eth2"},
+							NodeSelectors: []metav1.LabelSelector{
 								{
-									Name:  "bar",
-									Value: "1234:5678",
+									MatchLabels: map[string]string{"ospf": "true"},
 								},
 							},
 						},
 					},
 				},
 			},
-		},
-		{
-			desc: "duplicate communities definition (in the same cr)",
-			crs: ClusterResources{
-				Communities: []v1beta1.Community{
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						OSPFAdvertisements: []*OSPFAdvertisement{
+							{
+								Name:       "adv1",
+								Nodes:      map[string]bool{},
+								Metric:     pointer.Uint32Ptr(100),
+								MetricType: 1,
+							},
+							{
+								Name:       "adv2",
+								Nodes:      map[string]bool{},
+								MetricType: 2,
+							},
+						},
+					},
+				}},
+				OSPFAreas: map[string]*OSPFArea{
+					"backbone": {
+						Name:          "backbone",
+						Area:          "0.0.0.0",
+						Interfaces:    []string{"eth0"},
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"area1": {
+						Name:          "area1",
+						Area:          "0.0.0.1",
+						Interfaces:    []string{"eth1", "eth2"},
+						NodeSelectors: []labels.Selector{selector("ospf=true")},
+					},
+				},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "ospf advertisement with an invalid metric type",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				OSPFAdvs: []v1beta1.OSPFAdvertisement{
+					{
+						Spec: v1beta1.OSPFAdvertisementSpec{
+							MetricType: 3,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "ospf area with an invalid id",
+			crs: ClusterResources{
+				OSPFAreas: []v1beta1.OSPFArea{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "community",
+							Name: "area1",
 						},
-						Spec: v1beta1.CommunitySpec{
-							Communities: []v1beta1.CommunityAlias{
-								{
-									Name:  "bar",
-									Value: "1234:5678",
+						Spec: v1beta1.OSPFAreaSpec{
+							Area:       "area1",
+							Interfaces: []string{"eth0"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "pools announcing external IPs and the service cluster IP range",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "external"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+							AutoAssign:          pointer.BoolPtr(false),
+							AnnounceExternalIPs: true,
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "cluster-ips"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.96.0.0/12",
+							},
+							ServiceClusterIPRange: true,
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"external": {
+						Name:                "external",
+						CIDR:                []*net.IPNet{ipnet("1.2.3.0/24")},
+						AnnounceExternalIPs: true,
+					},
+					"cluster-ips": {
+						Name:                  "cluster-ips",
+						CIDR:                  []*net.IPNet{ipnet("10.96.0.0/12")},
+						ServiceClusterIPRange: true,
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "pool announcing external IPs with auto assign",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "external"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+							AnnounceExternalIPs: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "service cluster IP range announcing external IPs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "cluster-ips"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.96.0.0/12",
+							},
+							ServiceClusterIPRange: true,
+							AnnounceExternalIPs:   true,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "service cluster IP range with a service allocation",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "cluster-ips"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.96.0.0/12",
+							},
+							ServiceClusterIPRange: true,
+							AllocateTo: &v1beta1.ServiceAllocation{
+								Priority:   1,
+								Namespaces: []string{"test"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "pool leasing addresses from an ipam",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.0.0/30",
+							},
+							IPAM: &v1beta1.IPAMProvider{
+								Addresses: []string{
+									"10.20.1.0/24",
+									"10.20.2.1-10.20.2.2",
 								},
-								{
-									Name:  "bar",
-									Value: "1234:5678",
+								HTTP: &v1beta1.HTTPIPAMProvider{
+									URL: "http://ipam.example.com/metallb",
+								},
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{},
+							IPAM: &v1beta1.IPAMProvider{
+								Addresses: []string{
+									"10.30.0.0/24",
+								},
+								HTTP: &v1beta1.HTTPIPAMProvider{
+									URL: "https://ipam.example.com",
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("10.20.0.0/30"),
+							ipnet("10.20.1.0/24"),
+							ipnet("10.20.2.1/32"),
+							ipnet("10.20.2.2/32"),
+						},
+						IPAM: &IPAM{
+							CIDR: []*net.IPNet{
+								ipnet("10.20.1.0/24"),
+								ipnet("10.20.2.1/32"),
+								ipnet("10.20.2.2/32"),
+							},
+							URL: "http://ipam.example.com/metallb",
+						},
+					},
+					"pool2": {
+						Name:       "pool2",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("10.30.0.0/24")},
+						IPAM: &IPAM{
+							CIDR: []*net.IPNet{ipnet("10.30.0.0/24")},
+							URL:  "https://ipam.example.com",
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "ipam without provider",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							IPAM: &v1beta1.IPAMProvider{
+								Addresses: []string{
+									"10.20.1.0/24",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "ipam with an invalid url",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							IPAM: &v1beta1.IPAMProvider{
+								Addresses: []string{
+									"10.20.1.0/24",
+								},
+								HTTP: &v1beta1.HTTPIPAMProvider{
+									URL: "ipam.example.com",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "ipam addresses overlapping the pool addresses",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.1.0/24",
+							},
+							IPAM: &v1beta1.IPAMProvider{
+								Addresses: []string{
+									"10.20.1.128/25",
 								},
+								HTTP: &v1beta1.HTTPIPAMProvider{
+									URL: "http://ipam.example.com",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "kernel route advertisements",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
 							},
 						},
 					},
 				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "routes",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "routes-table",
+						},
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Mode:      v1beta1.KernelRouteModeRoute,
+							Table:     pointer.Uint32Ptr(100),
+							Interface: "dummy0",
+							Protocol:  pointer.Uint32Ptr(4),
+							Metric:    pointer.Uint32Ptr(10),
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "addresses",
+						},
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Mode:      v1beta1.KernelRouteModeAddress,
+							Interface: "dummy0",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						KernelRouteAdvertisements: []*KernelRouteAdvertisement{
+							{
+								Name:     "routes",
+								Nodes:    map[string]bool{},
+								Table:    254,
+								Protocol: 196,
+							},
+							{
+								Name:      "routes-table",
+								Nodes:     map[string]bool{},
+								Interface: "dummy0",
+								Table:     100,
+								Protocol:  4,
+								Metric:    10,
+							},
+							{
+								Name:      "addresses",
+								Nodes:     map[string]bool{},
+								Address:   true,
+								Interface: "dummy0",
+								Protocol:  196,
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "kernel route advertisement with an invalid mode",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Mode: "Tunnel",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "kernel route advertisement in address mode without interface",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Mode: v1beta1.KernelRouteModeAddress,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "kernel route advertisement in address mode with a table",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Mode:      v1beta1.KernelRouteModeAddress,
+							Interface: "dummy0",
+							Table:     pointer.Uint32Ptr(100),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "kernel route advertisement in the local table",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Table: pointer.Uint32Ptr(255),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "kernel route advertisement with a kernel protocol",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				KernelRouteAdvs: []v1beta1.KernelRouteAdvertisement{
+					{
+						Spec: v1beta1.KernelRouteAdvertisementSpec{
+							Protocol: pointer.Uint32Ptr(2),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "advertisement with as path prepend, med and node overrides",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   "node1",
+							Labels: map[string]string{"site": "standby"},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node2",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv1",
+						},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							ASPathPrepend: &v1beta1.ASPathPrepend{Count: 1},
+							MED:           pointer.Uint32Ptr(10),
+							NodeOverrides: []v1beta1.BGPAdvertisementNodeOverride{
+								{
+									NodeSelectors: []metav1.LabelSelector{
+										{
+											MatchLabels: map[string]string{"site": "standby"},
+										},
+									},
+									ASPathPrepend: &v1beta1.ASPathPrepend{ASN: 65010, Count: 3},
+								},
+								{
+									NodeSelectors: []metav1.LabelSelector{
+										{
+											MatchLabels: map[string]string{"site": "standby"},
+										},
+									},
+									MED: pointer.Uint32Ptr(100),
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv1",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true, "node2": true},
+								ASPathPrepend:       &ASPathPrepend{Count: 1},
+								MED:                 pointer.Uint32Ptr(10),
+								NodeOverrides: map[string]BGPNodeOverride{
+									"node1": {
+										ASPathPrepend: &ASPathPrepend{ASN: 65010, Count: 3},
+									},
+								},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "bad as path prepend count",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							ASPathPrepend: &v1beta1.ASPathPrepend{Count: 11},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "node override without node selectors",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							NodeOverrides: []v1beta1.BGPAdvertisementNodeOverride{
+								{
+									MED: pointer.Uint32Ptr(100),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad endpoint weighting",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EndpointWeighting: "Foo",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad aggregation length (too long)",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.10-1.2.3.1",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(34),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad aggregation length (incompatible with CIDR)",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+								"1.2.3.0/28",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(26),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "different local pref - same peers and nodes",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node1",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node2",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+						},
+					},
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "different local pref - different ipv4 aggregation length",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node1",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node2",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref:         100,
+							AggregationLength: pointer.Int32Ptr(24),
+						},
+					},
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "different local pref - different aggregation lengths",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node1",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node2",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref:           100,
+							AggregationLength:   pointer.Int32Ptr(24),
+							AggregationLengthV6: pointer.Int32Ptr(120),
+						},
+					},
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("10.20.30.40/24"),
+						},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								AggregationLength:   24,
+								AggregationLengthV6: 120,
+								LocalPref:           100,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true, "node2": true},
+							},
+							{
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           200,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true, "node2": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "different local pref - same peers & different nodes",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   "node1",
+							Labels: map[string]string{"node": "node1"},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:   "node2",
+							Labels: map[string]string{"node": "node2"},
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{"node": "node1"},
+								},
+							},
+						},
+					},
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{"node": "node2"},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("10.20.30.40/24"),
+						},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           100,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true},
+							},
+							{
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           200,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node2": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "different local pref - different peers & same nodes",
+			crs: ClusterResources{
+				Nodes: []corev1.Node{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node1",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "node2",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.20.30.40/24",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 100,
+							Peers:     []string{"peer1"},
+						},
+					},
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LocalPref: 200,
+							Peers:     []string{"peer2"},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("10.20.30.40/24"),
+						},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           100,
+								Peers:               []string{"peer1"},
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true, "node2": true},
+							},
+							{
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								LocalPref:           200,
+								Peers:               []string{"peer2"},
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{"node1": true, "node2": true},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "aggregation length by range",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"3.3.3.2-3.3.3.254",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(26),
+						},
+					},
+				},
+			},
+			want: &Config{
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR: []*net.IPNet{
+							ipnet("3.3.3.2/31"),
+							ipnet("3.3.3.4/30"),
+							ipnet("3.3.3.8/29"),
+							ipnet("3.3.3.16/28"),
+							ipnet("3.3.3.32/27"),
+							ipnet("3.3.3.64/26"),
+							ipnet("3.3.3.128/26"),
+							ipnet("3.3.3.192/27"),
+							ipnet("3.3.3.224/28"),
+							ipnet("3.3.3.240/29"),
+							ipnet("3.3.3.248/30"),
+							ipnet("3.3.3.252/31"),
+							ipnet("3.3.3.254/32"),
+						},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								AggregationLength:   26,
+								AggregationLengthV6: 128,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{},
+				Peers:       map[string]*Peer{},
+			},
+		},
+		{
+			desc: "aggregation length by range, too wide",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"3.3.3.2-3.3.3.254",
+							},
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							AggregationLength: pointer.Int32Ptr(24),
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate ip address pools - in L2 adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				L2Advs: []v1beta1.L2Advertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.L2AdvertisementSpec{
+							IPAddressPools: []string{testPoolName, testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate ip address pools - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName, testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate peers - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							IPAddressPools: []string{testPoolName},
+							Peers:          []string{"peer1", "peer1"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (wrong format) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1234"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (asn part doesn't fit) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"99999999:1"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (community# part doesn't fit) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1:99999999"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community ref (unknown ref) - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"community"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate community literal - in BGP adv",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{testPool},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: testAdvName},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Communities:    []string{"1234:5678", "1234:5678"},
+							IPAddressPools: []string{testPoolName},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (wrong format) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (asn part doesn't fit) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "99999999:1",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "bad community literal (community# part doesn't fit) - in the community CR",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1:99999999",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate communities definition (in 2 different crs)",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community1",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community2",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate communities definition (in the same cr)",
+			crs: ClusterResources{
+				Communities: []v1beta1.Community{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "community",
+						},
+						Spec: v1beta1.CommunitySpec{
+							Communities: []v1beta1.CommunityAlias{
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+								{
+									Name:  "bar",
+									Value: "1234:5678",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate pool definition",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec:       v1beta1.IPAddressPoolSpec{},
+					},
+				},
+			},
+		},
+		{
+			desc: "duplicate CIDRs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "overlapping CIDRs",
+			crs: ClusterResources{
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								" 10.0.0.0/8",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"10.0.0.0/16",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Session with default BFD Profile",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:      42,
+							ASN:        42,
+							Address:    "1.2.3.4",
+							BFDProfile: "default",
+						},
+					},
+				},
+				Pools: []v1beta1.IPAddressPool{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "pool1",
+						},
+						Spec: v1beta1.IPAddressPoolSpec{
+							Addresses: []string{
+								"1.2.3.0/24",
+							},
+						},
+					},
+				},
+				BFDProfiles: []v1beta1.BFDProfile{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "default",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "adv3",
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						BFDProfile:    "default",
+					},
+				},
+				Pools: &Pools{ByName: map[string]*Pool{
+					"pool1": {
+						Name:       "pool1",
+						AutoAssign: true,
+						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
+						BGPAdvertisements: []*BGPAdvertisement{
+							{
+								Name:                "adv3",
+								AggregationLength:   32,
+								AggregationLengthV6: 128,
+								Communities:         map[community.BGPCommunity]bool{},
+								Nodes:               map[string]bool{},
+							},
+						},
+					},
+				}},
+				BFDProfiles: map[string]*BFDProfile{
+					"default": {
+						Name: "default",
+					},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with both password and secret ref set",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							Password: "nopass",
+							PasswordSecret: corev1.SecretReference{Name: "nosecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with invalid secret type",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeOpaque, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"}},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer without password set in the secret",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"}},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with a valid secret",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Port:    179,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"},
+						Data: map[string][]byte{"password": []byte("nopass")}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						Port:          179,
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						BFDProfile:    "",
+						Password:      "nopass",
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP Peer with a next password in the secret",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"},
+						Data: map[string][]byte{"password": []byte("nopass"), "nextPassword": []byte("newpass")}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Password:      "nopass",
+						NextPassword:  "newpass",
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP Peer with the same next password in the secret",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"},
+						Data: map[string][]byte{"password": []byte("nopass"), "nextPassword": []byte("nopass")}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Password:      "nopass",
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP Peer with an empty next password in the secret",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
+								Namespace: "metallb-system"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"},
+						Data: map[string][]byte{"password": []byte("nopass"), "nextPassword": []byte("")}},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with a TCP-AO key chain",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "aokeys", Namespace: "metallb-system"},
+							},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`
+- sendID: 2
+  recvID: 3
+  algorithm: hmac(sha256)
+  key: new
+- sendID: 1
+  recvID: 1
+  algorithm: hmac(sha1)
+  key: old
+`)}},
+				},
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						AOKeys: []TCPAOKey{
+							{SendID: 2, RecvID: 3, Algorithm: "hmac(sha256)", Key: "new"},
+							{SendID: 1, RecvID: 1, Algorithm: "hmac(sha1)", Key: "old"},
+						},
+					},
+				},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
-			desc: "duplicate pool definition",
+			desc: "BGP Peer with both a password and a TCP-AO key chain",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
-					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
-					},
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec:       v1beta1.IPAddressPoolSpec{},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:    42,
+							ASN:      42,
+							Address:  "1.2.3.4",
+							Password: "nopass",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "aokeys", Namespace: "metallb-system"},
+							},
+						},
 					},
 				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`
+- sendID: 2
+  recvID: 3
+  algorithm: hmac(sha256)
+  key: new
+- sendID: 1
+  recvID: 1
+  algorithm: hmac(sha1)
+  key: old
+`)}},
+				},
 			},
 		},
 		{
-			desc: "duplicate CIDRs",
+			desc: "BGP Peer with unavailable TCP-AO key chain secret",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "missing", Namespace: "metallb-system"},
 							},
 						},
 					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`
+- sendID: 2
+  recvID: 3
+  algorithm: hmac(sha256)
+  key: new
+- sendID: 1
+  recvID: 1
+  algorithm: hmac(sha1)
+  key: old
+`)}},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with invalid TCP-AO algorithm",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "aokeys", Namespace: "metallb-system"},
 							},
 						},
 					},
 				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`
+- sendID: 1
+  recvID: 1
+  algorithm: md5
+  key: old
+`)}},
+				},
 			},
 		},
 		{
-			desc: "overlapping CIDRs",
+			desc: "BGP Peer with duplicate TCP-AO send ids",
 			crs: ClusterResources{
-				Pools: []v1beta1.IPAddressPool{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool1"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								" 10.0.0.0/8",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "aokeys", Namespace: "metallb-system"},
 							},
 						},
 					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`
+- sendID: 1
+  recvID: 1
+  algorithm: hmac(sha1)
+  key: old
+- sendID: 1
+  recvID: 2
+  algorithm: hmac(sha1)
+  key: new
+`)}},
+				},
+			},
+		},
+		{
+			desc: "BGP Peer with empty TCP-AO key chain",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{Name: "pool2"},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"10.0.0.0/16",
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type:           "AO",
+								KeyChainSecret: corev1.SecretReference{Name: "aokeys", Namespace: "metallb-system"},
 							},
 						},
 					},
 				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"aokeys": {ObjectMeta: metav1.ObjectMeta{Name: "aokeys", Namespace: "metallb-system"},
+						Data: map[string][]byte{"keychain": []byte(`[]`)}},
+				},
 			},
 		},
 		{
-			desc: "Session with default BFD Profile",
+			desc: "BGP Peer receiving filtered routes",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -1819,36 +3808,54 @@ func TestParse(t *testing.T) {
 							Name: "peer1",
 						},
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:      42,
-							ASN:        42,
-							Address:    "1.2.3.4",
-							BFDProfile: "default",
-						},
-					},
-				},
-				Pools: []v1beta1.IPAddressPool{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "pool1",
-						},
-						Spec: v1beta1.IPAddressPoolSpec{
-							Addresses: []string{
-								"1.2.3.0/24",
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode:         "filtered",
+								Prefixes:     []string{"10.0.0.0/8", "2001:db8::/32"},
+								RoutingTable: 100,
 							},
 						},
 					},
 				},
-				BFDProfiles: []v1beta1.BFDProfile{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "default",
+			},
+			want: &Config{
+				Peers: map[string]*Peer{
+					"peer1": {
+						Name:          "peer1",
+						MyASN:         42,
+						ASN:           42,
+						Addr:          net.ParseIP("1.2.3.4"),
+						HoldTime:      90 * time.Second,
+						KeepaliveTime: 30 * time.Second,
+						NodeSelectors: []labels.Selector{labels.Everything()},
+						Receive: &ReceivePolicy{
+							Mode:         ReceiveFiltered,
+							Prefixes:     []*net.IPNet{ipnet("10.0.0.0/8"), ipnet("2001:db8::/32")},
+							RoutingTable: 100,
 						},
 					},
 				},
-				BGPAdvs: []v1beta1.BGPAdvertisement{
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP Peer receiving no routes",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
 					{
 						ObjectMeta: metav1.ObjectMeta{
-							Name: "adv3",
+							Name: "peer1",
+						},
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode: "none",
+							},
 						},
 					},
 				},
@@ -1863,51 +3870,49 @@ func TestParse(t *testing.T) {
 						HoldTime:      90 * time.Second,
 						KeepaliveTime: 30 * time.Second,
 						NodeSelectors: []labels.Selector{labels.Everything()},
-						BFDProfile:    "default",
 					},
 				},
-				Pools: &Pools{ByName: map[string]*Pool{
-					"pool1": {
-						Name:       "pool1",
-						AutoAssign: true,
-						CIDR:       []*net.IPNet{ipnet("1.2.3.0/24")},
-						BGPAdvertisements: []*BGPAdvertisement{
-							{
-								Name:                "adv3",
-								AggregationLength:   32,
-								AggregationLengthV6: 128,
-								Communities:         map[community.BGPCommunity]bool{},
-								Nodes:               map[string]bool{},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+			},
+		},
+		{
+			desc: "BGP Peer receiving filtered routes without prefixes",
+			crs: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode: "filtered",
 							},
 						},
 					},
-				}},
-				BFDProfiles: map[string]*BFDProfile{
-					"default": {
-						Name: "default",
-					},
 				},
 			},
 		},
 		{
-			desc: "BGP Peer with both password and secret ref set",
+			desc: "BGP Peer receiving all routes with prefixes",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
 						Spec: v1beta2.BGPPeerSpec{
-							MyASN:    42,
-							ASN:      42,
-							Address:  "1.2.3.4",
-							Password: "nopass",
-							PasswordSecret: corev1.SecretReference{Name: "nosecret",
-								Namespace: "metallb-system"},
+							MyASN:   42,
+							ASN:     42,
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode:     "all",
+								Prefixes: []string{"10.0.0.0/8"},
+							},
 						},
 					},
 				},
 			},
 		},
 		{
-			desc: "BGP Peer with invalid secret type",
+			desc: "BGP Peer receiving routes with an invalid prefix",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -1915,18 +3920,17 @@ func TestParse(t *testing.T) {
 							MyASN:   42,
 							ASN:     42,
 							Address: "1.2.3.4",
-							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
-								Namespace: "metallb-system"},
+							Receive: &v1beta2.BGPReceive{
+								Mode:     "filtered",
+								Prefixes: []string{"10.0.0.0"},
+							},
 						},
 					},
 				},
-				PasswordSecrets: map[string]corev1.Secret{
-					"bgpsecret": {Type: corev1.SecretTypeOpaque, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"}},
-				},
 			},
 		},
 		{
-			desc: "BGP Peer without password set in the secret",
+			desc: "BGP Peer receiving no routes with a routing table",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
@@ -1934,56 +3938,30 @@ func TestParse(t *testing.T) {
 							MyASN:   42,
 							ASN:     42,
 							Address: "1.2.3.4",
-							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
-								Namespace: "metallb-system"},
+							Receive: &v1beta2.BGPReceive{
+								Mode:         "none",
+								RoutingTable: 100,
+							},
 						},
 					},
 				},
-				PasswordSecrets: map[string]corev1.Secret{
-					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"}},
-				},
 			},
 		},
 		{
-			desc: "BGP Peer with a valid secret",
+			desc: "BGP Peer with an invalid receive mode",
 			crs: ClusterResources{
 				Peers: []v1beta2.BGPPeer{
 					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "peer1",
-						},
 						Spec: v1beta2.BGPPeerSpec{
 							MyASN:   42,
 							ASN:     42,
-							Port:    179,
 							Address: "1.2.3.4",
-							PasswordSecret: corev1.SecretReference{Name: "bgpsecret",
-								Namespace: "metallb-system"},
+							Receive: &v1beta2.BGPReceive{
+								Mode: "some",
+							},
 						},
 					},
 				},
-				PasswordSecrets: map[string]corev1.Secret{
-					"bgpsecret": {Type: corev1.SecretTypeBasicAuth, ObjectMeta: metav1.ObjectMeta{Name: "bgpsecret", Namespace: "metallb-system"},
-						Data: map[string][]byte{"password": []byte("nopass")}},
-				},
-			},
-			want: &Config{
-				Peers: map[string]*Peer{
-					"peer1": {
-						Name:          "peer1",
-						MyASN:         42,
-						ASN:           42,
-						Addr:          net.ParseIP("1.2.3.4"),
-						Port:          179,
-						HoldTime:      90 * time.Second,
-						KeepaliveTime: 30 * time.Second,
-						NodeSelectors: []labels.Selector{labels.Everything()},
-						BFDProfile:    "",
-						Password:      "nopass",
-					},
-				},
-				Pools:       &Pools{ByName: map[string]*Pool{}},
-				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
 		{
@@ -3674,6 +5652,60 @@ func TestParse(t *testing.T) {
 				BFDProfiles: map[string]*BFDProfile{},
 			},
 		},
+		{
+			desc: "frr snippets",
+			crs: ClusterResources{
+				FRRSnippets: []v1beta1.FRRSnippet{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "all"},
+						Spec: v1beta1.FRRSnippetSpec{
+							Config: "ip prefix-list all seq 5 permit any",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "selected"},
+						Spec: v1beta1.FRRSnippetSpec{
+							Config: "ip prefix-list selected seq 5 permit any",
+							NodeSelectors: []metav1.LabelSelector{
+								{
+									MatchLabels: map[string]string{"rack": "a"},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Config{
+				Peers:       map[string]*Peer{},
+				Pools:       &Pools{ByName: map[string]*Pool{}},
+				BFDProfiles: map[string]*BFDProfile{},
+				FRRSnippets: map[string]*FRRSnippet{
+					"all": {
+						Name:          "all",
+						Config:        "ip prefix-list all seq 5 permit any",
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"selected": {
+						Name:          "selected",
+						Config:        "ip prefix-list selected seq 5 permit any",
+						NodeSelectors: []labels.Selector{selector("rack=a")},
+					},
+				},
+			},
+		},
+		{
+			desc: "frr snippet with empty config",
+			crs: ClusterResources{
+				FRRSnippets: []v1beta1.FRRSnippet{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "empty"},
+						Spec: v1beta1.FRRSnippetSpec{
+							Config: " \n",
+						},
+					},
+				},
+			},
+		},
 		{
 			desc: "config legacy pool with invalid community",
 			crs: ClusterResources{
@@ -3860,3 +5892,30 @@ func FuzzParseCIDR(f *testing.F) {
 		_, _ = ParseCIDR(input)
 	})
 }
+
+func TestReceivePolicyAccepts(t *testing.T) {
+	filtered := &ReceivePolicy{
+		Mode:     ReceiveFiltered,
+		Prefixes: []*net.IPNet{ipnet("10.0.0.0/8"), ipnet("2001:db8::/32")},
+	}
+	tests := []struct {
+		desc   string
+		policy *ReceivePolicy
+		prefix string
+		want   bool
+	}{
+		{"no policy", nil, "10.1.0.0/16", false},
+		{"all routes", &ReceivePolicy{Mode: ReceiveAll}, "192.168.1.0/24", true},
+		{"contained prefix", filtered, "10.1.0.0/16", true},
+		{"same prefix", filtered, "10.0.0.0/8", true},
+		{"larger prefix", filtered, "10.0.0.0/7", false},
+		{"other prefix", filtered, "192.168.1.0/24", false},
+		{"contained ipv6 prefix", filtered, "2001:db8:1::/48", true},
+		{"other ipv6 prefix", filtered, "2001:db9::/48", false},
+	}
+	for _, test := range tests {
+		if got := test.policy.Accepts(ipnet(test.prefix)); got != test.want {
+			t.Errorf("%s: expected %s accepted to be %v, got %v", test.desc, test.prefix, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.universe.tf/metallb/internal/bgp/community"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// BGPCommunitiesAnnotation adds the given comma separated communities to the BGP announcements of the service.
+	BGPCommunitiesAnnotation = "metallb.universe.tf/bgp-communities"
+	// BGPLocalPrefAnnotation overrides the LOCAL_PREF of the BGP announcements of the service.
+	BGPLocalPrefAnnotation = "metallb.universe.tf/bgp-local-pref"
+	// BGPASPathPrependAnnotation sets the number of times the local ASN is prepended to the
+	// AS_PATH of the BGP announcements of the service.
+	BGPASPathPrependAnnotation = "metallb.universe.tf/bgp-as-path-prepend"
+
+	// MaxASPathPrepend is the maximum number of times an ASN can be prepended to the AS_PATH.
+	MaxASPathPrepend = 10
+)
+
+// BGPServiceOverrides are the BGP attributes a service can override
+// via annotations, when allowed by the BGPAdvertisement.
+type BGPServiceOverrides struct {
+	// Communities to add to the ones of the advertisement.
+	Communities []community.BGPCommunity
+	// LOCAL_PREF to use instead of the one of the advertisement, if set.
+	LocalPref *uint32
+	// Number of times the local ASN is prepended to the AS_PATH.
+	ASPathPrependCount uint32
+}
+
+// BGPServiceOverridesFor parses the BGP overrides annotations of the given service.
+// It returns nil if the service does not have any.
+func BGPServiceOverridesFor(svc *corev1.Service) (*BGPServiceOverrides, error) {
+	if svc == nil {
+		return nil, nil
+	}
+	res := &BGPServiceOverrides{}
+	found := false
+
+	if v, ok := svc.Annotations[BGPCommunitiesAnnotation]; ok {
+		found = true
+		seen := map[string]bool{}
+		for _, c := range strings.Split(v, ",") {
+			c = strings.TrimSpace(c)
+			if seen[c] {
+				return nil, fmt.Errorf("duplicate community %q in %s", c, BGPCommunitiesAnnotation)
+			}
+			seen[c] = true
+			parsed, err := community.New(c)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid community %q in %s", c, BGPCommunitiesAnnotation)
+			}
+			res.Communities = append(res.Communities, parsed)
+		}
+	}
+
+	if v, ok := svc.Annotations[BGPLocalPrefAnnotation]; ok {
+		found = true
+		localPref, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", BGPLocalPrefAnnotation, v)
+		}
+		lp := uint32(localPref)
+		res.LocalPref = &lp
+	}
+
+	if v, ok := svc.Annotations[BGPASPathPrependAnnotation]; ok {
+		found = true
+		count, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", BGPASPathPrependAnnotation, v)
+		}
+		if count < 1 || count > MaxASPathPrepend {
+			return nil, fmt.Errorf("invalid %s %q, must be between 1 and %d", BGPASPathPrependAnnotation, v, MaxASPathPrepend)
+		}
+		res.ASPathPrependCount = uint32(count)
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// ValidateServiceAnnotations returns an error if the MetalLB annotations
+// of the given service are not valid.
+func ValidateServiceAnnotations(svc *corev1.Service) error {
+	_, err := BGPServiceOverridesFor(svc)
+	return err
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/bgp/community"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBGPServiceOverrides(t *testing.T) {
+	localPref := uint32(300)
+	legacy, _ := community.New("1234:5678")
+	large, _ := community.New("large:123:456:789")
+
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		want        *BGPServiceOverrides
+		mustFail    bool
+	}{
+		{
+			desc: "no annotations",
+		},
+		{
+			desc: "unrelated annotations",
+			annotations: map[string]string{
+				"metallb.universe.tf/address-pool": "foo",
+			},
+		},
+		{
+			desc: "all the overrides",
+			annotations: map[string]string{
+				BGPCommunitiesAnnotation:   "1234:5678, large:123:456:789",
+				BGPLocalPrefAnnotation:     "300",
+				BGPASPathPrependAnnotation: "3",
+			},
+			want: &BGPServiceOverrides{
+				Communities:        []community.BGPCommunity{legacy, large},
+				LocalPref:          &localPref,
+				ASPathPrependCount: 3,
+			},
+		},
+		{
+			desc: "invalid community",
+			annotations: map[string]string{
+				BGPCommunitiesAnnotation: "1234",
+			},
+			mustFail: true,
+		},
+		{
+			desc: "duplicate community",
+			annotations: map[string]string{
+				BGPCommunitiesAnnotation: "1234:5678,1234:5678",
+			},
+			mustFail: true,
+		},
+		{
+			desc: "invalid local pref",
+			annotations: map[string]string{
+				BGPLocalPrefAnnotation: "-1",
+			},
+			mustFail: true,
+		},
+		{
+			desc: "as path prepend too long",
+			annotations: map[string]string{
+				BGPASPathPrependAnnotation: "11",
+			},
+			mustFail: true,
+		},
+		{
+			desc: "zero as path prepend",
+			annotations: map[string]string{
+				BGPASPathPrependAnnotation: "0",
+			},
+			mustFail: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+			}
+			got, err := BGPServiceOverridesFor(svc)
+			if test.mustFail {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(community.BGPCommunityLegacy{}, community.BGPCommunityLarge{})); diff != "" {
+				t.Fatalf("unexpected overrides (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
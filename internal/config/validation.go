@@ -4,8 +4,11 @@ package config
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 
 	"github.com/pkg/errors"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	"go.universe.tf/metallb/internal/bgp/community"
 	"go.universe.tf/metallb/internal/ipfamily"
@@ -33,13 +36,36 @@ func DiscardFRROnly(c ClusterResources) error {
 		if p.Spec.KeepaliveTime.Duration != 0 {
 			return fmt.Errorf("peer %s has keepalive-time set on native bgp mode", p.Spec.Address)
 		}
-		if p.Spec.VRFName != "" {
-			return fmt.Errorf("peer %s has vrf set on native bgp mode", p.Spec.Address)
+		if p.Spec.DynamicAddress != nil && p.Spec.DynamicAddress.Source == string(DynamicAddressInterface) {
+			return fmt.Errorf("peer %s has an unnumbered dynamic address set on native bgp mode", p.Name)
+		}
+		if p.Spec.ListenRange != "" {
+			return fmt.Errorf("peer %s has listen range set on native bgp mode", p.Name)
+		}
+		if p.Spec.RawFRRConfig != "" {
+			return fmt.Errorf("peer %s has raw frr config set on native bgp mode", p.Name)
 		}
 	}
 	if len(c.BFDProfiles) > 0 {
 		return errors.New("bfd profiles section set")
 	}
+	if len(c.FRRSnippets) > 0 {
+		return errors.New("frr snippets section set")
+	}
+	if len(c.OSPFAdvs) > 0 {
+		return errors.New("ospf advertisements section set")
+	}
+	if len(c.OSPFAreas) > 0 {
+		return errors.New("ospf areas section set")
+	}
+	for _, adv := range c.BGPAdvs {
+		if len(adv.Spec.LeakToVRFs) > 0 {
+			return fmt.Errorf("bgpadvertisement %s has leakToVRFs set on native bgp mode", adv.Name)
+		}
+		if adv.Spec.EVPN != nil {
+			return fmt.Errorf("bgpadvertisement %s has evpn set on native bgp mode", adv.Name)
+		}
+	}
 	// Only IPv4 BGP advertisements are supported in native mode.
 	if err := findIPv6BGPAdvertisement(c); err != nil {
 		return err
@@ -127,14 +153,22 @@ func DontValidate(c ClusterResources) error {
 // DiscardNativeOnly returns an error if the current configFile contains
 // any options that are available only in the native implementation.
 func DiscardNativeOnly(c ClusterResources) error {
+	for _, p := range c.Peers {
+		if p.Spec.Authentication != nil && AuthType(p.Spec.Authentication.Type) == AuthAO {
+			return fmt.Errorf("peer %s has %s authentication set on frr bgp mode", p.Name, AuthAO)
+		}
+		if p.Spec.Receive != nil && p.Spec.Receive.RoutingTable != 0 {
+			return fmt.Errorf("peer %s has receive routing table set on frr bgp mode", p.Name)
+		}
+	}
 	if len(c.Peers) > 1 {
 		peerAddr := make(map[string]bool)
-		routerID := c.Peers[0].Spec.RouterID
+		routerID := routerIDKey(c.Peers[0].Spec)
 		peer0 := peerAddressKey(c.Peers[0].Spec)
 		peerAddr[peer0] = true
 		for _, p := range c.Peers[1:] {
-			if p.Spec.RouterID != routerID {
-				return fmt.Errorf("peer %s has RouterID different from %s, in FRR mode all RouterID must be equal", p.Spec.RouterID, c.Peers[0].Spec.RouterID)
+			if routerIDKey(p.Spec) != routerID {
+				return fmt.Errorf("peer %s has RouterID different from %s, in FRR mode all RouterID must be equal", routerIDKey(p.Spec), routerID)
 			}
 			peerKey := peerAddressKey(p.Spec)
 			if _, ok := peerAddr[peerKey]; ok {
@@ -145,12 +179,45 @@ func DiscardNativeOnly(c ClusterResources) error {
 	}
 	for _, p := range c.Peers {
 		for _, p1 := range c.Peers[1:] {
-			if p.Spec.MyASN != p1.Spec.MyASN &&
+			if myASNKey(p.Spec) != myASNKey(p1.Spec) &&
 				p.Spec.VRFName == p1.Spec.VRFName {
 				return fmt.Errorf("peer %s has myAsn different from %s, in FRR mode all myAsn must be equal for the same VRF", p.Spec.Address, p1.Spec.Address)
 			}
 		}
 	}
+	return validateEVPN(c)
+}
+
+// validateEVPN checks that the advertisements originating EVPN routes agree
+// on the L3VNI of each VRF, and are announced only to peers of the default VRF.
+func validateEVPN(c ClusterResources) error {
+	peerVRFs := make(map[string]string, len(c.Peers))
+	for _, p := range c.Peers {
+		peerVRFs[p.Name] = p.Spec.VRFName
+	}
+	byVRF := make(map[string]metallbv1beta1.BGPAdvertisement)
+	byVNI := make(map[uint32]string)
+	for _, adv := range c.BGPAdvs {
+		evpn := adv.Spec.EVPN
+		if evpn == nil {
+			continue
+		}
+		for _, p := range adv.Spec.Peers {
+			if peerVRFs[p] != "" {
+				return fmt.Errorf("bgpadvertisement %s has evpn set and peer %s in vrf %s, evpn routes are announced only to peers of the default vrf", adv.Name, p, peerVRFs[p])
+			}
+		}
+		if other, ok := byVRF[evpn.VRF]; ok {
+			if other.Spec.EVPN.VNI != evpn.VNI || !reflect.DeepEqual(other.Spec.EVPN.RouteTargets, evpn.RouteTargets) {
+				return fmt.Errorf("bgpadvertisements %s and %s have different evpn settings for vrf %s", other.Name, adv.Name, evpn.VRF)
+			}
+		}
+		if vrf, ok := byVNI[evpn.VNI]; ok && vrf != evpn.VRF {
+			return fmt.Errorf("bgpadvertisement %s has evpn vni %d already used by vrf %s", adv.Name, evpn.VNI, vrf)
+		}
+		byVRF[evpn.VRF] = adv
+		byVNI[evpn.VNI] = evpn.VRF
+	}
 	return nil
 }
 
@@ -200,6 +267,31 @@ func hasBFDEcho(peer *Peer, bfdProfiles map[string]*BFDProfile) bool {
 	return false
 }
 
+// routerIDKey identifies the router ID of the given peer. Peers taking
+// it from the same label or annotation share the same router ID on a given node.
+func routerIDKey(peer metallbv1beta2.BGPPeerSpec) string {
+	if ref := peer.RouterIDFrom; ref != nil {
+		return fmt.Sprintf("%s/%s", ref.Source, ref.Key)
+	}
+	return peer.RouterID
+}
+
+// myASNKey identifies the local ASN of the given peer, like routerIDKey.
+func myASNKey(peer metallbv1beta2.BGPPeerSpec) string {
+	if ref := peer.MyASNFrom; ref != nil {
+		return fmt.Sprintf("%s/%s", ref.Source, ref.Key)
+	}
+	return strconv.FormatUint(uint64(peer.MyASN), 10)
+}
+
 func peerAddressKey(peer metallbv1beta2.BGPPeerSpec) string {
+	if d := peer.DynamicAddress; d != nil {
+		// Peers resolving their address from the same source end up
+		// with the same address on a given node.
+		return fmt.Sprintf("%s/%s/%s/%s-%s", d.Source, d.Key, d.Interface, d.IPFamily, peer.VRFName)
+	}
+	if peer.ListenRange != "" {
+		return fmt.Sprintf("%s-%s", peer.ListenRange, peer.VRFName)
+	}
 	return fmt.Sprintf("%s-%s", peer.Address, peer.VRFName)
 }
@@ -8,6 +8,7 @@ import (
 
 	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/api/v1beta2"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,6 +32,19 @@ func TestValidate(t *testing.T) {
 			},
 			mustFail: true,
 		},
+		{
+			desc: "peer with vrf",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							VRFName: "red",
+						},
+					},
+				},
+			},
+		},
 		{
 			desc: "bfd profile set",
 			config: ClusterResources{
@@ -129,6 +143,116 @@ func TestValidate(t *testing.T) {
 			},
 			mustFail: true,
 		},
+		{
+			desc: "unnumbered peer",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source:    "Interface",
+								Interface: "eth0",
+							},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "listen range",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							ListenRange: "10.0.0.0/24",
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "raw frr config",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:      "1.2.3.4",
+							RawFRRConfig: "neighbor 1.2.3.4 description foo",
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "frr snippets set",
+			config: ClusterResources{
+				FRRSnippets: []v1beta1.FRRSnippet{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "foo"},
+						Spec: v1beta1.FRRSnippetSpec{
+							Config: "ip prefix-list foo seq 5 permit any",
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "leak to vrfs set",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							LeakToVRFs: []string{"red"},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "evpn set",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "ospf advertisements set",
+			config: ClusterResources{
+				OSPFAdvs: []v1beta1.OSPFAdvertisement{
+					{
+						Spec: v1beta1.OSPFAdvertisementSpec{
+							MetricType: 1,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "ospf areas set",
+			config: ClusterResources{
+				OSPFAreas: []v1beta1.OSPFArea{
+					{
+						Spec: v1beta1.OSPFAreaSpec{
+							Area:       "0.0.0.0",
+							Interfaces: []string{"eth0"},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
 		{
 			desc: "should pass",
 			config: ClusterResources{
@@ -350,6 +474,85 @@ func TestValidateFRR(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "per node myAsn, all equals",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn",
+							},
+							RouterIDFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/router-id",
+							},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.5",
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn",
+							},
+							RouterIDFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/router-id",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "per node myAsn, one static",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							MyASNFrom: &v1beta2.NodeValueRef{
+								Source: "NodeLabel",
+								Key:    "example.com/asn",
+							},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.5",
+							MyASN:   123,
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "per node routerID, one static",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							RouterIDFrom: &v1beta2.NodeValueRef{
+								Source: "NodeAnnotation",
+								Key:    "example.com/router-id",
+							},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:  "1.2.3.5",
+							RouterID: "1.2.3.4",
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
 		{
 			desc: "duplicate bgp address",
 			config: ClusterResources{
@@ -413,6 +616,209 @@ func TestValidateFRR(t *testing.T) {
 				},
 			},
 			mustFail: true,
+		}, {
+			desc: "dynamic peers with different sources",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "DefaultGateway",
+							},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source: "NodeLabel",
+								Key:    "example.com/tor",
+							},
+						},
+					},
+				},
+			},
+		}, {
+			desc: "dynamic peers with the same source",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source:    "Interface",
+								Interface: "eth0",
+							},
+						},
+					},
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							DynamicAddress: &v1beta2.DynamicPeerAddress{
+								Source:    "Interface",
+								Interface: "eth0",
+							},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		}, {
+			desc: "tcp-ao authentication",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							Authentication: &v1beta2.BGPAuthentication{
+								Type: "AO",
+							},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		}, {
+			desc: "receive routing table",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode:         "all",
+								RoutingTable: 100,
+							},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		}, {
+			desc: "next password, ignored",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:        "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Data: map[string][]byte{"password": []byte("nopass"), "nextPassword": []byte("newpass")}},
+				},
+			},
+		}, {
+			desc: "next password same as the password",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address:        "1.2.3.4",
+							PasswordSecret: corev1.SecretReference{Name: "bgpsecret"},
+						},
+					},
+				},
+				PasswordSecrets: map[string]corev1.Secret{
+					"bgpsecret": {Data: map[string][]byte{"password": []byte("nopass"), "nextPassword": []byte("nopass")}},
+				},
+			},
+		}, {
+			desc: "receive filtered routes",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							Receive: &v1beta2.BGPReceive{
+								Mode:     "filtered",
+								Prefixes: []string{"10.0.0.0/8"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn advertisements sharing a vrf",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100, RouteTargets: []string{"64512:100"}},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100, RouteTargets: []string{"64512:100"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "evpn advertisements with different vnis for a vrf",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 200},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "evpn advertisements with the same vni for different vrfs",
+			config: ClusterResources{
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100},
+						},
+					},
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv2"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							EVPN: &v1beta1.EVPNAdvertisement{VRF: "blue", VNI: 100},
+						},
+					},
+				},
+			},
+			mustFail: true,
+		},
+		{
+			desc: "evpn advertisement to a peer in a vrf",
+			config: ClusterResources{
+				Peers: []v1beta2.BGPPeer{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "peer1"},
+						Spec: v1beta2.BGPPeerSpec{
+							Address: "1.2.3.4",
+							VRFName: "red",
+						},
+					},
+				},
+				BGPAdvs: []v1beta1.BGPAdvertisement{
+					{
+						ObjectMeta: v1.ObjectMeta{Name: "adv1"},
+						Spec: v1beta1.BGPAdvertisementSpec{
+							Peers: []string{"peer1"},
+							EVPN:  &v1beta1.EVPNAdvertisement{VRF: "red", VNI: 100},
+						},
+					},
+				},
+			},
+			mustFail: true,
 		},
 	}
 
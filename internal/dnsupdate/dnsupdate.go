@@ -0,0 +1,250 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package dnsupdate // import "go.universe.tf/metallb/internal/dnsupdate"
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultTTL     = 300
+	tsigFudge      = 300
+	updateTimeout  = 10 * time.Second
+	defaultDNSPort = "53"
+)
+
+// Algorithms are the TSIG algorithms supported.
+var Algorithms = []string{"hmac-sha1", "hmac-sha224", "hmac-sha256", "hmac-sha384", "hmac-sha512"}
+
+// Config tells how to publish the records.
+type Config struct {
+	// Server is the address of the DNS server the updates are sent to,
+	// with an optional port.
+	Server string
+	// Zone is the zone the A and AAAA records are published in.
+	Zone string
+	// PTRZones are the reverse zones the PTR records are published in.
+	// No PTR record is published for an address outside of them.
+	PTRZones []string
+	// TTL is the TTL of the records, in seconds.
+	TTL uint32
+	// TSIGKeyName, TSIGAlgorithm and TSIGSecret are the TSIG key the
+	// updates are signed with. The secret is base64 encoded.
+	TSIGKeyName   string
+	TSIGAlgorithm string
+	TSIGSecret    string
+}
+
+// Updater publishes address records with RFC 2136 dynamic updates.
+type Updater struct {
+	server   string
+	zone     string
+	ptrZones []string
+	ttl      uint32
+	keyName  string
+	alg      string
+	client   *dns.Client
+}
+
+// New returns an Updater sending the updates described by cfg.
+func New(cfg Config) (*Updater, error) {
+	if cfg.Server == "" {
+		return nil, fmt.Errorf("missing dns server")
+	}
+	server := cfg.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, defaultDNSPort)
+	}
+
+	if cfg.Zone == "" {
+		return nil, fmt.Errorf("missing dns zone")
+	}
+	if _, ok := dns.IsDomainName(cfg.Zone); !ok {
+		return nil, fmt.Errorf("invalid dns zone %q", cfg.Zone)
+	}
+	ptrZones := []string{}
+	for _, z := range cfg.PTRZones {
+		if _, ok := dns.IsDomainName(z); !ok {
+			return nil, fmt.Errorf("invalid ptr zone %q", z)
+		}
+		ptrZones = append(ptrZones, dns.CanonicalName(z))
+	}
+
+	ttl := cfg.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	u := &Updater{
+		server:   server,
+		zone:     dns.CanonicalName(cfg.Zone),
+		ptrZones: ptrZones,
+		ttl:      ttl,
+		client:   &dns.Client{Net: "udp", Timeout: updateTimeout},
+	}
+
+	if cfg.TSIGKeyName == "" {
+		if cfg.TSIGSecret != "" {
+			return nil, fmt.Errorf("missing tsig key name")
+		}
+		return u, nil
+	}
+	if cfg.TSIGSecret == "" {
+		return nil, fmt.Errorf("missing secret for tsig key %q", cfg.TSIGKeyName)
+	}
+	alg := strings.ToLower(strings.TrimSuffix(cfg.TSIGAlgorithm, "."))
+	if alg == "" {
+		alg = "hmac-sha256"
+	}
+	supported := false
+	for _, a := range Algorithms {
+		if a == alg {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported tsig algorithm %q, must be one of %s", cfg.TSIGAlgorithm, strings.Join(Algorithms, ", "))
+	}
+	u.keyName = dns.CanonicalName(cfg.TSIGKeyName)
+	u.alg = dns.Fqdn(alg)
+	u.client.TsigSecret = map[string]string{u.keyName: cfg.TSIGSecret}
+	return u, nil
+}
+
+// Publish adds the A and AAAA records of the addresses to the hostname,
+// and the PTR records pointing the addresses to the hostname.
+func (u *Updater) Publish(hostname string, ips []net.IP) error {
+	name, err := u.name(hostname)
+	if err != nil {
+		return err
+	}
+	records, ptrs, err := u.records(name, ips)
+	if err != nil {
+		return err
+	}
+
+	m := u.message(u.zone)
+	m.Insert(records)
+	if err := u.send(m); err != nil {
+		return err
+	}
+	for zone, rrs := range ptrs {
+		m := u.message(zone)
+		m.Insert(rrs)
+		if err := u.send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Withdraw removes the records Publish added for the hostname and the
+// addresses, leaving the other records of the hostname alone.
+func (u *Updater) Withdraw(hostname string, ips []net.IP) error {
+	name, err := u.name(hostname)
+	if err != nil {
+		return err
+	}
+	records, ptrs, err := u.records(name, ips)
+	if err != nil {
+		return err
+	}
+
+	m := u.message(u.zone)
+	m.Remove(records)
+	if err := u.send(m); err != nil {
+		return err
+	}
+	for zone, rrs := range ptrs {
+		m := u.message(zone)
+		m.Remove(rrs)
+		if err := u.send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name returns the canonical name of the hostname, which must be in the zone.
+func (u *Updater) name(hostname string) (string, error) {
+	if _, ok := dns.IsDomainName(hostname); !ok || hostname == "" {
+		return "", fmt.Errorf("invalid hostname %q", hostname)
+	}
+	name := dns.CanonicalName(hostname)
+	if !dns.IsSubDomain(u.zone, name) {
+		return "", fmt.Errorf("hostname %q is not in zone %q", hostname, u.zone)
+	}
+	return name, nil
+}
+
+// records returns the address records of the name, and its PTR records
+// by reverse zone.
+func (u *Updater) records(name string, ips []net.IP) ([]dns.RR, map[string][]dns.RR, error) {
+	records := []dns.RR{}
+	ptrs := map[string][]dns.RR{}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			records = append(records, &dns.A{
+				Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: u.ttl},
+				A:   ip4,
+			})
+		} else {
+			records = append(records, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: u.ttl},
+				AAAA: ip,
+			})
+		}
+
+		reverse, err := dns.ReverseAddr(ip.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		zone := u.ptrZone(reverse)
+		if zone == "" {
+			continue
+		}
+		ptrs[zone] = append(ptrs[zone], &dns.PTR{
+			Hdr: dns.RR_Header{Name: reverse, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: u.ttl},
+			Ptr: name,
+		})
+	}
+	return records, ptrs, nil
+}
+
+// ptrZone returns the most specific reverse zone the name belongs to,
+// or "" if none.
+func (u *Updater) ptrZone(reverse string) string {
+	res := ""
+	for _, z := range u.ptrZones {
+		if dns.IsSubDomain(z, reverse) && len(z) > len(res) {
+			res = z
+		}
+	}
+	return res
+}
+
+func (u *Updater) message(zone string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetUpdate(zone)
+	if u.keyName != "" {
+		m.SetTsig(u.keyName, u.alg, tsigFudge, time.Now().Unix())
+	}
+	return m
+}
+
+func (u *Updater) send(m *dns.Msg) error {
+	r, _, err := u.client.Exchange(m, u.server)
+	if err != nil {
+		return fmt.Errorf("failed to send update for zone %q to %s: %w", m.Question[0].Name, u.server, err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update for zone %q refused by %s: %s", m.Question[0].Name, u.server, dns.RcodeToString[r.Rcode])
+	}
+	return nil
+}
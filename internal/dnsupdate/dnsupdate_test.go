@@ -0,0 +1,220 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package dnsupdate
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/miekg/dns"
+)
+
+const (
+	testKeyName = "metallb."
+	testSecret  = "c2VjcmV0LWtleS1mb3ItdGVzdHM="
+)
+
+// standIn is a local DNS server applying the updates to its records.
+type standIn struct {
+	sync.Mutex
+	records map[string]dns.RR
+	zones   []string // zones of the updates
+}
+
+func (s *standIn) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	s.Lock()
+	defer s.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(m)
+		return
+	}
+	m.SetTsig(testKeyName, dns.HmacSHA256, 300, int64(req.IsTsig().TimeSigned))
+
+	s.zones = append(s.zones, req.Question[0].Name)
+	for _, rr := range req.Ns {
+		h := rr.Header()
+		switch h.Class {
+		case dns.ClassINET:
+			s.records[rr.String()] = rr
+		case dns.ClassNONE:
+			// The records to delete don't carry the TTL.
+			h.Class = dns.ClassINET
+			for k, r := range s.records {
+				if dns.IsDuplicate(r, rr) {
+					delete(s.records, k)
+				}
+			}
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func (s *standIn) published() []string {
+	s.Lock()
+	defer s.Unlock()
+	res := []string{}
+	for r := range s.records {
+		res = append(res, r)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (s *standIn) updatedZones() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.zones...)
+}
+
+func startStandIn(t *testing.T) (*standIn, string) {
+	t.Helper()
+	s := &standIn{records: map[string]dns.RR{}}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           s,
+		TsigSecret:        map[string]string{testKeyName: testSecret},
+		NotifyStartedFunc: func() { close(started) },
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			// The default one rejects the updates.
+			if int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+				return dns.MsgAccept
+			}
+			return dns.DefaultMsgAcceptFunc(dh)
+		},
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return s, pc.LocalAddr().String()
+}
+
+func TestUpdater(t *testing.T) {
+	s, addr := startStandIn(t)
+
+	u, err := New(Config{
+		Server:        addr,
+		Zone:          "example.com",
+		PTRZones:      []string{"168.192.in-addr.arpa", "2.168.192.in-addr.arpa."},
+		TTL:           60,
+		TSIGKeyName:   "metallb",
+		TSIGAlgorithm: "hmac-sha256",
+		TSIGSecret:    testSecret,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the updater: %s", err)
+	}
+
+	ips := []net.IP{net.ParseIP("192.168.2.10"), net.ParseIP("2001:db8::10")}
+	if err := u.Publish("Web.example.com", ips); err != nil {
+		t.Fatalf("publish failed: %s", err)
+	}
+	if err := u.Publish("db.example.com", []net.IP{net.ParseIP("192.168.3.10")}); err != nil {
+		t.Fatalf("publish failed: %s", err)
+	}
+	want := []string{
+		"10.2.168.192.in-addr.arpa.\t60\tIN\tPTR\tweb.example.com.",
+		"10.3.168.192.in-addr.arpa.\t60\tIN\tPTR\tdb.example.com.",
+		"db.example.com.\t60\tIN\tA\t192.168.3.10",
+		"web.example.com.\t60\tIN\tA\t192.168.2.10",
+		"web.example.com.\t60\tIN\tAAAA\t2001:db8::10",
+	}
+	if diff := cmp.Diff(want, s.published()); diff != "" {
+		t.Fatalf("unexpected records after publish (-want +got)\n%s", diff)
+	}
+	wantZones := []string{"example.com.", "2.168.192.in-addr.arpa.", "example.com.", "168.192.in-addr.arpa."}
+	if diff := cmp.Diff(wantZones, s.updatedZones()); diff != "" {
+		t.Fatalf("unexpected zones updated (-want +got)\n%s", diff)
+	}
+
+	if err := u.Withdraw("web.example.com", ips); err != nil {
+		t.Fatalf("withdraw failed: %s", err)
+	}
+	want = []string{
+		"10.3.168.192.in-addr.arpa.\t60\tIN\tPTR\tdb.example.com.",
+		"db.example.com.\t60\tIN\tA\t192.168.3.10",
+	}
+	if diff := cmp.Diff(want, s.published()); diff != "" {
+		t.Fatalf("unexpected records after withdraw (-want +got)\n%s", diff)
+	}
+
+	if err := u.Publish("web.example.org", ips); err == nil {
+		t.Fatalf("publishing a hostname outside of the zone should have failed")
+	}
+
+	// The updates not signed with the key are refused.
+	u, err = New(Config{
+		Server:        addr,
+		Zone:          "example.com",
+		TSIGKeyName:   "metallb",
+		TSIGAlgorithm: "hmac-sha256",
+		TSIGSecret:    "d3Jvbmctc2VjcmV0",
+	})
+	if err != nil {
+		t.Fatalf("failed to create the updater: %s", err)
+	}
+	if err := u.Publish("web.example.com", ips); err == nil {
+		t.Fatalf("publishing with the wrong key should have failed")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			desc: "no tsig",
+			cfg:  Config{Server: "192.168.1.1", Zone: "example.com"},
+		},
+		{
+			desc: "default algorithm",
+			cfg:  Config{Server: "192.168.1.1:5353", Zone: "example.com", TSIGKeyName: "metallb", TSIGSecret: testSecret},
+		},
+		{
+			desc:    "no server",
+			cfg:     Config{Zone: "example.com"},
+			wantErr: true,
+		},
+		{
+			desc:    "no zone",
+			cfg:     Config{Server: "192.168.1.1"},
+			wantErr: true,
+		},
+		{
+			desc:    "key without secret",
+			cfg:     Config{Server: "192.168.1.1", Zone: "example.com", TSIGKeyName: "metallb"},
+			wantErr: true,
+		},
+		{
+			desc:    "unsupported algorithm",
+			cfg:     Config{Server: "192.168.1.1", Zone: "example.com", TSIGKeyName: "metallb", TSIGAlgorithm: "hmac-md5", TSIGSecret: testSecret},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		_, err := New(test.cfg)
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected error, got none", test.desc)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error %s", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+const (
+	// LeasePath is the path of the HTTP provider leasing the addresses.
+	LeasePath = "/lease"
+	// ReleasePath is the path of the HTTP provider releasing the addresses.
+	ReleasePath = "/release"
+
+	// callTimeout bounds each call to the provider, as the allocations
+	// wait for it. The allocations failing are retried later.
+	callTimeout = 3 * time.Second
+)
+
+// LeaseRequest is the body posted to the lease path of an HTTP provider.
+type LeaseRequest struct {
+	Pool    string `json:"pool"`
+	Owner   string `json:"owner,omitempty"`
+	Family  string `json:"family"`
+	Address string `json:"address,omitempty"`
+}
+
+// LeaseResponse is the body an HTTP provider answers a lease with.
+type LeaseResponse struct {
+	Address string `json:"address"`
+}
+
+// ReleaseRequest is the body posted to the release path of an HTTP provider.
+type ReleaseRequest struct {
+	Pool    string `json:"pool"`
+	Address string `json:"address"`
+}
+
+type httpProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP returns a provider speaking the HTTP/JSON lease protocol with
+// the server at url. A lease posts a LeaseRequest to the lease path, and
+// expects a LeaseResponse. A release posts a ReleaseRequest to the
+// release path, releasing an address not leased is not an error. Any
+// status other than 200 is a failure, the body being its reason. The
+// server errors and the unanswered calls fail with ErrUnavailable.
+func NewHTTP(url string) Provider {
+	return &httpProvider{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{},
+	}
+}
+
+func (p *httpProvider) Lease(pool, owner string, family ipfamily.Family, ip net.IP) (net.IP, error) {
+	req := LeaseRequest{
+		Pool:   pool,
+		Owner:  owner,
+		Family: string(family),
+	}
+	if ip != nil {
+		req.Address = ip.String()
+	}
+	body, err := p.post(LeasePath, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease address from %s: %w", p.url, err)
+	}
+
+	res := LeaseResponse{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("invalid lease response from %s: %w", p.url, err)
+	}
+	leased := net.ParseIP(res.Address)
+	if leased == nil {
+		return nil, fmt.Errorf("invalid address %q leased by %s", res.Address, p.url)
+	}
+	if ipfamily.ForAddress(leased) != family {
+		return nil, fmt.Errorf("address %s leased by %s is not %s", leased, p.url, family)
+	}
+	if ip != nil && !ip.Equal(leased) {
+		err := fmt.Errorf("address %s leased by %s instead of %s", leased, p.url, ip)
+		if releaseErr := p.Release(pool, leased); releaseErr != nil {
+			return nil, fmt.Errorf("%w, %w", err, releaseErr)
+		}
+		return nil, err
+	}
+	return leased, nil
+}
+
+func (p *httpProvider) Release(pool string, ip net.IP) error {
+	_, err := p.post(ReleasePath, ReleaseRequest{
+		Pool:    pool,
+		Address: ip.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release address %s to %s: %w", ip, p.url, err)
+	}
+	return nil
+}
+
+func (p *httpProvider) post(path string, req interface{}) ([]byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if res.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: %s: %s", ErrUnavailable, res.Status, strings.TrimSpace(string(body)))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+// standIn is a local HTTP provider leasing the addresses of a fixed list.
+type standIn struct {
+	free   []string
+	leased map[string]string // address -> pool
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case LeasePath:
+		req := LeaseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		addr := req.Address
+		switch {
+		case addr != "" && s.leased[addr] == req.Pool:
+		case addr != "" && s.leased[addr] != "":
+			http.Error(w, "address already leased", http.StatusConflict)
+			return
+		case addr != "":
+			s.leased[addr] = req.Pool
+		case len(s.free) == 0:
+			http.Error(w, "no address left", http.StatusConflict)
+			return
+		default:
+			addr, s.free = s.free[0], s.free[1:]
+			s.leased[addr] = req.Pool
+		}
+		_ = json.NewEncoder(w).Encode(LeaseResponse{Address: addr})
+	case ReleasePath:
+		req := ReleaseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.leased[req.Address] == req.Pool {
+			delete(s.leased, req.Address)
+			s.free = append(s.free, req.Address)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	s := &standIn{
+		free:   []string{"1.2.3.4", "1.2.3.5"},
+		leased: map[string]string{},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	p := NewHTTP(server.URL + "/")
+
+	ip, err := p.Lease("pool1", "default/svc1", ipfamily.IPv4, nil)
+	if err != nil {
+		t.Fatalf("lease failed: %s", err)
+	}
+	if !ip.Equal(net.ParseIP("1.2.3.4")) {
+		t.Fatalf("leased %s, expected 1.2.3.4", ip)
+	}
+
+	// Leasing again an address of the pool restores it.
+	if _, err := p.Lease("pool1", "default/svc2", ipfamily.IPv4, ip); err != nil {
+		t.Fatalf("lease of an address leased for the pool failed: %s", err)
+	}
+	if _, err := p.Lease("pool2", "default/svc2", ipfamily.IPv4, ip); err == nil {
+		t.Fatalf("lease of an address leased for another pool succeeded")
+	}
+
+	// The family of the leased address must be the one requested.
+	if _, err := p.Lease("pool1", "default/svc2", ipfamily.IPv6, nil); err == nil {
+		t.Fatalf("lease of an ipv4 address for ipv6 succeeded")
+	}
+	if _, err := p.Lease("pool1", "default/svc2", ipfamily.IPv4, nil); err == nil {
+		t.Fatalf("lease succeeded with no address left")
+	}
+
+	if err := p.Release("pool1", ip); err != nil {
+		t.Fatalf("release failed: %s", err)
+	}
+	if s.leased[ip.String()] != "" {
+		t.Fatalf("%s still leased after release", ip)
+	}
+	// Releasing an address not leased is not an error.
+	if err := p.Release("pool1", net.ParseIP("1.2.3.6")); err != nil {
+		t.Fatalf("release of an address not leased failed: %s", err)
+	}
+}
+
+func TestHTTPProviderOtherAddress(t *testing.T) {
+	released := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LeasePath:
+			_ = json.NewEncoder(w).Encode(LeaseResponse{Address: "1.2.3.9"})
+		case ReleasePath:
+			req := ReleaseRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			released = append(released, req.Address)
+		}
+	}))
+	defer server.Close()
+
+	p := NewHTTP(server.URL)
+	if _, err := p.Lease("pool1", "default/svc1", ipfamily.IPv4, net.ParseIP("1.2.3.4")); err == nil {
+		t.Fatalf("lease of another address than the one requested succeeded")
+	}
+	if len(released) != 1 || released[0] != "1.2.3.9" {
+		t.Fatalf("expected the address leased instead to be released, got %v", released)
+	}
+}
+
+func TestHTTPProviderUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+	}))
+	p := NewHTTP(server.URL)
+	if _, err := p.Lease("pool1", "default/svc1", ipfamily.IPv4, nil); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the lease to fail with ErrUnavailable, got %v", err)
+	}
+
+	// Nobody answers anymore.
+	server.Close()
+	if err := p.Release("pool1", net.ParseIP("1.2.3.4")); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the release to fail with ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package ipam // import "go.universe.tf/metallb/internal/ipam"
+
+import (
+	"errors"
+	"net"
+
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+// ErrUnavailable wraps the failures to reach the IPAM system, that are
+// worth retrying.
+var ErrUnavailable = errors.New("ipam provider unavailable")
+
+// Provider leases addresses out of an external IPAM system.
+type Provider interface {
+	// Lease leases an address of the given family for the pool, on
+	// behalf of owner. When ip is not nil, that very address is leased,
+	// and any other address the IPAM system grants is released.
+	// Leasing an address already leased for the pool must succeed, so
+	// the leases can be restored after a restart.
+	Lease(pool, owner string, family ipfamily.Family, ip net.IP) (net.IP, error)
+	// Release gives an address leased for the pool back to the IPAM system.
+	Release(pool string, ip net.IP) error
+}
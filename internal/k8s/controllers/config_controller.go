@@ -101,6 +101,30 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	var frrSnippets metallbv1beta1.FRRSnippetList
+	if err := r.List(ctx, &frrSnippets, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get frr snippets", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var ospfAdvertisements metallbv1beta1.OSPFAdvertisementList
+	if err := r.List(ctx, &ospfAdvertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get ospf advertisements", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var ospfAreas metallbv1beta1.OSPFAreaList
+	if err := r.List(ctx, &ospfAreas, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get ospf areas", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	var kernelRouteAdvertisements metallbv1beta1.KernelRouteAdvertisementList
+	if err := r.List(ctx, &kernelRouteAdvertisements, client.InNamespace(r.Namespace)); err != nil {
+		level.Error(r.Logger).Log("controller", "ConfigReconciler", "message", "failed to get kernel route advertisements", "error", err)
+		return ctrl.Result{}, err
+	}
+
 	secrets, err := r.getSecrets(ctx)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -137,6 +161,10 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 		Nodes:              nodes.Items,
 		Namespaces:         namespaces.Items,
 		BGPExtras:          extrasMap,
+		FRRSnippets:        frrSnippets.Items,
+		OSPFAdvs:           ospfAdvertisements.Items,
+		OSPFAreas:          ospfAreas.Items,
+		KernelRouteAdvs:    kernelRouteAdvertisements.Items,
 	}
 
 	level.Debug(r.Logger).Log("controller", "ConfigReconciler", "metallb CRs and Secrets", dumpClusterResources(&resources))
@@ -151,6 +179,9 @@ var requestHandler = func(r *ConfigReconciler, ctx context.Context, req ctrl.Req
 	if cfg.BGPExtras != "" {
 		level.Info(r.Logger).Log("controller", "ConfigReconciler", "warning message", "BGP Extras provided, please note that this configuration is not supported and used at your own risk")
 	}
+	if len(cfg.FRRSnippets) > 0 {
+		level.Info(r.Logger).Log("controller", "ConfigReconciler", "warning message", "FRR snippets provided, please note that this configuration is not supported and used at your own risk")
+	}
 	level.Debug(r.Logger).Log("controller", "ConfigReconciler", "rendered config", dumpConfig(cfg))
 	if r.currentConfig != nil && reflect.DeepEqual(r.currentConfig, cfg) {
 		level.Debug(r.Logger).Log("controller", "ConfigReconciler", "event", "configuration did not change, ignoring")
@@ -201,6 +232,10 @@ func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(&metallbv1beta1.BFDProfile{}, &handler.EnqueueRequestForObject{}).
 		Watches(&metallbv1beta1.AddressPool{}, &handler.EnqueueRequestForObject{}).
 		Watches(&metallbv1beta1.Community{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.FRRSnippet{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.OSPFAdvertisement{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.OSPFArea{}, &handler.EnqueueRequestForObject{}).
+		Watches(&metallbv1beta1.KernelRouteAdvertisement{}, &handler.EnqueueRequestForObject{}).
 		Watches(&corev1.Secret{}, &handler.EnqueueRequestForObject{}).
 		Watches(&corev1.Namespace{}, &handler.EnqueueRequestForObject{}).
 		Watches(&corev1.ConfigMap{}, &handler.EnqueueRequestForObject{}).
@@ -21,6 +21,10 @@ func toConfig(fromK8s config.ClusterResources, validate config.Validate) (*confi
 		Nodes:              sortedCopy(fromK8s.Nodes),
 		Namespaces:         sortedCopy(fromK8s.Namespaces),
 		BGPExtras:          fromK8s.BGPExtras,
+		FRRSnippets:        sortedCopy(fromK8s.FRRSnippets),
+		OSPFAdvs:           sortedCopy(fromK8s.OSPFAdvs),
+		OSPFAreas:          sortedCopy(fromK8s.OSPFAreas),
+		KernelRouteAdvs:    sortedCopy(fromK8s.KernelRouteAdvs),
 	}
 
 	cfg, err := config.For(resources, validate)
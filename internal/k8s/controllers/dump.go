@@ -21,6 +21,10 @@ func dumpClusterResources(c *config.ClusterResources) string {
 		LegacyAddressPools: c.LegacyAddressPools,
 		Communities:        c.Communities,
 		BGPExtras:          c.BGPExtras,
+		FRRSnippets:        c.FRRSnippets,
+		OSPFAdvs:           c.OSPFAdvs,
+		OSPFAreas:          c.OSPFAreas,
+		KernelRouteAdvs:    c.KernelRouteAdvs,
 	}
 	withNoSecret.PasswordSecrets = make(map[string]corev1.Secret)
 	for k, s := range c.PasswordSecrets {
@@ -23,13 +23,20 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 
+	metallbv1beta2 "go.universe.tf/metallb/api/v1beta2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 type NodeReconciler struct {
@@ -40,6 +47,9 @@ type NodeReconciler struct {
 	Namespace   string
 	Handler     func(log.Logger, *corev1.Node) SyncState
 	ForceReload func()
+	// Resync processes the node again when it receives an event, if
+	// set.
+	Resync chan event.GenericEvent
 }
 
 func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -82,16 +92,66 @@ func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				level.Error(r.Logger).Log("controller", "NodeReconciler", "error", "old object is not node", "name", oldNodeObj.GetName())
 				return true
 			}
-			// If there is no changes in node labels, ignore event.
+			// If there is no changes in node labels or in the annotations
+			// referenced by the BGPPeers, ignore event.
 			if labels.Equals(labels.Set(oldNodeObj.Labels), labels.Set(newNodeObj.Labels)) &&
+				!r.referencedAnnotationsChanged(oldNodeObj, newNodeObj) &&
 				reflect.DeepEqual(oldNodeObj.Status.Conditions, newNodeObj.Status.Conditions) {
 				return false
 			}
 			return true
 		},
 	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Node{}).
-		WithEventFilter(p).
-		Complete(r)
+	// The annotations ignored so far may be referenced by the new peers,
+	// and the node is processed again on resyncs.
+	enqueueNode := handler.EnqueueRequestsFromMapFunc(func(_ context.Context, _ client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: r.NodeName}}}
+	})
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}, builder.WithPredicates(p)).
+		Watches(&metallbv1beta2.BGPPeer{}, enqueueNode)
+	if r.Resync != nil {
+		b = b.WatchesRawSource(&source.Channel{Source: r.Resync}, enqueueNode)
+	}
+	return b.Complete(r)
+}
+
+// referencedAnnotationsChanged tells if the annotations of the node the
+// BGPPeers take values from changed.
+func (r *NodeReconciler) referencedAnnotationsChanged(oldNode, newNode *corev1.Node) bool {
+	if labels.Equals(labels.Set(oldNode.Annotations), labels.Set(newNode.Annotations)) {
+		return false
+	}
+	keys, err := r.referencedAnnotations(context.Background())
+	if err != nil {
+		level.Error(r.Logger).Log("controller", "NodeReconciler", "error", err, "msg", "failed to list the annotations referenced by the peers")
+		return true
+	}
+	for k := range keys {
+		if oldNode.Annotations[k] != newNode.Annotations[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedAnnotations returns the annotations of the nodes the BGPPeers
+// take values from.
+func (r *NodeReconciler) referencedAnnotations(ctx context.Context) (sets.Set[string], error) {
+	var peers metallbv1beta2.BGPPeerList
+	if err := r.List(ctx, &peers, client.InNamespace(r.Namespace)); err != nil {
+		return nil, err
+	}
+	res := sets.New[string]()
+	for _, p := range peers.Items {
+		if a := p.Spec.DynamicAddress; a != nil && a.Source == "NodeAnnotation" {
+			res.Insert(a.Key)
+		}
+		for _, ref := range []*metallbv1beta2.NodeValueRef{p.Spec.MyASNFrom, p.Spec.RouterIDFrom} {
+			if ref != nil && ref.Source == "NodeAnnotation" {
+				res.Insert(ref.Key)
+			}
+		}
+	}
+	return res, nil
 }
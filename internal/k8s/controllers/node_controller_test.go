@@ -131,6 +131,81 @@ func TestNodeController(t *testing.T) {
 	}
 }
 
+func TestNodeReferencedAnnotations(t *testing.T) {
+	peer := &v1beta2.BGPPeer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "peer",
+			Namespace: testNamespace,
+		},
+		Spec: v1beta2.BGPPeerSpec{
+			DynamicAddress: &v1beta2.DynamicPeerAddress{
+				Source: "NodeAnnotation",
+				Key:    "example.com/tor",
+			},
+			RouterIDFrom: &v1beta2.NodeValueRef{
+				Source: "NodeLabel",
+				Key:    "example.com/router-id",
+			},
+		},
+	}
+	fakeClient, err := newFakeClient([]client.Object{peer})
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+	r := &NodeReconciler{
+		Client:    fakeClient,
+		Logger:    log.NewNopLogger(),
+		Scheme:    scheme,
+		Namespace: testNamespace,
+	}
+
+	node := func(annotations map[string]string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "testNode",
+				Annotations: annotations,
+			},
+		}
+	}
+	tests := []struct {
+		desc     string
+		old, new map[string]string
+		want     bool
+	}{
+		{
+			desc: "no change",
+			old:  map[string]string{"example.com/tor": "1.1.1.1"},
+			new:  map[string]string{"example.com/tor": "1.1.1.1"},
+		},
+		{
+			desc: "referenced annotation changed",
+			old:  map[string]string{"example.com/tor": "1.1.1.1"},
+			new:  map[string]string{"example.com/tor": "1.1.1.2"},
+			want: true,
+		},
+		{
+			desc: "referenced annotation added",
+			new:  map[string]string{"example.com/tor": "1.1.1.1"},
+			want: true,
+		},
+		{
+			desc: "other annotation changed",
+			old:  map[string]string{"example.com/tor": "1.1.1.1", "other": "a"},
+			new:  map[string]string{"example.com/tor": "1.1.1.1", "other": "b"},
+		},
+		{
+			desc: "annotation named as a referenced label changed",
+			old:  map[string]string{"example.com/router-id": "1.1.1.1"},
+			new:  map[string]string{"example.com/router-id": "1.1.1.2"},
+		},
+	}
+	for _, test := range tests {
+		if got := r.referencedAnnotationsChanged(node(test.old), node(test.new)); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
 func TestNodeReconciler_SetupWithManager(t *testing.T) {
 	g := NewGomegaWithT(t)
 	testEnv := &envtest.Environment{
@@ -22,6 +22,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
@@ -47,6 +48,12 @@ type ServiceReconciler struct {
 	Endpoints         NeedEndPoints
 	LoadBalancerClass string
 	Reload            chan event.GenericEvent
+	// ClaimHandler handles the IPAddressClaims along with the services,
+	// as they draw from the same pools. Claims are ignored when not set.
+	ClaimHandler func(log.Logger, string, *metallbv1beta1.IPAddressClaim) SyncState
+	// SyncedHandler, when set, is called each time all the services
+	// were processed successfully.
+	SyncedHandler func(log.Logger)
 	// initialLoadPerformed is set after the first time we call reprocessAll.
 	// This is required because we want the first time we load the services to follow the assigned first, non assigned later order.
 	// This allows avoiding to have services with already assigned IP to get their IP stolen by other services.
@@ -54,10 +61,13 @@ type ServiceReconciler struct {
 }
 
 func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	if !isReloadReq(req) {
-		return r.reconcileService(ctx, req)
+	if isReloadReq(req) {
+		return r.reprocessAll(ctx, req)
 	}
-	return r.reprocessAll(ctx, req)
+	if isClaimReq(req) {
+		return r.reconcileClaim(ctx, req)
+	}
+	return r.reconcileService(ctx, req)
 }
 
 func (r *ServiceReconciler) reconcileService(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -113,9 +123,16 @@ func (r *ServiceReconciler) reconcileService(ctx context.Context, req ctrl.Reque
 }
 
 func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Service{})
+	if r.ClaimHandler != nil {
+		builder = builder.Watches(&metallbv1beta1.IPAddressClaim{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return []reconcile.Request{claimRequest(obj)}
+			}))
+	}
 	if r.Endpoints == EndpointSlices {
-		return ctrl.NewControllerManagedBy(mgr).
-			For(&v1.Service{}).
+		return builder.
 			Watches(&discovery.EndpointSlice{},
 				handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
 					epSlice, ok := obj.(*discovery.EndpointSlice)
@@ -135,8 +152,7 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			Complete(r)
 	}
 	if r.Endpoints == Endpoints {
-		return ctrl.NewControllerManagedBy(mgr).
-			For(&v1.Service{}).
+		return builder.
 			Watches(&v1.Endpoints{},
 				handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
 					endpoints, ok := obj.(*v1.Endpoints)
@@ -152,8 +168,7 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			Complete(r)
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1.Service{}).
+	return builder.
 		WatchesRawSource(&source.Channel{Source: r.Reload}, &handler.EnqueueRequestForObject{}).
 		Complete(r)
 }
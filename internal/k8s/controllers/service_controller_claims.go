@@ -0,0 +1,116 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The requests of the claims are told apart from the ones of the services
+// by the prefix of their name, which is not valid in a service name.
+const claimRequestPrefix = "ipaddressclaim:"
+
+func claimRequest(obj client.Object) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: obj.GetNamespace(),
+		Name:      claimRequestPrefix + obj.GetName(),
+	}}
+}
+
+func isClaimReq(req ctrl.Request) bool {
+	return strings.HasPrefix(req.Name, claimRequestPrefix)
+}
+
+// claimKey is the name the claim is handled with, which can't clash with
+// the "namespace/name" of the services.
+func claimKey(name types.NamespacedName) string {
+	return "IPAddressClaim/" + name.String()
+}
+
+func (r *ServiceReconciler) reconcileClaim(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	name := types.NamespacedName{
+		Namespace: req.Namespace,
+		Name:      strings.TrimPrefix(req.Name, claimRequestPrefix),
+	}
+	level.Info(r.Logger).Log("controller", "ServiceReconciler", "start reconcile", claimKey(name))
+	defer level.Info(r.Logger).Log("controller", "ServiceReconciler", "end reconcile", claimKey(name))
+	updates.Inc()
+
+	if !r.initialLoadPerformed {
+		level.Debug(r.Logger).Log("controller", "ServiceReconciler", "message", "filtered claim, still waiting for the initial load to be performed")
+		return ctrl.Result{}, nil
+	}
+
+	var claim *metallbv1beta1.IPAddressClaim
+	var res metallbv1beta1.IPAddressClaim
+	err := r.Get(ctx, name, &res)
+	switch {
+	case apierrors.IsNotFound(err): // deleted, the handler gets nil
+	case err != nil:
+		level.Error(r.Logger).Log("controller", "ServiceReconciler", "message", "failed to get claim", "claim", name, "error", err)
+		return ctrl.Result{}, err
+	default:
+		claim = &res
+	}
+
+	switch r.ClaimHandler(r.Logger, claimKey(name), claim) {
+	case SyncStateError:
+		updateErrors.Inc()
+		level.Info(r.Logger).Log("controller", "ServiceReconciler", "name", claimKey(name), "claim", dumpResource(claim), "event", "failed to handle claim")
+		return ctrl.Result{}, errRetry
+	case SyncStateReprocessAll:
+		level.Info(r.Logger).Log("controller", "ServiceReconciler", "event", "force service reload")
+		r.forceReload()
+		return ctrl.Result{}, nil
+	case SyncStateErrorNoRetry:
+		updateErrors.Inc()
+		level.Error(r.Logger).Log("controller", "ServiceReconciler", "name", claimKey(name), "claim", dumpResource(claim), "event", "failed to handle claim")
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *ServiceReconciler) listClaims(ctx context.Context) ([]metallbv1beta1.IPAddressClaim, error) {
+	if r.ClaimHandler == nil {
+		return nil, nil
+	}
+	var claims metallbv1beta1.IPAddressClaimList
+	if err := r.List(ctx, &claims); err != nil {
+		return nil, err
+	}
+	return claims.Items, nil
+}
+
+// reprocessClaims handles the claims having addresses already, or the
+// ones without, and tells if any of them must be retried.
+func (r *ServiceReconciler) reprocessClaims(claims []metallbv1beta1.IPAddressClaim, assigned bool) bool {
+	retry := false
+	for _, claim := range claims {
+		claim := claim // so we can use &claim
+		if (len(claim.Status.Addresses) > 0) != assigned {
+			continue
+		}
+		name := claimKey(types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name})
+		level.Debug(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "reprocessing claim", dumpResource(claim))
+
+		switch r.ClaimHandler(r.Logger, name, &claim) {
+		case SyncStateError:
+			level.Error(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "name", name, "claim", dumpResource(claim), "event", "failed to handle claim, retry")
+			retry = true
+		case SyncStateReprocessAll:
+			retry = true
+		case SyncStateErrorNoRetry:
+			level.Error(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "name", name, "claim", dumpResource(claim), "event", "failed to handle claim, no retry")
+		}
+	}
+	return retry
+}
@@ -68,13 +68,19 @@ func (r *ServiceReconciler) reprocessAll(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	// Make it process the already assigned services first
+	claims, err := r.listClaims(ctx)
+	if err != nil {
+		level.Error(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "message", "failed to list the claims", "error", err)
+		return ctrl.Result{}, err
+	}
+
+	// Make it process the already assigned claims and services first
 	sortedServices := services.Items
 	sort.Slice(sortedServices, func(i, j int) bool {
 		return len(sortedServices[i].Status.LoadBalancer.Ingress) > len(sortedServices[j].Status.LoadBalancer.Ingress)
 	})
 
-	retry := false
+	retry := r.reprocessClaims(claims, true)
 	for _, service := range sortedServices {
 		service := service // so we can use &service
 		if filterByLoadBalancerClass(&service, r.LoadBalancerClass) {
@@ -102,6 +108,9 @@ func (r *ServiceReconciler) reprocessAll(ctx context.Context, req ctrl.Request)
 			level.Error(r.Logger).Log("controller", "ServiceReconciler - reprocessAll", "name", serviceName, "service", dumpResource(service), "endpoints", dumpResource(eps), "event", "failed to handle service, no retry")
 		}
 	}
+	if r.reprocessClaims(claims, false) {
+		retry = true
+	}
 	if retry {
 		// in case we want to retry, we return an error to trigger the exponential backoff mechanism so that
 		// this controller won't loop at full speed
@@ -109,6 +118,9 @@ func (r *ServiceReconciler) reprocessAll(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, errRetry
 	}
 	r.initialLoadPerformed = true
+	if r.SyncedHandler != nil {
+		r.SyncedHandler(r.Logger)
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -24,6 +24,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/pointer"
 	corev1 "k8s.io/api/core/v1"
@@ -345,3 +346,87 @@ func TestLBClass(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceControllerClaims(t *testing.T) {
+	testService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "service",
+			Namespace: testNamespace,
+		},
+	}
+	assignedClaim := &v1beta1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "assigned",
+			Namespace: testNamespace,
+		},
+		Status: v1beta1.IPAddressClaimStatus{
+			Addresses: []string{"1.2.3.4"},
+		},
+	}
+	pendingClaim := &v1beta1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pending",
+			Namespace: testNamespace,
+		},
+	}
+	fakeClient, err := newFakeClient([]client.Object{testService, assignedClaim, pendingClaim})
+	if err != nil {
+		t.Fatalf("failed to create fake client: %v", err)
+	}
+
+	var handled []string
+	r := &ServiceReconciler{
+		Client:    fakeClient,
+		Logger:    log.NewNopLogger(),
+		Scheme:    scheme,
+		Namespace: testNamespace,
+		Handler: func(l log.Logger, serviceName string, s *corev1.Service, e epslices.EpsOrSlices) SyncState {
+			handled = append(handled, serviceName)
+			return SyncStateSuccess
+		},
+		ClaimHandler: func(l log.Logger, claimName string, c *v1beta1.IPAddressClaim) SyncState {
+			if c == nil {
+				handled = append(handled, claimName+" deleted")
+				return SyncStateSuccess
+			}
+			handled = append(handled, claimName)
+			return SyncStateSuccess
+		},
+		Endpoints: NoNeed,
+		Reload:    make(chan event.GenericEvent, 1),
+	}
+
+	// The claims having addresses are handled first along with the
+	// services, the others last.
+	_, err = r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: "metallbreload",
+			Name:      "reload",
+		},
+	})
+	if err != nil {
+		t.Fatalf("reprocessAll failed: %v", err)
+	}
+	want := []string{
+		"IPAddressClaim/" + testNamespace + "/assigned",
+		testNamespace + "/service",
+		"IPAddressClaim/" + testNamespace + "/pending",
+	}
+	if diff := cmp.Diff(want, handled); diff != "" {
+		t.Errorf("unexpected handler calls (-want +got)\n%s", diff)
+	}
+
+	handled = nil
+	for _, obj := range []client.Object{pendingClaim, &v1beta1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: testNamespace}}} {
+		if _, err := r.Reconcile(context.Background(), claimRequest(obj)); err != nil {
+			t.Fatalf("reconcile of claim %s failed: %v", obj.GetName(), err)
+		}
+	}
+	want = []string{
+		"IPAddressClaim/" + testNamespace + "/pending",
+		"IPAddressClaim/" + testNamespace + "/missing deleted",
+	}
+	if diff := cmp.Diff(want, handled); diff != "" {
+		t.Errorf("unexpected handler calls (-want +got)\n%s", diff)
+	}
+}
@@ -93,6 +93,9 @@ type Client struct {
 	mgr            manager.Manager
 	validateConfig config.Validate
 	ForceSync      func()
+	// ResyncNode processes the node of the process again, for the state
+	// taken from outside of the cluster to be refreshed.
+	ResyncNode func()
 }
 
 // Config specifies the configuration of the Kubernetes
@@ -115,6 +118,9 @@ type Config struct {
 	CertServiceName     string
 	LoadBalancerClass   string
 	WebhookWithHTTP2    bool
+	// DebugHandlers are served along with the metrics, by path, when
+	// pprof is enabled.
+	DebugHandlers map[string]http.Handler
 	Listener
 }
 
@@ -132,16 +138,20 @@ func New(cfg *Config) (*Client, error) {
 		LeaderElection: false,
 		Cache: cache.Options{
 			ByObject: map[client.Object]cache.ByObject{
-				&metallbv1beta1.AddressPool{}:      namespaceSelector,
-				&metallbv1beta1.BFDProfile{}:       namespaceSelector,
-				&metallbv1beta1.BGPAdvertisement{}: namespaceSelector,
-				&metallbv1beta1.BGPPeer{}:          namespaceSelector,
-				&metallbv1beta1.IPAddressPool{}:    namespaceSelector,
-				&metallbv1beta1.L2Advertisement{}:  namespaceSelector,
-				&metallbv1beta2.BGPPeer{}:          namespaceSelector,
-				&metallbv1beta1.Community{}:        namespaceSelector,
-				&corev1.Secret{}:                   namespaceSelector,
-				&corev1.ConfigMap{}:                namespaceSelector,
+				&metallbv1beta1.AddressPool{}:              namespaceSelector,
+				&metallbv1beta1.BFDProfile{}:               namespaceSelector,
+				&metallbv1beta1.BGPAdvertisement{}:         namespaceSelector,
+				&metallbv1beta1.BGPPeer{}:                  namespaceSelector,
+				&metallbv1beta1.IPAddressPool{}:            namespaceSelector,
+				&metallbv1beta1.L2Advertisement{}:          namespaceSelector,
+				&metallbv1beta2.BGPPeer{}:                  namespaceSelector,
+				&metallbv1beta1.Community{}:                namespaceSelector,
+				&metallbv1beta1.FRRSnippet{}:               namespaceSelector,
+				&metallbv1beta1.OSPFAdvertisement{}:        namespaceSelector,
+				&metallbv1beta1.OSPFArea{}:                 namespaceSelector,
+				&metallbv1beta1.KernelRouteAdvertisement{}: namespaceSelector,
+				&corev1.Secret{}:                           namespaceSelector,
+				&corev1.ConfigMap{}:                        namespaceSelector,
 			},
 		},
 		WebhookServer: webhookServer(9443, cfg.WebhookWithHTTP2),
@@ -165,6 +175,7 @@ func New(cfg *Config) (*Client, error) {
 		reloadChan <- controllers.NewReloadEvent()
 	}
 
+	resyncNodeChan := make(chan event.GenericEvent)
 	c := &Client{
 		logger:         cfg.Logger,
 		client:         clientset,
@@ -172,6 +183,9 @@ func New(cfg *Config) (*Client, error) {
 		mgr:            mgr,
 		validateConfig: cfg.ValidateConfig,
 		ForceSync:      reload,
+		ResyncNode: func() {
+			resyncNodeChan <- controllers.NewReloadEvent()
+		},
 	}
 
 	if cfg.ConfigChanged != nil {
@@ -211,7 +225,9 @@ func New(cfg *Config) (*Client, error) {
 			Scheme:      mgr.GetScheme(),
 			Handler:     cfg.NodeHandler,
 			NodeName:    cfg.NodeName,
+			Namespace:   cfg.Namespace,
 			ForceReload: reload,
+			Resync:      resyncNodeChan,
 		}).SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "node")
 			return nil, errors.Wrap(err, "failed to create node reconciler")
@@ -254,7 +270,7 @@ func New(cfg *Config) (*Client, error) {
 	}
 
 	if cfg.ServiceChanged != nil {
-		if err = (&controllers.ServiceReconciler{
+		r := &controllers.ServiceReconciler{
 			Client:            mgr.GetClient(),
 			Logger:            cfg.Logger,
 			Scheme:            mgr.GetScheme(),
@@ -262,7 +278,14 @@ func New(cfg *Config) (*Client, error) {
 			Endpoints:         needEndpoints,
 			Reload:            reloadChan,
 			LoadBalancerClass: cfg.LoadBalancerClass,
-		}).SetupWithManager(mgr); err != nil {
+		}
+		if cfg.ClaimChanged != nil {
+			r.ClaimHandler = cfg.ClaimHandler
+		}
+		if cfg.ServicesSynced != nil {
+			r.SyncedHandler = cfg.SyncedHandler
+		}
+		if err = r.SetupWithManager(mgr); err != nil {
 			level.Error(c.logger).Log("error", err, "unable to create controller", "service")
 			return nil, errors.Wrap(err, "failed to create service reconciler")
 		}
@@ -289,6 +312,9 @@ func New(cfg *Config) (*Client, error) {
 			mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 			mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 			mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+			for path, h := range cfg.DebugHandlers {
+				mux.Handle(path, h)
+			}
 		}
 
 		server := &http.Server{
@@ -416,6 +442,32 @@ func (c *Client) Errorf(svc *corev1.Service, kind, msg string, args ...interface
 	c.events.Eventf(svc, corev1.EventTypeWarning, kind, msg, args...)
 }
 
+// UpdateClaimStatus writes the status of the claim back into the
+// Kubernetes cluster.
+func (c *Client) UpdateClaimStatus(claim *metallbv1beta1.IPAddressClaim) error {
+	return c.mgr.GetClient().Status().Update(context.TODO(), claim)
+}
+
+// ClaimInfof logs an informational event about claim to the Kubernetes cluster.
+func (c *Client) ClaimInfof(claim *metallbv1beta1.IPAddressClaim, kind, msg string, args ...interface{}) {
+	c.events.Eventf(claim, corev1.EventTypeNormal, kind, msg, args...)
+}
+
+// ClaimErrorf logs an error event about claim to the Kubernetes cluster.
+func (c *Client) ClaimErrorf(claim *metallbv1beta1.IPAddressClaim, kind, msg string, args ...interface{}) {
+	c.events.Eventf(claim, corev1.EventTypeWarning, kind, msg, args...)
+}
+
+// NodeInfof logs an informational event about the given node to the Kubernetes cluster.
+func (c *Client) NodeInfof(node string, kind, msg string, args ...interface{}) {
+	c.events.Eventf(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: node}}, corev1.EventTypeNormal, kind, msg, args...)
+}
+
+// NodeErrorf logs an error event about the given node to the Kubernetes cluster.
+func (c *Client) NodeErrorf(node string, kind, msg string, args ...interface{}) {
+	c.events.Eventf(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: node}}, corev1.EventTypeWarning, kind, msg, args...)
+}
+
 // UseEndpointSlices detect if Endpoints Slices are enabled in the cluster.
 func UseEndpointSlices(kubeClient kubernetes.Interface) bool {
 	if _, err := kubeClient.Discovery().ServerResourcesForGroupVersion(discovery.SchemeGroupVersion.String()); err != nil {
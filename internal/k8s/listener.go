@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/go-kit/log"
+	metallbv1beta1 "go.universe.tf/metallb/api/v1beta1"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
@@ -18,6 +19,8 @@ type Listener struct {
 	ConfigChanged  func(log.Logger, *config.Config) controllers.SyncState
 	PoolChanged    func(log.Logger, *config.Pools) controllers.SyncState
 	NodeChanged    func(log.Logger, *v1.Node) controllers.SyncState
+	ClaimChanged   func(log.Logger, string, *metallbv1beta1.IPAddressClaim) controllers.SyncState
+	ServicesSynced func(log.Logger)
 }
 
 func (l *Listener) ServiceHandler(logger log.Logger, serviceName string, svc *v1.Service, endpointsOrSlices epslices.EpsOrSlices) controllers.SyncState {
@@ -43,3 +46,15 @@ func (l *Listener) PoolHandler(logger log.Logger, pools *config.Pools) controlle
 	defer l.Unlock()
 	return l.PoolChanged(logger, pools)
 }
+
+func (l *Listener) SyncedHandler(logger log.Logger) {
+	l.Lock()
+	defer l.Unlock()
+	l.ServicesSynced(logger)
+}
+
+func (l *Listener) ClaimHandler(logger log.Logger, claimName string, claim *metallbv1beta1.IPAddressClaim) controllers.SyncState {
+	l.Lock()
+	defer l.Unlock()
+	return l.ClaimChanged(logger, claimName, claim)
+}
@@ -64,6 +64,7 @@ func enableWebhook(mgr manager.Manager, validate config.Validate, namespace stri
 	metallbv1beta2.WebhookClient = mgr.GetAPIReader()
 	metallbv1beta1.Validator = config.NewValidator(validate)
 	metallbv1beta2.Validator = config.NewValidator(validate)
+	metallbv1beta1.ServiceAnnotationsValidator = config.ValidateServiceAnnotations
 
 	if err := (&metallbv1beta1.AddressPool{}).SetupWebhookWithManager(mgr); err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "unable to create webhook", "webhook", "AddressPool")
@@ -100,5 +101,10 @@ func enableWebhook(mgr manager.Manager, validate config.Validate, namespace stri
 		return err
 	}
 
+	if err := (&metallbv1beta1.ServiceValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		level.Error(logger).Log("op", "startup", "error", err, "msg", "unable to create webhook", "webhook", "Service")
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,394 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package netroute
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"go.universe.tf/metallb/internal/byteorder"
+	"golang.org/x/sys/unix"
+)
+
+// Route is a route of a kernel routing table.
+type Route struct {
+	Dst     *net.IPNet
+	Gateway net.IP
+	// LinkIndex is the index of the interface the route goes through.
+	// A route with neither a gateway nor an interface is a blackhole
+	// route, dropping the traffic.
+	LinkIndex int
+	Table     uint32
+	// Protocol tells who installed the route, as one of the RTPROT_
+	// values of include/uapi/linux/rtnetlink.h.
+	Protocol uint8
+	// Priority is the metric of the route.
+	Priority uint32
+}
+
+// HasNetAdmin tells if the process has the CAP_NET_ADMIN capability,
+// required to change the routes and the addresses of the node.
+func HasNetAdmin() (bool, error) {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false, os.NewSyscallError("capget", err)
+	}
+	return data[0].Effective&(1<<unix.CAP_NET_ADMIN) != 0, nil
+}
+
+// Add installs the route. It fails with unix.EEXIST if the table already
+// has a route to the destination.
+func Add(r Route) error {
+	return routeRequest(unix.RTM_NEWROUTE, unix.NLM_F_CREATE|unix.NLM_F_EXCL, r)
+}
+
+// Delete removes the route. It fails with unix.ESRCH if the table has no
+// such route.
+func Delete(r Route) error {
+	return routeRequest(unix.RTM_DELROUTE, 0, r)
+}
+
+func routeRequest(typ uint16, flags uint16, r Route) error {
+	family := unix.AF_INET6
+	dst := r.Dst.IP.To16()
+	gw := r.Gateway.To16()
+	if ip := r.Dst.IP.To4(); ip != nil {
+		family = unix.AF_INET
+		dst = ip
+		gw = r.Gateway.To4()
+	}
+	if r.Gateway != nil && gw == nil {
+		return fmt.Errorf("gateway %s is not in the family of %s", r.Gateway, r.Dst)
+	}
+	ones, _ := r.Dst.Mask.Size()
+
+	msg := rtMsg{
+		family:   uint8(family),
+		dstLen:   uint8(ones),
+		table:    unix.RT_TABLE_UNSPEC,
+		protocol: r.Protocol,
+		scope:    unix.RT_SCOPE_UNIVERSE,
+		typ:      unix.RTN_UNICAST,
+	}
+	if r.Table < 256 {
+		msg.table = uint8(r.Table)
+	}
+	switch {
+	case gw != nil:
+	case r.LinkIndex != 0:
+		msg.scope = unix.RT_SCOPE_LINK
+	default:
+		msg.typ = unix.RTN_BLACKHOLE
+	}
+	if typ == unix.RTM_DELROUTE {
+		msg.scope = unix.RT_SCOPE_NOWHERE
+	}
+
+	b := make([]byte, unix.SizeofNlMsghdr, 128)
+	b = append(b, msg.encode()...)
+	b = appendAttr(b, unix.RTA_DST, dst)
+	if gw != nil {
+		b = appendAttr(b, unix.RTA_GATEWAY, gw)
+	}
+	if r.LinkIndex != 0 {
+		b = appendAttr(b, unix.RTA_OIF, uint32Attr(uint32(r.LinkIndex)))
+	}
+	if r.Priority != 0 {
+		b = appendAttr(b, unix.RTA_PRIORITY, uint32Attr(r.Priority))
+	}
+	b = appendAttr(b, unix.RTA_TABLE, uint32Attr(r.Table))
+
+	return request(b, typ, flags)
+}
+
+// List returns the routes of all the tables installed with the given
+// protocol.
+func List(protocol uint8) ([]Route, error) {
+	b := make([]byte, unix.SizeofNlMsghdr, 64)
+	b = append(b, rtMsg{family: unix.AF_UNSPEC}.encode()...)
+	msgs, err := dump(b, unix.RTM_GETROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Route
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWROUTE || len(m.Data) < unix.SizeofRtMsg {
+			continue
+		}
+		// struct rtmsg
+		family, dstLen, table, proto, typ := m.Data[0], m.Data[1], m.Data[4], m.Data[5], m.Data[7]
+		if proto != protocol {
+			continue
+		}
+		if typ != unix.RTN_UNICAST && typ != unix.RTN_BLACKHOLE {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, err
+		}
+		bits := 128
+		if family == unix.AF_INET {
+			bits = 32
+		}
+		r := Route{
+			Dst:      &net.IPNet{Mask: net.CIDRMask(int(dstLen), bits)},
+			Table:    uint32(table),
+			Protocol: proto,
+		}
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case unix.RTA_DST:
+				r.Dst.IP = net.IP(a.Value)
+			case unix.RTA_GATEWAY:
+				r.Gateway = net.IP(a.Value)
+			case unix.RTA_OIF:
+				r.LinkIndex = int(byteorder.Host.Uint32(a.Value))
+			case unix.RTA_PRIORITY:
+				r.Priority = byteorder.Host.Uint32(a.Value)
+			case unix.RTA_TABLE:
+				r.Table = byteorder.Host.Uint32(a.Value)
+			}
+		}
+		// The IPv6 blackhole routes go through the loopback interface.
+		if typ == unix.RTN_BLACKHOLE {
+			r.LinkIndex = 0
+		}
+		// The default routes have no destination.
+		if r.Dst.IP == nil {
+			r.Dst.IP = make(net.IP, bits/8)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// Address is an address assigned to an interface.
+type Address struct {
+	LinkIndex int
+	Addr      *net.IPNet
+}
+
+// ifaProto is IFA_PROTO, the attribute telling who assigned an address,
+// supported by Linux 6.1 and newer.
+const ifaProto = 11
+
+// AddAddress assigns the address to the interface with the given index,
+// tagged with the given protocol. It fails with unix.EEXIST if the
+// interface already has the address.
+func AddAddress(linkIndex int, addr *net.IPNet, protocol uint8) error {
+	return addressRequest(unix.RTM_NEWADDR, unix.NLM_F_CREATE|unix.NLM_F_EXCL, linkIndex, addr, protocol)
+}
+
+// DeleteAddress removes the address from the interface with the given
+// index. It fails with unix.EADDRNOTAVAIL if the interface doesn't have
+// the address.
+func DeleteAddress(linkIndex int, addr *net.IPNet) error {
+	return addressRequest(unix.RTM_DELADDR, 0, linkIndex, addr, 0)
+}
+
+// ListAddresses returns the addresses assigned with the given protocol.
+// The kernels older than Linux 6.1 don't tag the addresses, and none is
+// returned.
+func ListAddresses(protocol uint8) ([]Address, error) {
+	b := make([]byte, unix.SizeofNlMsghdr, 64)
+	b = append(b, unix.AF_UNSPEC, 0, 0, 0, 0, 0, 0, 0)
+	msgs, err := dump(b, unix.RTM_GETADDR)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Address
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWADDR || len(m.Data) < unix.SizeofIfAddrmsg {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return nil, err
+		}
+		// struct ifaddrmsg
+		family, prefixLen := m.Data[0], m.Data[1]
+		bits := 128
+		if family == unix.AF_INET {
+			bits = 32
+		}
+		a := Address{
+			LinkIndex: int(byteorder.Host.Uint32(m.Data[4:])),
+			Addr:      &net.IPNet{Mask: net.CIDRMask(int(prefixLen), bits)},
+		}
+		tagged := false
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case unix.IFA_LOCAL:
+				a.Addr.IP = net.IP(attr.Value)
+			case unix.IFA_ADDRESS:
+				// The peer address of point to point links, the
+				// local one otherwise.
+				if a.Addr.IP == nil {
+					a.Addr.IP = net.IP(attr.Value)
+				}
+			case ifaProto:
+				tagged = len(attr.Value) > 0 && attr.Value[0] == protocol
+			}
+		}
+		if tagged && a.Addr.IP != nil {
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
+
+func addressRequest(typ uint16, flags uint16, linkIndex int, addr *net.IPNet, protocol uint8) error {
+	family := unix.AF_INET6
+	ip := addr.IP.To16()
+	// Skip the duplicate address detection, the addresses are meant to
+	// be announced by the routing daemons rather than resolved.
+	ifaFlags := uint8(unix.IFA_F_NODAD)
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		family = unix.AF_INET
+		ip = ip4
+		ifaFlags = 0
+	}
+	ones, _ := addr.Mask.Size()
+
+	// struct ifaddrmsg
+	msg := []byte{uint8(family), uint8(ones), ifaFlags, unix.RT_SCOPE_UNIVERSE, 0, 0, 0, 0}
+	byteorder.Host.PutUint32(msg[4:], uint32(linkIndex))
+
+	b := make([]byte, unix.SizeofNlMsghdr, 64)
+	b = append(b, msg...)
+	b = appendAttr(b, unix.IFA_LOCAL, ip)
+	b = appendAttr(b, unix.IFA_ADDRESS, ip)
+	if protocol != 0 {
+		b = appendAttr(b, ifaProto, []byte{protocol})
+	}
+
+	return request(b, typ, flags)
+}
+
+func uint32Attr(v uint32) []byte {
+	b := make([]byte, 4)
+	byteorder.Host.PutUint32(b, v)
+	return b
+}
+
+// rtMsg is struct rtmsg.
+type rtMsg struct {
+	family, dstLen, srcLen, tos uint8
+	table, protocol, scope, typ uint8
+	flags                       uint32
+}
+
+func (m rtMsg) encode() []byte {
+	b := []byte{m.family, m.dstLen, m.srcLen, m.tos, m.table, m.protocol, m.scope, m.typ, 0, 0, 0, 0}
+	byteorder.Host.PutUint32(b[8:], m.flags)
+	return b
+}
+
+// appendAttr appends a route attribute, padded to the netlink alignment.
+func appendAttr(b []byte, typ uint16, data []byte) []byte {
+	hdr := make([]byte, unix.SizeofRtAttr)
+	byteorder.Host.PutUint16(hdr, uint16(unix.SizeofRtAttr+len(data)))
+	byteorder.Host.PutUint16(hdr[2:], typ)
+	b = append(b, hdr...)
+	b = append(b, data...)
+	for len(b)%unix.NLMSG_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// request sends the given netlink message, whose header is filled here,
+// and waits for the kernel to acknowledge it.
+func request(b []byte, typ uint16, flags uint16) error {
+	fd, err := send(b, typ, flags|unix.NLM_F_ACK)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return fmt.Errorf("truncated netlink error message")
+			}
+			if errno := -int32(byteorder.Host.Uint32(m.Data)); errno != 0 {
+				return syscall.Errno(errno)
+			}
+			return nil
+		}
+	}
+}
+
+// dump sends the given netlink dump request, whose header is filled here,
+// and returns the messages the kernel answers with.
+func dump(b []byte, typ uint16) ([]syscall.NetlinkMessage, error) {
+	fd, err := send(b, typ, unix.NLM_F_DUMP)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	var res []syscall.NetlinkMessage
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return res, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("truncated netlink error message")
+				}
+				return nil, syscall.Errno(-int32(byteorder.Host.Uint32(m.Data)))
+			}
+			// The buffer is reused by the next read.
+			m.Data = append([]byte(nil), m.Data...)
+			res = append(res, m)
+		}
+	}
+}
+
+// send opens a netlink socket and sends the given message on it, filling
+// its header.
+func send(b []byte, typ uint16, flags uint16) (int, error) {
+	byteorder.Host.PutUint32(b, uint32(len(b)))
+	byteorder.Host.PutUint16(b[4:], typ)
+	byteorder.Host.PutUint16(b[6:], flags|unix.NLM_F_REQUEST)
+	byteorder.Host.PutUint32(b[8:], 1) // sequence number
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return 0, os.NewSyscallError("socket", err)
+	}
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return 0, os.NewSyscallError("sendto", err)
+	}
+	return fd, nil
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package netroute
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestAddDeleteRoute(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("198.51.100.0/24")
+	r := Route{
+		Dst:      dst,
+		Gateway:  net.ParseIP("127.0.0.2"),
+		Table:    4242,
+		Protocol: unix.RTPROT_BGP,
+	}
+	err := Add(r)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("not allowed to change the routing tables")
+	}
+	if err != nil {
+		t.Fatalf("add route: %s", err)
+	}
+	defer func() { _ = Delete(r) }()
+
+	if err := Add(r); !errors.Is(err, unix.EEXIST) {
+		t.Fatalf("expected adding the route again to fail with EEXIST, got %v", err)
+	}
+	if out, err := exec.Command("ip", "route", "show", "table", "4242").Output(); err == nil {
+		if !strings.Contains(string(out), "198.51.100.0/24 via 127.0.0.2") || !strings.Contains(string(out), "proto bgp") {
+			t.Fatalf("route not found in table 4242: %s", out)
+		}
+	}
+
+	if err := Delete(r); err != nil {
+		t.Fatalf("delete route: %s", err)
+	}
+	if err := Delete(r); !errors.Is(err, unix.ESRCH) {
+		t.Fatalf("expected deleting the route again to fail with ESRCH, got %v", err)
+	}
+}
+
+func TestAddDeleteBlackholeRoute(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("2001:db8:4242::1/128")
+	r := Route{
+		Dst:      dst,
+		Table:    4242,
+		Protocol: 196,
+		Priority: 50,
+	}
+	err := Add(r)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("not allowed to change the routing tables")
+	}
+	if err != nil {
+		t.Fatalf("add route: %s", err)
+	}
+	defer func() { _ = Delete(r) }()
+
+	if out, err := exec.Command("ip", "-6", "route", "show", "table", "4242").Output(); err == nil {
+		if !strings.Contains(string(out), "blackhole 2001:db8:4242::1") || !strings.Contains(string(out), "proto 196") || !strings.Contains(string(out), "metric 50") {
+			t.Fatalf("route not found in table 4242: %s", out)
+		}
+	}
+
+	if err := Delete(r); err != nil {
+		t.Fatalf("delete route: %s", err)
+	}
+	if err := Delete(r); !errors.Is(err, unix.ESRCH) {
+		t.Fatalf("expected deleting the route again to fail with ESRCH, got %v", err)
+	}
+}
+
+func TestAddDeleteAddress(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	_, addr, _ := net.ParseCIDR("198.51.100.42/32")
+	err = AddAddress(lo.Index, addr, 0)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("not allowed to change the addresses")
+	}
+	if err != nil {
+		t.Fatalf("add address: %s", err)
+	}
+	defer func() { _ = DeleteAddress(lo.Index, addr) }()
+
+	if err := AddAddress(lo.Index, addr, 0); !errors.Is(err, unix.EEXIST) {
+		t.Fatalf("expected adding the address again to fail with EEXIST, got %v", err)
+	}
+	if out, err := exec.Command("ip", "address", "show", "dev", "lo").Output(); err == nil {
+		if !strings.Contains(string(out), "198.51.100.42/32") {
+			t.Fatalf("address not found on lo: %s", out)
+		}
+	}
+
+	if err := DeleteAddress(lo.Index, addr); err != nil {
+		t.Fatalf("delete address: %s", err)
+	}
+	if err := DeleteAddress(lo.Index, addr); !errors.Is(err, unix.EADDRNOTAVAIL) {
+		t.Fatalf("expected deleting the address again to fail with EADDRNOTAVAIL, got %v", err)
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("2001:db8:4243::1/128")
+	r := Route{
+		Dst:      dst,
+		Table:    4243,
+		Protocol: 197,
+		Priority: 42,
+	}
+	err := Add(r)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("not allowed to change the routing tables")
+	}
+	if err != nil {
+		t.Fatalf("add route: %s", err)
+	}
+	defer func() { _ = Delete(r) }()
+
+	routes, err := List(197)
+	if err != nil {
+		t.Fatalf("list routes: %s", err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("expected one route tagged with the protocol, got %v", routes)
+	}
+	got := routes[0]
+	if got.Dst.String() != dst.String() || got.Table != r.Table || got.Protocol != r.Protocol || got.Priority != r.Priority || got.LinkIndex != 0 {
+		t.Fatalf("expected route %+v, got %+v", r, got)
+	}
+
+	if err := Delete(got); err != nil {
+		t.Fatalf("delete the listed route: %s", err)
+	}
+	routes, err = List(197)
+	if err != nil {
+		t.Fatalf("list routes: %s", err)
+	}
+	if len(routes) != 0 {
+		t.Fatalf("expected no route tagged with the protocol, got %v", routes)
+	}
+}
+
+func TestListAddresses(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+	_, tagged, _ := net.ParseCIDR("198.51.100.43/32")
+	_, untagged, _ := net.ParseCIDR("198.51.100.44/32")
+	err = AddAddress(lo.Index, tagged, 197)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("not allowed to change the addresses")
+	}
+	if err != nil {
+		t.Fatalf("add address: %s", err)
+	}
+	defer func() { _ = DeleteAddress(lo.Index, tagged) }()
+	if err := AddAddress(lo.Index, untagged, 0); err != nil {
+		t.Fatalf("add address: %s", err)
+	}
+	defer func() { _ = DeleteAddress(lo.Index, untagged) }()
+
+	addresses, err := ListAddresses(197)
+	if err != nil {
+		t.Fatalf("list addresses: %s", err)
+	}
+	if len(addresses) == 0 {
+		t.Skip("the kernel doesn't tag the addresses")
+	}
+	if len(addresses) != 1 || addresses[0].LinkIndex != lo.Index || addresses[0].Addr.String() != tagged.String() {
+		t.Fatalf("expected address %s on lo, got %v", tagged, addresses)
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package netroute // import "go.universe.tf/metallb/internal/netroute"
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.universe.tf/metallb/internal/byteorder"
+	"go.universe.tf/metallb/internal/ipfamily"
+)
+
+const (
+	ipv4RoutesFile = "/proc/net/route"
+	ipv6RoutesFile = "/proc/net/ipv6_route"
+
+	// Route flags, from include/uapi/linux/route.h.
+	rtfUp      = 0x1
+	rtfGateway = 0x2
+)
+
+// ErrNoDefaultGateway is returned when the host has no default route for the requested family.
+var ErrNoDefaultGateway = errors.New("no default gateway found")
+
+// ErrLinkLocalGateway is returned when the only IPv6 default gateways of the
+// host are link local addresses, which can't be reached without their interface.
+var ErrLinkLocalGateway = errors.New("the default gateway is a link local address")
+
+// DefaultGateway returns the gateway of the default route of the host
+// for the given IP family. When there are multiple default routes, the one
+// with the lowest metric is returned.
+func DefaultGateway(family ipfamily.Family) (net.IP, error) {
+	fileName := ipv4RoutesFile
+	parse := parseIPv4Routes
+	switch family {
+	case ipfamily.IPv4:
+	case ipfamily.IPv6:
+		fileName = ipv6RoutesFile
+		parse = parseIPv6Routes
+	default:
+		return nil, fmt.Errorf("unsupported ip family %q", family)
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// parseIPv4Routes parses the content of /proc/net/route, which looks like
+//
+//	Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+//	eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+//
+// where the addresses are in host byte order.
+func parseIPv4Routes(r io.Reader) (net.IP, error) {
+	var res net.IP
+	bestMetric := uint64(0)
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // skip the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route flags %q: %w", fields[3], err)
+		}
+		if flags&rtfUp == 0 || flags&rtfGateway == 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route metric %q: %w", fields[6], err)
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != net.IPv4len {
+			return nil, fmt.Errorf("invalid gateway %q", fields[2])
+		}
+		if res != nil && metric >= bestMetric {
+			continue
+		}
+		res = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(res, byteorder.Host.Uint32(gw))
+		bestMetric = metric
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, ErrNoDefaultGateway
+	}
+	return res, nil
+}
+
+// parseIPv6Routes parses the content of /proc/net/ipv6_route, which looks like
+//
+//	00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0
+//
+// with the destination, destination prefix length, source, source prefix length,
+// next hop, metric, reference count, use count, flags and interface name.
+// The link local gateways are skipped, since the address alone doesn't
+// tell the interface to reach them through.
+func parseIPv6Routes(r io.Reader) (net.IP, error) {
+	var res net.IP
+	bestMetric := uint64(0)
+	linkLocal := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if fields[0] != strings.Repeat("0", 32) || fields[1] != "00" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route flags %q: %w", fields[8], err)
+		}
+		if flags&rtfUp == 0 || flags&rtfGateway == 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route metric %q: %w", fields[5], err)
+		}
+		gw, err := hex.DecodeString(fields[4])
+		if err != nil || len(gw) != net.IPv6len {
+			return nil, fmt.Errorf("invalid gateway %q", fields[4])
+		}
+		if net.IP(gw).IsLinkLocalUnicast() {
+			linkLocal = true
+			continue
+		}
+		if res != nil && metric >= bestMetric {
+			continue
+		}
+		res = net.IP(gw)
+		bestMetric = metric
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if res == nil && linkLocal {
+		return nil, ErrLinkLocalGateway
+	}
+	if res == nil {
+		return nil, ErrNoDefaultGateway
+	}
+	return res, nil
+}
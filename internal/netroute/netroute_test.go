@@ -0,0 +1,117 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package netroute
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseIPv4Routes(t *testing.T) {
+	tests := []struct {
+		desc    string
+		routes  string
+		want    net.IP
+		wantErr error
+	}{
+		{
+			desc: "single default route",
+			routes: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`,
+			want: net.ParseIP("192.168.1.1"),
+		},
+		{
+			desc: "lowest metric wins",
+			routes: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	200	00000000	0	0	0
+eth1	00000000	FE01000A	0003	0	0	100	00000000	0	0	0
+`,
+			want: net.ParseIP("10.0.1.254"),
+		},
+		{
+			desc: "default route is down",
+			routes: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0002	0	0	100	00000000	0	0	0
+`,
+			wantErr: ErrNoDefaultGateway,
+		},
+		{
+			desc: "no default route",
+			routes: `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`,
+			wantErr: ErrNoDefaultGateway,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := parseIPv4Routes(strings.NewReader(test.routes))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("expected gateway %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseIPv6Routes(t *testing.T) {
+	tests := []struct {
+		desc    string
+		routes  string
+		want    net.IP
+		wantErr error
+	}{
+		{
+			desc: "single default route",
+			routes: `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 20010db8000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+`,
+			want: net.ParseIP("2001:db8::1"),
+		},
+		{
+			desc: "lowest metric wins",
+			routes: `00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000064 00000001 00000000 00000003     eth1
+`,
+			want: net.ParseIP("fd00::1"),
+		},
+		{
+			desc: "no default route",
+			routes: `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+`,
+			wantErr: ErrNoDefaultGateway,
+		},
+		{
+			desc: "link local gateway skipped",
+			routes: `00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000064 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth1
+`,
+			want: net.ParseIP("fd00::1"),
+		},
+		{
+			desc: "only link local gateways",
+			routes: `00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+`,
+			wantErr: ErrLinkLocalGateway,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := parseIPv6Routes(strings.NewReader(test.routes))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("expected gateway %s, got %s", test.want, got)
+			}
+		})
+	}
+}
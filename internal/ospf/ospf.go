@@ -0,0 +1,43 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package ospf describes the announcement of the LoadBalancer IPs
+// via OSPF, as external routes.
+package ospf
+
+import (
+	"net"
+	"reflect"
+)
+
+// Area is the configuration of OSPF in an area.
+type Area struct {
+	// The ID of the area, in dotted decimal notation.
+	ID string
+	// The interfaces OSPF runs on in the area.
+	Interfaces []string
+}
+
+// Advertisement is a prefix announced as an external route.
+type Advertisement struct {
+	// The prefix being advertised.
+	Prefix *net.IPNet
+	// The metric of the route. Nil means the default metric.
+	Metric *uint32
+	// The type of the external route, 1 or 2.
+	MetricType uint32
+}
+
+// Equal returns true if a and b are equivalent advertisements.
+func (a *Advertisement) Equal(b *Advertisement) bool {
+	return a.Prefix.String() == b.Prefix.String() &&
+		reflect.DeepEqual(a.Metric, b.Metric) &&
+		a.MetricType == b.MetricType
+}
+
+// Announcer announces prefixes via OSPF.
+type Announcer interface {
+	// SyncOSPFAreas replaces the areas OSPF runs in.
+	SyncOSPFAreas(areas []Area) error
+	// SetOSPFAdvertisements replaces the announced prefixes.
+	SetOSPFAdvertisements(advs []*Advertisement) error
+}
@@ -15,21 +15,29 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/bgp/community"
 	bgpfrr "go.universe.tf/metallb/internal/bgp/frr"
 	bgpnative "go.universe.tf/metallb/internal/bgp/native"
 	"go.universe.tf/metallb/internal/config"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	k8snodes "go.universe.tf/metallb/internal/k8s/nodes"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/netroute"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -46,16 +54,46 @@ const (
 type peer struct {
 	cfg     *config.Peer
 	session bgp.Session
+	// The parameters the session was established with, holding
+	// the values of the peer resolved on this node.
+	params bgp.SessionParameters
+	// The advertisements sent to the session by prefix, nil if the
+	// session must receive all of them again.
+	ads map[string]*bgp.Advertisement
+}
+
+// target returns a printable identifier of the remote end of the peer.
+func (p *peer) target() string {
+	switch {
+	case p.params.PeerInterface != "":
+		return p.params.PeerInterface
+	case p.params.PeerAddress != "":
+		return p.params.PeerAddress
+	case p.params.ListenRange != "":
+		return p.params.ListenRange
+	case p.cfg.Addr != nil:
+		return p.cfg.Addr.String()
+	}
+	return p.cfg.Name
 }
 
 type bgpController struct {
-	logger         log.Logger
-	myNode         string
-	nodeLabels     labels.Set
-	peers          []*peer
-	svcAds         map[string][]*bgp.Advertisement
-	bgpType        bgpImplementation
-	sessionManager bgp.SessionManager
+	logger          log.Logger
+	myNode          string
+	nodeLabels      labels.Set
+	nodeAnnotations map[string]string
+	peers           []*peer
+	svcAds          map[string][]*bgp.Advertisement
+	rangeAds        map[string][]*bgp.Advertisement
+	prefixAds       prefixAds
+	snippets        map[string]*config.FRRSnippet
+	bgpType         bgpImplementation
+	sessionManager  bgp.SessionManager
+	// The conflicting advertisements last reported on each service.
+	conflicts map[string][]adConflict
+	// Set when a peer is the default gateway of the node, see
+	// resyncGateway.
+	resolvesGateway atomic.Bool
 }
 
 func (c *bgpController) SetConfig(l log.Logger, cfg *config.Config) error {
@@ -66,7 +104,10 @@ newPeers:
 			if ep == nil {
 				continue
 			}
-			if reflect.DeepEqual(p, ep.cfg) {
+			if samePeer(p, ep.cfg) {
+				// The authentication of the session is changed by
+				// syncPeers, without establishing it again.
+				ep.cfg = p
 				newPeers = append(newPeers, ep)
 				c.peers[i] = nil
 				continue newPeers
@@ -80,19 +121,30 @@ newPeers:
 
 	oldPeers := c.peers
 	c.peers = newPeers
+	c.resolvesGateway.Store(c.usesDefaultGateway())
+
+	if c.bgpType != bgpNative {
+		// FRR authenticates a session with a single password, only the
+		// peers rotating their password are affected.
+		for _, p := range cfg.Peers {
+			if p.NextPassword != "" && p.NextPassword != p.Password {
+				level.Warn(l).Log("op", "setConfig", "peer", p.Name, "msg", "next password not supported in FRR mode, ignoring it")
+			}
+		}
+	}
 
 	for _, p := range oldPeers {
 		if p == nil {
 			continue
 		}
-		level.Info(l).Log("event", "peerRemoved", "peer", p.cfg.Addr, "reason", "removedFromConfig", "msg", "peer deconfigured, closing BGP session")
+		level.Info(l).Log("event", "peerRemoved", "peer", p.target(), "reason", "removedFromConfig", "msg", "peer deconfigured, closing BGP session")
 
 		if p.session != nil {
 			if err := p.session.Close(); err != nil {
-				level.Error(l).Log("op", "setConfig", "error", err, "peer", p.cfg.Addr, "msg", "failed to shut down BGP session")
+				level.Error(l).Log("op", "setConfig", "error", err, "peer", p.target(), "msg", "failed to shut down BGP session")
 			}
 		}
-		level.Debug(l).Log("event", "peerRemoved", "peer", p.cfg.Addr, "reason", "removedFromConfig", "msg", "peer deconfigured, BGP session closed")
+		level.Debug(l).Log("event", "peerRemoved", "peer", p.target(), "reason", "removedFromConfig", "msg", "peer deconfigured, BGP session closed")
 	}
 
 	err := c.syncBFDProfiles(cfg.BFDProfiles)
@@ -103,13 +155,95 @@ newPeers:
 	if err != nil {
 		return errors.Wrap(err, "failed to sync extra info")
 	}
+	c.snippets = cfg.FRRSnippets
+	err = c.syncSnippets()
+	if err != nil {
+		return errors.Wrap(err, "failed to sync frr snippets")
+	}
 
-	return c.syncPeers(l)
+	prefixes := c.syncServiceClusterIPRanges(cfg.Pools)
+	if err := c.syncPeers(l); err != nil {
+		return err
+	}
+	_, err = c.updateAds(prefixes)
+	return err
+}
+
+// samePeer tells if the given peer configurations are the same, apart
+// from the keys authenticating the session.
+func samePeer(a, b *config.Peer) bool {
+	withOldAuth := *a
+	withOldAuth.AOKeys = b.AOKeys
+	withOldAuth.Password = b.Password
+	withOldAuth.NextPassword = b.NextPassword
+	return reflect.DeepEqual(&withOldAuth, b)
+}
+
+// syncServiceClusterIPRanges updates the advertisements of the service
+// cluster IP ranges, announced as a whole by the nodes the BGPAdvertisements
+// of their pool select, and returns the prefixes whose advertisements
+// may have changed.
+func (c *bgpController) syncServiceClusterIPRanges(pools *config.Pools) map[string]bool {
+	newAds := map[string][]*bgp.Advertisement{}
+	if pools != nil {
+		for _, pool := range pools.ByName {
+			if !pool.ServiceClusterIPRange {
+				continue
+			}
+			var ads []*bgp.Advertisement
+			for _, cidr := range pool.CIDR {
+				for _, adCfg := range pool.BGPAdvertisements {
+					// skipping if this node is not enabled for this advertisement
+					if !adCfg.Nodes[c.myNode] {
+						continue
+					}
+					ads = append(ads, c.advertisementFor(cidr, adCfg))
+				}
+			}
+			if len(ads) > 0 {
+				// Not a valid service name, so the ranges can't be
+				// mistaken for a service.
+				newAds["ServiceClusterIPRange/"+pool.Name] = ads
+			}
+		}
+	}
+
+	var changed [][]*bgp.Advertisement
+	for key, ads := range c.rangeAds {
+		c.prefixAds.remove(key, ads)
+		changed = append(changed, ads)
+	}
+	for key, ads := range newAds {
+		c.prefixAds.add(key, ads)
+		changed = append(changed, ads)
+	}
+	c.rangeAds = newAds
+	return prefixesOf(changed...)
+}
+
+// syncSnippets applies the FRR snippets selecting this node.
+func (c *bgpController) syncSnippets() error {
+	selected := map[string]string{}
+	for name, s := range c.snippets {
+		for _, ns := range s.NodeSelectors {
+			if ns.Matches(c.nodeLabels) {
+				selected[name] = s.Config
+				break
+			}
+		}
+	}
+	return c.sessionManager.SyncSnippets(selected)
 }
 
 // hasHealthyEndpoint return true if this node has at least one healthy endpoint.
 // It only checks nodes matching the given filterNode function.
 func hasHealthyEndpoint(eps epslices.EpsOrSlices, filterNode func(*string) bool) bool {
+	return healthyEndpoints(eps, filterNode) > 0
+}
+
+// healthyEndpoints returns the number of healthy endpoints of the service.
+// It only counts endpoints on nodes matching the given filterNode function.
+func healthyEndpoints(eps epslices.EpsOrSlices, filterNode func(*string) bool) int {
 	ready := map[string]bool{}
 	switch eps.Type {
 	case epslices.Eps:
@@ -151,13 +285,13 @@ func hasHealthyEndpoint(eps epslices.EpsOrSlices, filterNode func(*string) bool)
 		}
 	}
 
+	count := 0
 	for _, r := range ready {
 		if r {
-			// At least one fully healthy endpoint on this machine.
-			return true
+			count++
 		}
 	}
-	return false
+	return count
 }
 
 func (c *bgpController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices, nodes map[string]*v1.Node) string {
@@ -166,23 +300,26 @@ func (c *bgpController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, po
 		return "notOwner"
 	}
 
-	if k8snodes.IsNetworkUnavailable(nodes[c.myNode]) {
-		level.Debug(l).Log("event", "skipping should announce bgp", "service", name, "reason", "speaker's node has NodeNetworkUnavailable condition")
+	return routesShouldAnnounce(l, "bgp", name, c.myNode, svc, eps, nodes)
+}
+
+// routesShouldAnnounce implements the checks shared by the protocols
+// announcing services as routes, BGP and OSPF: the node must be able to
+// route the traffic to at least one healthy endpoint.
+func routesShouldAnnounce(l log.Logger, protocol, name, myNode string, svc *v1.Service, eps epslices.EpsOrSlices, nodes map[string]*v1.Node) string {
+	if k8snodes.IsNetworkUnavailable(nodes[myNode]) {
+		level.Debug(l).Log("event", "skipping should announce "+protocol, "service", name, "reason", "speaker's node has NodeNetworkUnavailable condition")
 		return "nodeNetworkUnavailable"
 	}
+	notMyNode := func(toFilter *string) bool {
+		return toFilter == nil || *toFilter != myNode
+	}
 	// Should we advertise?
 	// Yes, if externalTrafficPolicy is
 	//  Cluster && any healthy endpoint exists
 	// or
 	//  Local && there's a ready local endpoint.
-	filterNode := func(toFilter *string) bool {
-		if toFilter == nil || *toFilter != c.myNode {
-			return true
-		}
-		return false
-	}
-
-	if svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && !hasHealthyEndpoint(eps, filterNode) {
+	if svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && !hasHealthyEndpoint(eps, notMyNode) {
 		return "noLocalEndpoints"
 	} else if !hasHealthyEndpoint(eps, func(toFilter *string) bool { return false }) {
 		return "noEndpoints"
@@ -190,6 +327,14 @@ func (c *bgpController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, po
 	return ""
 }
 
+// notMyNode tells if the given node name is not the node the speaker runs on.
+func (c *bgpController) notMyNode(toFilter *string) bool {
+	if toFilter == nil || *toFilter != c.myNode {
+		return true
+	}
+	return false
+}
+
 // Called when either the peer list or node labels have changed,
 // implying that the set of running BGP sessions may need tweaking.
 func (c *bgpController) syncPeers(l log.Logger) error {
@@ -211,42 +356,55 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 			}
 		}
 
+		var params bgp.SessionParameters
+		if shouldRun {
+			var err error
+			params, err = c.sessionParametersFor(p.cfg)
+			if err != nil {
+				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.cfg.Name, "msg", "failed to resolve the parameters of the peer, not peering from this node")
+				shouldRun = false
+				// The values taken from the node are resolved again when
+				// the node changes, which triggers a new sync. The default
+				// gateway comes from the routes of the node instead, it is
+				// resolved again on the periodic resyncs of the node.
+			}
+		}
+
+		// The TCP-AO keys may be changed without establishing the
+		// session again.
+		if p.session != nil && shouldRun && !reflect.DeepEqual(params, p.params) && updateAuthentication(l, p, params) {
+			p.params = params
+		}
+
+		// The values resolved on this node changed, the session
+		// must be established again with the new ones.
+		if p.session != nil && shouldRun && !reflect.DeepEqual(params, p.params) {
+			level.Info(l).Log("event", "peerChanged", "peer", p.target(), "msg", "peer parameters changed, closing BGP session")
+			if err := p.session.Close(); err != nil {
+				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.target(), "msg", "failed to shut down BGP session")
+			}
+			p.session = nil
+			p.ads = nil
+		}
+
 		// Now, compare current state to intended state, and correct.
 		if p.session != nil && !shouldRun {
 			// Oops, session is running but shouldn't be. Shut it down.
-			level.Info(l).Log("event", "peerRemoved", "peer", p.cfg.Addr, "reason", "filteredByNodeSelector", "msg", "peer deconfigured, closing BGP session")
+			level.Info(l).Log("event", "peerRemoved", "peer", p.target(), "reason", "filteredByNodeSelector", "msg", "peer deconfigured, closing BGP session")
 			if err := p.session.Close(); err != nil {
-				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.cfg.Addr, "msg", "failed to shut down BGP session")
+				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.target(), "msg", "failed to shut down BGP session")
 			}
 			p.session = nil
+			p.ads = nil
 		} else if p.session == nil && shouldRun {
 			// Session doesn't exist, but should be running. Create
 			// it.
-			level.Info(l).Log("event", "peerAdded", "peer", p.cfg.Addr, "msg", "peer configured, starting BGP session")
-			var routerID net.IP
-			if p.cfg.RouterID != nil {
-				routerID = p.cfg.RouterID
-			}
-			s, err := c.sessionManager.NewSession(c.logger,
-				bgp.SessionParameters{
-					PeerAddress:   net.JoinHostPort(p.cfg.Addr.String(), strconv.Itoa(int(p.cfg.Port))),
-					SourceAddress: p.cfg.SrcAddr,
-					MyASN:         p.cfg.MyASN,
-					RouterID:      routerID,
-					PeerASN:       p.cfg.ASN,
-					HoldTime:      p.cfg.HoldTime,
-					KeepAliveTime: p.cfg.KeepaliveTime,
-					Password:      p.cfg.Password,
-					CurrentNode:   c.myNode,
-					BFDProfile:    p.cfg.BFDProfile,
-					EBGPMultiHop:  p.cfg.EBGPMultiHop,
-					SessionName:   p.cfg.Name,
-					VRFName:       p.cfg.VRF,
-				},
-			)
+			p.params = params
+			level.Info(l).Log("event", "peerAdded", "peer", p.target(), "msg", "peer configured, starting BGP session")
+			s, err := c.sessionManager.NewSession(c.logger, params)
 
 			if err != nil {
-				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.cfg.Addr, "msg", "failed to create BGP session")
+				level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.target(), "msg", "failed to create BGP session")
 				errs++
 			} else {
 				p.session = s
@@ -256,7 +414,7 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 	}
 	if needUpdateAds {
 		// Some new sessions came up, resync advertisement state.
-		if err := c.updateAds(); err != nil {
+		if _, err := c.updateAds(nil); err != nil {
 			level.Error(l).Log("op", "updateAds", "error", err, "msg", "failed to update BGP advertisements")
 			return err
 		}
@@ -267,12 +425,194 @@ func (c *bgpController) syncPeers(l log.Logger) error {
 	return nil
 }
 
+// updateAuthentication changes the TCP-AO keys or the TCP MD5 passwords
+// of the session with the given peer, if they are the only parameters
+// that changed and the session supports it.
+func updateAuthentication(l log.Logger, p *peer, params bgp.SessionParameters) bool {
+	withOldAuth := params
+	withOldAuth.AOKeys = p.params.AOKeys
+	withOldAuth.Password = p.params.Password
+	withOldAuth.NextPassword = p.params.NextPassword
+	if !reflect.DeepEqual(withOldAuth, p.params) {
+		return false
+	}
+
+	if !reflect.DeepEqual(params.AOKeys, p.params.AOKeys) {
+		setter, ok := p.session.(bgp.AOKeySetter)
+		if !ok {
+			return false
+		}
+		if err := setter.SetAOKeys(params.AOKeys); err != nil {
+			level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.target(), "msg", "failed to change the TCP-AO keys of the BGP session")
+			return false
+		}
+		level.Info(l).Log("event", "peerKeysChanged", "peer", p.target(), "msg", "TCP-AO keys changed")
+	}
+
+	if params.Password != p.params.Password || params.NextPassword != p.params.NextPassword {
+		setter, ok := p.session.(bgp.PasswordSetter)
+		if !ok {
+			return false
+		}
+		if err := setter.SetPasswords(params.Password, params.NextPassword); err != nil {
+			level.Error(l).Log("op", "syncPeers", "error", err, "peer", p.target(), "msg", "failed to change the passwords of the BGP session")
+			return false
+		}
+		level.Info(l).Log("event", "peerPasswordsChanged", "peer", p.target(), "rotating", params.NextPassword != "", "msg", "TCP MD5 passwords changed")
+	}
+	return true
+}
+
+// sessionParametersFor returns the parameters of the session with the
+// given peer, resolving the values that depend on this node.
+func (c *bgpController) sessionParametersFor(p *config.Peer) (bgp.SessionParameters, error) {
+	addr, iface, err := c.resolvePeer(p)
+	if err != nil {
+		return bgp.SessionParameters{}, err
+	}
+	var peerAddress string
+	if addr != nil {
+		peerAddress = net.JoinHostPort(addr.String(), strconv.Itoa(int(p.Port)))
+	}
+
+	myASN := p.MyASN
+	if p.MyASNFrom != nil {
+		value, err := c.nodeValue(*p.MyASNFrom)
+		if err != nil {
+			return bgp.SessionParameters{}, err
+		}
+		asn, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || asn == 0 {
+			return bgp.SessionParameters{}, fmt.Errorf("invalid local ASN %q in %s", value, p.MyASNFrom.Key)
+		}
+		myASN = uint32(asn)
+	}
+	if myASN == p.ASN && p.EBGPMultiHop {
+		return bgp.SessionParameters{}, errors.New("invalid ebgp-multihop parameter set for an ibgp peer")
+	}
+
+	var routerID net.IP
+	if p.RouterID != nil {
+		routerID = p.RouterID
+	}
+	if p.RouterIDFrom != nil {
+		value, err := c.nodeValue(*p.RouterIDFrom)
+		if err != nil {
+			return bgp.SessionParameters{}, err
+		}
+		routerID = net.ParseIP(value)
+		if routerID == nil {
+			return bgp.SessionParameters{}, fmt.Errorf("invalid router ID %q in %s", value, p.RouterIDFrom.Key)
+		}
+	}
+
+	var listenRange string
+	if p.ListenRange != nil {
+		listenRange = p.ListenRange.String()
+	}
+
+	return bgp.SessionParameters{
+		PeerAddress:     peerAddress,
+		PeerInterface:   iface,
+		ListenRange:     listenRange,
+		Passive:         p.Passive,
+		SourceAddress:   p.SrcAddr,
+		MyASN:           myASN,
+		RouterID:        routerID,
+		PeerASN:         p.ASN,
+		HoldTime:        p.HoldTime,
+		KeepAliveTime:   p.KeepaliveTime,
+		Password:        p.Password,
+		NextPassword:    p.NextPassword,
+		AOKeys:          p.AOKeys,
+		CurrentNode:     c.myNode,
+		BFDProfile:      p.BFDProfile,
+		EBGPMultiHop:    p.EBGPMultiHop,
+		EBGPMultiHopTTL: p.EBGPMultiHopTTL,
+		TTLSecurityHops: p.TTLSecurityHops,
+		SessionName:     p.Name,
+		VRFName:         p.VRF,
+		Receive:         p.Receive,
+		RawFRRConfig:    p.RawFRRConfig,
+	}, nil
+}
+
+// resolvePeer returns the address of the given peer on this node, or the
+// interface to establish an unnumbered session over.
+func (c *bgpController) resolvePeer(p *config.Peer) (net.IP, string, error) {
+	if p.DynamicAddr == nil {
+		return p.Addr, "", nil
+	}
+	var ref config.NodeValueRef
+	switch p.DynamicAddr.Source {
+	case config.DynamicAddressInterface:
+		return nil, p.DynamicAddr.Interface, nil
+	case config.DynamicAddressDefaultGateway:
+		gw, err := defaultGateway(p.DynamicAddr.IPFamily)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to find the %s default gateway", p.DynamicAddr.IPFamily)
+		}
+		return gw, "", nil
+	case config.DynamicAddressNodeLabel:
+		ref = config.NodeValueRef{Source: config.NodeValueLabel, Key: p.DynamicAddr.Key}
+	case config.DynamicAddressNodeAnnotation:
+		ref = config.NodeValueRef{Source: config.NodeValueAnnotation, Key: p.DynamicAddr.Key}
+	default:
+		return nil, "", fmt.Errorf("unknown dynamic address source %s", p.DynamicAddr.Source)
+	}
+	value, err := c.nodeValue(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, "", fmt.Errorf("invalid peer address %q in %s", value, ref.Key)
+	}
+	return ip, "", nil
+}
+
+// nodeValue returns the value of the given label or annotation of this node.
+func (c *bgpController) nodeValue(ref config.NodeValueRef) (string, error) {
+	switch ref.Source {
+	case config.NodeValueLabel:
+		value, ok := c.nodeLabels[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("node has no label %s", ref.Key)
+		}
+		return strings.TrimSpace(value), nil
+	case config.NodeValueAnnotation:
+		value, ok := c.nodeAnnotations[ref.Key]
+		if !ok {
+			return "", fmt.Errorf("node has no annotation %s", ref.Key)
+		}
+		return strings.TrimSpace(value), nil
+	}
+	return "", fmt.Errorf("unknown node value source %s", ref.Source)
+}
+
 func (c *bgpController) syncBFDProfiles(profiles map[string]*config.BFDProfile) error {
 	return c.sessionManager.SyncBFDProfiles(profiles)
 }
 
-func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, _ service, _ *v1.Service) error {
-	c.svcAds[name] = nil
+func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, client service, svc *v1.Service, eps epslices.EpsOrSlices) error {
+	// With externalTrafficPolicy=Local, nodes can weight their announcements by
+	// the number of endpoints they host. With Cluster, the traffic is spread
+	// across all the endpoints regardless of the node it lands on.
+	localEndpoints := 0
+	if svc != nil && svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal {
+		localEndpoints = healthyEndpoints(eps, c.notMyNode)
+	}
+
+	// Invalid overrides can't be fixed by retrying, so we announce
+	// the service with the attributes of the advertisements.
+	overrides, err := c.bgpOverridesFor(svc)
+	if err != nil {
+		level.Error(l).Log("op", "setBalancer", "error", err, "msg", "ignoring invalid BGP overrides")
+		client.Errorf(svc, "InvalidBGPOverrides", "ignoring invalid BGP overrides: %s", err)
+		overrides = nil
+	}
+
+	var ads []*bgp.Advertisement
 	for _, lbIP := range lbIPs {
 		for _, adCfg := range pool.BGPAdvertisements {
 			// skipping if this node is not enabled for this advertisement
@@ -283,61 +623,349 @@ func (c *bgpController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, p
 			if lbIP.To4() == nil {
 				m = net.CIDRMask(adCfg.AggregationLengthV6, 128)
 			}
-			ad := &bgp.Advertisement{
-				Prefix: &net.IPNet{
-					IP:   lbIP.Mask(m),
-					Mask: m,
-				},
-				LocalPref: adCfg.LocalPref,
-			}
-			if len(adCfg.Peers) > 0 {
-				ad.Peers = make([]string, 0, len(adCfg.Peers))
-				ad.Peers = append(ad.Peers, adCfg.Peers...)
+			ad := c.advertisementFor(&net.IPNet{
+				IP:   lbIP.Mask(m),
+				Mask: m,
+			}, adCfg)
+			if overrides != nil && adCfg.ServiceOverridesNamespaces[svc.Namespace] {
+				if overrides.LocalPref != nil {
+					ad.LocalPref = *overrides.LocalPref
+				}
+				if overrides.ASPathPrependCount > 0 {
+					ad.ASPathPrependASN = 0
+					ad.ASPathPrependCount = overrides.ASPathPrependCount
+				}
+				communities := make(map[community.BGPCommunity]bool, len(adCfg.Communities)+len(overrides.Communities))
+				for comm := range adCfg.Communities {
+					communities[comm] = true
+				}
+				for _, comm := range overrides.Communities {
+					communities[comm] = true
+				}
+				ad.Communities = sortedCommunities(communities)
 			}
-			for comm := range adCfg.Communities {
-				ad.Communities = append(ad.Communities, comm)
+			switch adCfg.EndpointWeighting {
+			case config.LinkBandwidthWeighting:
+				ad.LinkBandwidth = uint32(localEndpoints)
+			case config.LocalPrefWeighting:
+				ad.LocalPref += uint32(localEndpoints)
 			}
-			sort.Slice(ad.Communities, func(i, j int) bool { return ad.Communities[i].LessThan(ad.Communities[j]) })
-			c.svcAds[name] = append(c.svcAds[name], ad)
+			ads = append(ads, ad)
 		}
 	}
 
-	if err := c.updateAds(); err != nil {
+	prefixes := prefixesOf(c.svcAds[name], ads)
+	c.prefixAds.remove(name, c.svcAds[name])
+	c.prefixAds.add(name, ads)
+	c.svcAds[name] = ads
+
+	conflicts, err := c.updateAds(prefixes)
+	c.reportConflicts(l, name, client, svc, conflicts)
+	if err != nil {
 		return err
 	}
 
-	level.Info(l).Log("event", "updatedAdvertisements", "numAds", len(c.svcAds[name]), "msg", "making advertisements using BGP")
+	level.Info(l).Log("event", "updatedAdvertisements", "numAds", len(ads), "msg", "making advertisements using BGP")
 	return nil
 }
 
-func (c *bgpController) updateAds() error {
-	var allAds []*bgp.Advertisement
-	for _, ads := range c.svcAds {
-		// This list might contain duplicates, but that's fine,
-		// they'll get compacted by the session code when it's
-		// calculating advertisements.
-		//
-		// TODO: be more intelligent about compacting advertisements
-		// and detecting conflicting advertisements.
-		allAds = append(allAds, ads...)
+// reportConflicts emits the conflicting advertisements of the given
+// service, when they differ from the ones last reported.
+func (c *bgpController) reportConflicts(l log.Logger, name string, client service, svc *v1.Service, conflicts []adConflict) {
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].prefix != conflicts[j].prefix {
+			return conflicts[i].prefix < conflicts[j].prefix
+		}
+		return conflicts[i].peer < conflicts[j].peer
+	})
+	if reflect.DeepEqual(conflicts, c.conflicts[name]) {
+		return
+	}
+	if len(conflicts) == 0 {
+		delete(c.conflicts, name)
+		return
+	}
+	c.conflicts[name] = conflicts
+	for _, conflict := range conflicts {
+		level.Warn(l).Log("event", "conflictingAdvertisements", "prefix", conflict.prefix, "peer", conflict.peer, "advertisedBy", conflict.winner, "conflictingServices", strings.Join(conflict.others, ","), "msg", "services advertise the same prefix with different attributes")
+		client.Errorf(svc, "BGPAdvertisementConflict", "prefix %s is advertised with different attributes by services %s, announcing the ones of %s to peer %s",
+			conflict.prefix, strings.Join(conflict.others, ", "), conflict.winner, conflict.peer)
+	}
+}
+
+// advertisementFor returns the advertisement of the given prefix, with the
+// attributes of the given BGPAdvertisement for this node.
+func (c *bgpController) advertisementFor(prefix *net.IPNet, adCfg *config.BGPAdvertisement) *bgp.Advertisement {
+	ad := &bgp.Advertisement{
+		Prefix:    prefix,
+		LocalPref: adCfg.LocalPref,
+	}
+	asPathPrepend, med := adCfg.AttributesFor(c.myNode)
+	if asPathPrepend != nil {
+		ad.ASPathPrependASN = asPathPrepend.ASN
+		ad.ASPathPrependCount = asPathPrepend.Count
+	}
+	if med != nil {
+		m := *med
+		ad.MED = &m
+	}
+	if len(adCfg.Peers) > 0 {
+		ad.Peers = make([]string, 0, len(adCfg.Peers))
+		ad.Peers = append(ad.Peers, adCfg.Peers...)
+	}
+	if len(adCfg.LeakToVRFs) > 0 {
+		ad.LeakToVRFs = make([]string, 0, len(adCfg.LeakToVRFs))
+		ad.LeakToVRFs = append(ad.LeakToVRFs, adCfg.LeakToVRFs...)
+	}
+	ad.EVPN = adCfg.EVPN
+	ad.Communities = sortedCommunities(adCfg.Communities)
+	return ad
+}
+
+func sortedCommunities(communities map[community.BGPCommunity]bool) []community.BGPCommunity {
+	var res []community.BGPCommunity
+	for comm := range communities {
+		res = append(res, comm)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].LessThan(res[j]) })
+	return res
+}
+
+// bgpOverridesFor returns the BGP attributes the given service overrides
+// via annotations, or nil if it doesn't override any.
+func (c *bgpController) bgpOverridesFor(svc *v1.Service) (*config.BGPServiceOverrides, error) {
+	overrides, err := config.BGPServiceOverridesFor(svc)
+	if err != nil || overrides == nil {
+		return nil, err
+	}
+	if c.bgpType == bgpNative {
+		for _, comm := range overrides.Communities {
+			if community.IsLarge(comm) {
+				return nil, fmt.Errorf("large community %s not supported in native mode", comm)
+			}
+		}
 	}
-	for _, peer := range c.peers {
-		if peer.session == nil {
+	return overrides, nil
+}
+
+// adConflict is a prefix advertised to a peer with different attributes
+// by several services.
+type adConflict struct {
+	prefix string
+	peer   string
+	// The service whose attributes are announced, and the others.
+	winner string
+	others []string
+}
+
+// updateAds sends the changes to the advertisements of the given prefixes
+// to the sessions, along with all the advertisements to the sessions that
+// need them, and returns the conflicting advertisements of the prefixes.
+func (c *bgpController) updateAds(prefixes map[string]bool) ([]adConflict, error) {
+	var (
+		conflicts []adConflict
+		errs      []string
+	)
+	for _, p := range c.peers {
+		if p.session == nil {
 			continue
 		}
-		if err := peer.session.Set(allAds...); err != nil {
-			return err
+
+		var (
+			add      []*bgp.Advertisement
+			withdraw []*net.IPNet
+		)
+		for prefix := range prefixes {
+			ad, winner, others := c.prefixAds.adFor(p.cfg.Name, prefix)
+			if len(others) > 0 {
+				conflicts = append(conflicts, adConflict{prefix: prefix, peer: p.target(), winner: winner, others: others})
+			}
+			if p.ads == nil {
+				continue
+			}
+			sent := p.ads[prefix]
+			switch {
+			case ad == nil && sent != nil:
+				withdraw = append(withdraw, sent.Prefix)
+			case ad != nil && (sent == nil || !ad.Equal(sent)):
+				add = append(add, ad)
+			}
+		}
+
+		if p.ads == nil {
+			if err := c.syncAds(p); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", p.target(), err))
+			}
+			continue
 		}
+		if len(add) == 0 && len(withdraw) == 0 {
+			continue
+		}
+		if err := p.session.Update(add, withdraw); err != nil {
+			// We don't know what the session got, send it
+			// everything next time. The other peers are still
+			// updated, as the changes are not computed again for
+			// them.
+			p.ads = nil
+			errs = append(errs, fmt.Sprintf("%s: %s", p.target(), err))
+			continue
+		}
+		for _, pfx := range withdraw {
+			delete(p.ads, pfx.String())
+		}
+		for _, ad := range add {
+			p.ads[ad.Prefix.String()] = ad
+		}
+	}
+	if len(errs) > 0 {
+		return conflicts, fmt.Errorf("failed to update the advertisements of %d BGP sessions: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return conflicts, nil
+}
+
+// syncAds replaces the advertisements of the session of the given peer
+// with all the ones it should receive.
+func (c *bgpController) syncAds(p *peer) error {
+	ads := map[string]*bgp.Advertisement{}
+	var all []*bgp.Advertisement
+	for prefix := range c.prefixAds {
+		ad, _, _ := c.prefixAds.adFor(p.cfg.Name, prefix)
+		if ad == nil {
+			continue
+		}
+		ads[prefix] = ad
+		all = append(all, ad)
+	}
+	if err := p.session.Set(all...); err != nil {
+		return err
 	}
+	p.ads = ads
 	return nil
 }
 
+// prefixAds indexes the advertisements of the services by prefix and
+// service, so the changes to a service only touch the prefixes it
+// advertises. A prefix advertised by several services stays advertised
+// until the last of them stops.
+type prefixAds map[string]map[string][]*bgp.Advertisement
+
+func (pa prefixAds) add(svc string, ads []*bgp.Advertisement) {
+	for _, ad := range ads {
+		prefix := ad.Prefix.String()
+		if pa[prefix] == nil {
+			pa[prefix] = map[string][]*bgp.Advertisement{}
+		}
+		pa[prefix][svc] = append(pa[prefix][svc], ad)
+	}
+}
+
+func (pa prefixAds) remove(svc string, ads []*bgp.Advertisement) {
+	for _, ad := range ads {
+		prefix := ad.Prefix.String()
+		delete(pa[prefix], svc)
+		if len(pa[prefix]) == 0 {
+			delete(pa, prefix)
+		}
+	}
+}
+
+// adFor returns the advertisement of the given prefix to send to the
+// given peer, or nil if the prefix is not advertised to it. The
+// advertisements of the prefix by the same service are merged. When
+// services advertise the prefix with different attributes, the ones of
+// the first service by name win, and the other services are returned.
+func (pa prefixAds) adFor(peer, prefix string) (*bgp.Advertisement, string, []string) {
+	bySvc := pa[prefix]
+	svcs := make([]string, 0, len(bySvc))
+	for svc := range bySvc {
+		svcs = append(svcs, svc)
+	}
+	sort.Strings(svcs)
+
+	var (
+		res    *bgp.Advertisement
+		winner string
+		others []string
+	)
+	for _, svc := range svcs {
+		var merged *bgp.Advertisement
+		for _, ad := range bySvc[svc] {
+			if ad.MatchesPeer(peer) {
+				merged = mergeAds(merged, ad)
+			}
+		}
+		switch {
+		case merged == nil:
+		case res == nil:
+			res, winner = merged, svc
+		case !sameAttributes(res, merged):
+			others = append(others, svc)
+		}
+	}
+	return res, winner, others
+}
+
+// mergeAds merges the advertisements of the same prefix by a service, as
+// its pool may have several BGPAdvertisements. The communities and the VRFs
+// the prefix is leaked into add up, and the highest local preference wins.
+// The other attributes are the ones of the first advertisement.
+func mergeAds(a, b *bgp.Advertisement) *bgp.Advertisement {
+	if a == nil {
+		return b
+	}
+	if sameAttributes(a, b) {
+		return a
+	}
+	res := *a
+	if b.LocalPref > res.LocalPref {
+		res.LocalPref = b.LocalPref
+	}
+	communities := map[community.BGPCommunity]bool{}
+	for _, comm := range a.Communities {
+		communities[comm] = true
+	}
+	for _, comm := range b.Communities {
+		communities[comm] = true
+	}
+	res.Communities = sortedCommunities(communities)
+	vrfs := sets.New(a.LeakToVRFs...).Insert(b.LeakToVRFs...)
+	res.LeakToVRFs = nil
+	if vrfs.Len() > 0 {
+		res.LeakToVRFs = sets.List(vrfs)
+	}
+	return &res
+}
+
+// sameAttributes returns true if the two advertisements are announced the
+// same way, regardless of the peers they are limited to.
+func sameAttributes(a, b *bgp.Advertisement) bool {
+	withPeers := *b
+	withPeers.Peers = a.Peers
+	return a.Equal(&withPeers)
+}
+
+// prefixesOf returns the prefixes of the given advertisements.
+func prefixesOf(ads ...[]*bgp.Advertisement) map[string]bool {
+	res := map[string]bool{}
+	for _, l := range ads {
+		for _, ad := range l {
+			res[ad.Prefix.String()] = true
+		}
+	}
+	return res
+}
+
 func (c *bgpController) DeleteBalancer(l log.Logger, name, reason string) error {
-	if _, ok := c.svcAds[name]; !ok {
-		return nil
+	ads, ok := c.svcAds[name]
+	if ok {
+		delete(c.svcAds, name)
+		c.prefixAds.remove(name, ads)
 	}
-	delete(c.svcAds, name)
-	return c.updateAds()
+	delete(c.conflicts, name)
+	// Even if the service is already gone, the sessions that failed
+	// to withdraw it get all their advertisements again.
+	_, err := c.updateAds(prefixesOf(ads))
+	return err
 }
 
 func (c *bgpController) SetNode(l log.Logger, node *v1.Node) error {
@@ -348,16 +976,72 @@ func (c *bgpController) SetNode(l log.Logger, node *v1.Node) error {
 	if nodeLabels == nil {
 		nodeLabels = map[string]string{}
 	}
+	nodeAnnotations := node.Annotations
+	if nodeAnnotations == nil {
+		nodeAnnotations = map[string]string{}
+	}
 	ns := labels.Set(nodeLabels)
-	if c.nodeLabels != nil && labels.Equals(c.nodeLabels, ns) {
-		// Node labels unchanged, no action required.
+	labelsChanged := c.nodeLabels == nil || !labels.Equals(c.nodeLabels, ns)
+	annotationsChanged := c.nodeAnnotations == nil || !labels.Equals(c.nodeAnnotations, nodeAnnotations)
+	if !labelsChanged && !annotationsChanged && !c.usesDefaultGateway() {
+		// Node labels and annotations unchanged, no action required.
 		return nil
 	}
 	c.nodeLabels = ns
-	level.Info(l).Log("event", "nodeLabelsChanged", "msg", "Node labels changed, resyncing BGP peers")
+	c.nodeAnnotations = nodeAnnotations
+	if labelsChanged {
+		level.Info(l).Log("event", "nodeLabelsChanged", "msg", "Node labels changed, resyncing BGP peers")
+		if err := c.syncSnippets(); err != nil {
+			return errors.Wrap(err, "failed to sync frr snippets")
+		}
+	} else if annotationsChanged {
+		level.Debug(l).Log("event", "nodeAnnotationsChanged", "msg", "Node annotations changed, resyncing BGP peers")
+	}
+	// The default gateway of the node may have changed in the meantime,
+	// it is resolved again on each node sync. The peers whose gateway
+	// can't be resolved are skipped until the next one.
 	return c.syncPeers(l)
 }
 
+// usesDefaultGateway tells if a peer is the default gateway of the node.
+func (c *bgpController) usesDefaultGateway() bool {
+	for _, p := range c.peers {
+		if usesDefaultGateway(p.cfg) {
+			return true
+		}
+	}
+	return false
+}
+
+func usesDefaultGateway(p *config.Peer) bool {
+	return p.DynamicAddr != nil && p.DynamicAddr.Source == config.DynamicAddressDefaultGateway
+}
+
+// gatewayResyncPeriod is how often the node is synced again while a peer
+// is its default gateway, for the changes of the gateway to be picked up.
+// It is overridden in tests.
+var gatewayResyncPeriod = 30 * time.Second
+
+// resyncGateway calls resync periodically while a peer is the default
+// gateway of the node, until stopCh is closed.
+func (c *bgpController) resyncGateway(resync func(), stopCh <-chan struct{}) {
+	t := time.NewTicker(gatewayResyncPeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-t.C:
+			if c.resolvesGateway.Load() {
+				resync()
+			}
+		}
+	}
+}
+
+// defaultGateway returns the default gateway of the node, overridden in tests.
+var defaultGateway = netroute.DefaultGateway
+
 // Create a new 'bgp.SessionManager' of type 'bgpType'.
 var newBGP = func(bgpType bgpImplementation, l log.Logger, logLevel logging.Level) bgp.SessionManager {
 	switch bgpType {
@@ -378,3 +1062,14 @@ func poolMatchesNodeBGP(pool *config.Pool, node string) bool {
 	}
 	return false
 }
+
+// receivedRoutesHandler serves the routes received from the BGP peers
+// as JSON.
+func receivedRoutesHandler(r bgp.RouteReceiver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(r.ReceivedRoutes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
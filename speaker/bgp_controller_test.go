@@ -6,16 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http/httptest"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"go.universe.tf/metallb/internal/bgp"
 	"go.universe.tf/metallb/internal/bgp/community"
 	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/ipfamily"
 	"go.universe.tf/metallb/internal/k8s/controllers"
 	"go.universe.tf/metallb/internal/k8s/epslices"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/netroute"
 	"go.universe.tf/metallb/internal/pointer"
 
 	"github.com/go-kit/log"
@@ -24,6 +28,7 @@ import (
 	discovery "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 func mustSelector(s string) labels.Selector {
@@ -94,6 +99,7 @@ type fakeBGP struct {
 func (f *fakeBGP) NewSessionManager(_ bgpImplementation, _ log.Logger, _ logging.Level) bgp.SessionManager {
 	f.sessionManager.t = f.t
 	f.sessionManager.gotAds = make(map[string][]*bgp.Advertisement)
+	f.sessionManager.gotParams = make(map[string]bgp.SessionParameters)
 
 	return &f.sessionManager
 }
@@ -104,22 +110,38 @@ type fakeBGPSessionManager struct {
 	sync.Mutex
 	// peer IP -> advertisements
 	gotAds map[string][]*bgp.Advertisement
+	// peer IP -> parameters of the session
+	gotParams map[string]bgp.SessionParameters
+	// number of incremental updates of the sessions
+	updates int
+	// number of sessions closed
+	closes int
+	// snippets applied on the node, by name
+	gotSnippets map[string]string
+	// peer IP -> whether the advertisements of the session fail to
+	// be set
+	failAds map[string]bool
 }
 
 func (f *fakeBGPSessionManager) NewSession(_ log.Logger, args bgp.SessionParameters) (bgp.Session, error) {
 	f.Lock()
 	defer f.Unlock()
 
-	if _, ok := f.gotAds[args.PeerAddress]; ok {
-		f.t.Errorf("Tried to create already existing BGP session to %q", args.PeerAddress)
+	addr := args.PeerAddress
+	if args.PeerInterface != "" {
+		addr = args.PeerInterface
+	}
+	if _, ok := f.gotAds[addr]; ok {
+		f.t.Errorf("Tried to create already existing BGP session to %q", addr)
 		return nil, errors.New("invariant violation")
 	}
 	// Nil because we haven't programmed any routes for it yet, but
 	// the key now exists in the map.
-	f.gotAds[args.PeerAddress] = nil
+	f.gotAds[addr] = nil
+	f.gotParams[addr] = args
 	return &fakeSession{
 		f:    f,
-		addr: args.PeerAddress,
+		addr: addr,
 	}, nil
 }
 
@@ -131,6 +153,13 @@ func (f *fakeBGPSessionManager) SyncExtraInfo(extra string) error {
 	return nil
 }
 
+func (f *fakeBGPSessionManager) SyncSnippets(snippets map[string]string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.gotSnippets = snippets
+	return nil
+}
+
 func (f *fakeBGPSessionManager) Ads() map[string][]*bgp.Advertisement {
 	ret := map[string][]*bgp.Advertisement{}
 
@@ -170,6 +199,37 @@ func (f *fakeSession) Close() error {
 	}
 
 	delete(f.f.gotAds, f.addr)
+	delete(f.f.gotParams, f.addr)
+	f.f.closes++
+	return nil
+}
+
+func (f *fakeSession) SetPasswords(password, nextPassword string) error {
+	f.f.Lock()
+	defer f.f.Unlock()
+
+	params, ok := f.f.gotParams[f.addr]
+	if !ok {
+		f.f.t.Errorf("Tried to set passwords on non-existent session to %q", f.addr)
+		return errors.New("invariant violation")
+	}
+	params.Password = password
+	params.NextPassword = nextPassword
+	f.f.gotParams[f.addr] = params
+	return nil
+}
+
+func (f *fakeSession) SetAOKeys(keys []config.TCPAOKey) error {
+	f.f.Lock()
+	defer f.f.Unlock()
+
+	params, ok := f.f.gotParams[f.addr]
+	if !ok {
+		f.f.t.Errorf("Tried to set keys on non-existent session to %q", f.addr)
+		return errors.New("invariant violation")
+	}
+	params.AOKeys = keys
+	f.f.gotParams[f.addr] = params
 	return nil
 }
 
@@ -181,11 +241,45 @@ func (f *fakeSession) Set(ads ...*bgp.Advertisement) error {
 		f.f.t.Errorf("Tried to set ads on non-existent session to %q", f.addr)
 		return errors.New("invariant violation")
 	}
+	if f.f.failAds[f.addr] {
+		return errors.New("session failed")
+	}
 
 	f.f.gotAds[f.addr] = ads
 	return nil
 }
 
+func (f *fakeSession) Update(add []*bgp.Advertisement, withdraw []*net.IPNet) error {
+	f.f.Lock()
+	defer f.f.Unlock()
+
+	ads, ok := f.f.gotAds[f.addr]
+	if !ok {
+		f.f.t.Errorf("Tried to update ads on non-existent session to %q", f.addr)
+		return errors.New("invariant violation")
+	}
+	if f.f.failAds[f.addr] {
+		return errors.New("session failed")
+	}
+
+	changed := map[string]bool{}
+	for _, pfx := range withdraw {
+		changed[pfx.String()] = true
+	}
+	for _, ad := range add {
+		changed[ad.Prefix.String()] = true
+	}
+	var res []*bgp.Advertisement
+	for _, ad := range ads {
+		if !changed[ad.Prefix.String()] {
+			res = append(res, ad)
+		}
+	}
+	f.f.gotAds[f.addr] = append(res, add...)
+	f.f.updates++
+	return nil
+}
+
 // testK8S implements service by recording what the controller wants
 // to do to k8s.
 type testK8S struct {
@@ -800,6 +894,126 @@ func TestBGPSpeaker(t *testing.T) {
 			expectedLBRet:  controllers.SyncStateSuccess,
 		},
 
+		{
+			desc: "Advertisement leaked to VRFs",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Name:          "peer1",
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools: &config.Pools{ByName: map[string]*config.Pool{
+					"default": {
+						CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+						BGPAdvertisements: []*config.BGPAdvertisement{
+							{
+								AggregationLength: 32,
+								LocalPref:         100,
+								Nodes:             map[string]bool{"pandora": true},
+								LeakToVRFs:        []string{"default", "red"},
+							},
+						},
+					},
+				}},
+			},
+			balancer: "test1",
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Cluster",
+				},
+				Status: statusAssigned("10.20.30.1"),
+			},
+			eps: epslices.EpsOrSlices{
+				EpVal: &v1.Endpoints{
+					Subsets: []v1.EndpointSubset{
+						{
+							Addresses: []v1.EndpointAddress{
+								{
+									IP:       "2.3.4.5",
+									NodeName: pointer.StrPtr("iris"),
+								},
+							},
+						},
+					},
+				},
+				Type: epslices.Eps,
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:     ipnet("10.20.30.1/32"),
+						LocalPref:  100,
+						LeakToVRFs: []string{"default", "red"},
+					},
+				},
+			},
+			expectedCfgRet: controllers.SyncStateReprocessAll,
+			expectedLBRet:  controllers.SyncStateSuccess,
+		},
+
+		{
+			desc: "Advertisement originated via EVPN",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Name:          "peer1",
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools: &config.Pools{ByName: map[string]*config.Pool{
+					"default": {
+						CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+						BGPAdvertisements: []*config.BGPAdvertisement{
+							{
+								AggregationLength: 32,
+								LocalPref:         100,
+								Nodes:             map[string]bool{"pandora": true},
+								EVPN:              &config.EVPNAdvertisement{VRF: "red", VNI: 100},
+							},
+						},
+					},
+				}},
+			},
+			balancer: "test1",
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Cluster",
+				},
+				Status: statusAssigned("10.20.30.1"),
+			},
+			eps: epslices.EpsOrSlices{
+				EpVal: &v1.Endpoints{
+					Subsets: []v1.EndpointSubset{
+						{
+							Addresses: []v1.EndpointAddress{
+								{
+									IP:       "2.3.4.5",
+									NodeName: pointer.StrPtr("iris"),
+								},
+							},
+						},
+					},
+				},
+				Type: epslices.Eps,
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.20.30.1/32"),
+						LocalPref: 100,
+						EVPN:      &config.EVPNAdvertisement{VRF: "red", VNI: 100},
+					},
+				},
+			},
+			expectedCfgRet: controllers.SyncStateReprocessAll,
+			expectedLBRet:  controllers.SyncStateSuccess,
+		},
+
 		{
 			desc: "Multiple peers",
 			config: &config.Config{
@@ -977,12 +1191,8 @@ func TestBGPSpeaker(t *testing.T) {
 				Type: epslices.Eps,
 			},
 			wantAds: map[string][]*bgp.Advertisement{
+				// The shared prefix is advertised once.
 				"1.2.3.4:0": {
-					// Prefixes duplicated because the dedupe happens
-					// inside the real BGP session.
-					{
-						Prefix: ipnet("10.20.30.1/32"),
-					},
 					{
 						Prefix: ipnet("10.20.30.1/32"),
 					},
@@ -991,9 +1201,6 @@ func TestBGPSpeaker(t *testing.T) {
 					{
 						Prefix: ipnet("10.20.30.1/32"),
 					},
-					{
-						Prefix: ipnet("10.20.30.1/32"),
-					},
 				},
 			},
 			expectedCfgRet: controllers.SyncStateReprocessAll,
@@ -1803,12 +2010,8 @@ func TestBGPSpeakerEPSlices(t *testing.T) {
 				Type: epslices.Slices,
 			},
 			wantAds: map[string][]*bgp.Advertisement{
+				// The shared prefix is advertised once.
 				"1.2.3.4:0": {
-					// Prefixes duplicated because the dedupe happens
-					// inside the real BGP session.
-					{
-						Prefix: ipnet("10.20.30.1/32"),
-					},
 					{
 						Prefix: ipnet("10.20.30.1/32"),
 					},
@@ -1817,9 +2020,6 @@ func TestBGPSpeakerEPSlices(t *testing.T) {
 					{
 						Prefix: ipnet("10.20.30.1/32"),
 					},
-					{
-						Prefix: ipnet("10.20.30.1/32"),
-					},
 				},
 			},
 		},
@@ -1928,7 +2128,7 @@ func TestBGPSpeakerEPSlices(t *testing.T) {
 	}
 }
 
-func TestNodeSelectors(t *testing.T) {
+func TestBGPSpeakerEndpointWeighting(t *testing.T) {
 	b := &fakeBGP{
 		t: t,
 	}
@@ -1943,189 +2143,1961 @@ func TestNodeSelectors(t *testing.T) {
 	}
 	c.client = &testK8S{t: t}
 
-	pools := map[string]*config.Pool{
-		"default": {
-			CIDR: []*net.IPNet{ipnet("1.2.3.0/24")},
-			BGPAdvertisements: []*config.BGPAdvertisement{
-				{
-					AggregationLength: 32,
-					Nodes:             map[string]bool{"pandora": true},
+	configWithWeighting := func(weighting config.EndpointWeighting) *config.Config {
+		return &config.Config{
+			Peers: map[string]*config.Peer{
+				"peer1": {
+					Addr:          net.ParseIP("1.2.3.4"),
+					NodeSelectors: []labels.Selector{labels.Everything()},
+				},
+			},
+			Pools: &config.Pools{ByName: map[string]*config.Pool{
+				"default": {
+					CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+					BGPAdvertisements: []*config.BGPAdvertisement{
+						{
+							AggregationLength: 32,
+							LocalPref:         100,
+							Nodes:             map[string]bool{"pandora": true},
+							EndpointWeighting: weighting,
+						},
+					},
+				},
+			}},
+		}
+	}
+
+	endpoint := func(addr, node string) discovery.Endpoint {
+		return discovery.Endpoint{
+			Addresses: []string{
+				addr,
+			},
+			NodeName: stringPtr(node),
+			Conditions: discovery.EndpointConditions{
+				Ready: pointer.BoolPtr(true),
+			},
+		}
+	}
+
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					endpoint("2.3.4.5", "pandora"),
+					endpoint("2.3.4.6", "pandora"),
+					endpoint("2.3.4.7", "iris"),
 				},
 			},
 		},
+		Type: epslices.Slices,
 	}
 
 	tests := []struct {
-		desc    string
-		config  *config.Config
-		node    *v1.Node
+		desc string
+
+		config *config.Config
+		svc    *v1.Service
+
 		wantAds map[string][]*bgp.Advertisement
 	}{
 		{
-			desc:    "No config, no advertisements",
-			wantAds: map[string][]*bgp.Advertisement{},
-		},
-
-		{
-			desc: "One peer, default node selector, no node labels",
-			config: &config.Config{
-				Peers: map[string]*config.Peer{
-					"peer1": {
-						Addr:          net.ParseIP("1.2.3.4"),
-						NodeSelectors: []labels.Selector{labels.Everything()},
-					},
+			desc:   "Link bandwidth, traffic policy local",
+			config: configWithWeighting(config.LinkBandwidthWeighting),
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Local",
 				},
-				Pools: &config.Pools{ByName: pools},
+				Status: statusAssigned("10.20.30.1"),
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
+				"1.2.3.4:0": {
+					{
+						Prefix:        ipnet("10.20.30.1/32"),
+						LocalPref:     100,
+						LinkBandwidth: 2,
+					},
+				},
 			},
 		},
-
 		{
-			desc: "Second peer, non-matching node selector",
-			config: &config.Config{
-				Peers: map[string]*config.Peer{
-					"peer1": {
-						Addr:          net.ParseIP("1.2.3.4"),
-						NodeSelectors: []labels.Selector{labels.Everything()},
-					},
-					"peer2": {
-						Addr: net.ParseIP("2.3.4.5"),
-						NodeSelectors: []labels.Selector{
-							mustSelector("foo=bar"),
-						},
-					},
+			desc:   "Local pref, traffic policy local",
+			config: configWithWeighting(config.LocalPrefWeighting),
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Local",
 				},
-				Pools: &config.Pools{ByName: pools},
+				Status: statusAssigned("10.20.30.1"),
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.20.30.1/32"),
+						LocalPref: 102,
+					},
+				},
 			},
 		},
-
 		{
-			desc: "Add node label that matches",
-			node: &v1.Node{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "pandora",
-					Labels: map[string]string{
-						"foo": "bar",
-					},
+			desc:   "Link bandwidth, traffic policy cluster",
+			config: configWithWeighting(config.LinkBandwidthWeighting),
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Cluster",
 				},
+				Status: statusAssigned("10.20.30.1"),
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
-				"2.3.4.5:0": nil,
-			},
-		},
-
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.20.30.1/32"),
+						LocalPref: 100,
+					},
+				},
+			},
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		if c.SetConfig(l, test.config) == controllers.SyncStateError {
+			t.Errorf("%q: SetConfig failed", test.desc)
+		}
+		if c.SetBalancer(l, "test1", test.svc, eps) == controllers.SyncStateError {
+			t.Errorf("%q: SetBalancer failed", test.desc)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestBGPSpeakerSharedPrefix(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	k := &testK8S{t: t}
+	c.client = k
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength: 24,
+						LocalPref:         100,
+						Nodes:             map[string]bool{"pandora": true},
+						EndpointWeighting: config.LocalPrefWeighting,
+					},
+				},
+			},
+		}},
+	}
+
+	service := func(ip string, policy v1.ServiceExternalTrafficPolicyType) *v1.Service {
+		return &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: policy,
+			},
+			Status: statusAssigned(ip),
+		}
+	}
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses:  []string{"2.3.4.5"},
+						NodeName:   stringPtr("pandora"),
+						Conditions: discovery.EndpointConditions{Ready: pointer.BoolPtr(true)},
+					},
+				},
+			},
+		},
+		Type: epslices.Slices,
+	}
+	aggregated := func(localPref uint32) map[string][]*bgp.Advertisement {
+		return map[string][]*bgp.Advertisement{
+			"1.2.3.4:0": {
+				{
+					Prefix:    ipnet("10.20.30.0/24"),
+					LocalPref: localPref,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		desc     string
+		balancer string
+		svc      *v1.Service
+
+		wantAds      map[string][]*bgp.Advertisement
+		wantUpdate   bool
+		wantConflict bool
+	}{
+		{
+			desc:       "First service",
+			balancer:   "a",
+			svc:        service("10.20.30.1", "Cluster"),
+			wantAds:    aggregated(100),
+			wantUpdate: true,
+		},
+		{
+			desc:     "Second service, same prefix and attributes",
+			balancer: "b",
+			svc:      service("10.20.30.2", "Cluster"),
+			wantAds:  aggregated(100),
+		},
+		{
+			desc:         "Third service, same prefix with different attributes",
+			balancer:     "c",
+			svc:          service("10.20.30.3", "Local"),
+			wantAds:      aggregated(100),
+			wantConflict: true,
+		},
+		{
+			desc:     "Third service synced again, the conflict is not reported again",
+			balancer: "c",
+			svc:      service("10.20.30.3", "Local"),
+			wantAds:  aggregated(100),
+		},
+		{
+			desc:     "Delete first service",
+			balancer: "a",
+			wantAds:  aggregated(100),
+		},
+		{
+			desc:       "Delete second service",
+			balancer:   "b",
+			wantAds:    aggregated(101),
+			wantUpdate: true,
+		},
+		{
+			desc:     "Delete third service",
+			balancer: "c",
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+			wantUpdate: true,
+		},
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+	for _, test := range tests {
+		k.loggedWarning = false
+		updates := b.sessionManager.updates
+		if c.SetBalancer(l, test.balancer, test.svc, eps) == controllers.SyncStateError {
+			t.Errorf("%q: SetBalancer failed", test.desc)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+		if gotUpdate := b.sessionManager.updates != updates; gotUpdate != test.wantUpdate {
+			t.Errorf("%q: expected session update %v, got %v", test.desc, test.wantUpdate, gotUpdate)
+		}
+		if k.loggedWarning != test.wantConflict {
+			t.Errorf("%q: expected conflict event %v, got %v", test.desc, test.wantConflict, k.loggedWarning)
+		}
+	}
+}
+
+func TestBGPSpeakerUpdateFailure(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"peer2": {
+				Addr:          net.ParseIP("1.2.3.5"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength: 32,
+						Nodes:             map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses:  []string{"2.3.4.5"},
+						NodeName:   stringPtr("pandora"),
+						Conditions: discovery.EndpointConditions{Ready: pointer.BoolPtr(true)},
+					},
+				},
+			},
+		},
+		Type: epslices.Slices,
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+	if c.SetBalancer(l, "a", svc, eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	// The first session fails to withdraw the service, the second
+	// one still withdraws it.
+	first := c.protocolHandlers[config.BGP].(*bgpController).peers[0].session.(*fakeSession).addr
+	b.sessionManager.failAds = map[string]bool{first: true}
+	if c.SetBalancer(l, "a", nil, epslices.EpsOrSlices{}) != controllers.SyncStateError {
+		t.Fatalf("expected SetBalancer to fail")
+	}
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {{Prefix: ipnet("10.20.30.1/32")}},
+		"1.2.3.5:0": {{Prefix: ipnet("10.20.30.1/32")}},
+	}
+	for addr := range wantAds {
+		if addr != first {
+			wantAds[addr] = nil
+		}
+	}
+	if diff := cmp.Diff(wantAds, b.sessionManager.Ads()); diff != "" {
+		t.Fatalf("unexpected advertisement state after the failure (-want +got)\n%s", diff)
+	}
+
+	// The retry sends everything to the failed session.
+	b.sessionManager.failAds = nil
+	if c.SetBalancer(l, "a", nil, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	wantAds = map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": nil,
+		"1.2.3.5:0": nil,
+	}
+	if diff := cmp.Diff(wantAds, b.sessionManager.Ads()); diff != "" {
+		t.Fatalf("unexpected advertisement state after the retry (-want +got)\n%s", diff)
+	}
+}
+
+func TestBGPSpeakerSameServiceAdvertisements(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	k := &testK8S{t: t}
+	c.client = k
+
+	community1, _ := community.New("0:1234")
+	community2, _ := community.New("0:2345")
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength: 32,
+						LocalPref:         100,
+						Communities:       map[community.BGPCommunity]bool{community1: true},
+						Nodes:             map[string]bool{"pandora": true},
+					},
+					{
+						AggregationLength: 32,
+						LocalPref:         200,
+						Communities:       map[community.BGPCommunity]bool{community2: true},
+						Nodes:             map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: statusAssigned("10.20.30.1"),
+	}
+
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses:  []string{"2.3.4.5"},
+						NodeName:   stringPtr("pandora"),
+						Conditions: discovery.EndpointConditions{Ready: pointer.BoolPtr(true)},
+					},
+				},
+			},
+		},
+		Type: epslices.Slices,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	// The advertisements of the same service are merged, rather than
+	// conflicting with each other.
+	wantAds := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{
+				Prefix:      ipnet("10.20.30.1/32"),
+				LocalPref:   200,
+				Communities: []community.BGPCommunity{community1, community2},
+			},
+		},
+	}
+	gotAds := b.sessionManager.Ads()
+	if diff := cmp.Diff(wantAds, gotAds); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+	if k.loggedWarning {
+		t.Errorf("unexpected conflict event")
+	}
+}
+
+func TestBGPSpeakerServiceOverrides(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	mustCommunity := func(c string) community.BGPCommunity {
+		res, err := community.New(c)
+		if err != nil {
+			t.Fatalf("invalid community %s: %s", c, err)
+		}
+		return res
+	}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength:          32,
+						LocalPref:                  100,
+						Communities:                map[community.BGPCommunity]bool{mustCommunity("0:1234"): true},
+						Nodes:                      map[string]bool{"pandora": true},
+						ServiceOverridesNamespaces: map[string]bool{"allowed": true},
+					},
+					{
+						AggregationLength: 24,
+						Nodes:             map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+	if c.SetConfig(log.NewNopLogger(), cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses: []string{
+							"2.3.4.5",
+						},
+						NodeName: stringPtr("iris"),
+						Conditions: discovery.EndpointConditions{
+							Ready: pointer.BoolPtr(true),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Slices,
+	}
+
+	service := func(namespace string, annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Annotations: annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+	}
+
+	overrides := map[string]string{
+		config.BGPCommunitiesAnnotation:   "0:2345,0:1234",
+		config.BGPLocalPrefAnnotation:     "300",
+		config.BGPASPathPrependAnnotation: "2",
+	}
+
+	notOverridden := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{
+				Prefix:      ipnet("10.20.30.1/32"),
+				LocalPref:   100,
+				Communities: []community.BGPCommunity{mustCommunity("0:1234")},
+			},
+			{
+				Prefix: ipnet("10.20.30.0/24"),
+			},
+		},
+	}
+
+	tests := []struct {
+		desc        string
+		svc         *v1.Service
+		wantAds     map[string][]*bgp.Advertisement
+		wantWarning bool
+	}{
+		{
+			desc: "Allowed namespace",
+			svc:  service("allowed", overrides),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:             ipnet("10.20.30.1/32"),
+						LocalPref:          300,
+						Communities:        []community.BGPCommunity{mustCommunity("0:1234"), mustCommunity("0:2345")},
+						ASPathPrependCount: 2,
+					},
+					{
+						Prefix: ipnet("10.20.30.0/24"),
+					},
+				},
+			},
+		},
+		{
+			desc:    "Namespace not allowed",
+			svc:     service("other", overrides),
+			wantAds: notOverridden,
+		},
+		{
+			desc: "Invalid overrides",
+			svc: service("allowed", map[string]string{
+				config.BGPLocalPrefAnnotation: "foo",
+			}),
+			wantAds:     notOverridden,
+			wantWarning: true,
+		},
+		{
+			desc: "Large community in native mode",
+			svc: service("allowed", map[string]string{
+				config.BGPCommunitiesAnnotation: "large:123:456:789",
+			}),
+			wantAds:     notOverridden,
+			wantWarning: true,
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		k := &testK8S{t: t}
+		c.client = k
+		if c.SetBalancer(l, "test1", test.svc, eps) == controllers.SyncStateError {
+			t.Errorf("%q: SetBalancer failed", test.desc)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+		if k.loggedWarning != test.wantWarning {
+			t.Errorf("%q: expected warning %v, got %v", test.desc, test.wantWarning, k.loggedWarning)
+		}
+	}
+}
+
+func TestBGPSpeakerASPathPrependAndMED(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength: 32,
+						Nodes:             map[string]bool{"pandora": true},
+						ASPathPrepend:     &config.ASPathPrepend{ASN: 65010, Count: 2},
+						MED:               pointer.Uint32Ptr(10),
+						NodeOverrides: map[string]config.BGPNodeOverride{
+							"pandora": {MED: pointer.Uint32Ptr(100)},
+						},
+						ServiceOverridesNamespaces: map[string]bool{"allowed": true},
+					},
+					{
+						AggregationLength: 24,
+						Nodes:             map[string]bool{"pandora": true},
+						MED:               pointer.Uint32Ptr(10),
+						NodeOverrides: map[string]config.BGPNodeOverride{
+							"iris": {MED: pointer.Uint32Ptr(100)},
+						},
+					},
+				},
+			},
+		}},
+	}
+	l := log.NewNopLogger()
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+
+	eps := epslices.EpsOrSlices{
+		SlicesVal: []discovery.EndpointSlice{
+			{
+				Endpoints: []discovery.Endpoint{
+					{
+						Addresses: []string{
+							"2.3.4.5",
+						},
+						NodeName: stringPtr("iris"),
+						Conditions: discovery.EndpointConditions{
+							Ready: pointer.BoolPtr(true),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Slices,
+	}
+
+	tests := []struct {
+		desc        string
+		annotations map[string]string
+		wantAds     map[string][]*bgp.Advertisement
+	}{
+		{
+			desc: "Node override",
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:             ipnet("10.20.30.1/32"),
+						ASPathPrependASN:   65010,
+						ASPathPrependCount: 2,
+						MED:                pointer.Uint32Ptr(100),
+					},
+					{
+						Prefix: ipnet("10.20.30.0/24"),
+						MED:    pointer.Uint32Ptr(10),
+					},
+				},
+			},
+		},
+		{
+			desc: "Service overrides the as path prepend",
+			annotations: map[string]string{
+				config.BGPASPathPrependAnnotation: "3",
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:             ipnet("10.20.30.1/32"),
+						ASPathPrependCount: 3,
+						MED:                pointer.Uint32Ptr(100),
+					},
+					{
+						Prefix: ipnet("10.20.30.0/24"),
+						MED:    pointer.Uint32Ptr(10),
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "allowed",
+				Annotations: test.annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: "Cluster",
+			},
+			Status: statusAssigned("10.20.30.1"),
+		}
+		if c.SetBalancer(l, "test1", svc, eps) == controllers.SyncStateError {
+			t.Errorf("%q: SetBalancer failed", test.desc)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestNodeSelectors(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	pools := map[string]*config.Pool{
+		"default": {
+			CIDR: []*net.IPNet{ipnet("1.2.3.0/24")},
+			BGPAdvertisements: []*config.BGPAdvertisement{
+				{
+					AggregationLength: 32,
+					Nodes:             map[string]bool{"pandora": true},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		config  *config.Config
+		node    *v1.Node
+		wantAds map[string][]*bgp.Advertisement
+	}{
+		{
+			desc:    "No config, no advertisements",
+			wantAds: map[string][]*bgp.Advertisement{},
+		},
+
+		{
+			desc: "One peer, default node selector, no node labels",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+
+		{
+			desc: "Second peer, non-matching node selector",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Addr: net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{
+							mustSelector("foo=bar"),
+						},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+
+		{
+			desc: "Add node label that matches",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
+
+		{
+			desc: "Change node label so it no longer matches",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"foo": "baz",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+			},
+		},
+
+		{
+			desc: "Change node selector so it matches again",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Addr: net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{
+							mustSelector("foo in (bar, baz)"),
+						},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
+
+		{
+			desc: "Change node label back, still matches",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
+
+		{
+			desc: "Multiple node selectors, only one matches",
+			config: &config.Config{
+				Peers: map[string]*config.Peer{
+					"peer1": {
+						Addr:          net.ParseIP("1.2.3.4"),
+						NodeSelectors: []labels.Selector{labels.Everything()},
+					},
+					"peer2": {
+						Addr: net.ParseIP("2.3.4.5"),
+						NodeSelectors: []labels.Selector{
+							mustSelector("host=frontend"),
+							mustSelector("foo in (bar, baz)"),
+						},
+					},
+				},
+				Pools: &config.Pools{ByName: pools},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
+
+		{
+			desc: "Change node labels to match the other selector",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"host": "frontend",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": nil,
+				"2.3.4.5:0": nil,
+			},
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		if test.config != nil {
+			if c.SetConfig(l, test.config) == controllers.SyncStateError {
+				t.Errorf("%q: SetConfig failed", test.desc)
+			}
+		}
+
+		if test.node != nil {
+			if c.SetNode(l, test.node) == controllers.SyncStateError {
+				t.Errorf("%q: SetNode failed", test.desc)
+			}
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestPeerAuthenticationChange(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	key1 := config.TCPAOKey{SendID: 1, RecvID: 1, Algorithm: "hmac(sha256)", Key: "key1"}
+	key2 := config.TCPAOKey{SendID: 2, RecvID: 2, Algorithm: "hmac(sha256)", Key: "key2"}
+	cfgFor := func(peer config.Peer) *config.Config {
+		peer.Addr = net.ParseIP("1.2.3.4")
+		peer.NodeSelectors = []labels.Selector{labels.Everything()}
+		return &config.Config{
+			Peers: map[string]*config.Peer{"peer1": &peer},
+			Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+		}
+	}
+
+	tests := []struct {
+		desc             string
+		peer             config.Peer
+		wantAOKeys       []config.TCPAOKey
+		wantPassword     string
+		wantNextPassword string
+	}{
+		{
+			desc:       "initial keys",
+			peer:       config.Peer{AOKeys: []config.TCPAOKey{key1}},
+			wantAOKeys: []config.TCPAOKey{key1},
+		},
+		{
+			desc:       "new key added",
+			peer:       config.Peer{AOKeys: []config.TCPAOKey{key1, key2}},
+			wantAOKeys: []config.TCPAOKey{key1, key2},
+		},
+		{
+			desc:       "old key removed",
+			peer:       config.Peer{AOKeys: []config.TCPAOKey{key2}},
+			wantAOKeys: []config.TCPAOKey{key2},
+		},
+		{
+			desc:         "password",
+			peer:         config.Peer{Password: "old"},
+			wantPassword: "old",
+		},
+		{
+			desc:             "next password added",
+			peer:             config.Peer{Password: "old", NextPassword: "new"},
+			wantPassword:     "old",
+			wantNextPassword: "new",
+		},
+		{
+			desc:         "password rotated",
+			peer:         config.Peer{Password: "new"},
+			wantPassword: "new",
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		if state := c.SetConfig(l, cfgFor(test.peer)); state != controllers.SyncStateReprocessAll {
+			t.Fatalf("%q: SetConfig failed", test.desc)
+		}
+		if b.sessionManager.closes != 0 {
+			t.Errorf("%q: the session was closed", test.desc)
+		}
+		params, ok := b.sessionManager.gotParams["1.2.3.4:0"]
+		if !ok {
+			t.Fatalf("%q: no session", test.desc)
+		}
+		if diff := cmp.Diff(test.wantAOKeys, params.AOKeys); diff != "" {
+			t.Errorf("%q: unexpected TCP-AO keys (-want +got)\n%s", test.desc, diff)
+		}
+		if params.Password != test.wantPassword || params.NextPassword != test.wantNextPassword {
+			t.Errorf("%q: expected passwords %q and %q, got %q and %q", test.desc,
+				test.wantPassword, test.wantNextPassword, params.Password, params.NextPassword)
+		}
+	}
+}
+
+func TestDynamicPeers(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	defaultGateway = func(family ipfamily.Family) (net.IP, error) {
+		if family != ipfamily.IPv4 {
+			return nil, errors.New("no default gateway")
+		}
+		return net.ParseIP("10.0.0.1"), nil
+	}
+	defer func() { defaultGateway = netroute.DefaultGateway }()
+
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"label": {
+				Name: "label",
+				DynamicAddr: &config.DynamicPeerAddress{
+					Source: config.DynamicAddressNodeLabel,
+					Key:    "example.com/tor",
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"annotation": {
+				Name: "annotation",
+				DynamicAddr: &config.DynamicPeerAddress{
+					Source: config.DynamicAddressNodeAnnotation,
+					Key:    "example.com/tor-v6",
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"gateway": {
+				Name: "gateway",
+				DynamicAddr: &config.DynamicPeerAddress{
+					Source:   config.DynamicAddressDefaultGateway,
+					IPFamily: ipfamily.IPv4,
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"unnumbered": {
+				Name: "unnumbered",
+				DynamicAddr: &config.DynamicPeerAddress{
+					Source:    config.DynamicAddressInterface,
+					Interface: "eth1",
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+	}
+
+	tests := []struct {
+		desc    string
+		config  *config.Config
+		node    *v1.Node
+		wantAds map[string][]*bgp.Advertisement
+	}{
+		{
+			desc:   "Node without label and annotation",
+			config: cfg,
+			wantAds: map[string][]*bgp.Advertisement{
+				"10.0.0.1:0": nil,
+				"eth1":       nil,
+			},
+		},
+		{
+			desc: "Node with label and annotation",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/tor": "1.1.1.1",
+					},
+					Annotations: map[string]string{
+						"example.com/tor-v6": "fd00::1",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"10.0.0.1:0":  nil,
+				"eth1":        nil,
+				"1.1.1.1:0":   nil,
+				"[fd00::1]:0": nil,
+			},
+		},
+		{
+			desc: "Label changes",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/tor": "1.1.1.2",
+					},
+					Annotations: map[string]string{
+						"example.com/tor-v6": "fd00::1",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"10.0.0.1:0":  nil,
+				"eth1":        nil,
+				"1.1.1.2:0":   nil,
+				"[fd00::1]:0": nil,
+			},
+		},
+		{
+			desc: "Annotation is invalid",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/tor": "1.1.1.2",
+					},
+					Annotations: map[string]string{
+						"example.com/tor-v6": "foo",
+					},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"10.0.0.1:0": nil,
+				"eth1":       nil,
+				"1.1.1.2:0":  nil,
+			},
+		},
+	}
+
+	l := log.NewNopLogger()
+	for _, test := range tests {
+		if test.config != nil {
+			if state := c.SetConfig(l, test.config); state != controllers.SyncStateReprocessAll {
+				t.Errorf("%q: SetConfig failed", test.desc)
+			}
+		}
+		if test.node != nil {
+			if state := c.SetNode(l, test.node); state == controllers.SyncStateError {
+				t.Errorf("%q: SetNode failed", test.desc)
+			}
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.wantAds)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.wantAds, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestDefaultGatewayResolvedAgain(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	var gateway net.IP
+	defaultGateway = func(family ipfamily.Family) (net.IP, error) {
+		if gateway == nil {
+			return nil, errors.New("no default gateway")
+		}
+		return gateway, nil
+	}
+	defer func() { defaultGateway = netroute.DefaultGateway }()
+
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"gateway": {
+				Name: "gateway",
+				DynamicAddr: &config.DynamicPeerAddress{
+					Source:   config.DynamicAddressDefaultGateway,
+					IPFamily: ipfamily.IPv4,
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pandora",
+		},
+	}
+
+	l := log.NewNopLogger()
+	if state := c.SetConfig(l, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+	// The other peers and protocols are still synced.
+	if state := c.SetNode(l, node); state == controllers.SyncStateError {
+		t.Errorf("SetNode failed with an unresolved default gateway")
+	}
+	if !c.protocolHandlers[config.BGP].(*bgpController).resolvesGateway.Load() {
+		t.Errorf("the node is not resynced while a peer is the default gateway")
+	}
+	if diff := cmp.Diff(map[string][]*bgp.Advertisement{}, b.sessionManager.Ads()); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+
+	// The same node is resynced once the gateway is there.
+	gateway = net.ParseIP("10.0.0.1")
+	if state := c.SetNode(l, node); state == controllers.SyncStateError {
+		t.Errorf("SetNode failed")
+	}
+	want := map[string][]*bgp.Advertisement{
+		"10.0.0.1:0": nil,
+	}
+	if diff := cmp.Diff(want, b.sessionManager.Ads()); diff != "" {
+		t.Errorf("unexpected advertisement state (-want +got)\n%s", diff)
+	}
+}
+
+func TestResyncGateway(t *testing.T) {
+	oldPeriod := gatewayResyncPeriod
+	gatewayResyncPeriod = 10 * time.Millisecond
+	defer func() { gatewayResyncPeriod = oldPeriod }()
+
+	c := &bgpController{}
+	resyncs := make(chan struct{}, 1)
+	resync := func() {
+		select {
+		case resyncs <- struct{}{}:
+		default:
+		}
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go c.resyncGateway(resync, stopCh)
+
+	select {
+	case <-resyncs:
+		t.Fatalf("node resynced while no peer is the default gateway")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.resolvesGateway.Store(true)
+	select {
+	case <-resyncs:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("node not resynced while a peer is the default gateway")
+	}
+}
+
+func TestPerNodeASNAndRouterID(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Name: "peer1",
+				Addr: net.ParseIP("1.2.3.4"),
+				ASN:  100,
+				MyASNFrom: &config.NodeValueRef{
+					Source: config.NodeValueLabel,
+					Key:    "example.com/asn",
+				},
+				RouterIDFrom: &config.NodeValueRef{
+					Source: config.NodeValueAnnotation,
+					Key:    "example.com/router-id",
+				},
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+	}
+
+	type sessionValues struct {
+		MyASN    uint32
+		RouterID string
+	}
+	tests := []struct {
+		desc string
+		node *v1.Node
+		want map[string]sessionValues
+	}{
+		{
+			desc: "Node without label and annotation",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+				},
+			},
+			want: map[string]sessionValues{},
+		},
+		{
+			desc: "Node with label, without annotation",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/asn": "65001",
+					},
+				},
+			},
+			want: map[string]sessionValues{},
+		},
+		{
+			desc: "Node with label and annotation",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/asn": "65001",
+					},
+					Annotations: map[string]string{
+						"example.com/router-id": "10.0.0.1",
+					},
+				},
+			},
+			want: map[string]sessionValues{
+				"1.2.3.4:0": {MyASN: 65001, RouterID: "10.0.0.1"},
+			},
+		},
+		{
+			desc: "ASN changes",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/asn": "65002",
+					},
+					Annotations: map[string]string{
+						"example.com/router-id": "10.0.0.1",
+					},
+				},
+			},
+			want: map[string]sessionValues{
+				"1.2.3.4:0": {MyASN: 65002, RouterID: "10.0.0.1"},
+			},
+		},
+		{
+			desc: "Router ID changes",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/asn": "65002",
+					},
+					Annotations: map[string]string{
+						"example.com/router-id": "10.0.0.2",
+					},
+				},
+			},
+			want: map[string]sessionValues{
+				"1.2.3.4:0": {MyASN: 65002, RouterID: "10.0.0.2"},
+			},
+		},
+		{
+			desc: "Invalid ASN",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"example.com/asn": "foo",
+					},
+					Annotations: map[string]string{
+						"example.com/router-id": "10.0.0.2",
+					},
+				},
+			},
+			want: map[string]sessionValues{},
+		},
+	}
+
+	l := log.NewNopLogger()
+	if state := c.SetConfig(l, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+	for _, test := range tests {
+		if state := c.SetNode(l, test.node); state == controllers.SyncStateError {
+			t.Errorf("%q: SetNode failed", test.desc)
+		}
+
+		b.sessionManager.Lock()
+		got := map[string]sessionValues{}
+		for addr, params := range b.sessionManager.gotParams {
+			got[addr] = sessionValues{MyASN: params.MyASN, RouterID: params.RouterID.String()}
+		}
+		b.sessionManager.Unlock()
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%q: unexpected sessions (-want +got)\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestFRRSnippets(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Name:          "peer1",
+				Addr:          net.ParseIP("1.2.3.4"),
+				MyASN:         100,
+				ASN:           200,
+				NodeSelectors: []labels.Selector{labels.Everything()},
+				RawFRRConfig:  "neighbor 1.2.3.4 description peer1",
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{}},
+		FRRSnippets: map[string]*config.FRRSnippet{
+			"all": {
+				Name:          "all",
+				Config:        "ip prefix-list all seq 5 permit any",
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+			"rack-a": {
+				Name:          "rack-a",
+				Config:        "ip prefix-list rack-a seq 5 permit any",
+				NodeSelectors: []labels.Selector{labels.SelectorFromSet(labels.Set{"rack": "a"})},
+			},
+		},
+	}
+
+	tests := []struct {
+		desc string
+		node *v1.Node
+		want map[string]string
+	}{
+		{
+			desc: "Node without labels",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+				},
+			},
+			want: map[string]string{
+				"all": "ip prefix-list all seq 5 permit any",
+			},
+		},
 		{
-			desc: "Change node label so it no longer matches",
+			desc: "Node in rack a",
 			node: &v1.Node{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "pandora",
 					Labels: map[string]string{
-						"foo": "baz",
+						"rack": "a",
 					},
 				},
 			},
-			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
+			want: map[string]string{
+				"all":    "ip prefix-list all seq 5 permit any",
+				"rack-a": "ip prefix-list rack-a seq 5 permit any",
+			},
+		},
+		{
+			desc: "Node moved to rack b",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+					Labels: map[string]string{
+						"rack": "b",
+					},
+				},
+			},
+			want: map[string]string{
+				"all": "ip prefix-list all seq 5 permit any",
 			},
 		},
+	}
+
+	l := log.NewNopLogger()
+	if state := c.SetConfig(l, cfg); state != controllers.SyncStateReprocessAll {
+		t.Fatalf("SetConfig failed")
+	}
+	for _, test := range tests {
+		if state := c.SetNode(l, test.node); state == controllers.SyncStateError {
+			t.Errorf("%q: SetNode failed", test.desc)
+		}
+
+		b.sessionManager.Lock()
+		got := b.sessionManager.gotSnippets
+		params := b.sessionManager.gotParams["1.2.3.4:0"]
+		b.sessionManager.Unlock()
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%q: unexpected snippets (-want +got)\n%s", test.desc, diff)
+		}
+		if params.RawFRRConfig != "neighbor 1.2.3.4 description peer1" {
+			t.Errorf("%q: expected the raw config of the peer in the session, got %q", test.desc, params.RawFRRConfig)
+		}
+	}
+}
+
+type fakeRouteReceiver []bgp.ReceivedRoutes
+
+func (f fakeRouteReceiver) ReceivedRoutes() []bgp.ReceivedRoutes {
+	return f
+}
 
+func TestReceivedRoutesHandler(t *testing.T) {
+	r := fakeRouteReceiver{
 		{
-			desc: "Change node selector so it matches again",
-			config: &config.Config{
-				Peers: map[string]*config.Peer{
-					"peer1": {
-						Addr:          net.ParseIP("1.2.3.4"),
-						NodeSelectors: []labels.Selector{labels.Everything()},
+			Peer: "10.0.0.1:179",
+			Routes: []bgp.Route{
+				{Prefix: ipnet("192.168.0.0/24"), NextHop: net.ParseIP("10.0.0.1")},
+			},
+		},
+		{
+			Peer:   "10.0.1.1:179",
+			VRF:    "red",
+			Routes: []bgp.Route{},
+		},
+	}
+	rec := httptest.NewRecorder()
+	receivedRoutesHandler(r).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/bgp/received", nil))
+
+	want := `[{"peer":"10.0.0.1:179","routes":[{"prefix":"192.168.0.0/24","nextHop":"10.0.0.1"}]},{"peer":"10.0.1.1:179","vrf":"red","routes":[]}]` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected body, want %s got %s", want, got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+}
+
+func TestBGPSpeakerServiceRanges(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("iris"),
+						},
 					},
-					"peer2": {
-						Addr: net.ParseIP("2.3.4.5"),
-						NodeSelectors: []labels.Selector{
-							mustSelector("foo in (bar, baz)"),
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	cfg := func(rangeNode string) *config.Config {
+		return &config.Config{
+			Peers: map[string]*config.Peer{
+				"peer1": {
+					Addr:          net.ParseIP("1.2.3.4"),
+					NodeSelectors: []labels.Selector{labels.Everything()},
+				},
+			},
+			Pools: &config.Pools{ByName: map[string]*config.Pool{
+				"cluster-ips": {
+					CIDR:                  []*net.IPNet{ipnet("10.96.0.0/12")},
+					ServiceClusterIPRange: true,
+					BGPAdvertisements: []*config.BGPAdvertisement{
+						{
+							AggregationLength: 32,
+							LocalPref:         100,
+							Nodes:             map[string]bool{rangeNode: true},
 						},
 					},
 				},
-				Pools: &config.Pools{ByName: pools},
+				"external": {
+					CIDR:                []*net.IPNet{ipnet("192.0.2.0/24")},
+					AnnounceExternalIPs: true,
+					ServiceAllocations: &config.ServiceAllocation{
+						Namespaces: sets.New("default"),
+					},
+					BGPAdvertisements: []*config.BGPAdvertisement{
+						{
+							AggregationLength: 32,
+							Nodes:             map[string]bool{"pandora": true},
+						},
+					},
+				},
+				"default": {
+					CIDR: []*net.IPNet{ipnet("198.51.100.0/24")},
+					BGPAdvertisements: []*config.BGPAdvertisement{
+						{
+							AggregationLength: 32,
+							Nodes:             map[string]bool{"pandora": true},
+						},
+					},
+				},
+			}},
+		}
+	}
+
+	tests := []struct {
+		desc string
+
+		balancer string
+		config   *config.Config
+		svc      *v1.Service
+
+		wantAds        map[string][]*bgp.Advertisement
+		expectedCfgRet controllers.SyncState
+		expectedLBRet  controllers.SyncState
+	}{
+		{
+			desc:   "Service cluster IP range announced",
+			config: cfg("pandora"),
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+				},
+			},
+			expectedCfgRet: controllers.SyncStateReprocessAll,
+		},
+		{
+			desc:     "External IP of a pool announcing them",
+			balancer: "test1",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+				Spec: v1.ServiceSpec{
+					Type:        "ClusterIP",
+					ExternalIPs: []string{"192.0.2.10"},
+				},
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
-				"2.3.4.5:0": nil,
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+					{
+						Prefix: ipnet("192.0.2.10/32"),
+					},
+				},
 			},
+			expectedLBRet: controllers.SyncStateSuccess,
 		},
-
 		{
-			desc: "Change node label back, still matches",
-			node: &v1.Node{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "pandora",
-					Labels: map[string]string{
-						"foo": "bar",
+			desc:     "External IP of a pool not announcing them",
+			balancer: "test2",
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:        "NodePort",
+					ExternalIPs: []string{"198.51.100.10"},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
 					},
+					{
+						Prefix: ipnet("192.0.2.10/32"),
+					},
+				},
+			},
+			expectedLBRet: controllers.SyncStateSuccess,
+		},
+		{
+			desc:     "External IP removed",
+			balancer: "test1",
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type: "ClusterIP",
 				},
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
-				"2.3.4.5:0": nil,
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+				},
 			},
+			expectedLBRet: controllers.SyncStateSuccess,
 		},
-
 		{
-			desc: "Multiple node selectors, only one matches",
-			config: &config.Config{
-				Peers: map[string]*config.Peer{
-					"peer1": {
-						Addr:          net.ParseIP("1.2.3.4"),
-						NodeSelectors: []labels.Selector{labels.Everything()},
+			desc:     "External IP of a namespace not allowed by the pool",
+			balancer: "test3",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "other"},
+				Spec: v1.ServiceSpec{
+					Type:        "ClusterIP",
+					ExternalIPs: []string{"192.0.2.11"},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
 					},
-					"peer2": {
-						Addr: net.ParseIP("2.3.4.5"),
-						NodeSelectors: []labels.Selector{
-							mustSelector("host=frontend"),
-							mustSelector("foo in (bar, baz)"),
-						},
+				},
+			},
+			expectedLBRet: controllers.SyncStateSuccess,
+		},
+		{
+			desc:     "LoadBalancer IP of the pool announcing external IPs",
+			balancer: "lb",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+				Spec: v1.ServiceSpec{
+					Type:                  "LoadBalancer",
+					ExternalTrafficPolicy: "Cluster",
+				},
+				Status: statusAssigned("192.0.2.20"),
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+					{
+						Prefix: ipnet("192.0.2.20/32"),
 					},
 				},
-				Pools: &config.Pools{ByName: pools},
+			},
+			expectedLBRet: controllers.SyncStateSuccess,
+		},
+		{
+			desc:     "External IP allocated to a LoadBalancer service",
+			balancer: "test4",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+				Spec: v1.ServiceSpec{
+					Type:        "ClusterIP",
+					ExternalIPs: []string{"192.0.2.20"},
+				},
 			},
 			wantAds: map[string][]*bgp.Advertisement{
-				"1.2.3.4:0": nil,
-				"2.3.4.5:0": nil,
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+					{
+						Prefix: ipnet("192.0.2.20/32"),
+					},
+				},
 			},
+			expectedLBRet: controllers.SyncStateSuccess,
 		},
-
 		{
-			desc: "Change node labels to match the other selector",
-			node: &v1.Node{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "pandora",
-					Labels: map[string]string{
-						"host": "frontend",
+			desc:     "LoadBalancer service releasing the external IP deleted",
+			balancer: "lb",
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+				},
+			},
+			expectedLBRet: controllers.SyncStateReprocessAll,
+		},
+		{
+			desc:     "External IP no longer allocated to a LoadBalancer service",
+			balancer: "test4",
+			svc: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+				Spec: v1.ServiceSpec{
+					Type:        "ClusterIP",
+					ExternalIPs: []string{"192.0.2.20"},
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
+					},
+					{
+						Prefix: ipnet("192.0.2.20/32"),
+					},
+				},
+			},
+			expectedLBRet: controllers.SyncStateSuccess,
+		},
+		{
+			desc:     "Second external IP removed",
+			balancer: "test4",
+			svc: &v1.Service{
+				Spec: v1.ServiceSpec{
+					Type: "ClusterIP",
+				},
+			},
+			wantAds: map[string][]*bgp.Advertisement{
+				"1.2.3.4:0": {
+					{
+						Prefix:    ipnet("10.96.0.0/12"),
+						LocalPref: 100,
 					},
 				},
 			},
+			expectedLBRet: controllers.SyncStateSuccess,
+		},
+		{
+			desc:   "Service cluster IP range announced by another node",
+			config: cfg("iris"),
 			wantAds: map[string][]*bgp.Advertisement{
 				"1.2.3.4:0": nil,
-				"2.3.4.5:0": nil,
 			},
+			expectedCfgRet: controllers.SyncStateReprocessAll,
 		},
 	}
 
 	l := log.NewNopLogger()
 	for _, test := range tests {
 		if test.config != nil {
-			if c.SetConfig(l, test.config) == controllers.SyncStateError {
+			if c.SetConfig(l, test.config) != test.expectedCfgRet {
 				t.Errorf("%q: SetConfig failed", test.desc)
 			}
 		}
-
-		if test.node != nil {
-			if c.SetNode(l, test.node) == controllers.SyncStateError {
-				t.Errorf("%q: SetNode failed", test.desc)
+		if test.balancer != "" {
+			if c.SetBalancer(l, test.balancer, test.svc, eps) != test.expectedLBRet {
+				t.Errorf("%q: SetBalancer failed", test.desc)
 			}
 		}
 
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/pointer"
+)
+
+// claimClient offers methods to report events about an IPAddressClaim.
+type claimClient interface {
+	ClaimInfof(claim *v1beta1.IPAddressClaim, desc, msg string, args ...interface{})
+	ClaimErrorf(claim *v1beta1.IPAddressClaim, desc, msg string, args ...interface{})
+}
+
+// claimEvents reports the events about the announcement of a claim on
+// the claim, rather than on the service standing for it.
+type claimEvents struct {
+	client claimClient
+	claim  *v1beta1.IPAddressClaim
+}
+
+func (e claimEvents) UpdateStatus(*v1.Service) error {
+	return nil
+}
+
+func (e claimEvents) Infof(_ *v1.Service, desc, msg string, args ...interface{}) {
+	e.client.ClaimInfof(e.claim, desc, msg, args...)
+}
+
+func (e claimEvents) Errorf(_ *v1.Service, desc, msg string, args ...interface{}) {
+	e.client.ClaimErrorf(e.claim, desc, msg, args...)
+}
+
+// SetClaim announces the addresses allocated to the claim, when it asks
+// for it, as the LoadBalancer IPs of a service with endpoints on the
+// nodes selected by the claim.
+func (c *controller) SetClaim(l log.Logger, name string, claim *v1beta1.IPAddressClaim) controllers.SyncState {
+	if claim == nil {
+		delete(c.claims, name)
+		return c.deleteBalancer(l, name, "claimDeleted")
+	}
+
+	if claim.Spec.Announce == nil {
+		delete(c.claims, name)
+		return c.deleteBalancer(l, name, "claimNotAnnounced")
+	}
+	c.claims[name] = true
+
+	level.Debug(l).Log("event", "startUpdate", "msg", "start of claim update")
+	defer level.Debug(l).Log("event", "endUpdate", "msg", "end of claim update")
+
+	if c.config == nil {
+		level.Debug(l).Log("event", "noConfig", "msg", "not processing, still waiting for config")
+		return controllers.SyncStateSuccess
+	}
+
+	if len(claim.Status.Addresses) == 0 {
+		return c.deleteBalancer(l, name, "noIPAllocated")
+	}
+
+	ips := []net.IP{}
+	for _, s := range claim.Status.Addresses {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			level.Error(l).Log("op", "setClaim", "error", fmt.Sprintf("invalid address %q", s), "msg", "invalid IP allocated by controller")
+			return c.deleteBalancer(l, name, "invalidIP")
+		}
+		ips = append(ips, ip)
+	}
+
+	l = log.With(l, "ips", ips)
+
+	poolName := poolFor(c.config.Pools, ips)
+	if poolName == "" {
+		level.Error(l).Log("op", "setClaim", "error", "assigned IP not allowed by config", "msg", "IP allocated by controller not allowed by config")
+		return c.deleteBalancer(l, name, "ipNotAllowed")
+	}
+
+	selectors := []labels.Selector{}
+	for _, s := range claim.Spec.Announce.NodeSelectors {
+		s := s // so we can use &s
+		selector, err := metav1.LabelSelectorAsSelector(&s)
+		if err != nil {
+			level.Error(l).Log("op", "setClaim", "error", err, "msg", "invalid node selector")
+			return c.deleteBalancer(l, name, "invalidNodeSelector")
+		}
+		selectors = append(selectors, selector)
+	}
+
+	svc, eps := claimService(claim, ips, selectors, c.nodes)
+	return c.announce(l, name, ips, poolName, svc, eps, claimEvents{client: c.claimClient, claim: claim})
+}
+
+// claimService returns the service standing for the claim: its
+// addresses are announced only from the nodes it selects, as for a
+// service with the local traffic policy.
+func claimService(claim *v1beta1.IPAddressClaim, ips []net.IP, selectors []labels.Selector, nodes map[string]*v1.Node) (*v1.Service, epslices.EpsOrSlices) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: claim.Namespace,
+			Name:      claim.Name,
+		},
+		Spec: v1.ServiceSpec{
+			Type:                  v1.ServiceTypeLoadBalancer,
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+	}
+	for _, ip := range ips {
+		svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: ip.String()})
+	}
+
+	names := []string{}
+	for name, node := range nodes {
+		if len(selectors) > 0 && !nodeMatchesSelectors(node, selectors) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	subset := v1.EndpointSubset{}
+	for _, name := range names {
+		subset.Addresses = append(subset.Addresses, v1.EndpointAddress{
+			// Only tells the endpoints apart.
+			IP:       name,
+			NodeName: pointer.StrPtr(name),
+		})
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{subset},
+		},
+		Type: epslices.Eps,
+	}
+	return svc, eps
+}
+
+func nodeMatchesSelectors(node *v1.Node, selectors []labels.Selector) bool {
+	for _, s := range selectors {
+		if s.Matches(labels.Set(node.Labels)) {
+			return true
+		}
+	}
+	return false
+}
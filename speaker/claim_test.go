@@ -0,0 +1,184 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/api/v1beta1"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func (s *testK8S) ClaimInfof(_ *v1beta1.IPAddressClaim, evtType string, msg string, args ...interface{}) {
+	s.t.Logf("k8s Info event %q: %s", evtType, fmt.Sprintf(msg, args...))
+}
+
+func (s *testK8S) ClaimErrorf(_ *v1beta1.IPAddressClaim, evtType string, msg string, args ...interface{}) {
+	s.t.Logf("k8s Warning event %q: %s", evtType, fmt.Sprintf(msg, args...))
+	s.loggedWarning = true
+}
+
+func TestClaimSpeaker(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	k := &testK8S{t: t}
+	c.client = k
+	c.claimClient = k
+	l := log.NewNopLogger()
+
+	cfg := &config.Config{
+		Peers: map[string]*config.Peer{
+			"peer1": {
+				Addr:          net.ParseIP("1.2.3.4"),
+				NodeSelectors: []labels.Selector{labels.Everything()},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24")},
+				BGPAdvertisements: []*config.BGPAdvertisement{
+					{
+						AggregationLength: 32,
+						Nodes:             map[string]bool{"pandora": true},
+					},
+				},
+			},
+		}},
+	}
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+	c.SetNode(l, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pandora",
+			Labels: map[string]string{"vms": "true"},
+		},
+	})
+
+	claim := func(announce bool, addresses ...string) *v1beta1.IPAddressClaim {
+		res := &v1beta1.IPAddressClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "vms", Name: "vm1"},
+			Status: v1beta1.IPAddressClaimStatus{
+				Addresses: addresses,
+			},
+		}
+		if announce {
+			res.Spec.Announce = &v1beta1.IPAddressClaimAnnouncement{
+				NodeSelectors: []metav1.LabelSelector{
+					{MatchLabels: map[string]string{"vms": "true"}},
+				},
+			}
+		}
+		return res
+	}
+	announced := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": {
+			{
+				Prefix: ipnet("10.20.30.1/32"),
+			},
+		},
+	}
+	withdrawn := map[string][]*bgp.Advertisement{
+		"1.2.3.4:0": nil,
+	}
+
+	tests := []struct {
+		desc    string
+		claim   *v1beta1.IPAddressClaim
+		node    *v1.Node
+		want    map[string][]*bgp.Advertisement
+		wantRes controllers.SyncState
+	}{
+		{
+			desc:  "Claim not announced",
+			claim: claim(false, "10.20.30.1"),
+			want:  withdrawn,
+		},
+		{
+			desc:  "Claim without addresses",
+			claim: claim(true),
+			want:  withdrawn,
+		},
+		{
+			desc:  "Claim announced from the node",
+			claim: claim(true, "10.20.30.1"),
+			want:  announced,
+		},
+		{
+			desc: "Node not selected anymore",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "pandora",
+				},
+			},
+			want:    announced,
+			wantRes: controllers.SyncStateReprocessAll,
+		},
+		{
+			desc:  "Claim reprocessed",
+			claim: claim(true, "10.20.30.1"),
+			want:  withdrawn,
+		},
+		{
+			desc: "Node selected again",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "pandora",
+					Labels: map[string]string{"vms": "true"},
+				},
+			},
+			want:    withdrawn,
+			wantRes: controllers.SyncStateReprocessAll,
+		},
+		{
+			desc:  "Claim announced again",
+			claim: claim(true, "10.20.30.1"),
+			want:  announced,
+		},
+	}
+
+	for _, test := range tests {
+		var res controllers.SyncState
+		if test.node != nil {
+			res = c.SetNode(l, test.node)
+		} else {
+			res = c.SetClaim(l, "IPAddressClaim/vms/vm1", test.claim)
+		}
+		if res != test.wantRes {
+			t.Errorf("%q: unexpected result %v, expected %v", test.desc, res, test.wantRes)
+		}
+
+		gotAds := b.sessionManager.Ads()
+		sortAds(test.want)
+		sortAds(gotAds)
+		if diff := cmp.Diff(test.want, gotAds); diff != "" {
+			t.Errorf("%q: unexpected advertisement state (-want +got)\n%s", test.desc, diff)
+		}
+	}
+
+	if c.SetClaim(l, "IPAddressClaim/vms/vm1", nil) != controllers.SyncStateSuccess {
+		t.Errorf("deleting the claim failed")
+	}
+	if len(c.claims) != 0 {
+		t.Errorf("deleted claim still tracked")
+	}
+}
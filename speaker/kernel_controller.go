@@ -0,0 +1,355 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/netroute"
+	"golang.org/x/sys/unix"
+	v1 "k8s.io/api/core/v1"
+)
+
+// The kernel is changed through these, to be replaced by the tests.
+var (
+	addRoute       = netroute.Add
+	deleteRoute    = netroute.Delete
+	addAddress     = netroute.AddAddress
+	deleteAddress  = netroute.DeleteAddress
+	listRoutes     = netroute.List
+	listAddresses  = netroute.ListAddresses
+	interfaceIndex = func(name string) (int, error) {
+		intf, err := net.InterfaceByName(name)
+		if err != nil {
+			return 0, err
+		}
+		return intf.Index, nil
+	}
+	interfaceName = func(index int) (string, error) {
+		intf, err := net.InterfaceByIndex(index)
+		if err != nil {
+			return "", err
+		}
+		return intf.Name, nil
+	}
+)
+
+// kernelController programs the LoadBalancer IPs the node announces
+// into its kernel, as routes or addresses, for a routing daemon running
+// outside of MetalLB to redistribute them.
+type kernelController struct {
+	myNode string
+	// The routes and addresses programmed for each service.
+	svcEntries map[string][]kernelEntry
+	// The number of services each route or address is programmed for,
+	// as services can share IPs.
+	refs map[kernelEntry]int
+	// The protocols the routes and addresses are tagged with, in this
+	// configuration or the previous ones.
+	protocols map[uint8]bool
+}
+
+// kernelEntry is a route or an address programmed into the kernel.
+type kernelEntry struct {
+	prefix   string
+	address  bool
+	intf     string
+	table    uint32
+	protocol uint8
+	metric   uint32
+}
+
+func (e kernelEntry) String() string {
+	if e.address {
+		return fmt.Sprintf("address %s dev %s", e.prefix, e.intf)
+	}
+	dev := "blackhole"
+	if e.intf != "" {
+		dev = "dev " + e.intf
+	}
+	return fmt.Sprintf("route %s %s table %d proto %d metric %d", e.prefix, dev, e.table, e.protocol, e.metric)
+}
+
+func (c *kernelController) SetConfig(_ log.Logger, cfg *config.Config) error {
+	if cfg.Pools == nil {
+		return nil
+	}
+	for _, pool := range cfg.Pools.ByName {
+		for _, adv := range pool.KernelRouteAdvertisements {
+			c.protocols[adv.Protocol] = true
+		}
+	}
+	return nil
+}
+
+func (c *kernelController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices, nodes map[string]*v1.Node) string {
+	if !poolMatchesNodeKernel(pool, c.myNode) {
+		level.Debug(l).Log("event", "skipping should announce kernel", "service", name, "reason", "pool not matching my node")
+		return "notOwner"
+	}
+	return routesShouldAnnounce(l, "kernel", name, c.myNode, svc, eps, nodes)
+}
+
+func (c *kernelController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, _ service, _ *v1.Service, _ epslices.EpsOrSlices) error {
+	var want []kernelEntry
+	for _, lbIP := range lbIPs {
+		m := net.CIDRMask(32, 32)
+		if lbIP.To4() == nil {
+			m = net.CIDRMask(128, 128)
+		}
+		prefix := (&net.IPNet{IP: lbIP, Mask: m}).String()
+		for _, adCfg := range pool.KernelRouteAdvertisements {
+			// skipping if this node is not enabled for this advertisement
+			if !adCfg.Nodes[c.myNode] {
+				continue
+			}
+			want = append(want, kernelEntry{
+				prefix:   prefix,
+				address:  adCfg.Address,
+				intf:     adCfg.Interface,
+				table:    adCfg.Table,
+				protocol: adCfg.Protocol,
+				metric:   adCfg.Metric,
+			})
+		}
+	}
+	return c.sync(l, name, want)
+}
+
+func (c *kernelController) DeleteBalancer(l log.Logger, name, reason string) error {
+	return c.sync(l, name, nil)
+}
+
+func (c *kernelController) SetNode(log.Logger, *v1.Node) error {
+	return nil
+}
+
+// sync programs the given routes and addresses for the service, and
+// removes the ones it doesn't need anymore.
+func (c *kernelController) sync(l log.Logger, name string, want []kernelEntry) error {
+	current := map[kernelEntry]bool{}
+	for _, e := range c.svcEntries[name] {
+		current[e] = true
+	}
+	wanted := map[kernelEntry]bool{}
+	for _, e := range want {
+		wanted[e] = true
+	}
+
+	errs := 0
+	for e := range wanted {
+		if current[e] {
+			continue
+		}
+		if err := c.ref(e); err != nil {
+			level.Error(l).Log("op", "setBalancer", "entry", e, "error", err, "msg", "failed to program the kernel")
+			errs++
+			continue
+		}
+		current[e] = true
+	}
+	for e := range current {
+		if wanted[e] {
+			continue
+		}
+		if err := c.unref(e); err != nil {
+			level.Error(l).Log("op", "setBalancer", "entry", e, "error", err, "msg", "failed to clean the kernel")
+			errs++
+			continue
+		}
+		delete(current, e)
+	}
+
+	entries := make([]kernelEntry, 0, len(current))
+	for e := range current {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].String() < entries[j].String()
+	})
+	if len(entries) == 0 {
+		delete(c.svcEntries, name)
+	} else {
+		c.svcEntries[name] = entries
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("failed to program %d routes or addresses", errs)
+	}
+	return nil
+}
+
+// removeStale removes the routes and addresses tagged with the protocols
+// of the configuration that no service needs, such as the ones left behind
+// by a previous run. It is called once all the services are processed.
+func (c *kernelController) removeStale(l log.Logger) {
+	for protocol := range c.protocols {
+		routes, err := listRoutes(protocol)
+		if err != nil {
+			level.Error(l).Log("op", "removeStale", "protocol", protocol, "error", err, "msg", "failed to list the routes")
+		}
+		for _, r := range routes {
+			e := kernelEntry{
+				prefix:   r.Dst.String(),
+				table:    r.Table,
+				protocol: r.Protocol,
+				metric:   r.Priority,
+			}
+			if r.LinkIndex != 0 {
+				e.intf, err = interfaceName(r.LinkIndex)
+				if err != nil {
+					level.Error(l).Log("op", "removeStale", "route", e, "error", err, "msg", "failed to find the interface of the route")
+					continue
+				}
+			}
+			if c.wanted(e) {
+				continue
+			}
+			level.Info(l).Log("op", "removeStale", "route", e, "msg", "removing stale route")
+			if err := deleteRoute(r); err != nil && !errors.Is(err, unix.ESRCH) {
+				level.Error(l).Log("op", "removeStale", "route", e, "error", err, "msg", "failed to remove the stale route")
+			}
+		}
+
+		addresses, err := listAddresses(protocol)
+		if err != nil {
+			level.Error(l).Log("op", "removeStale", "protocol", protocol, "error", err, "msg", "failed to list the addresses")
+		}
+		for _, a := range addresses {
+			e := kernelEntry{
+				prefix:   a.Addr.String(),
+				address:  true,
+				protocol: protocol,
+			}
+			e.intf, err = interfaceName(a.LinkIndex)
+			if err != nil {
+				level.Error(l).Log("op", "removeStale", "address", e, "error", err, "msg", "failed to find the interface of the address")
+				continue
+			}
+			if c.wanted(e) {
+				continue
+			}
+			level.Info(l).Log("op", "removeStale", "address", e, "msg", "removing stale address")
+			if err := deleteAddress(a.LinkIndex, a.Addr); err != nil && !errors.Is(err, unix.EADDRNOTAVAIL) {
+				level.Error(l).Log("op", "removeStale", "address", e, "error", err, "msg", "failed to remove the stale address")
+			}
+		}
+	}
+}
+
+// wanted tells if a service needs the entry read from the kernel.
+func (c *kernelController) wanted(e kernelEntry) bool {
+	if c.refs[e] > 0 {
+		return true
+	}
+	// The kernel gives the IPv6 routes without a metric the default one.
+	if !e.address && e.metric == ipv6DefaultMetric && strings.Contains(e.prefix, ":") {
+		e.metric = 0
+		return c.refs[e] > 0
+	}
+	return false
+}
+
+const ipv6DefaultMetric = 1024
+
+// ref programs the entry if no other service did already.
+func (c *kernelController) ref(e kernelEntry) error {
+	if c.refs[e] == 0 {
+		err := program(e)
+		// Left behind by a previous run, or configured by hand.
+		if errors.Is(err, unix.EEXIST) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	c.refs[e]++
+	return nil
+}
+
+// unref removes the entry if no other service needs it.
+func (c *kernelController) unref(e kernelEntry) error {
+	if c.refs[e] == 1 {
+		err := unprogram(e)
+		if errors.Is(err, unix.ESRCH) || errors.Is(err, unix.EADDRNOTAVAIL) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	c.refs[e]--
+	if c.refs[e] <= 0 {
+		delete(c.refs, e)
+	}
+	return nil
+}
+
+func program(e kernelEntry) error {
+	index, dst, err := resolveEntry(e)
+	if err != nil {
+		return err
+	}
+	if e.address {
+		return addAddress(index, dst, e.protocol)
+	}
+	return addRoute(kernelRoute(e, index, dst))
+}
+
+func unprogram(e kernelEntry) error {
+	index, dst, err := resolveEntry(e)
+	if err != nil && e.intf != "" {
+		// The routes and addresses went away with the interface.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if e.address {
+		return deleteAddress(index, dst)
+	}
+	return deleteRoute(kernelRoute(e, index, dst))
+}
+
+func resolveEntry(e kernelEntry) (int, *net.IPNet, error) {
+	_, dst, err := net.ParseCIDR(e.prefix)
+	if err != nil {
+		return 0, nil, err
+	}
+	if e.intf == "" {
+		return 0, dst, nil
+	}
+	index, err := interfaceIndex(e.intf)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to find interface %s: %w", e.intf, err)
+	}
+	return index, dst, nil
+}
+
+func kernelRoute(e kernelEntry, index int, dst *net.IPNet) netroute.Route {
+	return netroute.Route{
+		Dst:       dst,
+		LinkIndex: index,
+		Table:     e.table,
+		Protocol:  e.protocol,
+		Priority:  e.metric,
+	}
+}
+
+func poolMatchesNodeKernel(pool *config.Pool, node string) bool {
+	for _, adv := range pool.KernelRouteAdvertisements {
+		if adv.Nodes[node] {
+			return true
+		}
+	}
+	return false
+}
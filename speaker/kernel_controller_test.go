@@ -0,0 +1,409 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/netroute"
+	"go.universe.tf/metallb/internal/pointer"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fakeKernel records the routes and addresses programmed by the
+// kernel controller.
+type fakeKernel struct {
+	interfaces map[string]int
+	programmed map[string]bool
+	routes     map[string]netroute.Route
+	addresses  map[string]fakeAddress
+}
+
+type fakeAddress struct {
+	netroute.Address
+	protocol uint8
+}
+
+func routeEntry(r netroute.Route) string {
+	return fmt.Sprintf("route %s dev %d table %d proto %d metric %d", r.Dst, r.LinkIndex, r.Table, r.Protocol, r.Priority)
+}
+
+func addressEntry(index int, addr *net.IPNet) string {
+	return fmt.Sprintf("address %s dev %d", addr, index)
+}
+
+func (k *fakeKernel) install() {
+	addRoute = func(r netroute.Route) error {
+		k.routes[routeEntry(r)] = r
+		return k.add(routeEntry(r))
+	}
+	deleteRoute = func(r netroute.Route) error {
+		delete(k.routes, routeEntry(r))
+		return k.delete(routeEntry(r))
+	}
+	addAddress = func(index int, addr *net.IPNet, protocol uint8) error {
+		k.addresses[addressEntry(index, addr)] = fakeAddress{netroute.Address{LinkIndex: index, Addr: addr}, protocol}
+		return k.add(addressEntry(index, addr))
+	}
+	deleteAddress = func(index int, addr *net.IPNet) error {
+		delete(k.addresses, addressEntry(index, addr))
+		return k.delete(addressEntry(index, addr))
+	}
+	listRoutes = func(protocol uint8) ([]netroute.Route, error) {
+		res := []netroute.Route{}
+		for _, r := range k.routes {
+			if r.Protocol != protocol {
+				continue
+			}
+			// As the kernel does.
+			if r.Dst.IP.To4() == nil && r.Priority == 0 {
+				r.Priority = 1024
+			}
+			res = append(res, r)
+		}
+		return res, nil
+	}
+	listAddresses = func(protocol uint8) ([]netroute.Address, error) {
+		res := []netroute.Address{}
+		for _, a := range k.addresses {
+			if a.protocol == protocol {
+				res = append(res, a.Address)
+			}
+		}
+		return res, nil
+	}
+	interfaceIndex = func(name string) (int, error) {
+		index, ok := k.interfaces[name]
+		if !ok {
+			return 0, fmt.Errorf("no such network interface")
+		}
+		return index, nil
+	}
+	interfaceName = func(index int) (string, error) {
+		for name, i := range k.interfaces {
+			if i == index {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("no such network interface")
+	}
+}
+
+func (k *fakeKernel) add(entry string) error {
+	if k.programmed[entry] {
+		return fmt.Errorf("%s programmed twice", entry)
+	}
+	k.programmed[entry] = true
+	return nil
+}
+
+func (k *fakeKernel) delete(entry string) error {
+	if !k.programmed[entry] {
+		return fmt.Errorf("%s removed but not programmed", entry)
+	}
+	delete(k.programmed, entry)
+	return nil
+}
+
+func newFakeKernel() *fakeKernel {
+	k := &fakeKernel{
+		interfaces: map[string]int{"dummy0": 42},
+		programmed: map[string]bool{},
+		routes:     map[string]netroute.Route{},
+		addresses:  map[string]fakeAddress{},
+	}
+	k.install()
+	return k
+}
+
+func (k *fakeKernel) entries() []string {
+	res := []string{}
+	for e := range k.programmed {
+		res = append(res, e)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestKernelSpeaker(t *testing.T) {
+	k := newFakeKernel()
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:             "pandora",
+		DisableLayer2:      true,
+		bgpType:            bgpNative,
+		EnableKernelRoutes: true,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+	l := log.NewNopLogger()
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24"), ipnet("2001:db8::/64")},
+				KernelRouteAdvertisements: []*config.KernelRouteAdvertisement{
+					{
+						Name:     "routes",
+						Nodes:    map[string]bool{"pandora": true},
+						Table:    100,
+						Protocol: 196,
+						Metric:   10,
+					},
+					{
+						Name:      "addresses",
+						Nodes:     map[string]bool{"pandora": true},
+						Address:   true,
+						Interface: "dummy0",
+						Protocol:  196,
+					},
+				},
+			},
+			"other": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				KernelRouteAdvertisements: []*config.KernelRouteAdvertisement{
+					{
+						Name:      "missing",
+						Nodes:     map[string]bool{"pandora": true},
+						Interface: "dummy1",
+						Table:     254,
+						Protocol:  196,
+					},
+				},
+			},
+		}},
+	}
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("iris"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	svc := func(policy v1.ServiceExternalTrafficPolicyType, ips ...string) *v1.Service {
+		res := &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: policy,
+			},
+		}
+		for _, ip := range ips {
+			res.Status.LoadBalancer.Ingress = append(res.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: ip})
+		}
+		return res
+	}
+
+	if c.SetBalancer(l, "test1", svc("Cluster", "10.20.30.1", "2001:db8::1"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	want := []string{
+		"address 10.20.30.1/32 dev 42",
+		"address 2001:db8::1/128 dev 42",
+		"route 10.20.30.1/32 dev 0 table 100 proto 196 metric 10",
+		"route 2001:db8::1/128 dev 0 table 100 proto 196 metric 10",
+	}
+	if diff := cmp.Diff(want, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+
+	// A second service sharing the IP.
+	if c.SetBalancer(l, "test2", svc("Cluster", "10.20.30.1"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	// Local traffic policy, no endpoint on this node.
+	if c.SetBalancer(l, "test3", svc("Local", "10.20.30.3"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	if diff := cmp.Diff(want, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+
+	// The routes of the shared IP stay until both services are gone.
+	if c.SetBalancer(l, "test1", nil, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	want = []string{
+		"address 10.20.30.1/32 dev 42",
+		"route 10.20.30.1/32 dev 0 table 100 proto 196 metric 10",
+	}
+	if diff := cmp.Diff(want, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+	if c.SetBalancer(l, "test2", nil, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	if diff := cmp.Diff([]string{}, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+
+	// The interface doesn't exist yet, the service is retried.
+	if c.SetBalancer(l, "test4", svc("Cluster", "10.20.40.1"), eps) != controllers.SyncStateError {
+		t.Fatalf("expected SetBalancer to fail with a missing interface")
+	}
+	k.interfaces["dummy1"] = 43
+	if c.SetBalancer(l, "test4", svc("Cluster", "10.20.40.1"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	want = []string{
+		"route 10.20.40.1/32 dev 43 table 254 proto 196 metric 0",
+	}
+	if diff := cmp.Diff(want, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+}
+
+func TestKernelRemoveStale(t *testing.T) {
+	k := newFakeKernel()
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:             "pandora",
+		DisableLayer2:      true,
+		bgpType:            bgpNative,
+		EnableKernelRoutes: true,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+	l := log.NewNopLogger()
+
+	cfg := &config.Config{
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24"), ipnet("2001:db8::/64")},
+				KernelRouteAdvertisements: []*config.KernelRouteAdvertisement{
+					{
+						Name:     "routes",
+						Nodes:    map[string]bool{"pandora": true},
+						Table:    254,
+						Protocol: 196,
+					},
+					{
+						Name:      "addresses",
+						Nodes:     map[string]bool{"pandora": true},
+						Address:   true,
+						Interface: "dummy0",
+						Protocol:  196,
+					},
+				},
+			},
+		}},
+	}
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+
+	// Left behind by a previous run, or not programmed by MetalLB.
+	stale := []netroute.Route{
+		{Dst: ipnet("10.20.30.9/32"), Table: 254, Protocol: 196},
+		{Dst: ipnet("2001:db8::9/128"), Table: 100, Protocol: 196, Priority: 10},
+	}
+	for _, r := range stale {
+		if err := addRoute(r); err != nil {
+			t.Fatalf("adding route: %s", err)
+		}
+	}
+	if err := addRoute(netroute.Route{Dst: ipnet("10.20.30.10/32"), Table: 254, Protocol: 4}); err != nil {
+		t.Fatalf("adding route: %s", err)
+	}
+	if err := addAddress(42, ipnet("10.20.30.9/32"), 196); err != nil {
+		t.Fatalf("adding address: %s", err)
+	}
+	if err := addAddress(42, ipnet("10.20.30.11/32"), 0); err != nil {
+		t.Fatalf("adding address: %s", err)
+	}
+
+	svc := &v1.Service{
+		Spec: v1.ServiceSpec{
+			Type:                  "LoadBalancer",
+			ExternalTrafficPolicy: "Cluster",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "10.20.30.1"}, {IP: "2001:db8::1"}},
+			},
+		},
+	}
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("iris"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	if c.SetBalancer(l, "test1", svc, eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+
+	c.ServicesSynced(l)
+	want := []string{
+		"address 10.20.30.1/32 dev 42",
+		"address 10.20.30.11/32 dev 42",
+		"address 2001:db8::1/128 dev 42",
+		"route 10.20.30.1/32 dev 0 table 254 proto 196 metric 0",
+		"route 10.20.30.10/32 dev 0 table 254 proto 4 metric 0",
+		"route 2001:db8::1/128 dev 0 table 254 proto 196 metric 0",
+	}
+	if diff := cmp.Diff(want, k.entries()); diff != "" {
+		t.Errorf("unexpected kernel state (-want +got)\n%s", diff)
+	}
+}
+
+func TestKernelRoutesDisabled(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	newBGP = b.NewSessionManager
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpNative,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	if _, ok := c.protocolHandlers[config.Kernel]; ok {
+		t.Fatalf("expected no kernel handler when the kernel routes are disabled")
+	}
+	for _, p := range c.protocols {
+		if p == config.Kernel {
+			t.Fatalf("expected no kernel protocol when the kernel routes are disabled")
+		}
+	}
+}
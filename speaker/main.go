@@ -18,6 +18,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -36,9 +37,11 @@ import (
 	k8snodes "go.universe.tf/metallb/internal/k8s/nodes"
 	"go.universe.tf/metallb/internal/layer2"
 	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/ospf"
 	"go.universe.tf/metallb/internal/speakerlist"
 	"go.universe.tf/metallb/internal/version"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/yaml"
 )
 
@@ -81,6 +84,7 @@ func main() {
 		disableEpSlices   = flag.Bool("disable-epslices", false, "Disable the usage of EndpointSlices and default to Endpoints instead of relying on the autodiscovery mechanism")
 		enablePprof       = flag.Bool("enable-pprof", false, "Enable pprof profiling")
 		loadBalancerClass = flag.String("lb-class", "", "load balancer class. When enabled, metallb will handle only services whose spec.loadBalancerClass matches the given lb class")
+		enableKernel      = flag.Bool("enable-kernel-routes", false, "Enable programming the LoadBalancer IPs into the kernel of the node, as KernelRouteAdvertisements say")
 	)
 	flag.Parse()
 
@@ -155,12 +159,18 @@ func main() {
 		SList:                  sList,
 		bgpType:                bgpImplementation(bgpType),
 		InterfaceExcludeRegexp: interfacesToExclude,
+		EnableKernelRoutes:     *enableKernel,
 	})
 	if err != nil {
 		level.Error(logger).Log("op", "startup", "error", err, "msg", "failed to create MetalLB controller")
 		os.Exit(1)
 	}
 
+	debugHandlers := map[string]http.Handler{}
+	if r, ok := ctrl.protocolHandlers[config.BGP].(*bgpController).sessionManager.(bgp.RouteReceiver); ok {
+		debugHandlers["/debug/bgp/received"] = receivedRoutesHandler(r)
+	}
+
 	var validateConfig config.Validate
 	if bgpType == "native" {
 		validateConfig = config.DiscardFRROnly
@@ -179,11 +189,14 @@ func main() {
 		EnablePprof:   *enablePprof,
 		ReadEndpoints: true,
 		Namespace:     *namespace,
+		DebugHandlers: debugHandlers,
 
 		Listener: k8s.Listener{
 			ServiceChanged: ctrl.SetBalancer,
 			ConfigChanged:  ctrl.SetConfig,
 			NodeChanged:    ctrl.SetNode,
+			ClaimChanged:   ctrl.SetClaim,
+			ServicesSynced: ctrl.ServicesSynced,
 		},
 		ValidateConfig:    validateConfig,
 		LoadBalancerClass: *loadBalancerClass,
@@ -193,6 +206,22 @@ func main() {
 		os.Exit(1)
 	}
 	ctrl.client = client
+	ctrl.claimClient = client
+
+	if r, ok := ctrl.protocolHandlers[config.BGP].(*bgpController).sessionManager.(bgp.ReloadReporter); ok {
+		r.ReportReloads(func(err error) {
+			if err != nil {
+				client.NodeErrorf(*myNode, "FRRReloadFailed", "failed to reload the FRR configuration: %s", err)
+				return
+			}
+			client.NodeInfof(*myNode, "FRRReloadRecovered", "the FRR configuration was reloaded after failures")
+		})
+		r.ReportSnippets(func(snippet string, err error) {
+			client.NodeErrorf(*myNode, "FRRSnippetRejected", "ignoring the invalid FRR configuration snippet of %s: %s", snippet, err)
+		})
+	}
+
+	go ctrl.protocolHandlers[config.BGP].(*bgpController).resyncGateway(client.ResyncNode, stopCh)
 
 	sList.Start(client)
 	defer sList.Stop()
@@ -208,12 +237,20 @@ type controller struct {
 	nodes   map[string]*v1.Node
 	bgpType bgpImplementation
 
-	config *config.Config
-	client service
+	config      *config.Config
+	client      service
+	claimClient claimClient
 
 	protocolHandlers map[config.Proto]Protocol
 	announced        map[config.Proto]map[string]bool // for each protocol, says if we are advertising the given service
 	svcIPs           map[string][]net.IP              // service name -> assigned IPs
+	claims           map[string]bool                  // the claims to announce
+
+	// The LoadBalancer IPs and the externalIPs falling into a pool
+	// announcing them, by service name. The externalIPs allocated as
+	// LoadBalancer IPs are not announced.
+	lbIPs       map[string][]net.IP
+	externalIPs map[string][]net.IP
 
 	protocols []config.Proto
 }
@@ -232,20 +269,49 @@ type controllerConfig struct {
 	SupportedProtocols           []config.Proto
 	AnnouncedInterfacesToExclude []string `yaml:"announcedInterfacesToExclude"`
 	InterfaceExcludeRegexp       *regexp.Regexp
+
+	// EnableKernelRoutes enables programming the IPs into the kernel
+	// of the node, as KernelRouteAdvertisements say.
+	EnableKernelRoutes bool
 }
 
 func newController(cfg controllerConfig) (*controller, error) {
+	sessionManager := newBGP(cfg.bgpType, cfg.Logger, cfg.LogLevel)
 	handlers := map[config.Proto]Protocol{
 		config.BGP: &bgpController{
 			logger:         cfg.Logger,
 			myNode:         cfg.MyNode,
 			svcAds:         make(map[string][]*bgp.Advertisement),
+			prefixAds:      prefixAds{},
+			conflicts:      map[string][]adConflict{},
 			bgpType:        cfg.bgpType,
-			sessionManager: newBGP(cfg.bgpType, cfg.Logger, cfg.LogLevel),
+			sessionManager: sessionManager,
 		},
 	}
 	protocols := []config.Proto{config.BGP}
 
+	// OSPF is announced by the same routing daemon as BGP, when it
+	// supports it.
+	if announcer, ok := sessionManager.(ospf.Announcer); ok {
+		handlers[config.OSPF] = &ospfController{
+			logger:    cfg.Logger,
+			myNode:    cfg.MyNode,
+			announcer: announcer,
+			svcAds:    make(map[string][]*ospf.Advertisement),
+		}
+		protocols = append(protocols, config.OSPF)
+	}
+
+	if cfg.EnableKernelRoutes {
+		handlers[config.Kernel] = &kernelController{
+			myNode:     cfg.MyNode,
+			svcEntries: map[string][]kernelEntry{},
+			refs:       map[kernelEntry]int{},
+			protocols:  map[uint8]bool{},
+		}
+		protocols = append(protocols, config.Kernel)
+	}
+
 	if !cfg.DisableLayer2 {
 		a, err := layer2.New(cfg.Logger, cfg.InterfaceExcludeRegexp)
 		if err != nil {
@@ -265,10 +331,15 @@ func newController(cfg controllerConfig) (*controller, error) {
 		protocolHandlers: handlers,
 		announced:        map[config.Proto]map[string]bool{},
 		svcIPs:           map[string][]net.IP{},
+		claims:           map[string]bool{},
+		lbIPs:            map[string][]net.IP{},
+		externalIPs:      map[string][]net.IP{},
 		protocols:        protocols,
 	}
 	ret.announced[config.BGP] = map[string]bool{}
 	ret.announced[config.Layer2] = map[string]bool{}
+	ret.announced[config.OSPF] = map[string]bool{}
+	ret.announced[config.Kernel] = map[string]bool{}
 
 	ret.nodes = make(map[string]*v1.Node)
 
@@ -276,11 +347,23 @@ func newController(cfg controllerConfig) (*controller, error) {
 }
 
 func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
+	reprocess := c.setLoadBalancerIPs(name, svc)
+	delete(c.externalIPs, name)
+	res := c.setBalancer(l, name, svc, eps)
+	if reprocess && res == controllers.SyncStateSuccess {
+		// The externalIPs of other services are now allowed or
+		// refused.
+		return controllers.SyncStateReprocessAll
+	}
+	return res
+}
+
+func (c *controller) setBalancer(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
 	if svc == nil {
 		return c.deleteBalancer(l, name, "serviceDeleted")
 	}
 
-	if svc.Spec.Type != "LoadBalancer" {
+	if svc.Spec.Type != "LoadBalancer" && len(svc.Spec.ExternalIPs) == 0 {
 		return c.deleteBalancer(l, name, "notLoadBalancer")
 	}
 
@@ -292,7 +375,10 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 		return controllers.SyncStateSuccess
 	}
 
-	if len(svc.Status.LoadBalancer.Ingress) == 0 {
+	if svc.Spec.Type != "LoadBalancer" || len(svc.Status.LoadBalancer.Ingress) == 0 {
+		if len(svc.Spec.ExternalIPs) > 0 {
+			return c.setExternalIPs(l, name, svc, eps)
+		}
 		return c.deleteBalancer(l, name, "noIPAllocated")
 	}
 
@@ -314,6 +400,53 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 		return c.deleteBalancer(l, name, "ipNotAllowed")
 	}
 
+	return c.announce(l, name, lbIPs, poolName, svc, eps, c.client)
+}
+
+// setExternalIPs announces the externalIPs of the service, if they belong
+// to a pool announcing them.
+func (c *controller) setExternalIPs(l log.Logger, name string, svc *v1.Service, eps epslices.EpsOrSlices) controllers.SyncState {
+	ips := []net.IP{}
+	for _, s := range svc.Spec.ExternalIPs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			level.Error(l).Log("op", "setBalancer", "error", fmt.Sprintf("invalid external IP %q", s), "msg", "invalid external IP")
+			return c.deleteBalancer(l, name, "invalidExternalIP")
+		}
+		ips = append(ips, ip)
+	}
+
+	l = log.With(l, "ips", ips)
+
+	// The external IPs are set by the users, and may be announced by
+	// other means than MetalLB.
+	poolName := poolFor(c.config.Pools, ips)
+	if poolName == "" || !c.config.Pools.ByName[poolName].AnnounceExternalIPs {
+		level.Debug(l).Log("op", "setBalancer", "msg", "external IPs not in a pool announcing them")
+		return c.deleteBalancer(l, name, "externalIPsNotAllowed")
+	}
+	c.externalIPs[name] = ips
+
+	if !c.config.Pools.ByName[poolName].AllowsService(svc) {
+		level.Error(l).Log("op", "setBalancer", "pool", poolName, "error", "service not allowed by the service allocation of the pool", "msg", "external IPs not allowed")
+		c.client.Errorf(svc, "ExternalIPsNotAllowed", "The service allocation of pool %q doesn't allow the service", poolName)
+		return c.deleteBalancer(l, name, "externalIPsNotAllowed")
+	}
+	for other, lbIPs := range c.lbIPs {
+		if other != name && overlapIPs(ips, lbIPs) {
+			level.Error(l).Log("op", "setBalancer", "service", other, "error", "external IPs allocated to a LoadBalancer service", "msg", "external IPs not allowed")
+			c.client.Errorf(svc, "ExternalIPsNotAllowed", "The external IPs are allocated to the LoadBalancer service %q", other)
+			return c.deleteBalancer(l, name, "externalIPsAllocated")
+		}
+	}
+
+	return c.announce(l, name, ips, poolName, svc, eps, c.client)
+}
+
+// announce announces the given IPs of the service, all belonging to the
+// given pool, with the protocols whose advertisements select the pool.
+// The events about the service are reported through the given client.
+func (c *controller) announce(l log.Logger, name string, lbIPs []net.IP, poolName string, svc *v1.Service, eps epslices.EpsOrSlices, client service) controllers.SyncState {
 	l = log.With(l, "pool", poolName)
 	if c.config.Pools == nil || c.config.Pools.ByName[poolName] == nil {
 		level.Error(l).Log("bug", "true", "msg", "internal error: allocated IP has no matching address pool")
@@ -328,7 +461,7 @@ func (c *controller) SetBalancer(l log.Logger, name string, svc *v1.Service, eps
 	}
 
 	for _, protocol := range c.protocols {
-		if st := c.handleService(l, name, lbIPs, svc, pool, eps, protocol); st == controllers.SyncStateError {
+		if st := c.handleService(l, name, lbIPs, svc, pool, eps, protocol, client); st == controllers.SyncStateError {
 			return st
 		}
 	}
@@ -341,7 +474,8 @@ func (c *controller) handleService(l log.Logger,
 	lbIPs []net.IP,
 	svc *v1.Service, pool *config.Pool,
 	eps epslices.EpsOrSlices,
-	protocol config.Proto) controllers.SyncState {
+	protocol config.Proto,
+	client service) controllers.SyncState {
 	l = log.With(l, "protocol", protocol)
 	handler := c.protocolHandlers[protocol]
 	if handler == nil {
@@ -353,7 +487,7 @@ func (c *controller) handleService(l log.Logger,
 		return c.deleteBalancerProtocol(l, protocol, name, deleteReason)
 	}
 
-	if err := handler.SetBalancer(l, name, lbIPs, pool, c.client, svc); err != nil {
+	if err := handler.SetBalancer(l, name, lbIPs, pool, client, svc, eps); err != nil {
 		level.Error(l).Log("op", "setBalancer", "error", err, "msg", "failed to announce service")
 		return controllers.SyncStateError
 	}
@@ -372,7 +506,7 @@ func (c *controller) handleService(l log.Logger,
 		}).Set(1)
 	}
 	level.Info(l).Log("event", "serviceAnnounced", "msg", "service has IP, announcing", "protocol", protocol)
-	c.client.Infof(svc, "nodeAssigned", "announcing from node %q with protocol %q", c.myNode, protocol)
+	client.Infof(svc, "nodeAssigned", "announcing from node %q with protocol %q", c.myNode, protocol)
 	return controllers.SyncStateSuccess
 }
 
@@ -442,6 +576,48 @@ func poolFor(pools *config.Pools, ips []net.IP) string {
 	return ""
 }
 
+// setLoadBalancerIPs records the LoadBalancer IPs of the service. It
+// tells if they changed, and the old or the new ones are externalIPs of
+// other services.
+func (c *controller) setLoadBalancerIPs(name string, svc *v1.Service) bool {
+	var ips []net.IP
+	if svc != nil && svc.Spec.Type == "LoadBalancer" {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ip := net.ParseIP(ingress.IP); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	old := c.lbIPs[name]
+	if compareIPs(old, ips) {
+		return false
+	}
+	if len(ips) == 0 {
+		delete(c.lbIPs, name)
+	} else {
+		c.lbIPs[name] = ips
+	}
+
+	for other, externalIPs := range c.externalIPs {
+		if other != name && (overlapIPs(old, externalIPs) || overlapIPs(ips, externalIPs)) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapIPs tells if the two lists have an IP in common.
+func overlapIPs(ips1, ips2 []net.IP) bool {
+	for _, ip1 := range ips1 {
+		for _, ip2 := range ips2 {
+			if ip1.Equal(ip2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func compareIPs(ips1, ips2 []net.IP) bool {
 	if len(ips1) != len(ips2) {
 		return false
@@ -513,6 +689,8 @@ func (c *controller) SetConfig(l log.Logger, cfg *config.Config) controllers.Syn
 
 func (c *controller) SetNode(l log.Logger, node *v1.Node) controllers.SyncState {
 	conditionChanged := isNetworkConditionChanged(node.Name, c.nodes, node)
+	// The claims select the nodes announcing them by labels.
+	labelsChanged := c.nodes[node.Name] == nil || !labels.Equals(labels.Set(c.nodes[node.Name].Labels), labels.Set(node.Labels))
 	c.nodes[node.Name] = node
 
 	for proto, handler := range c.protocolHandlers {
@@ -522,13 +700,21 @@ func (c *controller) SetNode(l log.Logger, node *v1.Node) controllers.SyncState
 		}
 	}
 
-	if conditionChanged {
+	if conditionChanged || (labelsChanged && len(c.claims) > 0) {
 		return controllers.SyncStateReprocessAll
 	}
 
 	return controllers.SyncStateSuccess
 }
 
+// ServicesSynced is called once all the services are processed, for the
+// protocols to remove what no service needs anymore.
+func (c *controller) ServicesSynced(l log.Logger) {
+	if k, ok := c.protocolHandlers[config.Kernel].(*kernelController); ok {
+		k.removeStale(l)
+	}
+}
+
 func isNetworkConditionChanged(nodeName string, oldNodes map[string]*v1.Node, newNode *v1.Node) bool {
 	return k8snodes.IsNetworkUnavailable(oldNodes[nodeName]) != k8snodes.IsNetworkUnavailable(newNode)
 }
@@ -537,7 +723,7 @@ func isNetworkConditionChanged(nodeName string, oldNodes map[string]*v1.Node, ne
 type Protocol interface {
 	SetConfig(log.Logger, *config.Config) error
 	ShouldAnnounce(log.Logger, string, []net.IP, *config.Pool, *v1.Service, epslices.EpsOrSlices, map[string]*v1.Node) string
-	SetBalancer(log.Logger, string, []net.IP, *config.Pool, service, *v1.Service) error
+	SetBalancer(log.Logger, string, []net.IP, *config.Pool, service, *v1.Service, epslices.EpsOrSlices) error
 	DeleteBalancer(log.Logger, string, string) error
 	SetNode(log.Logger, *v1.Node) error
 }
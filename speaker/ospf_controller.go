@@ -0,0 +1,190 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"net"
+	"reflect"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/ospf"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type ospfController struct {
+	logger    log.Logger
+	myNode    string
+	announcer ospf.Announcer
+	svcAds    map[string][]*ospf.Advertisement
+	// The areas configured on the cluster, and the ones currently
+	// running on this node.
+	areas       map[string]*config.OSPFArea
+	activeAreas []ospf.Area
+	nodeLabels  labels.Set
+}
+
+func (c *ospfController) SetConfig(l log.Logger, cfg *config.Config) error {
+	c.areas = cfg.OSPFAreas
+	return c.syncAreas(l)
+}
+
+func (c *ospfController) ShouldAnnounce(l log.Logger, name string, _ []net.IP, pool *config.Pool, svc *v1.Service, eps epslices.EpsOrSlices, nodes map[string]*v1.Node) string {
+	if !poolMatchesNodeOSPF(pool, c.myNode) {
+		level.Debug(l).Log("event", "skipping should announce ospf", "service", name, "reason", "pool not matching my node")
+		return "notOwner"
+	}
+	return routesShouldAnnounce(l, "ospf", name, c.myNode, svc, eps, nodes)
+}
+
+func (c *ospfController) SetBalancer(l log.Logger, name string, lbIPs []net.IP, pool *config.Pool, _ service, _ *v1.Service, _ epslices.EpsOrSlices) error {
+	var ads []*ospf.Advertisement
+	for _, lbIP := range lbIPs {
+		m := net.CIDRMask(32, 32)
+		if lbIP.To4() == nil {
+			m = net.CIDRMask(128, 128)
+		}
+		for _, adCfg := range pool.OSPFAdvertisements {
+			// skipping if this node is not enabled for this advertisement
+			if !adCfg.Nodes[c.myNode] {
+				continue
+			}
+			ads = append(ads, &ospf.Advertisement{
+				Prefix:     &net.IPNet{IP: lbIP, Mask: m},
+				Metric:     adCfg.Metric,
+				MetricType: adCfg.MetricType,
+			})
+		}
+	}
+	c.svcAds[name] = ads
+	level.Info(l).Log("event", "updatedAdvertisements", "numAds", len(ads), "msg", "making advertisements using OSPF")
+	return c.updateAds()
+}
+
+func (c *ospfController) DeleteBalancer(l log.Logger, name, reason string) error {
+	if _, ok := c.svcAds[name]; !ok {
+		return nil
+	}
+	delete(c.svcAds, name)
+	return c.updateAds()
+}
+
+func (c *ospfController) SetNode(l log.Logger, node *v1.Node) error {
+	if c.myNode != node.Name {
+		return nil
+	}
+	nodeLabels := node.Labels
+	if nodeLabels == nil {
+		nodeLabels = map[string]string{}
+	}
+	ns := labels.Set(nodeLabels)
+	if c.nodeLabels != nil && labels.Equals(c.nodeLabels, ns) {
+		// Node labels unchanged, no action required.
+		return nil
+	}
+	c.nodeLabels = ns
+	level.Info(l).Log("event", "nodeLabelsChanged", "msg", "Node labels changed, resyncing OSPF areas")
+	return c.syncAreas(l)
+}
+
+// syncAreas configures the areas whose node selectors match the
+// node, when they changed.
+func (c *ospfController) syncAreas(l log.Logger) error {
+	names := make([]string, 0, len(c.areas))
+	for name := range c.areas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	areas := []ospf.Area{}
+	ownedBy := map[string]string{} // interface -> area name
+	for _, name := range names {
+		a := c.areas[name]
+		if !c.nodeMatches(a.NodeSelectors) {
+			continue
+		}
+		area := ospf.Area{ID: a.Area}
+		for _, intf := range a.Interfaces {
+			if owner, ok := ownedBy[intf]; ok {
+				level.Warn(l).Log("op", "syncAreas", "interface", intf, "area", name, "owner", owner, "msg", "interface already part of another area, ignoring")
+				continue
+			}
+			ownedBy[intf] = name
+			area.Interfaces = append(area.Interfaces, intf)
+		}
+		if len(area.Interfaces) == 0 {
+			continue
+		}
+		areas = append(areas, area)
+	}
+
+	if reflect.DeepEqual(areas, c.activeAreas) {
+		return nil
+	}
+	if err := c.announcer.SyncOSPFAreas(areas); err != nil {
+		return err
+	}
+	c.activeAreas = areas
+	return nil
+}
+
+func (c *ospfController) nodeMatches(selectors []labels.Selector) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, ns := range selectors {
+		if ns.Matches(c.nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateAds announces all the advertisements of the services, each
+// prefix only once.
+func (c *ospfController) updateAds() error {
+	byPrefix := map[string]*ospf.Advertisement{}
+	for _, ads := range c.svcAds {
+		for _, ad := range ads {
+			// The same prefix may come from several advertisements,
+			// pick one regardless of the order of the services.
+			if old, ok := byPrefix[ad.Prefix.String()]; ok && !lessOSPFAd(ad, old) {
+				continue
+			}
+			byPrefix[ad.Prefix.String()] = ad
+		}
+	}
+	allAds := make([]*ospf.Advertisement, 0, len(byPrefix))
+	for _, ad := range byPrefix {
+		allAds = append(allAds, ad)
+	}
+	sort.Slice(allAds, func(i, j int) bool {
+		return allAds[i].Prefix.String() < allAds[j].Prefix.String()
+	})
+	return c.announcer.SetOSPFAdvertisements(allAds)
+}
+
+// lessOSPFAd orders the advertisements of the same prefix, preferring
+// type 1 routes and then the lowest metric.
+func lessOSPFAd(a, b *ospf.Advertisement) bool {
+	if a.MetricType != b.MetricType {
+		return a.MetricType < b.MetricType
+	}
+	if a.Metric == nil || b.Metric == nil {
+		return a.Metric != nil
+	}
+	return *a.Metric < *b.Metric
+}
+
+func poolMatchesNodeOSPF(pool *config.Pool, node string) bool {
+	for _, adv := range pool.OSPFAdvertisements {
+		if adv.Nodes[node] {
+			return true
+		}
+	}
+	return false
+}
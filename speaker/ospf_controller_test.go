@@ -0,0 +1,214 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/google/go-cmp/cmp"
+	"go.universe.tf/metallb/internal/bgp"
+	"go.universe.tf/metallb/internal/config"
+	"go.universe.tf/metallb/internal/k8s/controllers"
+	"go.universe.tf/metallb/internal/k8s/epslices"
+	"go.universe.tf/metallb/internal/logging"
+	"go.universe.tf/metallb/internal/ospf"
+	"go.universe.tf/metallb/internal/pointer"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fakeOSPFSessionManager is a BGP session manager that also
+// announces via OSPF, as the FRR one does.
+type fakeOSPFSessionManager struct {
+	bgp.SessionManager
+	areas []ospf.Area
+	ads   []*ospf.Advertisement
+}
+
+func (f *fakeOSPFSessionManager) SyncOSPFAreas(areas []ospf.Area) error {
+	f.areas = areas
+	return nil
+}
+
+func (f *fakeOSPFSessionManager) SetOSPFAdvertisements(advs []*ospf.Advertisement) error {
+	f.ads = advs
+	return nil
+}
+
+// adsStrings returns the advertisements in a comparable form.
+func (f *fakeOSPFSessionManager) adsStrings() []string {
+	res := []string{}
+	for _, ad := range f.ads {
+		s := fmt.Sprintf("%s type%d", ad.Prefix, ad.MetricType)
+		if ad.Metric != nil {
+			s += fmt.Sprintf(" metric %d", *ad.Metric)
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+func TestOSPFSpeaker(t *testing.T) {
+	b := &fakeBGP{
+		t: t,
+	}
+	sm := &fakeOSPFSessionManager{}
+	newBGP = func(impl bgpImplementation, l log.Logger, lvl logging.Level) bgp.SessionManager {
+		sm.SessionManager = b.NewSessionManager(impl, l, lvl)
+		return sm
+	}
+	c, err := newController(controllerConfig{
+		MyNode:        "pandora",
+		DisableLayer2: true,
+		bgpType:       bgpFrr,
+	})
+	if err != nil {
+		t.Fatalf("creating controller: %s", err)
+	}
+	c.client = &testK8S{t: t}
+	l := log.NewNopLogger()
+
+	c.SetNode(l, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pandora",
+			Labels: map[string]string{"ospf": "true"},
+		},
+	})
+
+	cfg := &config.Config{
+		OSPFAreas: map[string]*config.OSPFArea{
+			"backbone": {
+				Name:       "backbone",
+				Area:       "0.0.0.0",
+				Interfaces: []string{"eth0", "eth1"},
+			},
+			"other": {
+				Name:          "other",
+				Area:          "0.0.0.1",
+				Interfaces:    []string{"eth1", "eth2"},
+				NodeSelectors: []labels.Selector{mustSelector("ospf=true")},
+			},
+			"unselected": {
+				Name:          "unselected",
+				Area:          "0.0.0.2",
+				Interfaces:    []string{"eth3"},
+				NodeSelectors: []labels.Selector{mustSelector("ospf=false")},
+			},
+		},
+		Pools: &config.Pools{ByName: map[string]*config.Pool{
+			"default": {
+				CIDR: []*net.IPNet{ipnet("10.20.30.0/24"), ipnet("2001:db8::/64")},
+				OSPFAdvertisements: []*config.OSPFAdvertisement{
+					{
+						Name:       "type2",
+						Nodes:      map[string]bool{"pandora": true},
+						MetricType: 2,
+					},
+					{
+						Name:       "type1",
+						Nodes:      map[string]bool{"pandora": true},
+						Metric:     pointer.Uint32Ptr(20),
+						MetricType: 1,
+					},
+				},
+			},
+			"other": {
+				CIDR: []*net.IPNet{ipnet("10.20.40.0/24")},
+				OSPFAdvertisements: []*config.OSPFAdvertisement{
+					{
+						Name:       "iris",
+						Nodes:      map[string]bool{"iris": true},
+						MetricType: 2,
+					},
+				},
+			},
+		}},
+	}
+	if c.SetConfig(l, cfg) == controllers.SyncStateError {
+		t.Fatalf("SetConfig failed")
+	}
+
+	wantAreas := []ospf.Area{
+		{ID: "0.0.0.0", Interfaces: []string{"eth0", "eth1"}},
+		{ID: "0.0.0.1", Interfaces: []string{"eth2"}},
+	}
+	if diff := cmp.Diff(wantAreas, sm.areas); diff != "" {
+		t.Errorf("unexpected areas (-want +got)\n%s", diff)
+	}
+
+	eps := epslices.EpsOrSlices{
+		EpVal: &v1.Endpoints{
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP:       "2.3.4.5",
+							NodeName: pointer.StrPtr("iris"),
+						},
+					},
+				},
+			},
+		},
+		Type: epslices.Eps,
+	}
+	svc := func(policy v1.ServiceExternalTrafficPolicyType, ips ...string) *v1.Service {
+		res := &v1.Service{
+			Spec: v1.ServiceSpec{
+				Type:                  "LoadBalancer",
+				ExternalTrafficPolicy: policy,
+			},
+		}
+		for _, ip := range ips {
+			res.Status.LoadBalancer.Ingress = append(res.Status.LoadBalancer.Ingress, v1.LoadBalancerIngress{IP: ip})
+		}
+		return res
+	}
+
+	if c.SetBalancer(l, "test1", svc("Cluster", "10.20.30.1", "2001:db8::1"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	// The type 1 advertisement wins over the type 2 one.
+	wantAds := []string{
+		"10.20.30.1/32 type1 metric 20",
+		"2001:db8::1/128 type1 metric 20",
+	}
+	if diff := cmp.Diff(wantAds, sm.adsStrings()); diff != "" {
+		t.Errorf("unexpected advertisements (-want +got)\n%s", diff)
+	}
+
+	// Local traffic policy, no endpoint on this node.
+	if c.SetBalancer(l, "test2", svc("Local", "10.20.30.2"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	// The pool is not announced from this node.
+	if c.SetBalancer(l, "test3", svc("Cluster", "10.20.40.1"), eps) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	if diff := cmp.Diff(wantAds, sm.adsStrings()); diff != "" {
+		t.Errorf("unexpected advertisements (-want +got)\n%s", diff)
+	}
+
+	if c.SetBalancer(l, "test1", nil, epslices.EpsOrSlices{}) == controllers.SyncStateError {
+		t.Fatalf("SetBalancer failed")
+	}
+	if diff := cmp.Diff([]string{}, sm.adsStrings()); diff != "" {
+		t.Errorf("unexpected advertisements (-want +got)\n%s", diff)
+	}
+
+	// The node leaves the areas selecting it by label.
+	c.SetNode(l, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pandora",
+		},
+	})
+	wantAreas = []ospf.Area{
+		{ID: "0.0.0.0", Interfaces: []string{"eth0", "eth1"}},
+	}
+	if diff := cmp.Diff(wantAreas, sm.areas); diff != "" {
+		t.Errorf("unexpected areas (-want +got)\n%s", diff)
+	}
+}
@@ -24,10 +24,12 @@ func mockNewController(l2Handler *MockProtocol, bgpHandler *MockProtocol, t *tes
 			config.Layer2: l2Handler,
 			config.BGP:    bgpHandler,
 		},
-		announced: map[config.Proto]map[string]bool{},
-		svcIPs:    map[string][]net.IP{},
-		protocols: config.Protocols,
-		client:    &testK8S{t: t},
+		announced:   map[config.Proto]map[string]bool{},
+		svcIPs:      map[string][]net.IP{},
+		lbIPs:       map[string][]net.IP{},
+		externalIPs: map[string][]net.IP{},
+		protocols:   config.Protocols,
+		client:      &testK8S{t: t},
 	}
 	ret.announced[config.BGP] = map[string]bool{}
 	ret.announced[config.Layer2] = map[string]bool{}
@@ -179,7 +181,7 @@ func (m *MockProtocol) ShouldAnnounce(_ log.Logger, _ string, _ []net.IP, _ *con
 	return "no announce"
 }
 
-func (m *MockProtocol) SetBalancer(_ log.Logger, _ string, _ []net.IP, _ *config.Pool, _ service, _ *v1.Service) error {
+func (m *MockProtocol) SetBalancer(_ log.Logger, _ string, _ []net.IP, _ *config.Pool, _ service, _ *v1.Service, _ epslices.EpsOrSlices) error {
 	m.setBalancerCalled = true
 	return nil
 }